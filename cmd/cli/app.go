@@ -1,5 +1,12 @@
 package cli
 
+import "errors"
+
+var (
+	ErrMissingCommand  = errors.New("missing command")
+	ErrMissingArgument = errors.New("missing argument")
+)
+
 type App struct {
 	Name     string
 	Usage    string
@@ -21,6 +28,8 @@ func (a *App) Run(args []string) error {
 	if ctx.HasArgs() {
 		cmd := a.FindCommand(ctx.Args[0])
 		if cmd != nil {
+			ctx.Command = cmd
+			ctx.Args = ctx.Args[1:]
 			return cmd.Run(ctx)
 		}
 	}
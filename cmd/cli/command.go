@@ -1,11 +1,35 @@
 package cli
 
 type Command struct {
-	Name   string
-	Usage  string
-	Action ActionFunc
+	Name     string
+	Usage    string
+	Commands []*Command
+	Action   ActionFunc
 }
 
+// Run runs the matching subcommand if the first argument names one.
+// Otherwise the action of the command runs
 func (c *Command) Run(ctx *Context) error {
+	if ctx.HasArgs() {
+		if sub := c.FindCommand(ctx.Args[0]); sub != nil {
+			ctx.Command = sub
+			ctx.Args = ctx.Args[1:]
+			return sub.Run(ctx)
+		}
+	}
+
+	if c.Action == nil {
+		return ErrMissingCommand
+	}
+
 	return c.Action(ctx)
 }
+
+func (c *Command) FindCommand(name string) *Command {
+	for _, sub := range c.Commands {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
@@ -12,6 +12,9 @@ func Execute() error {
 	app := &cli.App{
 		Name:  "portal",
 		Usage: "portal runs a DNS server",
+		Commands: []*cli.Command{
+			zoneCommand,
+		},
 		Action: func(c *cli.Context) error {
 			cfg, err := config.Read(c.Args[0])
 			if err != nil {
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-void/portal/cmd/cli"
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/types/rr"
+	"github.com/go-void/portal/pkg/zone"
+)
+
+var ErrZoneCheckFailed = errors.New("zone check failed")
+
+// zoneCommand provides subcommands to work with master files. Both take the
+// path of the master file and an optional origin. Without an origin it is
+// derived from the file name, e.g. example.com.zone or db.example.com
+var zoneCommand = &cli.Command{
+	Name:  "zone",
+	Usage: "zone check|fmt <file> [origin]",
+	Commands: []*cli.Command{
+		{
+			Name:   "check",
+			Usage:  "check <file> [origin] checks the syntax and records of a master file",
+			Action: checkZone,
+		},
+		{
+			Name:   "fmt",
+			Usage:  "fmt <file> [origin] writes a master file in canonical format to stdout",
+			Action: formatZone,
+		},
+	},
+}
+
+func checkZone(c *cli.Context) error {
+	origin, records, err := parseZone(c)
+	if err != nil {
+		return err
+	}
+
+	problems := zone.Check(origin, records)
+	for _, problem := range problems {
+		fmt.Fprintln(os.Stderr, problem)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %d problems", ErrZoneCheckFailed, len(problems))
+	}
+
+	fmt.Printf("zone %s: %d records OK\n", origin, len(records))
+	return nil
+}
+
+func formatZone(c *cli.Context) error {
+	origin, records, err := parseZone(c)
+	if err != nil {
+		return err
+	}
+
+	return zone.Write(os.Stdout, origin, records)
+}
+
+// parseZone parses the master file passed as first argument. The origin is
+// the second argument. Otherwise it is derived from the file name, unless
+// the file contains a SOA record, which sets the origin
+func parseZone(c *cli.Context) (string, []rr.RR, error) {
+	if !c.HasArgs() {
+		return "", nil, cli.ErrMissingArgument
+	}
+
+	if len(c.Args) > 1 {
+		origin := labels.Rootify(c.Args[1])
+		records, err := zone.ParseFile(c.Args[0], origin)
+		return origin, records, err
+	}
+
+	origin := originFromFile(c.Args[0])
+	records, err := zone.ParseFile(c.Args[0], origin)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, record := range records {
+		if record.Header().Type == rr.TypeSOA {
+			origin = record.Header().Name
+			break
+		}
+	}
+
+	return origin, records, nil
+}
+
+// originFromFile derives the origin from the name of a master file
+func originFromFile(path string) string {
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, ".zone")
+	name = strings.TrimPrefix(name, "db.")
+
+	return labels.Rootify(name)
+}
@@ -0,0 +1,61 @@
+// Package acl provides access control lists based on IP prefixes
+package acl
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+)
+
+var ErrInvalidEntry = errors.New("acl: invalid entry")
+
+// ACL is a list of IP prefixes which are allowed access. An empty list
+// denies access for all addresses
+type ACL []netip.Prefix
+
+// Parse parses a list of IP addresses and prefixes in CIDR notation. The
+// keyword 'any' allows access for all addresses.
+// Example: ["192.0.2.1", "2001:db8::/32", "any"]
+func Parse(entries []string) (ACL, error) {
+	acl := make(ACL, 0, len(entries))
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+
+		if strings.EqualFold(entry, "any") {
+			acl = append(acl, netip.MustParsePrefix("0.0.0.0/0"), netip.MustParsePrefix("::/0"))
+			continue
+		}
+
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, ErrInvalidEntry
+			}
+			acl = append(acl, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, ErrInvalidEntry
+		}
+		acl = append(acl, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return acl, nil
+}
+
+// Allows returns if the address is contained in any prefix of the list.
+// IPv4-mapped IPv6 addresses are treated as IPv4 addresses
+func (a ACL) Allows(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	for _, prefix := range a {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,350 @@
+// Package authority answers queries for zones the server is authoritative
+// for. See https://datatracker.ietf.org/doc/html/rfc1034#section-4.3.2
+package authority
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-void/portal/pkg/dnssec"
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/store"
+	"github.com/go-void/portal/pkg/types/dns"
+	"github.com/go-void/portal/pkg/types/rcode"
+	"github.com/go-void/portal/pkg/types/rr"
+)
+
+var ErrNotAuthoritative = errors.New("authority: not authoritative")
+
+// maxChainLength limits how many CNAME records are followed while
+// answering a single query
+const maxChainLength = 8
+
+// Result describes the answer to a query for authoritative data
+type Result struct {
+	Answer     []rr.RR
+	Authority  []rr.RR
+	Additional []rr.RR
+
+	// RCode is NameError if the name doesn't exist in the zone
+	RCode rcode.Code
+
+	// Authoritative indicates if the AA bit should be set. This is
+	// false for referrals to child zones
+	Authoritative bool
+}
+
+// Authority answers queries with data of zones stored in a record store
+type Authority struct {
+	Store store.Store
+
+	// Signer adds DNSSEC records to answers from signed zones. Zones
+	// are not signed if it is nil
+	Signer *dnssec.Signer
+}
+
+func New(s store.Store, signer *dnssec.Signer) *Authority {
+	return &Authority{
+		Store:  s,
+		Signer: signer,
+	}
+}
+
+// Lookup answers the question with data of the closest enclosing zone. If
+// do is set, answers from signed zones include DNSSEC records. It returns
+// ErrNotAuthoritative if no zone encloses the queried name
+func (a *Authority) Lookup(question dns.Question, do bool) (Result, error) {
+	if _, ok := a.Store.FindZone(question.Name); !ok {
+		return Result{}, ErrNotAuthoritative
+	}
+
+	result := Result{
+		Authoritative: true,
+	}
+
+	a.lookup(&result, question.Name, question.Class, question.Type, 0, do)
+	a.addAdditionals(&result, question.Class)
+
+	return result, nil
+}
+
+// lookup looks up name in the closest enclosing zone and adds the records
+// to the result. The depth indicates how many CNAME records were followed
+func (a *Authority) lookup(result *Result, name string, class, t uint16, depth int, do bool) {
+	origin, ok := a.Store.FindZone(name)
+	if !ok {
+		return
+	}
+
+	// Names at or below a zone cut are answered with a referral. The DS
+	// RRset however is owned by the parent side of the cut.
+	// See https://datatracker.ietf.org/doc/html/rfc1034#section-4.3.2
+	if delegation := a.findDelegation(name, origin, class, t); delegation != nil {
+		// The CNAME records which lead here are already in the answer
+		// section. The client can follow the chain itself
+		if depth > 0 {
+			return
+		}
+
+		result.Authoritative = false
+		result.Authority = append(result.Authority, delegation...)
+
+		if a.isSigned(origin, do) {
+			a.addDelegationSigner(result, delegation[0].Header().Name, origin, class)
+		}
+		return
+	}
+
+	if a.Store.Has(name) {
+		a.answer(result, name, name, origin, class, t, depth, do)
+		return
+	}
+
+	// The name doesn't exist, but a wildcard at the closest encloser can
+	// synthesize records. See https://datatracker.ietf.org/doc/html/rfc4592#section-3.3
+	wildcard := "*." + a.closestEncloser(name, origin)
+	if wildcard == "*.." {
+		wildcard = "*."
+	}
+
+	if a.Store.Has(wildcard) {
+		a.answer(result, name, wildcard, origin, class, t, depth, do)
+		return
+	}
+
+	result.RCode = rcode.NameError
+	a.addNegative(result, origin, class, do)
+
+	if a.isSigned(origin, do) {
+		encloser := labels.Parent(wildcard)
+		result.Authority = appendUnique(result.Authority, a.Signer.DenyName(origin, name, encloser))
+	}
+}
+
+// answer adds the records of source to the answer section. Records of a
+// wildcard source are synthesized with the queried name as owner
+func (a *Authority) answer(result *Result, name, source, origin string, class, t uint16, depth int, do bool) {
+	signed := a.isSigned(origin, do)
+
+	// Answers synthesized from a wildcard need a proof that the queried
+	// name doesn't exist. See https://datatracker.ietf.org/doc/html/rfc4035#section-3.1.3.3
+	var denial []rr.RR
+	if signed && name != source {
+		denial = a.Signer.DenyWildcard(origin, name, labels.Parent(source))
+	}
+
+	records, err := a.Store.Get(source, class, t)
+	if err == nil && len(records) > 0 {
+		result.RCode = rcode.NoError
+		result.Answer = append(result.Answer, synthesize(records, name, source)...)
+
+		if signed {
+			result.Answer = append(result.Answer, synthesize(a.signatures(origin, records), name, source)...)
+			result.Authority = appendUnique(result.Authority, denial)
+		}
+		return
+	}
+
+	if t != rr.TypeCNAME {
+		records, err = a.Store.Get(source, class, rr.TypeCNAME)
+		if err == nil && len(records) > 0 {
+			result.RCode = rcode.NoError
+			result.Answer = append(result.Answer, synthesize(records, name, source)...)
+
+			if signed {
+				result.Answer = append(result.Answer, synthesize(a.signatures(origin, records), name, source)...)
+				result.Authority = appendUnique(result.Authority, denial)
+			}
+
+			if depth+1 < maxChainLength {
+				target := records[0].(*rr.CNAME).Target
+				a.lookup(result, target, class, t, depth+1, do)
+			}
+			return
+		}
+	}
+
+	// The name exists but has no data of the requested type (NODATA)
+	result.RCode = rcode.NoError
+	a.addNegative(result, origin, class, do)
+
+	if signed {
+		result.Authority = appendUnique(result.Authority, denial)
+		result.Authority = appendUnique(result.Authority, a.Signer.DenyType(origin, source))
+	}
+}
+
+// findDelegation returns the NS records of the topmost zone cut between
+// the origin (exclusive) and name (inclusive) or nil if there is none
+func (a *Authority) findDelegation(name, origin string, class, t uint16) []rr.RR {
+	var delegation []rr.RR
+
+	name = strings.ToLower(labels.Rootify(name))
+	for n := name; n != "" && n != origin && labels.IsSubdomain(n, origin); n = labels.Parent(n) {
+		if n == name && t == rr.TypeDS {
+			continue
+		}
+
+		records, err := a.Store.Get(n, class, rr.TypeNS)
+		if err == nil && len(records) > 0 {
+			delegation = records
+		}
+	}
+
+	return delegation
+}
+
+// closestEncloser returns the longest existing ancestor of name within the
+// zone. See https://datatracker.ietf.org/doc/html/rfc4592#section-3.3.1
+func (a *Authority) closestEncloser(name, origin string) string {
+	for n := labels.Parent(name); n != "" && labels.IsSubdomain(n, origin); n = labels.Parent(n) {
+		if a.Store.Has(n) {
+			return n
+		}
+	}
+	return origin
+}
+
+// addNegative adds the SOA record of the zone to the authority section.
+// The TTL is the minimum of the SOA TTL and the SOA MINIMUM field.
+// See https://datatracker.ietf.org/doc/html/rfc2308#section-3
+func (a *Authority) addNegative(result *Result, origin string, class uint16, do bool) {
+	records, err := a.Store.Get(origin, class, rr.TypeSOA)
+	if err != nil || len(records) == 0 {
+		return
+	}
+
+	ttl := records[0].Header().TTL
+	if minimum := records[0].(*rr.SOA).Minimum; minimum < ttl {
+		ttl = minimum
+	}
+
+	if a.isSigned(origin, do) {
+		records = append(records, a.signatures(origin, records)...)
+	}
+
+	for _, record := range records {
+		record = rr.Copy(record)
+		record.Header().TTL = ttl
+		result.Authority = append(result.Authority, record)
+	}
+}
+
+// addDelegationSigner adds the signed DS RRset of the zone cut to the
+// authority section of a referral. Without DS records, the NSEC or NSEC3
+// records proving their absence are added instead.
+// See https://datatracker.ietf.org/doc/html/rfc4035#section-3.1.4
+func (a *Authority) addDelegationSigner(result *Result, cut, origin string, class uint16) {
+	records, err := a.Store.Get(cut, class, rr.TypeDS)
+	if err == nil && len(records) > 0 {
+		result.Authority = append(result.Authority, records...)
+		result.Authority = append(result.Authority, a.signatures(origin, records)...)
+		return
+	}
+
+	result.Authority = appendUnique(result.Authority, a.Signer.DenyType(origin, cut))
+}
+
+// isSigned returns if answers from the zone with origin include DNSSEC
+// records
+func (a *Authority) isSigned(origin string, do bool) bool {
+	return do && a.Signer != nil && a.Signer.IsSigned(origin)
+}
+
+// signatures returns the RRSIG records of every RRset in records, which
+// all share the same owner name
+func (a *Authority) signatures(origin string, records []rr.RR) []rr.RR {
+	var (
+		types  []uint16
+		rrsets = make(map[uint16][]rr.RR)
+	)
+
+	for _, record := range records {
+		t := record.Header().Type
+		if _, ok := rrsets[t]; !ok {
+			types = append(types, t)
+		}
+		rrsets[t] = append(rrsets[t], record)
+	}
+
+	var signatures []rr.RR
+	for _, t := range types {
+		signatures = append(signatures, a.Signer.Sign(origin, rrsets[t])...)
+	}
+
+	return signatures
+}
+
+// addAdditionals adds A and AAAA records of names referenced by NS and MX
+// records to the additional section. For referrals this adds the glue
+// records. See https://datatracker.ietf.org/doc/html/rfc1035#section-3.3.9
+func (a *Authority) addAdditionals(result *Result, class uint16) {
+	seen := make(map[string]bool)
+
+	for _, record := range append(result.Answer, result.Authority...) {
+		var target string
+
+		switch record := record.(type) {
+		case *rr.NS:
+			target = record.NSDName
+		case *rr.MX:
+			target = record.Exchange
+		default:
+			continue
+		}
+
+		if seen[target] {
+			continue
+		}
+		seen[target] = true
+
+		if _, ok := a.Store.FindZone(target); !ok {
+			continue
+		}
+
+		for _, t := range []uint16{rr.TypeA, rr.TypeAAAA} {
+			records, err := a.Store.Get(target, class, t)
+			if err == nil {
+				result.Additional = append(result.Additional, records...)
+			}
+		}
+	}
+}
+
+// synthesize returns copies of the records with name as the owner if the
+// records are owned by a wildcard. Otherwise the records are returned as is
+func synthesize(records []rr.RR, name, source string) []rr.RR {
+	if name == source {
+		return records
+	}
+
+	synthesized := make([]rr.RR, 0, len(records))
+	for _, record := range records {
+		record = rr.Copy(record)
+		record.Header().Name = name
+		synthesized = append(synthesized, record)
+	}
+
+	return synthesized
+}
+
+// appendUnique appends the records to the section which are not already
+// part of it. Proofs of non-existence often share NSEC or NSEC3 records
+func appendUnique(section, records []rr.RR) []rr.RR {
+	for _, record := range records {
+		if !contains(section, record) {
+			section = append(section, record)
+		}
+	}
+	return section
+}
+
+func contains(records []rr.RR, record rr.RR) bool {
+	header := record.Header()
+	for _, r := range records {
+		if r.Header().Type == header.Type && strings.EqualFold(r.Header().Name, header.Name) && r.IsSame(record) {
+			return true
+		}
+	}
+	return false
+}
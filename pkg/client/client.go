@@ -3,6 +3,7 @@ package client
 
 import (
 	"errors"
+	"fmt"
 	"math"
 	"math/rand"
 	"net"
@@ -14,7 +15,11 @@ import (
 	"github.com/go-void/portal/pkg/dio"
 	"github.com/go-void/portal/pkg/logger"
 	"github.com/go-void/portal/pkg/packers"
+	"github.com/go-void/portal/pkg/tsig"
 	"github.com/go-void/portal/pkg/types/dns"
+	"github.com/go-void/portal/pkg/types/opcode"
+	"github.com/go-void/portal/pkg/types/rcode"
+	"github.com/go-void/portal/pkg/types/rr"
 
 	"golang.org/x/net/ipv4"
 	"golang.org/x/net/ipv6"
@@ -23,8 +28,17 @@ import (
 var (
 	ErrInvalidNetwork  = errors.New("invalid network")
 	ErrNoMatchHeaderID = errors.New("header ids don't match")
+	ErrTransferFailed  = errors.New("zone transfer failed")
+	ErrUpdateFailed    = errors.New("dynamic update failed")
 )
 
+// Envelope carries the records of a single message of a zone transfer or
+// the error which ended the transfer
+type Envelope struct {
+	Records []rr.RR
+	Err     error
+}
+
 // Client is the default DNS client to query and retrieve DNS messages
 type Client struct {
 	// network the client is using (default: udp)
@@ -125,12 +139,15 @@ func (c *Client) Query(name string, class, t uint16, addr netip.Addr) (*dns.Mess
 	switch c.network {
 	case "udp", "udp4", "udp6":
 		return c.QueryUDP(query, netip.AddrPortFrom(addr, 53))
+	case "tcp", "tcp4", "tcp6":
+		return c.QueryTCP(query, netip.AddrPortFrom(addr, 53))
 	}
 
 	return nil, ErrInvalidNetwork
 }
 
-// QueryUDP sends a DNS 'query' to a remote DNS server with 'ip' using UDP
+// QueryUDP sends a DNS 'query' to a remote DNS server with 'ip' using UDP. The
+// response to a signed query must be signed as well
 func (c *Client) QueryUDP(query *dns.Message, addrPort netip.AddrPort) (*dns.Message, error) {
 	// Establish UDP connection
 	conn, err := c.Dial(c.network, addrPort)
@@ -154,10 +171,11 @@ func (c *Client) QueryUDP(query *dns.Message, addrPort netip.AddrPort) (*dns.Mes
 	// Read answer of the remote DNS server
 	buf := make([]byte, constants.UDPMinMessageSize)
 	udpConn := conn.(*net.UDPConn)
-	_, _, err = c.reader.ReadUDP(udpConn, buf)
+	n, _, err := c.reader.ReadUDP(udpConn, buf)
 	if err != nil {
 		return nil, err
 	}
+	buf = buf[:n]
 
 	// Unpack header data
 	header, offset, err := c.unpacker.UnpackHeader(buf)
@@ -169,13 +187,20 @@ func (c *Client) QueryUDP(query *dns.Message, addrPort netip.AddrPort) (*dns.Mes
 		return nil, ErrNoMatchHeaderID
 	}
 
+	if query.TSIG != nil {
+		err = query.TSIG.Verify(buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Unpack remaining message data
 	return c.unpacker.Unpack(header, buf, offset)
 }
 
 // QueryTCP sends a DNS 'query' to target DNS server with 'ip' using TCP
 func (c *Client) QueryTCP(query *dns.Message, addrPort netip.AddrPort) (*dns.Message, error) {
-	conn, err := c.Dial(c.network, addrPort)
+	conn, err := c.Dial(tcpNetwork(c.network), addrPort)
 	if err != nil {
 		return nil, err
 	}
@@ -187,12 +212,216 @@ func (c *Client) QueryTCP(query *dns.Message, addrPort netip.AddrPort) (*dns.Mes
 	}
 
 	tcpConn := conn.(*net.TCPConn)
-	err = c.writer.WriteTCPClose(tcpConn, b)
+	err = c.writer.WriteTCP(tcpConn, b)
 	if err != nil {
 		return nil, err
 	}
 
-	return nil, nil
+	return c.readTCP(tcpConn, query.Header.ID, query.TSIG)
+}
+
+// Update sends a dynamic update of the zone with 'origin' to the remote DNS
+// server with 'addrPort'. The prerequisites and updates make up the
+// prerequisite and update sections of the request. If 'key' is not nil, the
+// request is signed with the key.
+// See https://datatracker.ietf.org/doc/html/rfc2136#section-2
+func (c *Client) Update(origin string, prerequisites, updates []rr.RR, key *tsig.Key, addrPort netip.AddrPort) (*dns.Message, error) {
+	header := dns.NewHeader(c.GetID())
+	header.OpCode = opcode.Update
+	header.RecursionDesired = false
+	request := dns.NewMessageWith(header)
+
+	request.AddQuestion(dns.Question{
+		Name:  origin,
+		Type:  rr.TypeSOA,
+		Class: rr.IN,
+	})
+	request.AddAnswers(prerequisites)
+
+	for _, update := range updates {
+		request.AddAuthority(update)
+	}
+
+	if key != nil {
+		request.TSIG = tsig.New(key)
+	}
+
+	var (
+		response *dns.Message
+		err      error
+	)
+
+	switch c.network {
+	case "udp", "udp4", "udp6":
+		response, err = c.QueryUDP(request, addrPort)
+	case "tcp", "tcp4", "tcp6":
+		response, err = c.QueryTCP(request, addrPort)
+	default:
+		return nil, ErrInvalidNetwork
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if response.Header.RCode != rcode.NoError {
+		return response, fmt.Errorf("%w: %d", ErrUpdateFailed, response.Header.RCode)
+	}
+
+	return response, nil
+}
+
+// Transfer requests a transfer of the zone with 'origin' from the remote DNS
+// server with 'addrPort' using TCP. If 'soa' is nil the request is an AXFR,
+// otherwise an IXFR starting at the version of the provided SOA record. If
+// 'key' is not nil, the request is signed with the key and the response must
+// be signed as well. The records of each response message are sent on the
+// returned channel, which is closed after the last message or the first
+// error. See https://datatracker.ietf.org/doc/html/rfc5936#section-2.2
+func (c *Client) Transfer(origin string, soa *rr.SOA, key *tsig.Key, addrPort netip.AddrPort) (<-chan Envelope, error) {
+	header := dns.NewHeader(c.GetID())
+	header.RecursionDesired = false
+	query := dns.NewMessageWith(header)
+
+	t := rr.TypeAXFR
+	if soa != nil {
+		t = rr.TypeIXFR
+		query.AddAuthority(soa)
+	}
+
+	query.AddQuestion(dns.Question{
+		Name:  origin,
+		Type:  t,
+		Class: rr.IN,
+	})
+
+	if key != nil {
+		query.TSIG = tsig.New(key)
+	}
+
+	conn, err := c.Dial(tcpNetwork(c.network), addrPort)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := c.packer.Pack(query)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	tcpConn := conn.(*net.TCPConn)
+	err = c.writer.WriteTCP(tcpConn, b)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	envelopes := make(chan Envelope)
+	go c.readTransfer(tcpConn, query, envelopes)
+
+	return envelopes, nil
+}
+
+// readTransfer reads response messages of a zone transfer until the final
+// SOA record is received and sends the records of each message on the
+// channel. An AXFR ends with the second occurrence of the SOA record. An
+// incremental IXFR response ends with the third occurrence, as the new SOA
+// record also ends the last set of added records.
+// See https://datatracker.ietf.org/doc/html/rfc1995#section-4
+func (c *Client) readTransfer(conn *net.TCPConn, query *dns.Message, envelopes chan<- Envelope) {
+	defer close(envelopes)
+	defer conn.Close()
+
+	var (
+		t      = query.Question[0].Type
+		serial uint32
+		count  int
+		total  int
+		end    = 2
+	)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+
+		message, err := c.readTCP(conn, query.Header.ID, query.TSIG)
+		if err != nil {
+			envelopes <- Envelope{Err: err}
+			return
+		}
+
+		if message.Header.RCode != rcode.NoError {
+			envelopes <- Envelope{Err: fmt.Errorf("%w: %d", ErrTransferFailed, message.Header.RCode)}
+			return
+		}
+
+		for _, record := range message.Answer {
+			total++
+
+			soa, ok := record.(*rr.SOA)
+			if !ok {
+				continue
+			}
+
+			switch {
+			case total == 1:
+				serial = soa.Serial
+			case total == 2 && t == rr.TypeIXFR:
+				end = 3
+			}
+
+			if soa.Serial == serial {
+				count++
+			}
+		}
+
+		done := count >= end || (t == rr.TypeIXFR && total == 1 && count == 1)
+
+		// The last message of a signed transfer must be signed
+		if done && query.TSIG != nil && query.TSIG.Pending() {
+			envelopes <- Envelope{Err: tsig.ErrUnsigned}
+			return
+		}
+
+		envelopes <- Envelope{Records: message.Answer}
+
+		// A single SOA record answers an IXFR if the client is up to date
+		if done {
+			return
+		}
+
+		if total == 0 {
+			envelopes <- Envelope{Err: ErrTransferFailed}
+			return
+		}
+	}
+}
+
+// readTCP reads and unpacks a single DNS message from the TCP conn. If the
+// transaction is not nil, the message gets verified
+func (c *Client) readTCP(conn *net.TCPConn, id uint16, t *tsig.Transaction) (*dns.Message, error) {
+	buf, err := c.reader.ReadTCP(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	header, offset, err := c.unpacker.UnpackHeader(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.ID != id {
+		return nil, ErrNoMatchHeaderID
+	}
+
+	if t != nil {
+		err = t.Verify(buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c.unpacker.Unpack(header, buf, offset)
 }
 
 // GetID returns the current header ID and generates a new one
@@ -203,3 +432,14 @@ func (c *Client) GetID() uint16 {
 	c.lock.Unlock()
 	return id
 }
+
+// tcpNetwork returns the TCP network matching the IP version of network
+func tcpNetwork(network string) string {
+	switch network {
+	case "udp4", "tcp4":
+		return "tcp4"
+	case "udp6", "tcp6":
+		return "tcp6"
+	}
+	return "tcp"
+}
@@ -1,7 +1,7 @@
 package collector
 
 import (
-	"net"
+	"net/netip"
 	"time"
 
 	"github.com/go-void/portal/pkg/types/dns"
@@ -13,13 +13,14 @@ type Entry struct {
 	Question      dns.Question
 	Answer        []rr.RR
 	QueryTime     time.Duration
-	ClientIP      net.IP
+	ClientIP      netip.Addr
 	AppliedFilter string
 	Filtered      bool
 	Cached        bool
+	Rewritten     bool
 }
 
-func NewEntry(question dns.Question, answer []rr.RR, queryTime time.Duration, ip net.IP) Entry {
+func NewEntry(question dns.Question, answer []rr.RR, queryTime time.Duration, ip netip.Addr) Entry {
 	return Entry{
 		ID:            "",
 		QueryTime:     queryTime,
@@ -29,17 +30,24 @@ func NewEntry(question dns.Question, answer []rr.RR, queryTime time.Duration, ip
 		AppliedFilter: "",
 		Filtered:      false,
 		Cached:        false,
+		Rewritten:     false,
 	}
 }
 
-func NewCachedEntry(question dns.Question, answer []rr.RR, queryTime time.Duration, ip net.IP) Entry {
+func NewCachedEntry(question dns.Question, answer []rr.RR, queryTime time.Duration, ip netip.Addr) Entry {
 	entry := NewEntry(question, answer, queryTime, ip)
 	entry.Cached = true
 	return entry
 }
 
-func NewFilteredEntry(question dns.Question, answer []rr.RR, queryTime time.Duration, ip net.IP) Entry {
+func NewFilteredEntry(question dns.Question, answer []rr.RR, queryTime time.Duration, ip netip.Addr) Entry {
 	entry := NewEntry(question, answer, queryTime, ip)
 	entry.Filtered = true
 	return entry
 }
+
+func NewRewrittenEntry(question dns.Question, answer []rr.RR, queryTime time.Duration, ip netip.Addr) Entry {
+	entry := NewEntry(question, answer, queryTime, ip)
+	entry.Rewritten = true
+	return entry
+}
@@ -1,11 +1,17 @@
 package config
 
 import (
+	"encoding/hex"
 	"errors"
 	"net/netip"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/go-void/portal/pkg/acl"
 	"github.com/go-void/portal/pkg/constants"
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/tsig"
 	"github.com/go-void/portal/pkg/utils"
 
 	"github.com/pelletier/go-toml/v2"
@@ -16,8 +22,25 @@ var (
 	ErrInvalidCollectorBackend = errors.New("invalid collector backend")
 	ErrInvalidServerAddress    = errors.New("invalid server address")
 	ErrInvalidServerNetwork    = errors.New("invalid network")
+	ErrInvalidDoHOptions       = errors.New("invalid DNS over HTTPS options")
 	ErrInvalidResolverMode     = errors.New("invalid resolver mode")
 	ErrInvalidLogMode          = errors.New("invalid log mode")
+	ErrInvalidZoneName         = errors.New("invalid zone name")
+	ErrInvalidZoneFile         = errors.New("invalid zone file")
+	ErrInvalidZoneACL          = errors.New("invalid zone ACL")
+	ErrInvalidZoneType         = errors.New("invalid zone type")
+	ErrInvalidZonePrimary      = errors.New("invalid zone primary")
+	ErrInvalidZoneNotify       = errors.New("invalid zone also-notify target")
+	ErrInvalidZoneKey          = errors.New("invalid zone key")
+	ErrInvalidZoneDNSSEC       = errors.New("invalid zone DNSSEC options")
+	ErrInvalidKey              = errors.New("invalid TSIG key")
+	ErrInvalidStoreBackend     = errors.New("invalid store backend")
+	ErrInvalidFilterMode       = errors.New("invalid filter mode")
+	ErrInvalidFilterList       = errors.New("invalid filter list")
+	ErrInvalidPolicyZone       = errors.New("invalid filter policy zone")
+	ErrInvalidFilterGroup      = errors.New("invalid filter group")
+	ErrInvalidTrustedProxies   = errors.New("invalid filter trusted proxies")
+	ErrInvalidFilterSchedule   = errors.New("invalid filter schedule")
 )
 
 // NOTE (Techassi): Can we define the options in the packages itself?
@@ -30,6 +53,8 @@ type Config struct {
 	Server    ServerOptions    `toml:"server"`
 	Store     StoreOptions     `toml:"store"`
 	Log       LogOptions       `toml:"log"`
+	Zones     []ZoneOptions    `toml:"zones"`
+	Keys      []KeyOptions     `toml:"keys"`
 }
 
 // CollectorOptions specifies available collector config options
@@ -51,18 +76,98 @@ type ResolverOptions struct {
 	Mode         string     `toml:"mode"`
 }
 
-// FilterOptions specifies available filter config options
+// FilterOptions specifies available filter config options. Rules use the
+// format '[<ip-address>] <domain>'. Lists are checked for changes every
+// reload interval (in seconds). Bloom filters speed up lookups of names
+// which are not filtered in large lists. Policy zones are applied in order
+// of precedence before the rules and lists. These options make up the
+// default filter, groups select their own filters for specific clients.
+// Only trusted proxies can select groups via the EDNS Client Subnet option.
+// Names (and their subdomains) or glob patterns in allow are never filtered
+// by any filter. Lists and groups can refer to schedules by name, which
+// restrict when they apply. Rewrites use the format '<name> <target>' or
+// '<name> <ip-address>...' and answer queries for the name with the target
+// or addresses. Safe search enables the built-in rewrites of search engines
+// to their safe search endpoints
 type FilterOptions struct {
-	TTL  int    `toml:"ttl"`
-	Mode string `toml:"mode"`
+	Enabled           bool                 `toml:"enabled"`
+	TTL               int                  `toml:"ttl"`
+	Mode              string               `toml:"mode"`
+	Rules             []string             `toml:"rules"`
+	Allow             []string             `toml:"allow"`
+	Rewrites          []string             `toml:"rewrites"`
+	SafeSearch        []string             `toml:"safe_search"`
+	Lists             []FilterListOptions  `toml:"lists"`
+	PolicyZones       []PolicyZoneOptions  `toml:"policy_zones"`
+	Groups            []FilterGroupOptions `toml:"groups"`
+	Schedules         []ScheduleOptions    `toml:"schedules"`
+	RawTrustedProxies []string             `toml:"trusted_proxies"`
+	TrustedProxies    acl.ACL              `toml:"-"`
+	ReloadInterval    int                  `toml:"reload_interval"`
+	BloomFilter       bool                 `toml:"bloom_filter"`
 }
 
-// ServerOptions specifies available server config options
+// FilterGroupOptions specifies a group of clients with its own filter.
+// Clients are IP addresses or prefixes in CIDR notation, which match the
+// source address or client subnet of queries. Tokens match the last path
+// segment of DNS over HTTPS requests (/dns-query/<token>). The mode
+// defaults to the mode of the default filter. Names in allow are never
+// filtered for the group. Outside of its schedule, the clients of the group
+// get the default filter
+type FilterGroupOptions struct {
+	Name       string              `toml:"name"`
+	Mode       string              `toml:"mode"`
+	Schedule   string              `toml:"schedule"`
+	Rules      []string            `toml:"rules"`
+	Allow      []string            `toml:"allow"`
+	Rewrites   []string            `toml:"rewrites"`
+	SafeSearch []string            `toml:"safe_search"`
+	Lists      []FilterListOptions `toml:"lists"`
+	RawClients []string            `toml:"clients"`
+	Clients    acl.ACL             `toml:"-"`
+	Tokens     []string            `toml:"tokens"`
+}
+
+// FilterListOptions specifies a list of filter rules stored in a local
+// file. The format is one of domains, hosts or adblock. The rules of a list
+// with a schedule only apply while the schedule is active
+type FilterListOptions struct {
+	Name     string `toml:"name"`
+	Path     string `toml:"path"`
+	Format   string `toml:"format"`
+	Schedule string `toml:"schedule"`
+}
+
+// ScheduleOptions specifies a schedule of time ranges in the format
+// '[<days>] [<hh:mm>-<hh:mm>]', e.g. 'sun-thu 20:00-07:00'. The time zone is
+// an IANA time zone name and defaults to the local time zone
+type ScheduleOptions struct {
+	Name     string   `toml:"name"`
+	TimeZone string   `toml:"time_zone"`
+	Ranges   []string `toml:"ranges"`
+}
+
+// PolicyZoneOptions specifies a response policy zone (RPZ). The zone is
+// loaded from the master file. Without a file, the records of the zone
+// with the same name are used, e.g. a secondary zone kept up to date via
+// zone transfers
+type PolicyZoneOptions struct {
+	Name string `toml:"name"`
+	File string `toml:"file"`
+}
+
+// ServerOptions specifies available server config options. DNS over HTTPS
+// is served in addition if an address is set. Without a certificate and key
+// requests are served via plain HTTP, e.g. behind a reverse proxy
 type ServerOptions struct {
 	CacheEnabled bool           `toml:"cache_enabled"`
 	Address      string         `toml:"address"`
 	AddrPort     netip.AddrPort `toml:"-"`
 	Network      string         `toml:"network"`
+	DoHAddress   string         `toml:"doh_address"`
+	DoHAddrPort  netip.AddrPort `toml:"-"`
+	DoHCert      string         `toml:"doh_cert"`
+	DoHKey       string         `toml:"doh_key"`
 }
 
 type StoreOptions struct {
@@ -72,6 +177,46 @@ type StoreOptions struct {
 	Backend  string `toml:"backend"`
 	Host     string `toml:"host"`
 	Port     int    `toml:"port"`
+	CacheTTL uint   `toml:"cache_ttl"`
+}
+
+// ZoneOptions specifies available options of a zone the server is
+// authoritative for
+type ZoneOptions struct {
+	Name          string           `toml:"name"`
+	Type          string           `toml:"type"`
+	File          string           `toml:"file"`
+	RawPrimaries  []string         `toml:"primaries"`
+	Primaries     []netip.AddrPort `toml:"-"`
+	AllowTransfer []string         `toml:"allow_transfer"`
+	TransferACL   acl.ACL          `toml:"-"`
+	AllowUpdate   []string         `toml:"allow_update"`
+	UpdateACL     acl.ACL          `toml:"-"`
+	RawAlsoNotify []string         `toml:"also_notify"`
+	AlsoNotify    []netip.AddrPort `toml:"-"`
+	RawKey        string           `toml:"key"`
+	Key           *tsig.Key        `toml:"-"`
+
+	// DNSSEC enables online signing of primary zones. Keys are loaded
+	// from or generated into the key directory
+	DNSSEC          bool   `toml:"dnssec"`
+	KeyDirectory    string `toml:"key_directory"`
+	RawAlgorithm    string `toml:"algorithm"`
+	Algorithm       uint8  `toml:"-"`
+	NSEC3           bool   `toml:"nsec3"`
+	NSEC3OptOut     bool   `toml:"nsec3_opt_out"`
+	NSEC3Iterations uint16 `toml:"nsec3_iterations"`
+	RawNSEC3Salt    string `toml:"nsec3_salt"`
+	NSEC3Salt       []byte `toml:"-"`
+}
+
+// KeyOptions specifies a TSIG key shared with other servers or clients.
+// The secret is base64 encoded
+type KeyOptions struct {
+	Name      string    `toml:"name"`
+	Algorithm string    `toml:"algorithm"`
+	Secret    string    `toml:"secret"`
+	Key       *tsig.Key `toml:"-"`
 }
 
 // LogOptions specifies available log config options
@@ -122,8 +267,9 @@ func Default() *Config {
 			MaxExpire:    300,
 		},
 		Filter: FilterOptions{
-			TTL:  0,
-			Mode: "null",
+			Enabled: true,
+			TTL:     0,
+			Mode:    "null",
 		},
 		Collector: CollectorOptions{
 			MaxEntries: 1000,
@@ -132,6 +278,9 @@ func Default() *Config {
 			Enabled:    true,
 			Backend:    "default",
 		},
+		Store: StoreOptions{
+			Backend: "default",
+		},
 		Log: LogOptions{
 			Enabled: true,
 			Mode:    "production",
@@ -153,6 +302,18 @@ func (c *Config) Validate() error {
 		return ErrInvalidServerNetwork
 	}
 
+	if c.Server.DoHAddress != "" {
+		addrPort, err := netip.ParseAddrPort(c.Server.DoHAddress)
+		if err != nil {
+			return ErrInvalidDoHOptions
+		}
+		c.Server.DoHAddrPort = addrPort
+	}
+
+	if (c.Server.DoHCert == "") != (c.Server.DoHKey == "") {
+		return ErrInvalidDoHOptions
+	}
+
 	if utils.NotIn(c.Resolver.Mode, []string{"r", "i", "f"}) {
 		return ErrInvalidResolverMode
 	}
@@ -165,14 +326,142 @@ func (c *Config) Validate() error {
 		c.Resolver.Upstream = addr
 	}
 
+	if c.Filter.Enabled {
+		err := c.validateFilter()
+		if err != nil {
+			return err
+		}
+	}
+
 	if c.Collector.Enabled && utils.NotIn(c.Collector.Backend, []string{"default", "mysql", "mariadb"}) {
 		return ErrInvalidCollectorBackend
 	}
 
+	if utils.NotIn(c.Store.Backend, []string{"", "default", "sqlite", "mysql", "mariadb"}) {
+		return ErrInvalidStoreBackend
+	}
+
 	if c.Log.Enabled && utils.NotIn(c.Log.Mode, []string{"", "dev", "development", "prod", "production"}) {
 		return ErrInvalidLogMode
 	}
 
+	keys := make(tsig.Keyring)
+	for i, key := range c.Keys {
+		if key.Algorithm == "" {
+			key.Algorithm = tsig.HmacSHA256
+		}
+
+		k, err := tsig.NewKey(key.Name, key.Algorithm, key.Secret)
+		if err != nil || key.Name == "" || !labels.IsValid(key.Name) {
+			return ErrInvalidKey
+		}
+		c.Keys[i].Key = k
+		keys.Add(k)
+	}
+
+	for i, zone := range c.Zones {
+		if zone.Name == "" || !labels.IsValid(zone.Name) {
+			return ErrInvalidZoneName
+		}
+		c.Zones[i].Name = labels.Rootify(zone.Name)
+
+		switch zone.Type {
+		case "", "primary":
+			c.Zones[i].Type = "primary"
+
+			if zone.File == "" {
+				return ErrInvalidZoneFile
+			}
+		case "secondary":
+			if len(zone.RawPrimaries) == 0 {
+				return ErrInvalidZonePrimary
+			}
+
+			for _, raw := range zone.RawPrimaries {
+				primary, err := parseAddrPort(raw, 53)
+				if err != nil {
+					return ErrInvalidZonePrimary
+				}
+				c.Zones[i].Primaries = append(c.Zones[i].Primaries, primary)
+			}
+		default:
+			return ErrInvalidZoneType
+		}
+
+		transferACL, err := acl.Parse(zone.AllowTransfer)
+		if err != nil {
+			return ErrInvalidZoneACL
+		}
+		c.Zones[i].TransferACL = transferACL
+
+		updateACL, err := acl.Parse(zone.AllowUpdate)
+		if err != nil {
+			return ErrInvalidZoneACL
+		}
+		c.Zones[i].UpdateACL = updateACL
+
+		for _, raw := range zone.RawAlsoNotify {
+			target, err := parseAddrPort(raw, 53)
+			if err != nil {
+				return ErrInvalidZoneNotify
+			}
+			c.Zones[i].AlsoNotify = append(c.Zones[i].AlsoNotify, target)
+		}
+
+		if zone.RawKey != "" {
+			key, ok := keys.Get(zone.RawKey)
+			if !ok {
+				return ErrInvalidZoneKey
+			}
+			c.Zones[i].Key = key
+		}
+
+		if zone.DNSSEC {
+			err := c.Zones[i].validateDNSSEC()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateDNSSEC validates the signing options of the zone. Only primary
+// zones can be signed
+func (z *ZoneOptions) validateDNSSEC() error {
+	if z.Type != "primary" {
+		return ErrInvalidZoneDNSSEC
+	}
+
+	if z.KeyDirectory == "" {
+		z.KeyDirectory = constants.DNSSECDefaultKeyDirectory
+	}
+
+	// See https://www.iana.org/assignments/dns-sec-alg-numbers/dns-sec-alg-numbers.xhtml
+	switch strings.ToLower(z.RawAlgorithm) {
+	case "", "ecdsap256sha256":
+		z.Algorithm = 13
+	case "ed25519":
+		z.Algorithm = 15
+	default:
+		return ErrInvalidZoneDNSSEC
+	}
+
+	if z.NSEC3OptOut && !z.NSEC3 {
+		return ErrInvalidZoneDNSSEC
+	}
+
+	// The salt is hex encoded, "-" denotes an empty salt.
+	// See https://datatracker.ietf.org/doc/html/rfc5155#section-3.3
+	if z.RawNSEC3Salt != "" && z.RawNSEC3Salt != "-" {
+		salt, err := hex.DecodeString(z.RawNSEC3Salt)
+		if err != nil || len(salt) > 255 {
+			return ErrInvalidZoneDNSSEC
+		}
+		z.NSEC3Salt = salt
+	}
+
 	return nil
 }
 
@@ -194,3 +483,136 @@ func (c *Config) Defaults() {
 		c.Log.Outputs = []string{"stderr"}
 	}
 }
+
+// parseAddrPort parses an IP address with an optional port. The default
+// port is used if the port is missing
+func parseAddrPort(s string, port uint16) (netip.AddrPort, error) {
+	addr, err := netip.ParseAddr(s)
+	if err == nil {
+		return netip.AddrPortFrom(addr, port), nil
+	}
+	return netip.ParseAddrPort(s)
+}
+
+// validateFilter validates the filter options and fills in defaults of
+// the lists and groups
+func (c *Config) validateFilter() error {
+	modes := []string{"nxdomain", "localip", "nodata", "null"}
+	if utils.NotIn(strings.ToLower(c.Filter.Mode), modes) {
+		return ErrInvalidFilterMode
+	}
+
+	if c.Filter.ReloadInterval <= 0 {
+		c.Filter.ReloadInterval = constants.FilterDefaultReloadInterval
+	}
+
+	schedules := make(map[string]bool)
+	for _, schedule := range c.Filter.Schedules {
+		if schedule.Name == "" || schedules[schedule.Name] || len(schedule.Ranges) == 0 {
+			return ErrInvalidFilterSchedule
+		}
+		schedules[schedule.Name] = true
+
+		if _, err := time.LoadLocation(schedule.TimeZone); err != nil {
+			return ErrInvalidFilterSchedule
+		}
+	}
+
+	err := validateFilterLists(c.Filter.Lists, schedules)
+	if err != nil {
+		return err
+	}
+
+	proxies, err := acl.Parse(c.Filter.RawTrustedProxies)
+	if err != nil {
+		return ErrInvalidTrustedProxies
+	}
+	c.Filter.TrustedProxies = proxies
+
+	groups := map[string]bool{constants.FilterDefaultName: true}
+	for i, group := range c.Filter.Groups {
+		if group.Name == "" || groups[group.Name] {
+			return ErrInvalidFilterGroup
+		}
+		groups[group.Name] = true
+
+		if group.Mode == "" {
+			c.Filter.Groups[i].Mode = c.Filter.Mode
+		} else if utils.NotIn(strings.ToLower(group.Mode), modes) {
+			return ErrInvalidFilterMode
+		}
+
+		if group.Schedule != "" && !schedules[group.Schedule] {
+			return ErrInvalidFilterSchedule
+		}
+
+		err := validateFilterLists(group.Lists, schedules)
+		if err != nil {
+			return err
+		}
+
+		clients, err := acl.Parse(group.RawClients)
+		if err != nil {
+			return ErrInvalidFilterGroup
+		}
+		c.Filter.Groups[i].Clients = clients
+
+		for _, token := range group.Tokens {
+			if token == "" || strings.Contains(token, "/") {
+				return ErrInvalidFilterGroup
+			}
+		}
+	}
+
+	zones := make(map[string]bool)
+	for i, pz := range c.Filter.PolicyZones {
+		name := strings.ToLower(labels.Rootify(pz.Name))
+		if pz.Name == "" || !labels.IsValid(pz.Name) || zones[name] {
+			return ErrInvalidPolicyZone
+		}
+		c.Filter.PolicyZones[i].Name = name
+		zones[name] = true
+
+		if pz.File == "" && !c.hasZone(name) {
+			return ErrInvalidPolicyZone
+		}
+	}
+
+	return nil
+}
+
+// validateFilterLists validates the lists and fills in their names. Lists
+// can only refer to the schedules
+func validateFilterLists(lists []FilterListOptions, schedules map[string]bool) error {
+	names := make(map[string]bool)
+	for i, list := range lists {
+		if list.Path == "" || utils.NotIn(strings.ToLower(list.Format), []string{"", "domains", "hosts", "adblock"}) {
+			return ErrInvalidFilterList
+		}
+
+		if list.Name == "" {
+			lists[i].Name = list.Path
+		}
+
+		if names[lists[i].Name] {
+			return ErrInvalidFilterList
+		}
+		names[lists[i].Name] = true
+
+		if list.Schedule != "" && !schedules[list.Schedule] {
+			return ErrInvalidFilterSchedule
+		}
+	}
+
+	return nil
+}
+
+// hasZone returns if a zone with the name is configured
+func (c *Config) hasZone(name string) bool {
+	for _, zone := range c.Zones {
+		if strings.EqualFold(labels.Rootify(zone.Name), name) {
+			return true
+		}
+	}
+	return false
+}
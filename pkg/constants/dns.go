@@ -6,4 +6,7 @@ const (
 
 	// The DNS header is always 12 octects long
 	DNSHeaderLen = 12
+
+	// RRs always have a fixed length of 10 octets for TYPE, CLASS, TTL and RDLENGTH
+	RRHeaderFixedLen = 10
 )
@@ -0,0 +1,16 @@
+package constants
+
+import "time"
+
+const (
+	// DoHPath is the path of DNS over HTTPS requests. Requests to
+	// sub-paths carry a token, which selects the filter of the client.
+	// See https://datatracker.ietf.org/doc/html/rfc8484#section-4.1
+	DoHPath = "/dns-query"
+
+	// DoHContentType is the media type of DNS messages
+	DoHContentType = "application/dns-message"
+
+	// DoHReadTimeout limits the time to read a request
+	DoHReadTimeout = 10 * time.Second
+)
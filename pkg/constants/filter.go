@@ -0,0 +1,6 @@
+package constants
+
+const (
+	FilterDefaultName           = "default"
+	FilterDefaultReloadInterval = 60
+)
@@ -0,0 +1,13 @@
+package constants
+
+import "time"
+
+// This file defines constants related to TCP
+
+const (
+	TCPMaxMessageSize = 65535
+
+	// TCPIdleTimeout is the time after which idle TCP conns get closed.
+	// See https://datatracker.ietf.org/doc/html/rfc7766#section-6.2.3
+	TCPIdleTimeout = 10 * time.Second
+)
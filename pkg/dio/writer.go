@@ -64,7 +64,7 @@ func (w *DefaultWriter) WriteTCPClose(conn *net.TCPConn, buf []byte) error {
 // WriteTCP writes a byte slice back to a client with 'addr' via the provided TCP conn
 func (w *DefaultWriter) WriteTCP(conn *net.TCPConn, buf []byte) error {
 	b := make([]byte, len(buf)+2)
-	binary.BigEndian.PutUint16(b, uint16(len(b)))
+	binary.BigEndian.PutUint16(b, uint16(len(buf)))
 	copy(b[2:], buf)
 
 	_, err := conn.Write(b)
@@ -0,0 +1,350 @@
+package dnssec
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"sort"
+	"strings"
+
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/types/rr"
+)
+
+// chain is the chain of NSEC or NSEC3 records of a zone, which proves the
+// non-existence of names and types
+type chain interface {
+	// match returns the record owned by or matching the name. It is nil if
+	// the name doesn't exist
+	match(string) rr.RR
+
+	// cover returns the record covering the name, which proves that the
+	// name doesn't exist
+	cover(string) rr.RR
+
+	// records returns all records of the chain
+	records() []rr.RR
+}
+
+// names describes the names of a zone and their types as input for
+// building a chain
+type names struct {
+	origin string
+	class  uint16
+
+	// ttl is the TTL of NSEC and NSEC3 records, which is the minimum of
+	// the SOA TTL and the SOA MINIMUM field.
+	// See https://datatracker.ietf.org/doc/html/rfc4034#section-4
+	ttl uint32
+
+	// types maps every authoritative name and every delegation of the
+	// zone to the types of its records
+	types map[string][]uint16
+
+	// delegations contains all names with NS records below the apex
+	delegations map[string]bool
+}
+
+// collect groups the records of the zone by name. Names below delegations
+// are not authoritative and are left out
+func collect(origin string, records []rr.RR) *names {
+	n := &names{
+		origin:      canonicalName(origin),
+		types:       make(map[string][]uint16),
+		delegations: make(map[string]bool),
+	}
+
+	for _, record := range records {
+		header := record.Header()
+		name := canonicalName(header.Name)
+
+		switch {
+		case header.Type == rr.TypeSOA && name == n.origin:
+			soa := record.(*rr.SOA)
+			n.class = header.Class
+			n.ttl = header.TTL
+			if soa.Minimum < n.ttl {
+				n.ttl = soa.Minimum
+			}
+		case header.Type == rr.TypeNS && name != n.origin:
+			n.delegations[name] = true
+		}
+
+		// RRSIG, NSEC and NSEC3 records are maintained by the signer
+		switch header.Type {
+		case rr.TypeRRSIG, rr.TypeNSEC, rr.TypeNSEC3:
+			continue
+		}
+
+		if !hasType(n.types[name], header.Type) {
+			n.types[name] = append(n.types[name], header.Type)
+		}
+	}
+
+	for name := range n.types {
+		if n.isOccluded(name) {
+			delete(n.types, name)
+		}
+	}
+
+	return n
+}
+
+// isOccluded returns if the name is below a delegation
+func (n *names) isOccluded(name string) bool {
+	for p := labels.Parent(name); p != "" && p != n.origin && labels.IsSubdomain(p, n.origin); p = labels.Parent(p) {
+		if n.delegations[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// authoritativeTypes returns the types of the name which the zone is
+// authoritative for. At delegations these are only NS and DS
+func (n *names) authoritativeTypes(name string) []uint16 {
+	types := n.types[name]
+	if !n.delegations[name] {
+		return types
+	}
+
+	delegation := []uint16{rr.TypeNS}
+	if hasType(types, rr.TypeDS) {
+		delegation = append(delegation, rr.TypeDS)
+	}
+
+	return delegation
+}
+
+// nsecChain is a chain of NSEC records in canonical order of their owner
+// names. See https://datatracker.ietf.org/doc/html/rfc4034#section-4
+type nsecChain struct {
+	owners []string
+	nsecs  []rr.RR
+}
+
+func buildNSEC(n *names) *nsecChain {
+	c := &nsecChain{}
+
+	for name := range n.types {
+		c.owners = append(c.owners, name)
+	}
+
+	sort.Slice(c.owners, func(i, j int) bool {
+		return labels.Compare(c.owners[i], c.owners[j]) < 0
+	})
+
+	for i, name := range c.owners {
+		next := c.owners[(i+1)%len(c.owners)]
+
+		types := append([]uint16{rr.TypeNSEC, rr.TypeRRSIG}, n.authoritativeTypes(name)...)
+		sortTypes(types)
+
+		c.nsecs = append(c.nsecs, &rr.NSEC{
+			H: rr.Header{
+				Name:  name,
+				Type:  rr.TypeNSEC,
+				Class: n.class,
+				TTL:   n.ttl,
+			},
+			NextDomain: next,
+			Types:      types,
+		})
+	}
+
+	return c
+}
+
+func (c *nsecChain) match(name string) rr.RR {
+	i := c.search(name)
+	if i < 0 || c.owners[i] != canonicalName(name) {
+		return nil
+	}
+	return c.nsecs[i]
+}
+
+func (c *nsecChain) cover(name string) rr.RR {
+	i := c.search(name)
+	if i < 0 {
+		return nil
+	}
+	return c.nsecs[i]
+}
+
+func (c *nsecChain) records() []rr.RR {
+	return c.nsecs
+}
+
+// search returns the index of the last owner name which sorts before or
+// equal to the name or -1 if there is none
+func (c *nsecChain) search(name string) int {
+	i := sort.Search(len(c.owners), func(i int) bool {
+		return labels.Compare(c.owners[i], name) > 0
+	})
+	return i - 1
+}
+
+// nsec3Chain is a chain of NSEC3 records ordered by the hashes of their
+// owner names. See https://datatracker.ietf.org/doc/html/rfc5155#section-7.1
+type nsec3Chain struct {
+	origin     string
+	iterations uint16
+	salt       []byte
+
+	hashes [][]byte
+	nsec3s []rr.RR
+}
+
+func buildNSEC3(n *names, optOut bool, iterations uint16, salt []byte) *nsec3Chain {
+	c := &nsec3Chain{
+		origin:     n.origin,
+		iterations: iterations,
+		salt:       salt,
+	}
+
+	// With opt-out, insecure delegations don't get NSEC3 records
+	included := make(map[string]bool)
+	for name, types := range n.types {
+		if optOut && n.delegations[name] && !hasType(types, rr.TypeDS) {
+			continue
+		}
+		included[name] = true
+	}
+
+	// Empty non-terminals between included names and the apex need NSEC3
+	// records as well. See https://datatracker.ietf.org/doc/html/rfc5155#section-7.1
+	for name := range included {
+		for p := labels.Parent(name); p != "" && p != n.origin && labels.IsSubdomain(p, n.origin); p = labels.Parent(p) {
+			if _, ok := n.types[p]; ok {
+				break
+			}
+			included[p] = true
+		}
+	}
+
+	type entry struct {
+		hash  []byte
+		types []uint16
+	}
+
+	entries := make([]entry, 0, len(included))
+	for name := range included {
+		types := append([]uint16{}, n.authoritativeTypes(name)...)
+
+		// Only insecure delegations have no signed RRsets
+		if len(types) > 0 && !(n.delegations[name] && !hasType(types, rr.TypeDS)) {
+			types = append(types, rr.TypeRRSIG)
+		}
+		sortTypes(types)
+
+		entries = append(entries, entry{
+			hash:  hashName(name, iterations, salt),
+			types: types,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].hash, entries[j].hash) < 0
+	})
+
+	var flags uint8
+	if optOut {
+		flags = rr.NSEC3FlagOptOut
+	}
+
+	for i, e := range entries {
+		next := entries[(i+1)%len(entries)]
+
+		c.hashes = append(c.hashes, e.hash)
+		c.nsec3s = append(c.nsec3s, &rr.NSEC3{
+			H: rr.Header{
+				Name:  c.owner(e.hash),
+				Type:  rr.TypeNSEC3,
+				Class: n.class,
+				TTL:   n.ttl,
+			},
+			HashAlgorithm: HashSHA1,
+			Flags:         flags,
+			Iterations:    iterations,
+			Salt:          salt,
+			NextHashed:    next.hash,
+			Types:         e.types,
+		})
+	}
+
+	return c
+}
+
+func (c *nsec3Chain) match(name string) rr.RR {
+	hash := hashName(name, c.iterations, c.salt)
+
+	i := c.search(hash)
+	if i < 0 || !bytes.Equal(c.hashes[i], hash) {
+		return nil
+	}
+	return c.nsec3s[i]
+}
+
+func (c *nsec3Chain) cover(name string) rr.RR {
+	if len(c.hashes) == 0 {
+		return nil
+	}
+
+	// A hash before the first record is covered by the last record,
+	// which wraps around to the first hash
+	i := c.search(hashName(name, c.iterations, c.salt))
+	if i < 0 {
+		i = len(c.hashes) - 1
+	}
+	return c.nsec3s[i]
+}
+
+func (c *nsec3Chain) records() []rr.RR {
+	return c.nsec3s
+}
+
+// search returns the index of the last hash which sorts before or equal to
+// the hash or -1 if there is none
+func (c *nsec3Chain) search(hash []byte) int {
+	i := sort.Search(len(c.hashes), func(i int) bool {
+		return bytes.Compare(c.hashes[i], hash) > 0
+	})
+	return i - 1
+}
+
+// owner returns the owner name of the NSEC3 record with the hash
+func (c *nsec3Chain) owner(hash []byte) string {
+	return strings.ToLower(rr.Base32Hex.EncodeToString(hash)) + "." + c.origin
+}
+
+// hashName returns the iterated SHA-1 hash of the name in canonical wire
+// format. See https://datatracker.ietf.org/doc/html/rfc5155#section-5
+func hashName(name string, iterations uint16, salt []byte) []byte {
+	h := sha1.New()
+	h.Write(packName(name))
+	h.Write(salt)
+	hash := h.Sum(nil)
+
+	for i := uint16(0); i < iterations; i++ {
+		h.Reset()
+		h.Write(hash)
+		h.Write(salt)
+		hash = h.Sum(nil)
+	}
+
+	return hash
+}
+
+func hasType(types []uint16, t uint16) bool {
+	for _, typ := range types {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
+
+func sortTypes(types []uint16) {
+	sort.Slice(types, func(i, j int) bool {
+		return types[i] < types[j]
+	})
+}
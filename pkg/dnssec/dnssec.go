@@ -0,0 +1,68 @@
+// Package dnssec signs authoritative zones online. It manages the keys of
+// signed zones, generates RRSIG records on the fly and maintains the NSEC
+// or NSEC3 chain of each zone. See https://datatracker.ietf.org/doc/html/rfc4033
+package dnssec
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-void/portal/pkg/compression"
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/pack"
+)
+
+// Supported signing algorithms.
+// See https://www.iana.org/assignments/dns-sec-alg-numbers/dns-sec-alg-numbers.xhtml
+const (
+	ECDSAP256SHA256 uint8 = 13
+	ED25519         uint8 = 15
+)
+
+// DigestSHA256 is the DS digest type of SHA-256.
+// See https://datatracker.ietf.org/doc/html/rfc4509#section-5
+const DigestSHA256 uint8 = 2
+
+// HashSHA1 is the only defined NSEC3 hash algorithm.
+// See https://datatracker.ietf.org/doc/html/rfc5155#section-11
+const HashSHA1 uint8 = 1
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("dnssec: unsupported algorithm")
+	ErrInvalidKeyFile       = errors.New("dnssec: invalid key file")
+	ErrNoKeys               = errors.New("dnssec: zone has no signing keys")
+)
+
+// canonicalName returns the name in canonical form, which is lowercase and
+// fully qualified. See https://datatracker.ietf.org/doc/html/rfc4034#section-6.2
+func canonicalName(name string) string {
+	return strings.ToLower(labels.Rootify(name))
+}
+
+// packName returns the uncompressed wire format of the name in canonical
+// form
+func packName(name string) []byte {
+	name = canonicalName(name)
+
+	buf := make([]byte, labels.Len(name))
+	offset, _ := pack.PackDomainName(name, buf, 0, compression.New())
+
+	return buf[:offset]
+}
+
+// labelCount returns the number of labels of the name without the root
+// label and a leading wildcard label.
+// See https://datatracker.ietf.org/doc/html/rfc4034#section-3.1.3
+func labelCount(name string) uint8 {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return 0
+	}
+
+	count := strings.Count(name, ".") + 1
+	if strings.HasPrefix(name, "*.") || name == "*" {
+		count--
+	}
+
+	return uint8(count)
+}
@@ -0,0 +1,241 @@
+package dnssec
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-void/portal/pkg/compression"
+	"github.com/go-void/portal/pkg/types/rr"
+)
+
+// pemType is the PEM block type of stored private keys
+const pemType = "PRIVATE KEY"
+
+// Key is a signing key of a zone. A key with the SEP flag is a key signing
+// key (KSK), which only signs the DNSKEY RRset. All other keys are zone
+// signing keys (ZSK)
+type Key struct {
+	DNSKEY *rr.DNSKEY
+
+	private crypto.Signer
+}
+
+// GenerateKey generates a new key for the zone with origin. The flags
+// select between KSK (rr.DNSKEYFlagZone | rr.DNSKEYFlagSEP) and ZSK
+// (rr.DNSKEYFlagZone)
+func GenerateKey(origin string, algorithm uint8, flags uint16) (*Key, error) {
+	var (
+		private crypto.Signer
+		err     error
+	)
+
+	switch algorithm {
+	case ECDSAP256SHA256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case ED25519:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newKey(origin, algorithm, flags, private)
+}
+
+func newKey(origin string, algorithm uint8, flags uint16, private crypto.Signer) (*Key, error) {
+	var public []byte
+
+	switch key := private.Public().(type) {
+	case *ecdsa.PublicKey:
+		if algorithm != ECDSAP256SHA256 || key.Curve != elliptic.P256() {
+			return nil, ErrUnsupportedAlgorithm
+		}
+
+		// The public key is encoded as X | Y, each padded to 32 octets.
+		// See https://datatracker.ietf.org/doc/html/rfc6605#section-4
+		public = append(pad(key.X, 32), pad(key.Y, 32)...)
+	case ed25519.PublicKey:
+		if algorithm != ED25519 {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		public = key
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	return &Key{
+		DNSKEY: &rr.DNSKEY{
+			H: rr.Header{
+				Name:  canonicalName(origin),
+				Type:  rr.TypeDNSKEY,
+				Class: rr.IN,
+			},
+			Flags:     flags,
+			Protocol:  3,
+			Algorithm: algorithm,
+			PublicKey: public,
+		},
+		private: private,
+	}, nil
+}
+
+// IsKSK returns if the key is a key signing key
+func (k *Key) IsKSK() bool {
+	return k.DNSKEY.Flags&rr.DNSKEYFlagSEP != 0
+}
+
+// Tag returns the key tag of the key
+func (k *Key) Tag() uint16 {
+	return k.DNSKEY.KeyTag()
+}
+
+// DS returns the SHA-256 digest of the key as DS record, which is also
+// used for CDS records. See https://datatracker.ietf.org/doc/html/rfc4034#section-5.1.4
+func (k *Key) DS() *rr.DS {
+	rdata := make([]byte, k.DNSKEY.Len())
+	k.DNSKEY.Pack(rdata, 0, compression.New())
+
+	digest := sha256.New()
+	digest.Write(packName(k.DNSKEY.H.Name))
+	digest.Write(rdata)
+
+	return &rr.DS{
+		H: rr.Header{
+			Name:  k.DNSKEY.H.Name,
+			Type:  rr.TypeDS,
+			Class: k.DNSKEY.H.Class,
+			TTL:   k.DNSKEY.H.TTL,
+		},
+		KeyTag:     k.Tag(),
+		Algorithm:  k.DNSKEY.Algorithm,
+		DigestType: DigestSHA256,
+		Digest:     digest.Sum(nil),
+	}
+}
+
+// FileName returns the name of the file the key is stored in. It follows
+// the K<origin>+<algorithm>+<key tag> naming scheme
+func (k *Key) FileName() string {
+	origin := strings.TrimSuffix(k.DNSKEY.H.Name, ".")
+	return fmt.Sprintf("K%s.+%03d+%05d.pem", origin, k.DNSKEY.Algorithm, k.Tag())
+}
+
+// Save writes the private key as PKCS #8 PEM file into the directory. The
+// flags of the key are stored as PEM header
+func (k *Key) Save(dir string) error {
+	der, err := x509.MarshalPKCS8PrivateKey(k.private)
+	if err != nil {
+		return err
+	}
+
+	block := &pem.Block{
+		Type: pemType,
+		Headers: map[string]string{
+			"Flags": strconv.Itoa(int(k.DNSKEY.Flags)),
+		},
+		Bytes: der,
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, k.FileName()), pem.EncodeToMemory(block), 0600)
+}
+
+// LoadKeys loads all keys of the zone with origin from the directory
+func LoadKeys(dir, origin string) ([]*Key, error) {
+	pattern := filepath.Join(dir, fmt.Sprintf("K%s.+*.pem", strings.TrimSuffix(canonicalName(origin), ".")))
+
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*Key
+	for _, path := range paths {
+		key, err := loadKey(path, origin)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", ErrInvalidKeyFile, path, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// EnsureKeys loads the keys of the zone with origin from the directory. If
+// there are no keys yet, a KSK and a ZSK get generated and stored
+func EnsureKeys(dir, origin string, algorithm uint8) ([]*Key, error) {
+	keys, err := LoadKeys(dir, origin)
+	if err != nil || len(keys) > 0 {
+		return keys, err
+	}
+
+	for _, flags := range []uint16{rr.DNSKEYFlagZone | rr.DNSKEYFlagSEP, rr.DNSKEYFlagZone} {
+		key, err := GenerateKey(origin, algorithm, flags)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := key.Save(dir); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func loadKey(path, origin string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != pemType {
+		return nil, ErrInvalidKeyFile
+	}
+
+	flags, err := strconv.ParseUint(block.Headers["Flags"], 10, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch private := private.(type) {
+	case *ecdsa.PrivateKey:
+		return newKey(origin, ECDSAP256SHA256, uint16(flags), private)
+	case ed25519.PrivateKey:
+		return newKey(origin, ED25519, uint16(flags), private)
+	}
+
+	return nil, ErrUnsupportedAlgorithm
+}
+
+// pad returns the big-endian bytes of n left-padded with zeros to size
+func pad(n *big.Int, size int) []byte {
+	b := make([]byte, size)
+	return n.FillBytes(b)
+}
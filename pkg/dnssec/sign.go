@@ -0,0 +1,185 @@
+package dnssec
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"math/big"
+	"sort"
+
+	"github.com/go-void/portal/pkg/compression"
+	"github.com/go-void/portal/pkg/pack"
+	"github.com/go-void/portal/pkg/types/rr"
+)
+
+// Sign signs the RRset with the key. All records must share the same owner,
+// class and type. The RRSIG gets the TTL of the RRset and the inception and
+// expiration times in seconds since the epoch
+func (k *Key) Sign(rrset []rr.RR, inception, expiration uint32) (*rr.RRSIG, error) {
+	if len(rrset) == 0 {
+		return nil, nil
+	}
+
+	header := rrset[0].Header()
+	sig := &rr.RRSIG{
+		H: rr.Header{
+			Name:  header.Name,
+			Type:  rr.TypeRRSIG,
+			Class: header.Class,
+			TTL:   header.TTL,
+		},
+		TypeCovered: header.Type,
+		Algorithm:   k.DNSKEY.Algorithm,
+		Labels:      labelCount(header.Name),
+		OriginalTTL: header.TTL,
+		Expiration:  expiration,
+		Inception:   inception,
+		KeyTag:      k.Tag(),
+		SignerName:  k.DNSKEY.H.Name,
+	}
+
+	data, err := signedData(sig, rrset)
+	if err != nil {
+		return nil, err
+	}
+
+	switch private := k.private.(type) {
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(data)
+
+		der, err := private.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			return nil, err
+		}
+
+		// Signatures are encoded as r | s, each padded to 32 octets.
+		// See https://datatracker.ietf.org/doc/html/rfc6605#section-4
+		var rs struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(der, &rs); err != nil {
+			return nil, err
+		}
+		sig.Signature = append(pad(rs.R, 32), pad(rs.S, 32)...)
+	case ed25519.PrivateKey:
+		sig.Signature = ed25519.Sign(private, data)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	return sig, nil
+}
+
+// signedData returns the data the signature is calculated over. This is
+// the RRSIG RDATA without the signature followed by the RRset in canonical
+// form and order. See https://datatracker.ietf.org/doc/html/rfc4034#section-3.1.8.1
+func signedData(sig *rr.RRSIG, rrset []rr.RR) ([]byte, error) {
+	buf := make([]byte, sig.Len())
+	offset, err := sig.PackWithoutSignature(buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	data := buf[:offset]
+
+	// The owner name of records synthesized from a wildcard is replaced
+	// by the wildcard. See https://datatracker.ietf.org/doc/html/rfc4035#section-5.3.2
+	owner := packName(rrset[0].Header().Name)
+
+	rdatas, err := canonicalRDATA(rrset)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rdata := range rdatas {
+		fixed := make([]byte, 10)
+		pack.PackUint16(sig.TypeCovered, fixed, 0)
+		pack.PackUint16(sig.H.Class, fixed, 2)
+		pack.PackUint32(sig.OriginalTTL, fixed, 4)
+		pack.PackUint16(uint16(len(rdata)), fixed, 8)
+
+		data = append(data, owner...)
+		data = append(data, fixed...)
+		data = append(data, rdata...)
+	}
+
+	return data, nil
+}
+
+// canonicalRDATA returns the RDATA of all records in canonical form, sorted
+// and without duplicates. See https://datatracker.ietf.org/doc/html/rfc4034#section-6.3
+func canonicalRDATA(rrset []rr.RR) ([][]byte, error) {
+	rdatas := make([][]byte, 0, len(rrset))
+
+	for _, record := range rrset {
+		record = canonicalRecord(record)
+
+		buf := make([]byte, record.Len())
+		offset, err := record.Pack(buf, 0, compression.New())
+		if err != nil {
+			return nil, err
+		}
+		rdatas = append(rdatas, buf[:offset])
+	}
+
+	sort.Slice(rdatas, func(i, j int) bool {
+		return bytes.Compare(rdatas[i], rdatas[j]) < 0
+	})
+
+	unique := rdatas[:0]
+	for i, rdata := range rdatas {
+		if i > 0 && bytes.Equal(rdata, rdatas[i-1]) {
+			continue
+		}
+		unique = append(unique, rdata)
+	}
+
+	return unique, nil
+}
+
+// canonicalRecord returns a copy of the record where domain names in the
+// RDATA are lowercase for all types listed in
+// https://datatracker.ietf.org/doc/html/rfc4034#section-6.2 without NSEC
+// and RRSIG. See https://datatracker.ietf.org/doc/html/rfc6840#section-5.1
+func canonicalRecord(record rr.RR) rr.RR {
+	switch record := rr.Copy(record).(type) {
+	case *rr.NS:
+		record.NSDName = canonicalName(record.NSDName)
+		return record
+	case *rr.CNAME:
+		record.Target = canonicalName(record.Target)
+		return record
+	case *rr.PTR:
+		record.PTRDName = canonicalName(record.PTRDName)
+		return record
+	case *rr.MX:
+		record.Exchange = canonicalName(record.Exchange)
+		return record
+	case *rr.SOA:
+		record.MName = canonicalName(record.MName)
+		record.RName = canonicalName(record.RName)
+		return record
+	case *rr.MB:
+		record.MADName = canonicalName(record.MADName)
+		return record
+	case *rr.MD:
+		record.MADName = canonicalName(record.MADName)
+		return record
+	case *rr.MF:
+		record.MADName = canonicalName(record.MADName)
+		return record
+	case *rr.MG:
+		record.MGMName = canonicalName(record.MGMName)
+		return record
+	case *rr.MR:
+		record.NewName = canonicalName(record.NewName)
+		return record
+	case *rr.MINFO:
+		record.RMailBox = canonicalName(record.RMailBox)
+		record.EMailBox = canonicalName(record.EMailBox)
+		return record
+	default:
+		return record
+	}
+}
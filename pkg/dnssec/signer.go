@@ -0,0 +1,539 @@
+package dnssec
+
+import (
+	"crypto/sha256"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/store"
+	"github.com/go-void/portal/pkg/types/rr"
+)
+
+const (
+	// validity is the validity period of new signatures
+	validity = 14 * 24 * time.Hour
+
+	// refreshBefore is the remaining validity at which signatures get
+	// replaced by new ones
+	refreshBefore = 7 * 24 * time.Hour
+
+	// skew backdates the inception of signatures to tolerate validators
+	// with clocks running behind
+	skew = time.Hour
+
+	// purgeInterval is the interval in which signatures due for refresh
+	// get removed from the cache
+	purgeInterval = time.Hour
+)
+
+// Options describe how a zone gets signed
+type Options struct {
+	// KeyDirectory is the directory the keys of the zone are stored in
+	KeyDirectory string
+
+	// Algorithm is the algorithm of newly generated keys
+	Algorithm uint8
+
+	// NSEC3 selects NSEC3 instead of NSEC records for authenticated denial
+	// of existence
+	NSEC3 bool
+
+	// OptOut excludes insecure delegations from the NSEC3 chain
+	OptOut bool
+
+	// Iterations and Salt are the parameters of the NSEC3 hash
+	Iterations uint16
+	Salt       []byte
+}
+
+// Signer signs zones of a record store online. Signatures are generated
+// when RRsets are requested and cached until they are due for refresh. The
+// NSEC or NSEC3 chain of each zone is rebuilt whenever the zone changes
+type Signer struct {
+	store store.Store
+
+	zones map[string]*zone
+	stop  chan struct{}
+	lock  sync.RWMutex
+	wg    sync.WaitGroup
+}
+
+// zone describes the signing state of a single zone
+type zone struct {
+	origin  string
+	options Options
+	keys    []*Key
+
+	// chain proves the non-existence of names and types. It gets
+	// replaced whenever the zone changes
+	chain chain
+
+	// signatures caches the signatures of RRsets by a fingerprint of
+	// the RRset
+	signatures map[[sha256.Size]byte]signature
+	lock       sync.Mutex
+}
+
+// signature is a cache entry of the signatures of a single RRset
+type signature struct {
+	records []rr.RR
+
+	// refresh is the time at which the signatures get replaced
+	refresh time.Time
+}
+
+// New returns a new signer which maintains the signed zones whenever they
+// change in the store
+func New(s store.Store) *Signer {
+	signer := &Signer{
+		store: s,
+		zones: make(map[string]*zone),
+		stop:  make(chan struct{}),
+	}
+	s.Subscribe(signer.onChange)
+
+	return signer
+}
+
+// AddZone enables signing of the zone with origin. Existing keys are loaded
+// from the key directory, otherwise a new KSK and ZSK are generated. Zones
+// need to be added before their records are added to the store
+func (s *Signer) AddZone(origin string, opts Options) error {
+	origin = canonicalName(origin)
+
+	keys, err := EnsureKeys(opts.KeyDirectory, origin, opts.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return ErrNoKeys
+	}
+
+	s.lock.Lock()
+	s.zones[origin] = &zone{
+		origin:     origin,
+		options:    opts,
+		keys:       keys,
+		signatures: make(map[[sha256.Size]byte]signature),
+	}
+	s.lock.Unlock()
+
+	// The zone might already be in the store
+	if _, err := s.store.Zone(origin); err == nil {
+		s.onChange(store.Change{Kind: store.ZoneUpdated, Origin: origin})
+	}
+
+	return nil
+}
+
+// IsSigned returns if the zone with origin is signed
+func (s *Signer) IsSigned(origin string) bool {
+	return s.zone(origin) != nil
+}
+
+// Keys returns the keys of the zone with origin
+func (s *Signer) Keys(origin string) []*Key {
+	if z := s.zone(origin); z != nil {
+		return z.keys
+	}
+	return nil
+}
+
+// Run starts removing signatures due for refresh from the cache
+func (s *Signer) Run() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(purgeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case now := <-ticker.C:
+				s.purge(now)
+			}
+		}
+	}()
+}
+
+// Stop stops removing signatures from the cache
+func (s *Signer) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// Sign returns the RRSIG records of the RRset of the zone with origin. The
+// DNSKEY RRset is signed by the KSKs, all other RRsets by the ZSKs. It
+// returns nil if the zone is not signed
+func (s *Signer) Sign(origin string, rrset []rr.RR) []rr.RR {
+	z := s.zone(origin)
+	if z == nil || len(rrset) == 0 {
+		return nil
+	}
+
+	return z.sign(rrset, time.Now())
+}
+
+// DenyName returns the NSEC or NSEC3 records and their signatures which
+// prove that the name doesn't exist and that no wildcard at the closest
+// encloser could have matched.
+// See https://datatracker.ietf.org/doc/html/rfc4035#section-3.1.3.2 and
+// https://datatracker.ietf.org/doc/html/rfc5155#section-7.2.2
+func (s *Signer) DenyName(origin, name, encloser string) []rr.RR {
+	z := s.zone(origin)
+	if z == nil {
+		return nil
+	}
+
+	c := z.current()
+	wildcard := "*." + canonicalName(encloser)
+
+	if _, ok := c.(*nsec3Chain); ok {
+		return z.proof(c.match(encloser), c.cover(nextCloser(name, encloser)), c.cover(wildcard))
+	}
+	return z.proof(c.cover(name), c.cover(wildcard))
+}
+
+// DenyType returns the NSEC or NSEC3 records and their signatures which
+// prove that the name has no records of the queried type.
+// See https://datatracker.ietf.org/doc/html/rfc4035#section-3.1.3.1 and
+// https://datatracker.ietf.org/doc/html/rfc5155#section-7.2.3
+func (s *Signer) DenyType(origin, name string) []rr.RR {
+	z := s.zone(origin)
+	if z == nil {
+		return nil
+	}
+
+	c := z.current()
+	if record := c.match(name); record != nil {
+		return z.proof(record)
+	}
+
+	if _, ok := c.(*nsec3Chain); !ok {
+		// Empty non-terminals have no NSEC record. The NSEC record
+		// covering the name proves that it has no records at all
+		return z.proof(c.cover(name))
+	}
+
+	// Insecure delegations are not part of an opt-out chain. The closest
+	// encloser proof shows that the name is covered by an opt-out record.
+	// See https://datatracker.ietf.org/doc/html/rfc5155#section-7.2.4
+	encloser := labels.Parent(canonicalName(name))
+	for ; encloser != "" && labels.IsSubdomain(encloser, z.origin); encloser = labels.Parent(encloser) {
+		if record := c.match(encloser); record != nil {
+			return z.proof(record, c.cover(nextCloser(name, encloser)))
+		}
+	}
+
+	return nil
+}
+
+// DenyWildcard returns the NSEC or NSEC3 records and their signatures which
+// prove that the name doesn't exist and thus was correctly answered by the
+// wildcard at the closest encloser.
+// See https://datatracker.ietf.org/doc/html/rfc4035#section-3.1.3.3 and
+// https://datatracker.ietf.org/doc/html/rfc5155#section-7.2.6
+func (s *Signer) DenyWildcard(origin, name, encloser string) []rr.RR {
+	z := s.zone(origin)
+	if z == nil {
+		return nil
+	}
+
+	c := z.current()
+	if _, ok := c.(*nsec3Chain); ok {
+		return z.proof(c.match(encloser), c.cover(nextCloser(name, encloser)))
+	}
+	return z.proof(c.cover(name))
+}
+
+// zone returns the signing state of the zone with origin or nil if the
+// zone is not signed
+func (s *Signer) zone(origin string) *zone {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.zones[canonicalName(origin)]
+}
+
+// onChange publishes the keys of a signed zone and rebuilds its chain
+// whenever the zone changes in the store
+func (s *Signer) onChange(change store.Change) {
+	z := s.zone(change.Origin)
+	if z == nil || change.Kind == store.ZoneRemoved {
+		return
+	}
+
+	// Publishing the keys changes the zone again, which rebuilds the
+	// chain within the nested call
+	published, err := s.publish(z)
+	if err != nil || published {
+		return
+	}
+
+	records, err := s.store.Zone(z.origin)
+	if err != nil {
+		return
+	}
+
+	n := collect(z.origin, records)
+
+	var c chain
+	if z.options.NSEC3 {
+		c = buildNSEC3(n, z.options.OptOut, z.options.Iterations, z.options.Salt)
+	} else {
+		c = buildNSEC(n)
+	}
+
+	z.lock.Lock()
+	z.chain = c
+	z.lock.Unlock()
+}
+
+// publish adds the DNSKEY, CDS, CDNSKEY and NSEC3PARAM records of the zone
+// to its apex if they differ from the published ones. It returns if the
+// zone was changed. See https://datatracker.ietf.org/doc/html/rfc7344#section-4
+func (s *Signer) publish(z *zone) (bool, error) {
+	txn, err := s.store.Begin(z.origin)
+	if err != nil {
+		return false, err
+	}
+
+	// The SOA record is always the first record of the zone
+	soa, ok := txn.Records()[0].(*rr.SOA)
+	if !ok {
+		txn.Rollback()
+		return false, store.ErrMissingSOA
+	}
+
+	class, ttl := soa.H.Class, soa.H.TTL
+
+	header := func(t uint16) rr.Header {
+		return rr.Header{Name: z.origin, Type: t, Class: class, TTL: ttl}
+	}
+
+	var dnskeys, cdss, cdnskeys, params []rr.RR
+	for _, key := range z.keys {
+		dnskey := *key.DNSKEY
+		dnskey.H = header(rr.TypeDNSKEY)
+		dnskeys = append(dnskeys, &dnskey)
+
+		// Only KSKs are referenced by the parent zone
+		if !key.IsKSK() {
+			continue
+		}
+
+		ds := key.DS()
+		cdss = append(cdss, &rr.CDS{
+			H:          header(rr.TypeCDS),
+			KeyTag:     ds.KeyTag,
+			Algorithm:  ds.Algorithm,
+			DigestType: ds.DigestType,
+			Digest:     ds.Digest,
+		})
+		cdnskeys = append(cdnskeys, &rr.CDNSKEY{
+			H:         header(rr.TypeCDNSKEY),
+			Flags:     dnskey.Flags,
+			Protocol:  dnskey.Protocol,
+			Algorithm: dnskey.Algorithm,
+			PublicKey: dnskey.PublicKey,
+		})
+	}
+
+	if z.options.NSEC3 {
+		params = append(params, &rr.NSEC3PARAM{
+			H:             rr.Header{Name: z.origin, Type: rr.TypeNSEC3PARAM, Class: class},
+			HashAlgorithm: HashSHA1,
+			Iterations:    z.options.Iterations,
+			Salt:          z.options.Salt,
+		})
+	}
+
+	changed := false
+	for _, rrset := range []struct {
+		t       uint16
+		records []rr.RR
+	}{
+		{rr.TypeDNSKEY, dnskeys},
+		{rr.TypeCDS, cdss},
+		{rr.TypeCDNSKEY, cdnskeys},
+		{rr.TypeNSEC3PARAM, params},
+	} {
+		if sameRRset(txn.Get(z.origin, class, rrset.t), rrset.records) {
+			continue
+		}
+
+		err = txn.Replace(z.origin, class, rrset.t, rrset.records)
+		if err != nil {
+			txn.Rollback()
+			return false, err
+		}
+		changed = true
+	}
+
+	if !changed {
+		txn.Rollback()
+		return false, nil
+	}
+
+	_, err = txn.Commit()
+	return err == nil, err
+}
+
+// current returns the current chain of the zone
+func (z *zone) current() chain {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+
+	if z.chain == nil {
+		return &nsecChain{}
+	}
+	return z.chain
+}
+
+// sign returns the cached signatures of the RRset or signs it if there are
+// no signatures or they are due for refresh
+func (z *zone) sign(rrset []rr.RR, now time.Time) []rr.RR {
+	fingerprint := fingerprint(rrset)
+
+	z.lock.Lock()
+	cached, ok := z.signatures[fingerprint]
+	z.lock.Unlock()
+
+	if ok && now.Before(cached.refresh) {
+		return cached.records
+	}
+
+	var (
+		inception  = uint32(now.Add(-skew).Unix())
+		expiration = uint32(now.Add(validity).Unix())
+		records    []rr.RR
+	)
+
+	for _, key := range z.signingKeys(rrset[0].Header().Type) {
+		sig, err := key.Sign(rrset, inception, expiration)
+		if err != nil || sig == nil {
+			continue
+		}
+		records = append(records, sig)
+	}
+
+	z.lock.Lock()
+	z.signatures[fingerprint] = signature{
+		records: records,
+		refresh: now.Add(validity - refreshBefore),
+	}
+	z.lock.Unlock()
+
+	return records
+}
+
+// signingKeys returns the keys which sign RRsets of the type. Zones without
+// a KSK or ZSK sign all RRsets with the available keys
+func (z *zone) signingKeys(t uint16) []*Key {
+	var keys []*Key
+	for _, key := range z.keys {
+		if key.IsKSK() == (t == rr.TypeDNSKEY) {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return z.keys
+	}
+	return keys
+}
+
+// proof returns the records of the chain and their signatures. Records
+// which prove multiple facts are only included once
+func (z *zone) proof(records ...rr.RR) []rr.RR {
+	var (
+		proof []rr.RR
+		seen  = make(map[string]bool)
+	)
+
+	for _, record := range records {
+		if record == nil || seen[record.Header().Name] {
+			continue
+		}
+		seen[record.Header().Name] = true
+
+		proof = append(proof, record)
+		proof = append(proof, z.sign([]rr.RR{record}, time.Now())...)
+	}
+
+	return proof
+}
+
+// purge removes all signatures due for refresh from the cache of every
+// zone
+func (s *Signer) purge(now time.Time) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for _, z := range s.zones {
+		z.lock.Lock()
+		for fingerprint, cached := range z.signatures {
+			if !now.Before(cached.refresh) {
+				delete(z.signatures, fingerprint)
+			}
+		}
+		z.lock.Unlock()
+	}
+}
+
+// fingerprint returns a hash of the RRset which includes the owner, class,
+// type, TTL and data of each record independent of the order of records
+func fingerprint(rrset []rr.RR) [sha256.Size]byte {
+	lines := make([]string, 0, len(rrset))
+	for _, record := range rrset {
+		lines = append(lines, record.String())
+	}
+	sort.Strings(lines)
+
+	return sha256.Sum256([]byte(strings.Join(lines, "\n")))
+}
+
+// nextCloser returns the name one label longer than the closest encloser
+// which is an ancestor of or equal to name.
+// See https://datatracker.ietf.org/doc/html/rfc5155#section-1.3
+func nextCloser(name, encloser string) string {
+	name, encloser = canonicalName(name), canonicalName(encloser)
+
+	for n := name; n != ""; n = labels.Parent(n) {
+		if labels.Parent(n) == encloser {
+			return n
+		}
+	}
+	return name
+}
+
+// sameRRset returns if both RRsets contain the same records with the same
+// TTL
+func sameRRset(a, b []rr.RR) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if x.IsSame(y) && x.Header().TTL == y.Header().TTL {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,107 @@
+package filter
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-void/portal/pkg/labels"
+)
+
+// Allowlist is a set of rules which allow names regardless of any block
+// rule, including important ones, and policy zone. Rules are names, which
+// allow the name and all its subdomains, or glob patterns.
+// Example: 'example.com' or '*.cdn.example.com'
+type Allowlist struct {
+	rules []Rule
+
+	// index contains the rules (*sourceIndex) and is replaced whenever
+	// the rules change
+	index atomic.Value
+	lock  sync.Mutex
+}
+
+// NewAllowlist returns a new empty allowlist
+func NewAllowlist() *Allowlist {
+	a := &Allowlist{}
+	a.index.Store(&index{})
+
+	return a
+}
+
+// Add adds the rule to the allowlist
+func (a *Allowlist) Add(rule string) error {
+	name, err := parseName(strings.TrimSpace(rule), true)
+	if err != nil {
+		return err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for _, r := range a.rules {
+		if r.Name == name {
+			return nil
+		}
+	}
+
+	a.rules = append(a.rules, Rule{
+		Name:       name,
+		Address:    defaultFilterIP,
+		Subdomains: true,
+	})
+	a.update()
+
+	return nil
+}
+
+// Remove removes the rule from the allowlist
+func (a *Allowlist) Remove(rule string) error {
+	name := strings.ToLower(labels.Rootify(strings.TrimSpace(rule)))
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for i, r := range a.rules {
+		if r.Name == name {
+			a.rules = append(a.rules[:i], a.rules[i+1:]...)
+			a.update()
+			return nil
+		}
+	}
+
+	return ErrNoSuchRule
+}
+
+// Rules returns the names and patterns of all rules in lexical order
+func (a *Allowlist) Rules() []string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	rules := make([]string, 0, len(a.rules))
+	for _, r := range a.rules {
+		rules = append(rules, r.Name)
+	}
+	sort.Strings(rules)
+
+	return rules
+}
+
+// Allows returns if one of the rules matches the lowercase, fully qualified
+// name
+func (a *Allowlist) Allows(name string) bool {
+	if a == nil {
+		return false
+	}
+
+	_, ok := a.index.Load().(*index).match(name)
+	return ok
+}
+
+// update rebuilds the index of the rules. The lock needs to be held
+func (a *Allowlist) update() {
+	a.index.Store(&index{
+		sources: []*sourceIndex{newSourceIndex(a.rules, false)},
+	})
+}
@@ -0,0 +1,233 @@
+package filter
+
+import (
+	"bytes"
+	"net/netip"
+	"sort"
+)
+
+// compactSet is an immutable, memory-efficient set of names, which is used
+// for the name rules of large lists. Names are stored with their labels in
+// reverse order (www.example.com. -> com.example.www) back to back in one
+// sorted byte slice. The ancestors of a name are prefixes of its reversed
+// form, so that they can be looked up by binary search without allocations.
+// Only the name of a matching rule is allocated. Addresses other than the
+// unspecified IPv4 address and important flags are rare and are stored
+// separately
+type compactSet struct {
+	data    []byte
+	offsets []uint32
+
+	addresses map[uint32]netip.Addr
+	important map[uint32]bool
+}
+
+// newCompactSet builds a set of the name rules. Duplicate names keep the
+// address of the first rule and are important if any of the rules is
+func newCompactSet(rules []*Rule) *compactSet {
+	type entry struct {
+		key  string
+		rule *Rule
+		pos  int
+	}
+
+	entries := make([]entry, 0, len(rules))
+	size := 0
+	for i, rule := range rules {
+		key := string(reverseName(nil, rule.Name))
+		entries = append(entries, entry{key, rule, i})
+		size += len(key)
+	}
+
+	// Duplicates keep the order of the rules, so that the first rule
+	// comes first
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].key != entries[j].key {
+			return entries[i].key < entries[j].key
+		}
+		return entries[i].pos < entries[j].pos
+	})
+
+	s := &compactSet{
+		data:      make([]byte, 0, size),
+		offsets:   make([]uint32, 0, len(entries)+1),
+		addresses: make(map[uint32]netip.Addr),
+		important: make(map[uint32]bool),
+	}
+
+	for i, e := range entries {
+		n := uint32(len(s.offsets))
+		if i > 0 && e.key == entries[i-1].key {
+			n--
+		} else {
+			s.offsets = append(s.offsets, uint32(len(s.data)))
+			s.data = append(s.data, e.key...)
+
+			if e.rule.Address != defaultFilterIP {
+				s.addresses[n] = e.rule.Address
+			}
+		}
+
+		if e.rule.Important {
+			s.important[n] = true
+		}
+	}
+	s.offsets = append(s.offsets, uint32(len(s.data)))
+
+	return s
+}
+
+// len returns the number of names in the set
+func (s *compactSet) len() int {
+	return len(s.offsets) - 1
+}
+
+// key returns the reversed name at index i
+func (s *compactSet) key(i int) []byte {
+	return s.data[s.offsets[i]:s.offsets[i+1]]
+}
+
+// get returns the rule of the reversed name. The name of the rule is left
+// empty to avoid allocations, see ruleName
+func (s *compactSet) get(key []byte) (Rule, bool) {
+	if s == nil || s.len() == 0 {
+		return Rule{}, false
+	}
+
+	i := sort.Search(s.len(), func(i int) bool {
+		return bytes.Compare(s.key(i), key) >= 0
+	})
+
+	if i == s.len() || !bytes.Equal(s.key(i), key) {
+		return Rule{}, false
+	}
+
+	address, ok := s.addresses[uint32(i)]
+	if !ok {
+		address = defaultFilterIP
+	}
+
+	return Rule{
+		Address:   address,
+		Important: s.important[uint32(i)],
+	}, true
+}
+
+// ruleName returns the fully qualified name of the reversed name. The name
+// is built on the stack, the returned string is the only allocation
+func ruleName(key []byte) string {
+	var buf [256]byte
+
+	n, end := 0, len(key)
+	for i := len(key) - 1; i >= -1; i-- {
+		if i >= 0 && key[i] != '.' {
+			continue
+		}
+
+		n += copy(buf[n:], key[i+1:end])
+		n += copy(buf[n:], ".")
+		end = i
+	}
+
+	return string(buf[:n])
+}
+
+// reverseName appends the labels of the name in reverse order to buf. The
+// trailing dot is left out and the result of the reversed form is the
+// original fully qualified name.
+// Example: www.example.com. -> com.example.www and com.example.www -> www.example.com.
+func reverseName(buf []byte, name string) []byte {
+	fqdn := len(name) > 0 && name[len(name)-1] == '.'
+	if fqdn {
+		name = name[:len(name)-1]
+	}
+
+	end := len(name)
+	for i := len(name) - 1; i >= -1; i-- {
+		if i >= 0 && name[i] != '.' {
+			continue
+		}
+
+		buf = append(buf, name[i+1:end]...)
+		if i >= 0 {
+			buf = append(buf, '.')
+		}
+		end = i
+	}
+
+	if !fqdn && len(buf) > 0 {
+		buf = append(buf, '.')
+	}
+
+	return buf
+}
+
+// bloom is a blocked Bloom filter with about 1% false positives. All bits
+// of a key are set within a single block of 512 bits, which is the size of
+// a cache line, so that a lookup touches only one cache line
+type bloom struct {
+	blocks [][8]uint64
+}
+
+// bloomHashes is the number of bits set per key, which is the optimal
+// number of hash functions of a Bloom filter with 10 bits per key
+const bloomHashes = 7
+
+func newBloom(n int) *bloom {
+	return &bloom{
+		blocks: make([][8]uint64, n*10/512+1),
+	}
+}
+
+// newSetBloom returns a Bloom filter of the names of the sets
+func newSetBloom(sets ...*compactSet) *bloom {
+	n := 0
+	for _, set := range sets {
+		n += set.len()
+	}
+
+	b := newBloom(n)
+	for _, set := range sets {
+		for i := 0; i < set.len(); i++ {
+			b.add(bloomHash(set.key(i)))
+		}
+	}
+	return b
+}
+
+func (b *bloom) add(h uint64) {
+	block := &b.blocks[(h>>32)%uint64(len(b.blocks))]
+	for i, g := uint64(0), h; i < bloomHashes; i, g = i+1, g+(h>>32|1) {
+		bit := g % 512
+		block[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b *bloom) has(h uint64) bool {
+	block := &b.blocks[(h>>32)%uint64(len(b.blocks))]
+	for i, g := uint64(0), h; i < bloomHashes; i, g = i+1, g+(h>>32|1) {
+		bit := g % 512
+		if block[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHash returns the 64 bit FNV-1a hash of the key. The upper half
+// selects the block, both halves select the bits within the block (double
+// hashing)
+func bloomHash(key []byte) uint64 {
+	h := uint64(14695981039346656037)
+	for _, c := range key {
+		h ^= uint64(c)
+		h *= 1099511628211
+	}
+
+	// The low bits of FNV-1a hashes are mixed poorly, the finalizer of
+	// MurmurHash3 spreads all bits of the hash over the low bits
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	return h
+}
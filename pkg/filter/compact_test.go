@@ -0,0 +1,155 @@
+package filter
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"testing"
+
+	"github.com/go-void/portal/pkg/labels"
+)
+
+// benchmarkSize is the number of rules of the benchmark lists, which is
+// the size of a typical combined blocklist
+const benchmarkSize = 1000000
+
+// benchmarkRules returns n distinct rules with names spread over many
+// second-level domains, like real blocklists
+func benchmarkRules(n int) []Rule {
+	rules := make([]Rule, n)
+	for i := range rules {
+		rules[i] = Rule{
+			Name:    fmt.Sprintf("host%d.tracker%d.example%d.com.", i, i%5000, i%97),
+			Address: defaultFilterIP,
+		}
+	}
+	return rules
+}
+
+// benchmarkQueries returns names of which every second one is filtered,
+// followed by the filtered names and the names which are not filtered. The
+// names which are not filtered share the domains of the rules, so that they
+// are spread over the whole set like real queries
+func benchmarkQueries(rules []Rule) (mixed, hits, misses []string) {
+	for i := 0; i < 1024; i += 2 {
+		j := (i * 7919) % len(rules)
+		hits = append(hits, rules[j].Name)
+		misses = append(misses, fmt.Sprintf("www.tracker%d.example%d.com.", j%5000, j%97))
+		mixed = append(mixed, hits[len(hits)-1], misses[len(misses)-1])
+	}
+	return mixed, hits, misses
+}
+
+// mapRules builds the representation of filter rules which the compact
+// sets replaced: a map of fully qualified names to net.IP addresses. Every
+// rule parsed its own address, which allocated a separate 16 byte slice
+func mapRules(rules []Rule) map[string]net.IP {
+	m := make(map[string]net.IP)
+	for _, rule := range rules {
+		m[rule.Name] = net.ParseIP(rule.Address.String())
+	}
+	return m
+}
+
+// matchMap looks up the name and its ancestors, like subdomain rules do
+func matchMap(m map[string]net.IP, name string) bool {
+	for n := name; n != ""; n = labels.Parent(n) {
+		if _, ok := m[n]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// heapSize returns the bytes allocated on the heap after a collection. The
+// rules are generated after measuring the heap and dropped before measuring
+// again, so that only the memory of the matcher remains
+func heapSize() uint64 {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+func BenchmarkMapMemory(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		before := heapSize()
+		rules := benchmarkRules(benchmarkSize)
+
+		m := mapRules(rules)
+		rules = nil
+
+		b.ReportMetric(float64(heapSize()-before)/benchmarkSize, "bytes/rule")
+		runtime.KeepAlive(m)
+	}
+}
+
+func BenchmarkCompactMemory(b *testing.B) {
+	for _, withBloom := range []bool{false, true} {
+		b.Run(fmt.Sprintf("bloom=%t", withBloom), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				before := heapSize()
+				rules := benchmarkRules(benchmarkSize)
+
+				s := newSourceIndex(rules, withBloom)
+				rules = nil
+
+				b.ReportMetric(float64(heapSize()-before)/benchmarkSize, "bytes/rule")
+				runtime.KeepAlive(s)
+			}
+		})
+	}
+}
+
+func BenchmarkMapLookup(b *testing.B) {
+	rules := benchmarkRules(benchmarkSize)
+	queries, _, _ := benchmarkQueries(rules)
+	m := mapRules(rules)
+
+	// Collect the garbage of building the map, which otherwise slows
+	// down the lookups
+	runtime.GC()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matchMap(m, queries[i%len(queries)])
+	}
+}
+
+func BenchmarkCompactLookup(b *testing.B) {
+	rules := benchmarkRules(benchmarkSize)
+	mixed, hits, misses := benchmarkQueries(rules)
+
+	// The indices are built once, sub-benchmarks run multiple times
+	indices := map[bool]*index{}
+	for _, withBloom := range []bool{false, true} {
+		indices[withBloom] = &index{
+			sources:   []*sourceIndex{newSourceIndex(rules, withBloom)},
+			withBloom: withBloom,
+		}
+	}
+	runtime.GC()
+
+	for _, withBloom := range []bool{false, true} {
+		for _, q := range []struct {
+			name    string
+			queries []string
+		}{
+			{"mixed", mixed},
+			{"hits", hits},
+			{"misses", misses},
+		} {
+			b.Run(fmt.Sprintf("bloom=%t/%s", withBloom, q.name), func(b *testing.B) {
+				idx := indices[withBloom]
+				queries := q.queries
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					idx.match(queries[i%len(queries)])
+				}
+			})
+		}
+	}
+}
@@ -3,57 +3,552 @@ package filter
 
 import (
 	"errors"
-	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/go-void/portal/pkg/labels"
 	"github.com/go-void/portal/pkg/logger"
 	"github.com/go-void/portal/pkg/types/dns"
+	"github.com/go-void/portal/pkg/types/rr"
 
 	"go.uber.org/zap"
 )
 
 var (
-	ErrNoSuchFilter = errors.New("filter: no such filter")
+	ErrNoSuchFilter    = errors.New("filter: no such filter")
+	ErrInvalidFilter   = errors.New("filter: invalid filter")
+	ErrMissingQuestion = errors.New("filter: message without question")
 )
 
+// Action describes how the server responds to a filtered message
+type Action int
+
+const (
+	// ActionAnswer answers with the message, which already carries the
+	// filtered answer
+	ActionAnswer Action = iota
+
+	// ActionDrop doesn't respond at all
+	ActionDrop
+
+	// ActionTCPOnly responds to queries via UDP with a truncated response.
+	// Queries via TCP are answered as if they were not filtered
+	ActionTCPOnly
+)
+
+// Result describes the outcome of matching a message against a filter
+type Result struct {
+	// Filtered indicates if the message was filtered. The message then
+	// already carries the filtered answer
+	Filtered bool
+
+	// Filter is the name of the filter which was applied
+	Filter string
+
+	// PolicyZone is the name of the policy zone whose rule filtered the
+	// message. It is empty if a filter rule filtered the message
+	PolicyZone string
+
+	// Action is the action the server takes to respond
+	Action Action
+
+	// Rewrite is the name of the rewrite set whose rule rewrote the
+	// answer, e.g. 'safesearch-google'. It is empty if no rewrite rule
+	// matched
+	Rewrite string
+
+	// Schedule is the name of the schedule which was active when the
+	// message was filtered. It is empty if the rule applies at all times
+	Schedule string
+
+	// Target is the name the answer was rewritten to by a CNAME record. The
+	// server resolves the target and adds its records to the answer
+	Target string
+}
+
+// Client identifies the client which sent a query. Filters are selected by
+// the token first, then by the client subnet and finally by the address
+type Client struct {
+	// Addr is the source address of the query
+	Addr netip.Addr
+
+	// Subnet is the subnet of the EDNS Client Subnet option of the query.
+	// It is only used if the query was sent by a trusted proxy
+	Subnet netip.Prefix
+
+	// Token is the token in the path of DNS over HTTPS requests
+	Token string
+}
+
 type Engine interface {
-	AddFilter(Filter) error
+	// AddFilter registers a filter. A filter with the same name gets
+	// replaced
+	AddFilter(*Filter) error
+
+	// RemoveFilter removes the filter with the name and the clients
+	// selecting it. The default filter can't be removed
+	RemoveFilter(string) error
+
+	// SetDefaultFilter sets the filter which applies to clients not
+	// selecting any other filter
+	SetDefaultFilter(string) error
+
+	// AddClients selects the filter with the name for clients whose
+	// address or client subnet is within one of the prefixes and for DNS
+	// over HTTPS requests with one of the tokens
+	AddClients(string, []netip.Prefix, []string) error
+
+	// SetTrustedProxies sets the prefixes of proxies and forwarders whose
+	// EDNS Client Subnet options are used to select filters
+	SetTrustedProxies([]netip.Prefix)
+
+	// AddAllowRule adds the rule to the allowlist of the filter with the
+	// name. An empty name adds the rule to the global allowlist, which
+	// applies to all filters
+	AddAllowRule(string, string) error
 
-	GetFilter(*net.IP) (*Filter, error)
+	// RemoveAllowRule removes the rule from the allowlist of the filter
+	// with the name or from the global allowlist if the name is empty
+	RemoveAllowRule(string, string) error
 
-	Match(net.IP, dns.Message) (bool, dns.Message, error)
+	// AllowRules returns the rules of the allowlist of the filter with the
+	// name or of the global allowlist if the name is empty
+	AllowRules(string) ([]string, error)
+
+	// AddRewrite adds the rewrite rule to the filter with the name
+	AddRewrite(string, string) error
+
+	// RemoveRewrite removes the rewrite rule of the name from the filter
+	// with the name
+	RemoveRewrite(string, string) error
+
+	// SetSafeSearch enables the built-in safe search sets for the filter
+	// with the name and disables all others
+	SetSafeSearch(string, []string) error
+
+	// Disable disables filtering for all clients for the duration.
+	// Filtering is enabled again automatically afterwards
+	Disable(time.Duration)
+
+	// DisableClient disables filtering for the client with the address
+	// for the duration. Filtering is enabled again automatically
+	// afterwards
+	DisableClient(netip.Addr, time.Duration)
+
+	// Enable enables filtering again for all clients, including clients
+	// for which filtering was disabled individually
+	Enable()
+
+	// EnableClient enables filtering again for the client with the
+	// address. Filtering stays disabled if it is disabled for all clients
+	EnableClient(netip.Addr)
+
+	// DisabledUntil returns the time until which filtering is disabled for
+	// the client and if it is disabled at all
+	DisabledUntil(Client) (time.Time, bool)
+
+	// GetFilter returns the filter which applies to the client
+	GetFilter(Client) (*Filter, error)
+
+	// Match matches the message of the client against the filter which
+	// applies to the client
+	Match(Client, *dns.Message) (Result, error)
+
+	// MatchResponse matches the resolved answer of the message of the
+	// client against the response triggers of the policy zones and the
+	// rules of the filter which applies to the client, including CNAME
+	// targets and addresses. The records are the NS and glue records of
+	// the name servers which were consulted to resolve the answer
+	MatchResponse(Client, *dns.Message, []rr.RR) (Result, error)
 }
 
 type DefaultEngine struct {
-	ipToFilter map[*net.IP]int
-	filters    []Filter
-	logger     *logger.Logger
+	filters map[string]*Filter
+
+	// defaultFilter is the name of the filter which applies to all
+	// clients not selecting any other filter. This is the first
+	// registered filter unless set otherwise
+	defaultFilter string
+
+	// tokens maps DNS over HTTPS tokens to filter names
+	tokens map[string]string
+
+	// prefixes select filters by client address or subnet. Longer
+	// prefixes come first
+	prefixes []clientPrefix
+
+	// trustedProxies are allowed to select filters via the EDNS Client
+	// Subnet option
+	trustedProxies []netip.Prefix
+
+	// allowlist contains names which are never filtered by any filter
+	allowlist *Allowlist
+
+	// disabledUntil is the time until which filtering is disabled for all
+	// clients. disabledClients maps client addresses to the time until
+	// which filtering is disabled for them
+	disabledUntil   time.Time
+	disabledClients map[netip.Addr]time.Time
+
+	logger *logger.Logger
+	lock   sync.RWMutex
+}
+
+// clientPrefix selects the filter with the name for clients within the
+// prefix
+type clientPrefix struct {
+	prefix netip.Prefix
+	filter string
 }
 
 func NewDefaultEngine(l *logger.Logger) Engine {
 	return &DefaultEngine{
-		ipToFilter: make(map[*net.IP]int),
-		logger:     l,
+		filters:         make(map[string]*Filter),
+		tokens:          make(map[string]string),
+		allowlist:       NewAllowlist(),
+		disabledClients: make(map[netip.Addr]time.Time),
+		logger:          l,
 	}
 }
 
-func (e *DefaultEngine) AddFilter(f Filter) error {
-	// Code ...
+func (e *DefaultEngine) AddFilter(f *Filter) error {
+	if f == nil || f.Name == "" {
+		return ErrInvalidFilter
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.defaultFilter == "" {
+		e.defaultFilter = f.Name
+	}
+
+	e.filters[f.Name] = f
+	return nil
+}
+
+func (e *DefaultEngine) RemoveFilter(name string) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if _, ok := e.filters[name]; !ok {
+		return ErrNoSuchFilter
+	}
+
+	if name == e.defaultFilter {
+		return ErrInvalidFilter
+	}
+
+	delete(e.filters, name)
+
+	for token, filter := range e.tokens {
+		if filter == name {
+			delete(e.tokens, token)
+		}
+	}
+
+	var prefixes []clientPrefix
+	for _, p := range e.prefixes {
+		if p.filter != name {
+			prefixes = append(prefixes, p)
+		}
+	}
+	e.prefixes = prefixes
+
+	return nil
+}
+
+func (e *DefaultEngine) SetDefaultFilter(name string) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if _, ok := e.filters[name]; !ok {
+		return ErrNoSuchFilter
+	}
+
+	e.defaultFilter = name
 	return nil
 }
 
-func (e *DefaultEngine) GetFilter(*net.IP) (*Filter, error) {
+func (e *DefaultEngine) AddClients(name string, prefixes []netip.Prefix, tokens []string) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if _, ok := e.filters[name]; !ok {
+		return ErrNoSuchFilter
+	}
+
+	for _, prefix := range prefixes {
+		e.prefixes = append(e.prefixes, clientPrefix{prefix.Masked(), name})
+	}
+
+	sort.SliceStable(e.prefixes, func(i, j int) bool {
+		return e.prefixes[i].prefix.Bits() > e.prefixes[j].prefix.Bits()
+	})
+
+	for _, token := range tokens {
+		e.tokens[token] = name
+	}
+
+	return nil
+}
+
+func (e *DefaultEngine) SetTrustedProxies(prefixes []netip.Prefix) {
+	e.lock.Lock()
+	e.trustedProxies = prefixes
+	e.lock.Unlock()
+}
+
+func (e *DefaultEngine) AddAllowRule(name, rule string) error {
+	a, err := e.getAllowlist(name)
+	if err != nil {
+		return err
+	}
+
+	return a.Add(rule)
+}
+
+func (e *DefaultEngine) RemoveAllowRule(name, rule string) error {
+	a, err := e.getAllowlist(name)
+	if err != nil {
+		return err
+	}
+
+	return a.Remove(rule)
+}
+
+func (e *DefaultEngine) AllowRules(name string) ([]string, error) {
+	a, err := e.getAllowlist(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.Rules(), nil
+}
+
+// getAllowlist returns the allowlist of the filter with the name or the
+// global allowlist if the name is empty
+func (e *DefaultEngine) getAllowlist(name string) (*Allowlist, error) {
+	if name == "" {
+		return e.allowlist, nil
+	}
+
+	f, err := e.getFilter(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.Allowlist, nil
+}
+
+func (e *DefaultEngine) AddRewrite(name, rule string) error {
+	f, err := e.getFilter(name)
+	if err != nil {
+		return err
+	}
+
+	return f.AddRewrite(rule)
+}
+
+func (e *DefaultEngine) RemoveRewrite(name, rewrite string) error {
+	f, err := e.getFilter(name)
+	if err != nil {
+		return err
+	}
+
+	return f.RemoveRewrite(rewrite)
+}
+
+func (e *DefaultEngine) SetSafeSearch(name string, sets []string) error {
+	f, err := e.getFilter(name)
+	if err != nil {
+		return err
+	}
+
+	return f.SetSafeSearch(sets)
+}
+
+// getFilter returns the filter with the name
+func (e *DefaultEngine) getFilter(name string) (*Filter, error) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	f, ok := e.filters[name]
+	if !ok {
+		return nil, ErrNoSuchFilter
+	}
+
+	return f, nil
+}
+
+func (e *DefaultEngine) Disable(d time.Duration) {
+	e.lock.Lock()
+	e.disabledUntil = time.Now().Add(d)
+	e.lock.Unlock()
+
+	e.logger.Info("disabled filtering",
+		zap.String("context", "filter"),
+		zap.Duration("duration", d),
+	)
+}
+
+func (e *DefaultEngine) DisableClient(addr netip.Addr, d time.Duration) {
+	e.lock.Lock()
+	e.disabledClients[addr.Unmap()] = time.Now().Add(d)
+	e.lock.Unlock()
+
+	e.logger.Info("disabled filtering",
+		zap.String("context", "filter"),
+		zap.String("address", addr.Unmap().String()),
+		zap.Duration("duration", d),
+	)
+}
+
+func (e *DefaultEngine) Enable() {
+	e.lock.Lock()
+	e.disabledUntil = time.Time{}
+	e.disabledClients = make(map[netip.Addr]time.Time)
+	e.lock.Unlock()
+}
+
+func (e *DefaultEngine) EnableClient(addr netip.Addr) {
+	e.lock.Lock()
+	delete(e.disabledClients, addr.Unmap())
+	e.lock.Unlock()
+}
+
+// DisabledUntil returns the later of the times until which filtering is
+// disabled for all clients and for the address of the client. Expired
+// entries are removed
+func (e *DefaultEngine) DisabledUntil(client Client) (time.Time, bool) {
+	var (
+		addr = client.Addr.Unmap()
+		now  = time.Now()
+	)
+
+	e.lock.RLock()
+	until, ok := e.disabledClients[addr]
+	global := e.disabledUntil
+	e.lock.RUnlock()
+
+	if ok && !now.Before(until) {
+		e.lock.Lock()
+		if e.disabledClients[addr] == until {
+			delete(e.disabledClients, addr)
+		}
+		e.lock.Unlock()
+	}
+
+	if global.After(until) {
+		until = global
+	}
+
+	if !now.Before(until) {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// GetFilter returns the filter which applies to the client. The token
+// selects the filter first. The client subnet is used instead of the
+// address if the query was sent by a trusted proxy. The filter with the
+// longest prefix containing the address or subnet applies. Filters whose
+// schedule is not active are skipped. All other clients get the default
+// filter
+func (e *DefaultEngine) GetFilter(client Client) (*Filter, error) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	now := time.Now()
+
+	if name, ok := e.tokens[client.Token]; ok && client.Token != "" {
+		if f := e.filters[name]; f.Schedule.Active(now) {
+			return f, nil
+		}
+	}
+
+	addr := client.Addr.Unmap()
+	subnet, _ := addr.Prefix(addr.BitLen())
+	if client.Subnet.IsValid() && e.isTrustedProxy(addr) {
+		subnet = client.Subnet
+	}
+
+	for _, p := range e.prefixes {
+		if p.prefix.Bits() <= subnet.Bits() && p.prefix.Contains(subnet.Addr()) {
+			if f := e.filters[p.filter]; f.Schedule.Active(now) {
+				return f, nil
+			}
+		}
+	}
+
+	if f, ok := e.filters[e.defaultFilter]; ok && f.Schedule.Active(now) {
+		return f, nil
+	}
 	return nil, ErrNoSuchFilter
 }
 
-func (e *DefaultEngine) Match(ip net.IP, message dns.Message) (bool, dns.Message, error) {
-	filter, err := e.GetFilter(&ip)
+// isTrustedProxy returns if the address belongs to a trusted proxy. The
+// read lock needs to be held
+func (e *DefaultEngine) isTrustedProxy(addr netip.Addr) bool {
+	for _, prefix := range e.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match matches the message of the client against the filter which applies
+// to the client. Messages of clients for which filtering is disabled and
+// names on the global allowlist are never filtered
+func (e *DefaultEngine) Match(client Client, message *dns.Message) (Result, error) {
+	if len(message.Question) == 0 {
+		return Result{}, ErrMissingQuestion
+	}
+
+	if e.bypass(client, message) {
+		return Result{}, nil
+	}
+
+	filter, err := e.GetFilter(client)
 	if err != nil {
 		e.logger.Debug(logger.DebugNoSuchFilter,
 			zap.String("context", "filter"),
+			zap.String("address", client.Addr.String()),
 			zap.Error(err),
 		)
-		return false, message, nil
+		return Result{}, nil
 	}
 
 	return filter.Match(message)
 }
+
+func (e *DefaultEngine) MatchResponse(client Client, message *dns.Message, servers []rr.RR) (Result, error) {
+	if len(message.Question) == 0 {
+		return Result{}, ErrMissingQuestion
+	}
+
+	if e.bypass(client, message) {
+		return Result{}, nil
+	}
+
+	filter, err := e.GetFilter(client)
+	if err != nil {
+		return Result{}, nil
+	}
+
+	return filter.matchResponse(message, servers, e.allowlist)
+}
+
+// bypass returns if filtering is disabled for the client or the question
+// name of the message is on the global allowlist
+func (e *DefaultEngine) bypass(client Client, message *dns.Message) bool {
+	if _, disabled := e.DisabledUntil(client); disabled {
+		return true
+	}
+
+	return e.allowlist.Allows(strings.ToLower(labels.Rootify(message.Question[0].Name)))
+}
@@ -2,8 +2,11 @@ package filter
 
 import (
 	"errors"
-	"net"
+	"net/netip"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/go-void/portal/pkg/labels"
 	"github.com/go-void/portal/pkg/types/dns"
@@ -17,8 +20,10 @@ var (
 	ErrInvalidIPAddress    = errors.New("filter: invalid ip address")
 	ErrInvalidName         = errors.New("filter: invalid name")
 	ErrNoSuchRule          = errors.New("filter: no such rule")
+	ErrNoSuchPolicyZone    = errors.New("filter: no such policy zone")
 
-	defaultFilterIP = net.IPv4(0, 0, 0, 0)
+	defaultFilterIP   = netip.IPv4Unspecified()
+	defaultFilterIPv6 = netip.IPv6Unspecified()
 )
 
 type RuleType int
@@ -26,9 +31,14 @@ type RuleType int
 const (
 	DomainRule RuleType = iota
 	RPZRule
+	HostsRule
+	AdblockRule
 )
 
 type Filter struct {
+	// Name identifies the filter, e.g. in collector entries
+	Name string
+
 	// FilterMode defines how the filter should answer a filtered request
 	FilterMode FilterMode
 
@@ -36,112 +46,503 @@ type Filter struct {
 	TTL int
 
 	// Address defines the IP address of this DNS server
-	Address net.IP
+	Address netip.Addr
+
+	// BloomFilter enables a Bloom filter in front of the name rules of
+	// each source. Names without any name rule in a source skip all of
+	// its name rules after one probe, at the cost of about 10 bits of
+	// memory per rule
+	BloomFilter bool
+
+	// Allowlist contains names which are never filtered by the filter
+	Allowlist *Allowlist
+
+	// Schedule restricts when the filter applies to the clients selecting
+	// it. Outside of the schedule these clients get the default filter. It
+	// needs to be set before the filter is added to an engine
+	Schedule *Schedule
+
+	// sources maps the name of a rule source, e.g. a list, to the index
+	// of its rules. Rules added via AddRule belong to the source ""
+	sources map[string]*sourceIndex
+	manual  []Rule
+
+	// schedules maps the names of sources to the schedules restricting
+	// when their rules apply
+	schedules map[string]*Schedule
+
+	// index combines the indices of all sources and is swapped
+	// atomically whenever a source changes (*index)
+	index atomic.Value
+
+	// policies are the policy zones of the filter in order of precedence
+	// ([]*PolicyZone). The slice is replaced whenever a zone changes
+	policies atomic.Value
+
+	// rewrites indexes the rewrite rules of the enabled safe search sets
+	// and the custom rewrite rules (*rewriteIndex). It is replaced
+	// whenever they change
+	rewrites       atomic.Value
+	customRewrites []Rewrite
+	safeSearch     []string
 
-	// Rules stores a map of rules
-	Rules map[string]net.IP
+	// updateLock serializes changes of the sources and policy zones
+	updateLock sync.Mutex
 }
 
-func (f *Filter) Match(message dns.Message) (bool, dns.Message, error) {
-	var question = message.Question[0]
+// New returns a new filter without any rules
+func New(name string, mode FilterMode, ttl int, address netip.Addr) *Filter {
+	f := &Filter{
+		Name:       name,
+		FilterMode: mode,
+		TTL:        ttl,
+		Address:    address,
+		Allowlist:  NewAllowlist(),
+		sources:    make(map[string]*sourceIndex),
+		schedules:  make(map[string]*Schedule),
+	}
+	f.index.Store(&index{})
+	f.policies.Store([]*PolicyZone{})
+	f.rewrites.Store(newRewriteIndex(nil))
 
-	if ip, ok := f.Rules[question.Name]; ok {
-		switch f.FilterMode {
-		case NxDomainMode:
-			message.Header.RCode = rcode.NameError
-			return true, message, nil
-		case LocalIPMode:
-			answer, err := rr.New(question.Type)
-			if err != nil {
-				return true, message, err
-			}
+	return f
+}
 
-			err = answer.SetData(f.Address)
-			if err != nil {
-				return true, message, err
-			}
+// Match matches the question of the message against the rewrite rules, the
+// QNAME triggers of the policy zones and the rules of the filter. Names on
+// the allowlist are never filtered. Rewrite rules answer with their target
+// or addresses and take precedence over all other rules. Policy zones are checked
+// first in order of precedence. If a policy zone matches, its action is
+// applied. Otherwise, if a rule matches, the message is answered according
+// to the filter mode. Exception rules allow names matched by block rules,
+// unless the block rule is marked important. Questions for other types
+// than A and AAAA or for addresses of the other family are answered with
+// NODATA
+func (f *Filter) Match(message *dns.Message) (Result, error) {
+	var (
+		question = message.Question[0]
+		name     = strings.ToLower(labels.Rootify(question.Name))
+	)
 
-			answer.SetHeader(rr.Header{
-				Name:     question.Name,
-				Type:     question.Type,
-				Class:    question.Class,
-				TTL:      uint32(f.TTL),
-				RDLength: answer.Len(),
-			})
-
-			message.AddAnswer(answer)
-			return true, message, nil
-		case NoDataMode:
-			return true, message, nil
-		case NullMode:
-			answer, err := rr.New(question.Type)
-			if err != nil {
-				return true, message, err
-			}
+	if f.Allowlist.Allows(name) {
+		return Result{}, nil
+	}
 
-			err = answer.SetData(ip)
-			if err != nil {
-				return true, message, err
-			}
+	if rewrite := f.rewrites.Load().(*rewriteIndex).match(name); rewrite != nil {
+		return f.rewrite(message, rewrite)
+	}
+
+	for _, z := range f.policyZones() {
+		if policy := z.matchQName(name); policy != nil {
+			return f.applyPolicy(message, z, policy), nil
+		}
+	}
+
+	rule, schedule, ok := f.index.Load().(*index).lookup(name)
+	if !ok {
+		return Result{}, nil
+	}
+
+	return f.block(message, rule, schedule)
+}
+
+// block answers the question of the message according to the filter mode
+// because the rule of the source with the schedule matched
+func (f *Filter) block(message *dns.Message, rule Rule, schedule *Schedule) (Result, error) {
+	question := message.Question[0]
+
+	result := Result{
+		Filtered: true,
+		Filter:   f.Name,
+		Schedule: f.activeSchedule(schedule),
+	}
+
+	// SVCB and HTTPS records can't carry the filter IP address.
+	// Answering with NODATA prevents clients from learning
+	// alternative endpoints or ECH configs of blocked names
+	if isServiceType(question.Type) && f.FilterMode != NxDomainMode {
+		return result, nil
+	}
+
+	switch f.FilterMode {
+	case NxDomainMode:
+		message.Header.RCode = rcode.NameError
+		return result, nil
+	case LocalIPMode:
+		return result, f.answer(message, question, f.Address)
+	case NoDataMode:
+		return result, nil
+	case NullMode:
+		// The unspecified address of the rule is used for both families
+		ip := rule.Address
+		if ip.IsUnspecified() && question.Type == rr.TypeAAAA {
+			ip = defaultFilterIPv6
+		}
+		return result, f.answer(message, question, ip)
+	}
+
+	return Result{}, nil
+}
+
+// MatchResponse matches the resolved answer of the message against the
+// response triggers of the policy zones: addresses in the answer (RPZ-IP),
+// the names of the name servers (NSDNAME) and their addresses (NSIP). Each
+// zone is checked for all triggers before the next zone. A QNAME trigger
+// which passes the query through stops the search, just like names on the
+// allowlist. If a trigger matches, the action of its rule replaces the
+// answer.
+//
+// Afterwards the answer is matched against the rules of the filter: every
+// owner name and CNAME target, which uncovers names cloaked behind
+// CNAME records, and the addresses of A and AAAA records, which are matched
+// against IP rules. If a rule matches, the answer is replaced according to
+// the filter mode
+func (f *Filter) MatchResponse(message *dns.Message, servers []rr.RR) (Result, error) {
+	return f.matchResponse(message, servers, nil)
+}
 
-			answer.SetHeader(rr.Header{
-				Name:     question.Name,
-				Type:     question.Type,
-				Class:    question.Class,
-				TTL:      uint32(f.TTL),
-				RDLength: answer.Len(),
-			})
+// matchResponse matches the resolved answer like MatchResponse. Names on the
+// additional allowlist are never filtered as well
+func (f *Filter) matchResponse(message *dns.Message, servers []rr.RR, allowlist *Allowlist) (Result, error) {
+	name := strings.ToLower(labels.Rootify(message.Question[0].Name))
+	if f.Allowlist.Allows(name) {
+		return Result{}, nil
+	}
 
-			message.AddAnswer(answer)
-			return true, message, nil
+	for _, z := range f.policyZones() {
+		if policy := z.matchQName(name); policy != nil {
+			return f.applyPolicy(message, z, policy), nil
+		}
+
+		if policy := z.matchResponse(message.Answer, servers); policy != nil {
+			return f.applyPolicy(message, z, policy), nil
 		}
 	}
 
-	return false, message, nil
+	rule, schedule, ok := f.matchAnswer(message.Answer, name, allowlist)
+	if !ok {
+		return Result{}, nil
+	}
+
+	message.ClearRecords()
+	message.Header.RCode = rcode.NoError
+
+	return f.block(message, rule, schedule)
 }
 
-// ParseRule parses a filter rule with the following format: '<ip-address> <domain>'.
-// Example: '0.0.0.0 example.com'
-func (f *Filter) ParseRule(t RuleType, input string) (string, net.IP, error) {
-	// FIXME (Techassi): Actually check rule type
-	parts := strings.Split(input, " ")
-	switch len(parts) {
-	case 1:
-		return parts[0], defaultFilterIP, nil
-	case 2:
-		ip := net.ParseIP(parts[0])
-		if ip == nil {
-			return "", nil, ErrInvalidIPAddress
+// matchAnswer returns the first rule which matches a name or address of the
+// answer and the schedule of its source. The question name was already
+// matched before the query was resolved and is skipped. Allowed names don't
+// match, but the records they point to still do
+func (f *Filter) matchAnswer(answer []rr.RR, question string, allowlist *Allowlist) (Rule, *Schedule, bool) {
+	var (
+		idx     = f.index.Load().(*index)
+		checked = map[string]bool{question: true}
+	)
+
+	matchName := func(name string) (Rule, *Schedule, bool) {
+		name = strings.ToLower(labels.Rootify(name))
+		if checked[name] {
+			return Rule{}, nil, false
+		}
+		checked[name] = true
+
+		if f.Allowlist.Allows(name) || allowlist.Allows(name) {
+			return Rule{}, nil, false
 		}
+		return idx.lookup(name)
+	}
+
+	for _, record := range answer {
+		if rule, schedule, ok := matchName(record.Header().Name); ok {
+			return rule, schedule, true
+		}
+
+		var (
+			target string
+			addr   netip.Addr
+		)
 
-		if !labels.IsValid(parts[1]) {
-			return "", nil, ErrInvalidName
+		switch r := record.(type) {
+		case *rr.CNAME:
+			target = r.Target
+		case *rr.A:
+			addr = r.Address
+		case *rr.AAAA:
+			addr = r.Address
 		}
 
-		return parts[1], ip, nil
+		if target != "" {
+			if rule, schedule, ok := matchName(target); ok {
+				return rule, schedule, true
+			}
+		}
+
+		if addr.IsValid() {
+			if rule, schedule, ok := idx.matchIP(addr); ok {
+				return rule, schedule, true
+			}
+		}
+	}
+
+	return Rule{}, nil, false
+}
+
+// applyPolicy applies the action of the rule of the policy zone to the
+// message
+func (f *Filter) applyPolicy(message *dns.Message, z *PolicyZone, policy *Policy) Result {
+	result := Result{
+		Filtered:   true,
+		Filter:     f.Name,
+		PolicyZone: z.Name,
+		Schedule:   f.activeSchedule(nil),
+	}
+
+	switch policy.Action {
+	case PolicyPassthru:
+		return Result{}
+	case PolicyDrop:
+		result.Action = ActionDrop
+	case PolicyTCPOnly:
+		result.Action = ActionTCPOnly
+	case PolicyNXDomain:
+		message.ClearRecords()
+		message.Header.RCode = rcode.NameError
+	case PolicyNoData:
+		message.ClearRecords()
+		message.Header.RCode = rcode.NoError
+	case PolicyLocalData:
+		message.ClearRecords()
+		message.Header.RCode = rcode.NoError
+		result.Target = policy.answer(message, message.Question[0])
+	}
+
+	return result
+}
+
+// activeSchedule returns the name of the schedule of the source which
+// filtered the message or the name of the schedule of the filter. The
+// schedule of the source is more specific
+func (f *Filter) activeSchedule(source *Schedule) string {
+	switch {
+	case source != nil:
+		return source.Name
+	case f.Schedule != nil:
+		return f.Schedule.Name
+	}
+	return ""
+}
+
+// answer adds an A or AAAA record with the address to the message. Nothing
+// is added if the question type doesn't match the address family
+func (f *Filter) answer(message *dns.Message, question dns.Question, addr netip.Addr) error {
+	switch {
+	case question.Type == rr.TypeA && addr.Is4():
+	case question.Type == rr.TypeAAAA && addr.Is6():
 	default:
-		return "", nil, ErrInvalidDomainRule
+		return nil
 	}
+
+	answer, err := rr.New(question.Type)
+	if err != nil {
+		return err
+	}
+
+	err = answer.SetData(addr)
+	if err != nil {
+		return err
+	}
+
+	answer.SetHeader(rr.Header{
+		Name:     question.Name,
+		Type:     question.Type,
+		Class:    question.Class,
+		TTL:      uint32(f.TTL),
+		RDLength: answer.Len(),
+	})
+
+	message.AddAnswer(answer)
+	return nil
 }
 
+// isServiceType returns if t is a service binding type (SVCB or HTTPS)
+func isServiceType(t uint16) bool {
+	return t == rr.TypeSVCB || t == rr.TypeHTTPS
+}
+
+// AddRule parses a single rule of the rule type and adds it to the rules
+// of the filter
 func (f *Filter) AddRule(t RuleType, rule string) error {
-	domain, ip, err := f.ParseRule(t, rule)
+	rules, err := ParseRule(t, rule)
 	if err != nil {
 		return err
 	}
 
-	domain = labels.Rootify(domain)
-	f.Rules[domain] = ip
+	f.updateLock.Lock()
+	defer f.updateLock.Unlock()
+
+	f.manual = append(f.manual, rules...)
+	f.update("", newSourceIndex(f.manual, f.BloomFilter))
 
 	return nil
 }
 
+// RemoveRule removes all rules for the domain which were added via AddRule
 func (f *Filter) RemoveRule(domain string) error {
-	if _, ok := f.Rules[domain]; ok {
-		delete(f.Rules, domain)
-		return nil
+	domain = strings.ToLower(labels.Rootify(domain))
+
+	f.updateLock.Lock()
+	defer f.updateLock.Unlock()
+
+	var rules []Rule
+	for _, rule := range f.manual {
+		if rule.Name != domain {
+			rules = append(rules, rule)
+		}
+	}
+
+	if len(rules) == len(f.manual) {
+		return ErrNoSuchRule
+	}
+
+	f.manual = rules
+	f.update("", newSourceIndex(f.manual, f.BloomFilter))
+
+	return nil
+}
+
+// SetRules replaces the rules of the source, e.g. when a list was reloaded.
+// The rules are indexed before any lock is taken
+func (f *Filter) SetRules(source string, rules []Rule) {
+	setRules([]*Filter{f}, source, rules)
+}
+
+// setRules replaces the rules of the source in all filters. The index of
+// the rules is built once and shared by filters with the same Bloom filter
+// setting
+func setRules(filters []*Filter, source string, rules []Rule) {
+	indices := make(map[bool]*sourceIndex)
+
+	for _, f := range filters {
+		s, ok := indices[f.BloomFilter]
+		if !ok {
+			s = newSourceIndex(rules, f.BloomFilter)
+			indices[f.BloomFilter] = s
+		}
+
+		f.updateLock.Lock()
+		f.update(source, s)
+		f.updateLock.Unlock()
+	}
+}
+
+// RemoveRules removes all rules of the source
+func (f *Filter) RemoveRules(source string) {
+	f.updateLock.Lock()
+	defer f.updateLock.Unlock()
+
+	f.update(source, nil)
+}
+
+// update replaces the index of the source, a nil index removes the source.
+// The combined index of all sources replaces the current index at once, so
+// that matching continues with the current index until then. The caller
+// needs to hold the update lock
+func (f *Filter) update(source string, s *sourceIndex) {
+	if s == nil {
+		delete(f.sources, source)
+	} else {
+		f.sources[source] = s
 	}
-	return ErrNoSuchRule
+
+	names := make([]string, 0, len(f.sources))
+	for name := range f.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	combined := &index{}
+	for _, name := range names {
+		combined.sources = append(combined.sources, f.sources[name])
+		combined.withBloom = combined.withBloom || f.sources[name].hasBloom()
+	}
+
+	if len(f.schedules) > 0 {
+		combined.schedules = make([]*Schedule, len(names))
+		for j, name := range names {
+			combined.schedules[j] = f.schedules[name]
+		}
+	}
+
+	f.index.Store(combined)
+}
+
+// SetSchedule restricts the rules of the source to the times of the
+// schedule. A nil schedule applies the rules at all times again
+func (f *Filter) SetSchedule(source string, s *Schedule) {
+	f.updateLock.Lock()
+	defer f.updateLock.Unlock()
+
+	if s == nil {
+		delete(f.schedules, source)
+	} else {
+		f.schedules[source] = s
+	}
+
+	f.update(source, f.sources[source])
+}
+
+// SetPolicyZone replaces the policy zone with the same name, e.g. when the
+// zone was reloaded. New zones take precedence after all existing zones
+func (f *Filter) SetPolicyZone(z *PolicyZone) {
+	f.updateLock.Lock()
+	defer f.updateLock.Unlock()
+
+	var (
+		zones    = f.policyZones()
+		policies = make([]*PolicyZone, 0, len(zones)+1)
+		replaced = false
+	)
+
+	for _, existing := range zones {
+		if existing.Name == z.Name {
+			existing, replaced = z, true
+		}
+		policies = append(policies, existing)
+	}
+
+	if !replaced {
+		policies = append(policies, z)
+	}
+
+	f.policies.Store(policies)
+}
+
+// RemovePolicyZone removes the policy zone with the name
+func (f *Filter) RemovePolicyZone(name string) error {
+	name = strings.ToLower(labels.Rootify(name))
+
+	f.updateLock.Lock()
+	defer f.updateLock.Unlock()
+
+	var policies []*PolicyZone
+	for _, z := range f.policyZones() {
+		if z.Name != name {
+			policies = append(policies, z)
+		}
+	}
+
+	if len(policies) == len(f.policyZones()) {
+		return ErrNoSuchPolicyZone
+	}
+
+	f.policies.Store(policies)
+	return nil
+}
+
+// policyZones returns the policy zones in order of precedence
+func (f *Filter) policyZones() []*PolicyZone {
+	return f.policies.Load().([]*PolicyZone)
 }
 
 func (f *Filter) Mode() FilterMode {
@@ -0,0 +1,409 @@
+package filter
+
+import (
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-void/portal/pkg/tree"
+)
+
+// sourceIndex stores the rules of a single source, e.g. a list. Name rules
+// are stored in compact sets, optionally behind Bloom filters of the exact
+// and the subdomain names, so that names without a name rule skip the sets
+// after one probe. Glob rules are stored in a label tree at the
+// node of their labels without wildcards, so that only globs of ancestors
+// of a name are checked. Regular expressions are checked one after
+// another. IP rules are stored by their prefix, so that an address is
+// looked up once per prefix length. The index is not modified after it was
+// built
+type sourceIndex struct {
+	block           *compactSet
+	blockSubdomains *compactSet
+	allow           *compactSet
+	allowSubdomains *compactSet
+
+	// names and subdomains are the Bloom filters of the names of the
+	// exact and subdomain sets. They are nil if disabled
+	names      *bloom
+	subdomains *bloom
+
+	globs   *tree.Tree
+	regexps []*Rule
+
+	ips map[netip.Prefix][]*Rule
+
+	// ip4Bits and ip6Bits are the distinct prefix lengths of the IPv4 and
+	// IPv6 rules, longest first
+	ip4Bits []int
+	ip6Bits []int
+}
+
+// newSourceIndex builds the index of the rules of a source. Duplicate rules
+// keep the address of the first rule and are important if any of the
+// rules is
+func newSourceIndex(rules []Rule, withBloom bool) *sourceIndex {
+	var (
+		s     = &sourceIndex{}
+		names [4][]*Rule
+		seen  = make(map[ruleKey]*Rule)
+	)
+
+	for i := range rules {
+		rule := &rules[i]
+
+		switch {
+		case rule.Regexp != nil, rule.isGlob(), rule.isIP():
+			if existing, ok := seen[rule.key()]; ok {
+				existing.Important = existing.Important || rule.Important
+				continue
+			}
+
+			rule := *rule
+			seen[rule.key()] = &rule
+
+			switch {
+			case rule.isIP():
+				s.addIP(&rule)
+			case rule.Regexp != nil:
+				s.regexps = append(s.regexps, &rule)
+			default:
+				s.addGlob(&rule)
+			}
+		case rule.Allow && rule.Subdomains:
+			names[3] = append(names[3], rule)
+		case rule.Allow:
+			names[2] = append(names[2], rule)
+		case rule.Subdomains:
+			names[1] = append(names[1], rule)
+		default:
+			names[0] = append(names[0], rule)
+		}
+	}
+
+	s.block = newCompactSet(names[0])
+	s.blockSubdomains = newCompactSet(names[1])
+	s.allow = newCompactSet(names[2])
+	s.allowSubdomains = newCompactSet(names[3])
+
+	if withBloom {
+		s.names = newSetBloom(s.block, s.allow)
+		s.subdomains = newSetBloom(s.blockSubdomains, s.allowSubdomains)
+	}
+
+	return s
+}
+
+// mayHaveName reports if the exact name sets might contain the reversed
+// name with the Bloom filter hash h
+func (s *sourceIndex) mayHaveName(h uint64) bool {
+	if s.block.len() == 0 && s.allow.len() == 0 {
+		return false
+	}
+	return s.names == nil || s.names.has(h)
+}
+
+// mayHaveSubdomain reports if the subdomain sets might contain the reversed
+// name with the Bloom filter hash h
+func (s *sourceIndex) mayHaveSubdomain(h uint64) bool {
+	if s.blockSubdomains.len() == 0 && s.allowSubdomains.len() == 0 {
+		return false
+	}
+	return s.subdomains == nil || s.subdomains.has(h)
+}
+
+// hasBloom returns if the source has Bloom filters
+func (s *sourceIndex) hasBloom() bool {
+	return s.names != nil
+}
+
+func (s *sourceIndex) addGlob(rule *Rule) {
+	if s.globs == nil {
+		s.globs = tree.New()
+	}
+
+	node, err := s.globs.Populate(literalSuffix(rule.Name))
+	if err != nil {
+		return
+	}
+
+	globs, _ := node.Value().([]*Rule)
+	node.SetValue(append(globs, rule))
+}
+
+func (s *sourceIndex) addIP(rule *Rule) {
+	if s.ips == nil {
+		s.ips = make(map[netip.Prefix][]*Rule)
+	}
+
+	lengths := &s.ip4Bits
+	if rule.Prefix.Addr().Is6() {
+		lengths = &s.ip6Bits
+	}
+
+	bits := rule.Prefix.Bits()
+	i := sort.Search(len(*lengths), func(i int) bool { return (*lengths)[i] <= bits })
+	if i == len(*lengths) || (*lengths)[i] != bits {
+		*lengths = append(*lengths, 0)
+		copy((*lengths)[i+1:], (*lengths)[i:])
+		(*lengths)[i] = bits
+	}
+
+	s.ips[rule.Prefix] = append(s.ips[rule.Prefix], rule)
+}
+
+// ipBits returns the prefix lengths of the IP rules of the address family
+func (s *sourceIndex) ipBits(addr netip.Addr) []int {
+	if addr.Is4() {
+		return s.ip4Bits
+	}
+	return s.ip6Bits
+}
+
+// index combines the indices of all sources of a filter. Sources are
+// ordered by name. Sources with a schedule are only checked while their
+// schedule is active
+type index struct {
+	sources []*sourceIndex
+
+	// schedules are the schedules of the sources at the same position. It
+	// is nil if no source has a schedule
+	schedules []*Schedule
+
+	// withBloom is true if any source has a Bloom filter
+	withBloom bool
+}
+
+// match returns the block rule which matches the lowercase, fully qualified
+// name. See lookup for the precedence of rules
+func (i *index) match(name string) (Rule, bool) {
+	rule, _, ok := i.lookup(name)
+	return rule, ok
+}
+
+// lookup returns the block rule which matches the lowercase, fully
+// qualified name and the schedule of its source. The precedence is:
+//
+//  1. Important block rules
+//  2. Exception rules
+//  3. Block rules
+//
+// If multiple block rules match, the most specific one is returned: exact
+// rules before subdomain rules of the closest ancestor, before glob rules
+// and regular expressions. Rules of the same kind keep the order of their
+// sources
+func (i *index) lookup(name string) (Rule, *Schedule, bool) {
+	var (
+		matched  Rule
+		schedule *Schedule
+		found    bool
+		allowed  bool
+
+		// matchedKey is the reversed name of matched rules of compact
+		// sets, which don't carry the name
+		matchedKey []byte
+	)
+
+	// Schedules are only evaluated if any source has one, so that
+	// filters without schedules don't pay for them
+	var inactive []bool
+	if i.schedules != nil {
+		inactive = i.inactive(time.Now())
+	}
+
+	// active reports if the source at the position is active
+	active := func(j int) bool {
+		return inactive == nil || !inactive[j]
+	}
+
+	// check reports if the search is over, which is the case when an
+	// important block rule matched. j is the position of the source
+	check := func(rule Rule, ok bool, key []byte, j int) bool {
+		switch {
+		case !ok:
+		case rule.Allow:
+			allowed = true
+		case rule.Important:
+			matched, found, matchedKey = rule, true, key
+			schedule = i.schedule(j)
+			return true
+		case !found:
+			matched, found, matchedKey = rule, true, key
+			schedule = i.schedule(j)
+		}
+		return false
+	}
+
+	// result fills in the name of the matched rule
+	result := func() (Rule, *Schedule, bool) {
+		if matchedKey != nil {
+			matched.Name = ruleName(matchedKey)
+		}
+		return matched, schedule, true
+	}
+
+	// Names are at most 255 octets long, so the reversed name fits into
+	// the buffer on the stack
+	var buf [256]byte
+	key := reverseName(buf[:0], name)
+
+	// The hash of a key is computed once and shared by the Bloom filters
+	// of all sources
+	hash := i.bloomHash(key)
+
+	for j, s := range i.sources {
+		if !active(j) || !s.mayHaveName(hash) {
+			continue
+		}
+
+		rule, ok := s.block.get(key)
+		if check(rule, ok, key, j) {
+			return result()
+		}
+
+		rule, ok = s.allow.get(key)
+		rule.Allow = true
+		check(rule, ok, nil, j)
+	}
+
+	for end := len(key); end > 0; end = lastDot(key[:end]) {
+		if end < len(key) {
+			hash = i.bloomHash(key[:end])
+		}
+
+		for j, s := range i.sources {
+			if !active(j) || !s.mayHaveSubdomain(hash) {
+				continue
+			}
+
+			rule, ok := s.blockSubdomains.get(key[:end])
+			rule.Subdomains = true
+			if check(rule, ok, key[:end], j) {
+				return result()
+			}
+
+			rule, ok = s.allowSubdomains.get(key[:end])
+			rule.Allow = true
+			check(rule, ok, nil, j)
+		}
+	}
+
+	for j, s := range i.sources {
+		if s.globs == nil || !active(j) {
+			continue
+		}
+
+		nodes, _ := s.globs.WalkChain(name)
+		for d := len(nodes) - 1; d >= 0; d-- {
+			globs, _ := nodes[d].Value().([]*Rule)
+			for _, rule := range globs {
+				if check(*rule, matchGlob(rule, name), nil, j) {
+					return result()
+				}
+			}
+		}
+	}
+
+	trimmed := strings.TrimSuffix(name, ".")
+	for j, s := range i.sources {
+		if !active(j) {
+			continue
+		}
+
+		for _, rule := range s.regexps {
+			if check(*rule, rule.Regexp.MatchString(trimmed), nil, j) {
+				return result()
+			}
+		}
+	}
+
+	if !found || allowed {
+		return Rule{}, nil, false
+	}
+
+	return result()
+}
+
+// matchIP returns the IP rule which matches the address and the schedule of
+// its source. The precedence is the same as for names. If multiple block
+// rules match, the rule with the longest prefix is returned
+func (i *index) matchIP(addr netip.Addr) (Rule, *Schedule, bool) {
+	var (
+		matched  *Rule
+		schedule *Schedule
+		bits     = -1
+		allowed  bool
+	)
+
+	addr = addr.Unmap()
+
+	var inactive []bool
+	if i.schedules != nil {
+		inactive = i.inactive(time.Now())
+	}
+
+	for j, s := range i.sources {
+		if s.ips == nil || inactive != nil && inactive[j] {
+			continue
+		}
+
+		for _, b := range s.ipBits(addr) {
+			prefix, _ := addr.Prefix(b)
+			for _, rule := range s.ips[prefix] {
+				switch {
+				case rule.Allow:
+					allowed = true
+				case rule.Important:
+					return *rule, i.schedule(j), true
+				case b > bits:
+					matched, schedule, bits = rule, i.schedule(j), b
+				}
+			}
+		}
+	}
+
+	if matched == nil || allowed {
+		return Rule{}, nil, false
+	}
+
+	return *matched, schedule, true
+}
+
+// bloomHash returns the Bloom filter hash of the reversed name if any
+// source has a Bloom filter
+func (i *index) bloomHash(key []byte) uint64 {
+	if !i.withBloom {
+		return 0
+	}
+	return bloomHash(key)
+}
+
+// inactive returns which sources have a schedule which is not active at the
+// time
+func (i *index) inactive(t time.Time) []bool {
+	inactive := make([]bool, len(i.sources))
+	for j, s := range i.schedules {
+		inactive[j] = !s.Active(t)
+	}
+	return inactive
+}
+
+// schedule returns the schedule of the source at the position or nil
+func (i *index) schedule(j int) *Schedule {
+	if i.schedules == nil {
+		return nil
+	}
+	return i.schedules[j]
+}
+
+// lastDot returns the index of the last label separator of the reversed
+// name or 0 if there is none
+func lastDot(key []byte) int {
+	for i := len(key) - 1; i > 0; i-- {
+		if key[i] == '.' {
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,144 @@
+package filter
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestIndexLookup(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// sources contains the Adblock lines of each source
+		sources [][]string
+		query   string
+
+		// match is the name of the matching rule or empty if the query
+		// isn't filtered
+		match string
+	}{
+		{
+			name:    "exact",
+			sources: [][]string{{"|example.com^"}},
+			query:   "example.com.",
+			match:   "example.com.",
+		},
+		{
+			name:    "exact doesn't match subdomains",
+			sources: [][]string{{"|example.com^"}},
+			query:   "www.example.com.",
+		},
+		{
+			name:    "subdomain matches the name itself",
+			sources: [][]string{{"||example.com^"}},
+			query:   "example.com.",
+			match:   "example.com.",
+		},
+		{
+			name:    "no match",
+			sources: [][]string{{"||example.com^", "/^ads/", "*.example.org"}},
+			query:   "example.net.",
+		},
+		{
+			name:    "exact before subdomain",
+			sources: [][]string{{"||example.com^", "|www.example.com^"}},
+			query:   "www.example.com.",
+			match:   "www.example.com.",
+		},
+		{
+			name:    "closest ancestor",
+			sources: [][]string{{"||example.com^", "||a.example.com^"}},
+			query:   "x.a.example.com.",
+			match:   "a.example.com.",
+		},
+		{
+			name:    "subdomain before glob",
+			sources: [][]string{{"|ads*.example.com^", "||example.com^"}},
+			query:   "ads1.example.com.",
+			match:   "example.com.",
+		},
+		{
+			name:    "glob before regexp",
+			sources: [][]string{{`/^ads[0-9]\./`, "|ads*.example.com^"}},
+			query:   "ads1.example.com.",
+			match:   "ads*.example.com.",
+		},
+		{
+			name:    "regexp",
+			sources: [][]string{{`/^ads[0-9]+\.example\.com$/`}},
+			query:   "ads12.example.com.",
+			match:   `/^ads[0-9]+\.example\.com$/`,
+		},
+		{
+			name:    "exception overrides block",
+			sources: [][]string{{"||example.com^", "@@||www.example.com^"}},
+			query:   "a.www.example.com.",
+		},
+		{
+			name:    "exception of sibling",
+			sources: [][]string{{"||example.com^", "@@||www.example.com^"}},
+			query:   "mail.example.com.",
+			match:   "example.com.",
+		},
+		{
+			name:    "exception regexp overrides block",
+			sources: [][]string{{"|ads.example.com^", `@@/^ads\./`}},
+			query:   "ads.example.com.",
+		},
+		{
+			name:    "important overrides exception",
+			sources: [][]string{{"||example.com^$important", "@@||www.example.com^"}},
+			query:   "www.example.com.",
+			match:   "example.com.",
+		},
+		{
+			name:    "important before more specific block",
+			sources: [][]string{{"|www.example.com^", "||example.com^$important"}},
+			query:   "www.example.com.",
+			match:   "example.com.",
+		},
+		{
+			name:    "exception of other source",
+			sources: [][]string{{"||example.com^"}, {"@@|www.example.com^"}},
+			query:   "www.example.com.",
+		},
+		{
+			name:    "exact of other source before subdomain",
+			sources: [][]string{{"||example.com^"}, {"|www.example.com^"}},
+			query:   "www.example.com.",
+			match:   "www.example.com.",
+		},
+		{
+			name:    "first source wins",
+			sources: [][]string{{"|a*.example.com^"}, {"|*a.example.com^"}},
+			query:   "aa.example.com.",
+			match:   "a*.example.com.",
+		},
+	}
+
+	for _, withBloom := range []bool{false, true} {
+		for _, test := range tests {
+			t.Run(fmt.Sprintf("bloom=%t/%s", withBloom, test.name), func(t *testing.T) {
+				idx := &index{withBloom: withBloom}
+
+				for _, lines := range test.sources {
+					var rules []Rule
+					for _, line := range lines {
+						parsed, err := ParseRule(AdblockRule, line)
+						if err != nil {
+							t.Fatalf("failed to parse rule %q: %v", line, err)
+						}
+						rules = append(rules, parsed...)
+					}
+
+					idx.sources = append(idx.sources, newSourceIndex(rules, withBloom))
+				}
+
+				rule, ok := idx.match(test.query)
+				if ok != (test.match != "") || rule.Name != test.match {
+					t.Errorf("expected match %q, got %q (%t)", test.match, rule.Name, ok)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package filter
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-void/portal/pkg/logger"
+	"github.com/go-void/portal/pkg/zone"
+
+	"go.uber.org/zap"
+)
+
+// List describes a list of rules stored in a local file
+type List struct {
+	// Name identifies the list. The rules of the list are the source
+	// with this name in the filter
+	Name string
+
+	// Path is the path of the file
+	Path string
+
+	// Type is the format of the rules in the file
+	Type RuleType
+}
+
+// LoadList reads and parses the rules of the list. Lines which can't be
+// parsed are returned as *LineError, the error is only set if the file
+// can't be read
+func LoadList(list List) ([]Rule, []error, error) {
+	f, err := os.Open(list.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	rules, errs := Parse(f, list.Type)
+	return rules, errs, nil
+}
+
+// Watcher loads lists and policy zones into filters and reloads them when
+// their files change. Changes are detected by the modification time and
+// size of the files
+type Watcher struct {
+	files    []*watchedFile
+	interval time.Duration
+	logger   *logger.Logger
+
+	stop chan struct{}
+	lock sync.Mutex
+	wg   sync.WaitGroup
+}
+
+// watchedFile is a list or policy zone loaded into one or more filters
+type watchedFile struct {
+	// kind is either list or zone and is used as key of the name in log
+	// messages
+	kind string
+	name string
+	path string
+
+	// key identifies the content of the file. Filters adding a file with
+	// the same key share the loaded rules
+	key     string
+	filters []*Filter
+
+	// load parses the file and replaces its rules in the filters. It
+	// returns the number of rules and the rules which couldn't be parsed
+	load func([]*Filter) (int, []error, error)
+
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher returns a new watcher which checks the files of all lists and
+// policy zones for changes every interval
+func NewWatcher(interval time.Duration, l *logger.Logger) *Watcher {
+	return &Watcher{
+		interval: interval,
+		logger:   l,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Add loads the list into the filter and keeps it up to date. Filters
+// adding the same list share its rules. It returns an error if the file
+// can't be read
+func (w *Watcher) Add(f *Filter, list List) error {
+	return w.add(f, &watchedFile{
+		kind: "list",
+		name: list.Name,
+		path: list.Path,
+		key:  fmt.Sprintf("list/%d/%s/%s", list.Type, list.Name, list.Path),
+		load: func(filters []*Filter) (int, []error, error) {
+			rules, errs, err := LoadList(list)
+			if err != nil {
+				return 0, nil, err
+			}
+
+			setRules(filters, list.Name, rules)
+			return len(rules), errs, nil
+		},
+	})
+}
+
+// AddPolicyZone loads the policy zone with the origin from the master file
+// into the filter and keeps it up to date. Filters adding the same zone
+// share it. It returns an error if the file can't be read or parsed
+func (w *Watcher) AddPolicyZone(f *Filter, origin, path string) error {
+	return w.add(f, &watchedFile{
+		kind: "zone",
+		name: origin,
+		path: path,
+		key:  fmt.Sprintf("zone/%s/%s", origin, path),
+		load: func(filters []*Filter) (int, []error, error) {
+			records, err := zone.ParseFile(path, origin)
+			if err != nil {
+				return 0, nil, err
+			}
+
+			z, errs := NewPolicyZone(origin, records)
+			for _, f := range filters {
+				f.SetPolicyZone(z)
+			}
+			return len(records), errs, nil
+		},
+	})
+}
+
+// add loads the file into the filter. If the file is already watched, the
+// filter gets added to the filters of the file, which are all reloaded
+func (w *Watcher) add(f *Filter, wf *watchedFile) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	watched := false
+	for _, existing := range w.files {
+		if existing.key == wf.key {
+			wf, watched = existing, true
+			break
+		}
+	}
+
+	info, err := os.Stat(wf.path)
+	if err != nil {
+		return err
+	}
+
+	wf.filters = append(wf.filters, f)
+	err = w.load(wf, info)
+	if err != nil {
+		wf.filters = wf.filters[:len(wf.filters)-1]
+		return err
+	}
+
+	if !watched {
+		w.files = append(w.files, wf)
+	}
+
+	return nil
+}
+
+// Run starts checking the files for changes in the background
+func (w *Watcher) Run() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				w.check()
+			}
+		}
+	}()
+}
+
+// Stop stops checking the files for changes
+func (w *Watcher) Stop() {
+	close(w.stop)
+	w.wg.Wait()
+}
+
+// check reloads all files which changed. Files which can't be read keep
+// their current rules
+func (w *Watcher) check() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, wf := range w.files {
+		info, err := os.Stat(wf.path)
+		if err == nil && info.ModTime().Equal(wf.modTime) && info.Size() == wf.size {
+			continue
+		}
+
+		if err == nil {
+			err = w.load(wf, info)
+		}
+
+		if err != nil {
+			w.logger.Error(logger.ErrLoadFilterList,
+				zap.String("context", "filter"),
+				zap.String(wf.kind, wf.name),
+				zap.String("path", wf.path),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// load parses the file and replaces its rules in its filters. The lock
+// needs to be held
+func (w *Watcher) load(wf *watchedFile, info os.FileInfo) error {
+	n, errs, err := wf.load(wf.filters)
+	if err != nil {
+		return err
+	}
+
+	for _, err := range errs {
+		w.logger.Debug(logger.DebugParseFilterRule,
+			zap.String("context", "filter"),
+			zap.String(wf.kind, wf.name),
+			zap.Error(err),
+		)
+	}
+
+	wf.modTime = info.ModTime()
+	wf.size = info.Size()
+
+	w.logger.Info("loaded filter source",
+		zap.String("context", "filter"),
+		zap.String(wf.kind, wf.name),
+		zap.Int("rules", n),
+		zap.Int("errors", len(errs)),
+	)
+
+	return nil
+}
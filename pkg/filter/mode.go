@@ -1,5 +1,7 @@
 package filter
 
+import "strings"
+
 type FilterMode int
 
 const (
@@ -15,9 +17,11 @@ func (m FilterMode) String() string {
 	return modes[m]
 }
 
+// MethodFromString returns the filter mode of m. The mode is matched
+// case-insensitively
 func MethodFromString(m string) (FilterMode, error) {
 	for i, method := range modes {
-		if m == method {
+		if strings.EqualFold(m, method) {
 			return FilterMode(i), nil
 		}
 	}
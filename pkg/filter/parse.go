@@ -0,0 +1,314 @@
+package filter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"regexp"
+	"strings"
+
+	"github.com/go-void/portal/pkg/labels"
+)
+
+var (
+	ErrUnsupportedRuleType = errors.New("filter: unsupported rule type")
+	ErrUnsupportedRule     = errors.New("filter: unsupported rule")
+	ErrUnsupportedModifier = errors.New("filter: unsupported rule modifier")
+	ErrInvalidRegexp       = errors.New("filter: invalid regular expression")
+)
+
+// maxLineLength is the maximum length of a single line of a list. Some
+// lists contain very long cosmetic rules
+const maxLineLength = 64 * 1024
+
+// localNames are names every hosts file contains, which are never filtered
+var localNames = map[string]bool{
+	"localhost.":             true,
+	"localhost.localdomain.": true,
+	"local.":                 true,
+	"broadcasthost.":         true,
+	"ip6-localhost.":         true,
+	"ip6-loopback.":          true,
+	"ip6-localnet.":          true,
+	"ip6-mcastprefix.":       true,
+	"ip6-allnodes.":          true,
+	"ip6-allrouters.":        true,
+	"ip6-allhosts.":          true,
+	"0.0.0.0.":               true,
+}
+
+// LineError describes a line of a list which couldn't be parsed
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// RuleTypeFromString returns the rule type of the list format t
+func RuleTypeFromString(t string) (RuleType, error) {
+	switch strings.ToLower(t) {
+	case "", "domains":
+		return DomainRule, nil
+	case "hosts":
+		return HostsRule, nil
+	case "adblock":
+		return AdblockRule, nil
+	case "rpz":
+		return RPZRule, nil
+	}
+
+	return -1, ErrUnsupportedRuleType
+}
+
+// Parse parses all lines of a list. Lines which can't be parsed are
+// skipped and reported as *LineError. Duplicate rules are removed, the
+// first rule wins. A duplicate rule marked important marks the remaining
+// rule important as well
+func Parse(r io.Reader, t RuleType) ([]Rule, []error) {
+	var (
+		rules   []Rule
+		errs    []error
+		indices = make(map[ruleKey]int)
+		line    = 0
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineLength)
+
+	for scanner.Scan() {
+		line++
+
+		parsed, err := ParseRule(t, scanner.Text())
+		if err != nil {
+			errs = append(errs, &LineError{Line: line, Err: err})
+			continue
+		}
+
+		for _, rule := range parsed {
+			if i, ok := indices[rule.key()]; ok {
+				rules[i].Important = rules[i].Important || rule.Important
+				continue
+			}
+
+			indices[rule.key()] = len(rules)
+			rules = append(rules, rule)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, &LineError{Line: line + 1, Err: err})
+	}
+
+	return rules, errs
+}
+
+// ParseRule parses a single line of a list with the format of the rule
+// type. Empty lines and comments result in no rules.
+//
+// Domain rules use the format '[<ip-address>] <domain>'. Example: 'example.com'
+// or '0.0.0.0 example.com'. Lines with an IP address are parsed like hosts
+// rules and can list multiple domains. The domain can be a glob pattern,
+// where '*' matches any sequence of characters. Example: '*.example.com'. A
+// single IP address or prefix in CIDR notation blocks answers containing
+// addresses within the prefix. Example: '192.0.2.0/24'.
+//
+// Hosts rules use the format of /etc/hosts: '<ip-address> <domain> [<domain>...]'.
+// Example: '0.0.0.0 example.com www.example.com'.
+//
+// Adblock rules use the DNS subset of the Adblock Plus / uBlock Origin
+// syntax: '||example.com^' matches the name and all its subdomains,
+// '|example.com^' only the name itself. Names can contain the wildcard '*'
+// and '/<expression>/' matches names with a regular expression. Exception
+// rules start with '@@' and the '$important' modifier lets block rules take
+// precedence over exceptions. IP addresses and prefixes in CIDR notation are
+// IP rules, like in domain rules. Example: '||192.0.2.1^' or '192.0.2.0/24'
+func ParseRule(t RuleType, input string) ([]Rule, error) {
+	switch t {
+	case DomainRule:
+		return parseDomain(input)
+	case HostsRule:
+		return parseHosts(input)
+	case AdblockRule:
+		return parseAdblock(input)
+	}
+
+	return nil, ErrUnsupportedRuleType
+}
+
+func parseDomain(input string) ([]Rule, error) {
+	fields := strings.Fields(stripComment(input, "#"))
+
+	switch len(fields) {
+	case 0:
+		return nil, nil
+	case 1:
+		if prefix, ok := parsePrefix(fields[0]); ok {
+			return []Rule{ipRule(prefix)}, nil
+		}
+
+		name, err := parseName(fields[0], true)
+		if err != nil {
+			return nil, err
+		}
+
+		return []Rule{{Name: name, Address: defaultFilterIP}}, nil
+	}
+
+	return parseHosts(input)
+}
+
+func parseHosts(input string) ([]Rule, error) {
+	fields := strings.Fields(stripComment(input, "#"))
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	if len(fields) == 1 {
+		return nil, ErrInvalidDomainRule
+	}
+
+	ip, err := netip.ParseAddr(fields[0])
+	if err != nil {
+		return nil, ErrInvalidIPAddress
+	}
+
+	rules := make([]Rule, 0, len(fields)-1)
+	for _, field := range fields[1:] {
+		name, err := parseName(field, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if localNames[name] {
+			continue
+		}
+
+		rules = append(rules, Rule{Name: name, Address: ip})
+	}
+
+	return rules, nil
+}
+
+func parseAdblock(input string) ([]Rule, error) {
+	input = strings.TrimSpace(input)
+
+	// Skip comments, the list header and cosmetic rules, which don't
+	// apply to names
+	switch {
+	case input == "", strings.HasPrefix(input, "!"), strings.HasPrefix(input, "["):
+		return nil, nil
+	case strings.Contains(input, "##"), strings.Contains(input, "#@#"),
+		strings.Contains(input, "#?#"), strings.Contains(input, "#$#"):
+		return nil, nil
+	}
+
+	rule := Rule{Address: defaultFilterIP}
+
+	if strings.HasPrefix(input, "@@") {
+		rule.Allow = true
+		input = input[2:]
+	}
+
+	// Regular expressions can contain '$', so modifiers can only follow
+	// the closing slash
+	i := strings.LastIndex(input, "$")
+	if strings.HasPrefix(input, "/") {
+		i = strings.LastIndex(input, "/")
+		if i <= 0 {
+			return nil, ErrInvalidRegexp
+		}
+
+		if i++; i < len(input) && input[i] != '$' {
+			return nil, ErrUnsupportedRule
+		}
+	}
+
+	if i >= 0 && i < len(input) {
+		for _, modifier := range strings.Split(input[i+1:], ",") {
+			switch strings.TrimSpace(modifier) {
+			case "important":
+				rule.Important = true
+			default:
+				return nil, ErrUnsupportedModifier
+			}
+		}
+		input = input[:i]
+	}
+
+	if strings.HasPrefix(input, "/") {
+		// An empty expression would match every name
+		if len(input) < 3 {
+			return nil, ErrInvalidRegexp
+		}
+
+		re, err := regexp.Compile("(?i)" + input[1:len(input)-1])
+		if err != nil {
+			return nil, ErrInvalidRegexp
+		}
+
+		rule.Name = input
+		rule.Regexp = re
+		return []Rule{rule}, nil
+	}
+
+	switch {
+	case strings.HasPrefix(input, "||"):
+		rule.Subdomains = true
+		input = input[2:]
+	case strings.HasPrefix(input, "|"):
+		input = input[1:]
+	default:
+		// Plain names match subdomains as well, like in uBlock Origin
+		rule.Subdomains = true
+	}
+
+	input = strings.TrimSuffix(input, "|")
+	input = strings.TrimSuffix(input, "^")
+
+	if prefix, ok := parsePrefix(input); ok {
+		ip := ipRule(prefix)
+		ip.Allow, ip.Important = rule.Allow, rule.Important
+		return []Rule{ip}, nil
+	}
+
+	// Rules with paths or other separators only apply to URLs
+	if strings.ContainsAny(input, "/^|:?=&") {
+		return nil, ErrUnsupportedRule
+	}
+
+	name, err := parseName(input, true)
+	if err != nil {
+		return nil, err
+	}
+	rule.Name = name
+
+	return []Rule{rule}, nil
+}
+
+// parseName validates the name and returns it lowercase and fully
+// qualified. Globs are names containing the wildcard '*'
+func parseName(name string, glob bool) (string, error) {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || !glob && strings.Contains(name, "*") || !labels.IsValid(name) {
+		return "", ErrInvalidName
+	}
+
+	return strings.ToLower(labels.Rootify(name)), nil
+}
+
+// stripComment removes everything starting at the comment prefix
+func stripComment(input, prefix string) string {
+	if i := strings.Index(input, prefix); i >= 0 {
+		return input[:i]
+	}
+	return input
+}
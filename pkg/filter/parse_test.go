@@ -0,0 +1,356 @@
+package filter
+
+import (
+	"errors"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// compareRules returns if the rules are equal. Compiled regular expressions
+// are only checked for presence, the expression is part of the name
+func compareRules(got, want []Rule) bool {
+	if len(got) != len(want) {
+		return false
+	}
+
+	for i := range got {
+		if (got[i].Regexp != nil) != strings.HasPrefix(want[i].Name, "/") {
+			return false
+		}
+
+		g := got[i]
+		g.Regexp = nil
+		if !reflect.DeepEqual(g, want[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestParseRule(t *testing.T) {
+	var (
+		block     = netip.MustParseAddr("0.0.0.0")
+		localhost = netip.MustParseAddr("127.0.0.1")
+	)
+
+	tests := []struct {
+		name  string
+		t     RuleType
+		input string
+		rules []Rule
+		err   error
+	}{
+		// Domain lists
+		{
+			name:  "domain",
+			t:     DomainRule,
+			input: "Example.com",
+			rules: []Rule{{Name: "example.com.", Address: block}},
+		},
+		{
+			name:  "domain with comment",
+			t:     DomainRule,
+			input: "example.com # tracker",
+			rules: []Rule{{Name: "example.com.", Address: block}},
+		},
+		{
+			name:  "domain comment line",
+			t:     DomainRule,
+			input: "# example.com",
+		},
+		{
+			name:  "domain glob",
+			t:     DomainRule,
+			input: "ads*.example.com",
+			rules: []Rule{{Name: "ads*.example.com.", Address: block}},
+		},
+		{
+			name:  "domain IP prefix",
+			t:     DomainRule,
+			input: "192.0.2.0/24",
+			rules: []Rule{{Name: "192.0.2.0/24", Prefix: netip.MustParsePrefix("192.0.2.0/24"), Address: block}},
+		},
+		{
+			name:  "domain IP address",
+			t:     DomainRule,
+			input: "2001:db8::1",
+			rules: []Rule{{Name: "2001:db8::1/128", Prefix: netip.MustParsePrefix("2001:db8::1/128"), Address: block}},
+		},
+		{
+			name:  "domain with address",
+			t:     DomainRule,
+			input: "127.0.0.1 example.com",
+			rules: []Rule{{Name: "example.com.", Address: localhost}},
+		},
+		{
+			name:  "domain with address and multiple names",
+			t:     DomainRule,
+			input: "0.0.0.0 a.com b.com",
+			rules: []Rule{{Name: "a.com.", Address: block}, {Name: "b.com.", Address: block}},
+		},
+		{
+			name:  "domain invalid name",
+			t:     DomainRule,
+			input: "exa mple",
+			err:   ErrInvalidIPAddress,
+		},
+
+		// Hosts files
+		{
+			name:  "hosts",
+			t:     HostsRule,
+			input: "0.0.0.0 example.com www.example.com",
+			rules: []Rule{{Name: "example.com.", Address: block}, {Name: "www.example.com.", Address: block}},
+		},
+		{
+			name:  "hosts local names",
+			t:     HostsRule,
+			input: "127.0.0.1 localhost localhost.localdomain",
+			rules: []Rule{},
+		},
+		{
+			name:  "hosts missing name",
+			t:     HostsRule,
+			input: "0.0.0.0",
+			err:   ErrInvalidDomainRule,
+		},
+		{
+			name:  "hosts invalid address",
+			t:     HostsRule,
+			input: "example.com example.org",
+			err:   ErrInvalidIPAddress,
+		},
+		{
+			name:  "hosts glob",
+			t:     HostsRule,
+			input: "0.0.0.0 *.example.com",
+			err:   ErrInvalidName,
+		},
+
+		// Adblock lists
+		{
+			name:  "adblock subdomains",
+			t:     AdblockRule,
+			input: "||example.com^",
+			rules: []Rule{{Name: "example.com.", Address: block, Subdomains: true}},
+		},
+		{
+			name:  "adblock exact",
+			t:     AdblockRule,
+			input: "|example.com^",
+			rules: []Rule{{Name: "example.com.", Address: block}},
+		},
+		{
+			name:  "adblock plain name",
+			t:     AdblockRule,
+			input: "example.com",
+			rules: []Rule{{Name: "example.com.", Address: block, Subdomains: true}},
+		},
+		{
+			name:  "adblock exception",
+			t:     AdblockRule,
+			input: "@@||example.com^",
+			rules: []Rule{{Name: "example.com.", Address: block, Subdomains: true, Allow: true}},
+		},
+		{
+			name:  "adblock important",
+			t:     AdblockRule,
+			input: "||example.com^$important",
+			rules: []Rule{{Name: "example.com.", Address: block, Subdomains: true, Important: true}},
+		},
+		{
+			name:  "adblock unsupported modifier",
+			t:     AdblockRule,
+			input: "||example.com^$third-party",
+			err:   ErrUnsupportedModifier,
+		},
+		{
+			name:  "adblock glob",
+			t:     AdblockRule,
+			input: "||ads*.example.com^",
+			rules: []Rule{{Name: "ads*.example.com.", Address: block, Subdomains: true}},
+		},
+		{
+			name:  "adblock regexp",
+			t:     AdblockRule,
+			input: `/^ad[0-9]+\./`,
+			rules: []Rule{{Name: `/^ad[0-9]+\./`, Address: block}},
+		},
+		{
+			name:  "adblock regexp with modifier",
+			t:     AdblockRule,
+			input: `@@/^ad$/$important`,
+			rules: []Rule{{Name: `/^ad$/`, Address: block, Allow: true, Important: true}},
+		},
+		{
+			name:  "adblock empty regexp",
+			t:     AdblockRule,
+			input: "//",
+			err:   ErrInvalidRegexp,
+		},
+		{
+			name:  "adblock empty exception regexp",
+			t:     AdblockRule,
+			input: "@@//",
+			err:   ErrInvalidRegexp,
+		},
+		{
+			name:  "adblock empty regexp with modifier",
+			t:     AdblockRule,
+			input: "//$important",
+			err:   ErrInvalidRegexp,
+		},
+		{
+			name:  "adblock unterminated regexp",
+			t:     AdblockRule,
+			input: "/",
+			err:   ErrInvalidRegexp,
+		},
+		{
+			name:  "adblock invalid regexp",
+			t:     AdblockRule,
+			input: "/ad(/",
+			err:   ErrInvalidRegexp,
+		},
+		{
+			name:  "adblock IP rule",
+			t:     AdblockRule,
+			input: "||192.0.2.1^",
+			rules: []Rule{{Name: "192.0.2.1/32", Prefix: netip.MustParsePrefix("192.0.2.1/32"), Address: block}},
+		},
+		{
+			name:  "adblock CIDR exception",
+			t:     AdblockRule,
+			input: "@@192.0.2.0/24",
+			rules: []Rule{{Name: "192.0.2.0/24", Prefix: netip.MustParsePrefix("192.0.2.0/24"), Address: block, Allow: true}},
+		},
+		{
+			name:  "adblock URL rule",
+			t:     AdblockRule,
+			input: "||example.com/ads/banner.js",
+			err:   ErrUnsupportedRule,
+		},
+		{
+			name:  "adblock URL parameters",
+			t:     AdblockRule,
+			input: "||example.com^?ad=1",
+			err:   ErrUnsupportedRule,
+		},
+		{
+			name:  "adblock comment",
+			t:     AdblockRule,
+			input: "! Title: list",
+		},
+		{
+			name:  "adblock header",
+			t:     AdblockRule,
+			input: "[Adblock Plus 2.0]",
+		},
+		{
+			name:  "adblock cosmetic rule",
+			t:     AdblockRule,
+			input: "example.com##.banner",
+		},
+		{
+			name:  "unsupported type",
+			t:     RPZRule,
+			input: "example.com",
+			err:   ErrUnsupportedRuleType,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules, err := ParseRule(test.t, test.input)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+
+			if len(rules) != 0 || len(test.rules) != 0 {
+				if !compareRules(rules, test.rules) {
+					t.Errorf("expected rules %+v, got %+v", test.rules, rules)
+				}
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	block := netip.MustParseAddr("0.0.0.0")
+
+	tests := []struct {
+		name  string
+		t     RuleType
+		input string
+		rules []Rule
+
+		// lines are the line numbers of the lines which failed to parse
+		lines []int
+	}{
+		{
+			name: "duplicates keep the first rule",
+			t:    DomainRule,
+			input: "example.com\n" +
+				"127.0.0.1 example.com\n" +
+				"example.org\n",
+			rules: []Rule{{Name: "example.com.", Address: block}, {Name: "example.org.", Address: block}},
+		},
+		{
+			name: "important duplicates",
+			t:    AdblockRule,
+			input: "||example.com^\n" +
+				"||example.com^$important\n",
+			rules: []Rule{{Name: "example.com.", Address: block, Subdomains: true, Important: true}},
+		},
+		{
+			name: "distinct kinds are no duplicates",
+			t:    AdblockRule,
+			input: "||example.com^\n" +
+				"|example.com^\n" +
+				"@@||example.com^\n",
+			rules: []Rule{
+				{Name: "example.com.", Address: block, Subdomains: true},
+				{Name: "example.com.", Address: block},
+				{Name: "example.com.", Address: block, Subdomains: true, Allow: true},
+			},
+		},
+		{
+			name: "line errors",
+			t:    AdblockRule,
+			input: "! comment\n" +
+				"||example.com^\n" +
+				"//\n" +
+				"\n" +
+				"||example.org/path\n" +
+				"||example.net^$third-party\n",
+			rules: []Rule{{Name: "example.com.", Address: block, Subdomains: true}},
+			lines: []int{3, 5, 6},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules, errs := Parse(strings.NewReader(test.input), test.t)
+
+			if !compareRules(rules, test.rules) {
+				t.Errorf("expected rules %+v, got %+v", test.rules, rules)
+			}
+
+			var lines []int
+			for _, err := range errs {
+				var lineErr *LineError
+				if !errors.As(err, &lineErr) {
+					t.Fatalf("expected *LineError, got %T", err)
+				}
+				lines = append(lines, lineErr.Line)
+			}
+
+			if !reflect.DeepEqual(lines, test.lines) {
+				t.Errorf("expected errors on lines %v, got %v", test.lines, lines)
+			}
+		})
+	}
+}
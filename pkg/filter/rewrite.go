@@ -0,0 +1,267 @@
+package filter
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/types/dns"
+	"github.com/go-void/portal/pkg/types/rr"
+)
+
+var (
+	ErrInvalidRewrite   = errors.New("filter: invalid rewrite rule")
+	ErrNoSuchRewriteSet = errors.New("filter: no such rewrite set")
+)
+
+// CustomRewriteSet is the set of rewrite rules added via AddRewrite
+const CustomRewriteSet = "rewrite"
+
+// Rewrite maps a name to a CNAME target or to fixed addresses. Queries for
+// the name are answered with the target or the addresses instead of being
+// resolved
+type Rewrite struct {
+	// Name is the lowercase, fully qualified name the rule matches. Names
+	// starting with '*.' match all subdomains of the name following the
+	// wildcard, but not the name itself
+	Name string
+
+	// Target is the target of the CNAME record. It is empty if the rule
+	// answers with addresses
+	Target string
+
+	// Addresses are the addresses of the A and AAAA records
+	Addresses []netip.Addr
+
+	// Set is the name of the set the rule belongs to
+	Set string
+}
+
+// ParseRewrite parses a rewrite rule in the format '<name> <target>' or
+// '<name> <ip-address> [<ip-address>...]'.
+// Example: 'search.example.com safe.example.com' or '*.example.com 192.0.2.1 2001:db8::1'
+func ParseRewrite(input string) (Rewrite, error) {
+	fields := strings.Fields(stripComment(input, "#"))
+	if len(fields) < 2 {
+		return Rewrite{}, ErrInvalidRewrite
+	}
+
+	name, err := parseRewriteName(fields[0])
+	if err != nil {
+		return Rewrite{}, err
+	}
+
+	rewrite := Rewrite{
+		Name: name,
+		Set:  CustomRewriteSet,
+	}
+
+	for _, field := range fields[1:] {
+		addr, err := netip.ParseAddr(field)
+		if err != nil {
+			break
+		}
+		rewrite.Addresses = append(rewrite.Addresses, addr.Unmap())
+	}
+
+	if len(rewrite.Addresses) == len(fields)-1 {
+		return rewrite, nil
+	}
+
+	if len(fields) != 2 {
+		return Rewrite{}, ErrInvalidRewrite
+	}
+
+	target, err := parseName(fields[1], false)
+	if err != nil {
+		return Rewrite{}, err
+	}
+	rewrite.Target = target
+
+	return rewrite, nil
+}
+
+// parseRewriteName validates the name of a rewrite rule. The wildcard is
+// only allowed as the first label
+func parseRewriteName(name string) (string, error) {
+	if strings.Contains(strings.TrimPrefix(name, "*."), "*") {
+		return "", ErrInvalidName
+	}
+
+	return parseName(name, true)
+}
+
+// AddRewrite parses the rewrite rule and adds it to the custom rewrite
+// rules of the filter. A rule of the same name gets replaced. Custom rules
+// take precedence over the rules of safe search sets
+func (f *Filter) AddRewrite(rule string) error {
+	rewrite, err := ParseRewrite(rule)
+	if err != nil {
+		return err
+	}
+
+	f.updateLock.Lock()
+	defer f.updateLock.Unlock()
+
+	var rewrites []Rewrite
+	for _, r := range f.customRewrites {
+		if r.Name != rewrite.Name {
+			rewrites = append(rewrites, r)
+		}
+	}
+
+	f.customRewrites = append(rewrites, rewrite)
+	f.updateRewrites()
+
+	return nil
+}
+
+// RemoveRewrite removes the custom rewrite rule of the name
+func (f *Filter) RemoveRewrite(name string) error {
+	name = strings.ToLower(labels.Rootify(strings.TrimSpace(name)))
+
+	f.updateLock.Lock()
+	defer f.updateLock.Unlock()
+
+	var rewrites []Rewrite
+	for _, r := range f.customRewrites {
+		if r.Name != name {
+			rewrites = append(rewrites, r)
+		}
+	}
+
+	if len(rewrites) == len(f.customRewrites) {
+		return ErrNoSuchRule
+	}
+
+	f.customRewrites = rewrites
+	f.updateRewrites()
+
+	return nil
+}
+
+// SetSafeSearch enables the built-in safe search sets with the names and
+// disables all others. See SafeSearchSets for the available sets
+func (f *Filter) SetSafeSearch(sets []string) error {
+	for _, set := range sets {
+		if _, ok := safeSearchSets[strings.ToLower(set)]; !ok {
+			return ErrNoSuchRewriteSet
+		}
+	}
+
+	f.updateLock.Lock()
+	defer f.updateLock.Unlock()
+
+	f.safeSearch = nil
+	for _, set := range sets {
+		f.safeSearch = append(f.safeSearch, strings.ToLower(set))
+	}
+	f.updateRewrites()
+
+	return nil
+}
+
+// SafeSearch returns the names of the enabled safe search sets
+func (f *Filter) SafeSearch() []string {
+	f.updateLock.Lock()
+	defer f.updateLock.Unlock()
+
+	return append([]string(nil), f.safeSearch...)
+}
+
+// updateRewrites rebuilds the index of the rewrite rules. The caller needs
+// to hold the update lock
+func (f *Filter) updateRewrites() {
+	var rewrites []Rewrite
+	for _, set := range f.safeSearch {
+		rewrites = append(rewrites, safeSearchSets[set]...)
+	}
+	rewrites = append(rewrites, f.customRewrites...)
+
+	f.rewrites.Store(newRewriteIndex(rewrites))
+}
+
+// rewriteIndex stores rewrite rules by name. Wildcard rules are stored by
+// the name following the wildcard. The index is not modified after it was
+// built
+type rewriteIndex struct {
+	exact     map[string]*Rewrite
+	wildcards map[string]*Rewrite
+}
+
+// newRewriteIndex builds the index of the rules. Later rules replace
+// earlier rules of the same name
+func newRewriteIndex(rewrites []Rewrite) *rewriteIndex {
+	i := &rewriteIndex{
+		exact:     make(map[string]*Rewrite),
+		wildcards: make(map[string]*Rewrite),
+	}
+
+	for j := range rewrites {
+		rewrite := &rewrites[j]
+		if strings.HasPrefix(rewrite.Name, "*.") {
+			i.wildcards[rewrite.Name[2:]] = rewrite
+		} else {
+			i.exact[rewrite.Name] = rewrite
+		}
+	}
+
+	return i
+}
+
+// match returns the rule of the lowercase, fully qualified name or the
+// wildcard rule of its closest ancestor
+func (i *rewriteIndex) match(name string) *Rewrite {
+	if rewrite, ok := i.exact[name]; ok {
+		return rewrite
+	}
+
+	for n := labels.Parent(name); n != ""; n = labels.Parent(n) {
+		if rewrite, ok := i.wildcards[n]; ok {
+			return rewrite
+		}
+	}
+
+	return nil
+}
+
+// rewrite answers the question of the message with the CNAME target or the
+// addresses of the rule. Addresses of the other family than the question
+// type result in NODATA
+func (f *Filter) rewrite(message *dns.Message, rewrite *Rewrite) (Result, error) {
+	question := message.Question[0]
+
+	result := Result{
+		Filtered: true,
+		Filter:   f.Name,
+		Rewrite:  rewrite.Set,
+		Schedule: f.activeSchedule(nil),
+	}
+
+	if rewrite.Target == "" {
+		for _, addr := range rewrite.Addresses {
+			err := f.answer(message, question, addr)
+			if err != nil {
+				return result, err
+			}
+		}
+		return result, nil
+	}
+
+	cname := &rr.CNAME{Target: rewrite.Target}
+	cname.SetHeader(rr.Header{
+		Name:     question.Name,
+		Type:     rr.TypeCNAME,
+		Class:    question.Class,
+		TTL:      uint32(f.TTL),
+		RDLength: cname.Len(),
+	})
+	message.AddAnswer(cname)
+
+	if question.Type != rr.TypeCNAME {
+		result.Target = rewrite.Target
+	}
+
+	return result, nil
+}
@@ -0,0 +1,418 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/types/dns"
+	"github.com/go-void/portal/pkg/types/rr"
+)
+
+var (
+	ErrUnsupportedTrigger = errors.New("filter: unsupported policy trigger")
+	ErrInvalidTrigger     = errors.New("filter: invalid policy trigger")
+	ErrInvalidPolicy      = errors.New("filter: invalid policy")
+)
+
+// Special owner name suffixes and CNAME targets of response policy zones.
+// See https://datatracker.ietf.org/doc/html/draft-vixie-dnsop-dns-rpz-00
+const (
+	rpzIP       = ".rpz-ip"
+	rpzNSIP     = ".rpz-nsip"
+	rpzNSDName  = ".rpz-nsdname"
+	rpzClientIP = ".rpz-client-ip"
+
+	rpzPassthru = "rpz-passthru."
+	rpzDrop     = "rpz-drop."
+	rpzTCPOnly  = "rpz-tcp-only."
+)
+
+// PolicyAction describes what happens with a query which matched a trigger
+// of a policy zone
+type PolicyAction int
+
+const (
+	// PolicyNXDomain answers with NXDOMAIN (CNAME .)
+	PolicyNXDomain PolicyAction = iota
+
+	// PolicyNoData answers with NODATA (CNAME *.)
+	PolicyNoData
+
+	// PolicyPassthru exempts the query from all policies and filter
+	// rules (CNAME rpz-passthru.)
+	PolicyPassthru
+
+	// PolicyDrop doesn't answer the query at all (CNAME rpz-drop.)
+	PolicyDrop
+
+	// PolicyTCPOnly answers queries via UDP with a truncated response,
+	// which makes clients retry via TCP (CNAME rpz-tcp-only.)
+	PolicyTCPOnly
+
+	// PolicyLocalData answers with the records of the trigger. A CNAME
+	// record rewrites the query to its target
+	PolicyLocalData
+)
+
+var policyActions = []string{"NXDOMAIN", "NODATA", "PASSTHRU", "DROP", "TCP-ONLY", "LOCAL-DATA"}
+
+func (a PolicyAction) String() string {
+	return policyActions[a]
+}
+
+// Policy is a single rule of a policy zone
+type Policy struct {
+	// Trigger is the owner name of the rule relative to the zone origin
+	Trigger string
+
+	// Action is the action of the rule
+	Action PolicyAction
+
+	// Records are the records used to answer queries with local data
+	Records []rr.RR
+}
+
+// PolicyError describes a rule of a policy zone which couldn't be parsed
+type PolicyError struct {
+	Owner string
+	Err   error
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Owner, e.Err)
+}
+
+func (e *PolicyError) Unwrap() error {
+	return e.Err
+}
+
+// PolicyZone is a response policy zone (RPZ). Its rules are triggered by
+// the query name (QNAME), addresses in the response (RPZ-IP), the names of
+// the authoritative name servers (NSDNAME) or their addresses (NSIP). The
+// zone is not modified after it was built
+type PolicyZone struct {
+	// Name is the origin of the zone
+	Name string
+
+	qnames           map[string]*Policy
+	qnameWildcards   map[string]*Policy
+	nsdnames         map[string]*Policy
+	nsdnameWildcards map[string]*Policy
+	responseIPs      []ipPolicy
+	nameServerIPs    []ipPolicy
+}
+
+// ipPolicy is a rule triggered by addresses within the prefix
+type ipPolicy struct {
+	prefix netip.Prefix
+	policy *Policy
+}
+
+// NewPolicyZone builds the policy zone with the origin from the records of
+// the zone. Rules which can't be parsed are skipped and reported as
+// *PolicyError
+func NewPolicyZone(origin string, records []rr.RR) (*PolicyZone, []error) {
+	var (
+		z = &PolicyZone{
+			Name:             strings.ToLower(labels.Rootify(origin)),
+			qnames:           make(map[string]*Policy),
+			qnameWildcards:   make(map[string]*Policy),
+			nsdnames:         make(map[string]*Policy),
+			nsdnameWildcards: make(map[string]*Policy),
+		}
+
+		owners []string
+		groups = make(map[string][]rr.RR)
+		errs   []error
+	)
+
+	// Records of the same owner form a single rule
+	for _, record := range records {
+		owner := strings.ToLower(record.Header().Name)
+		if owner == z.Name || !labels.IsSubdomain(owner, z.Name) {
+			continue
+		}
+
+		if _, ok := groups[owner]; !ok {
+			owners = append(owners, owner)
+		}
+		groups[owner] = append(groups[owner], record)
+	}
+
+	for _, owner := range owners {
+		trigger := strings.TrimSuffix(owner, "."+z.Name)
+		if z.Name == "." {
+			trigger = strings.TrimSuffix(owner, ".")
+		}
+
+		policy, err := newPolicy(trigger, groups[owner])
+		if err == nil {
+			err = z.add(policy)
+		}
+
+		if err != nil {
+			errs = append(errs, &PolicyError{Owner: owner, Err: err})
+		}
+	}
+
+	// Longer prefixes are more specific and are checked first
+	for _, ips := range [][]ipPolicy{z.responseIPs, z.nameServerIPs} {
+		sort.SliceStable(ips, func(i, j int) bool {
+			return ips[i].prefix.Bits() > ips[j].prefix.Bits()
+		})
+	}
+
+	return z, errs
+}
+
+// newPolicy returns the rule with the records of the trigger. A single
+// CNAME record selects the action, all other records are local data
+func newPolicy(trigger string, records []rr.RR) (*Policy, error) {
+	policy := &Policy{
+		Trigger: trigger,
+		Action:  PolicyLocalData,
+		Records: records,
+	}
+
+	for _, record := range records {
+		cname, ok := record.(*rr.CNAME)
+		if !ok {
+			continue
+		}
+
+		if len(records) > 1 {
+			return nil, ErrInvalidPolicy
+		}
+
+		switch strings.ToLower(cname.Target) {
+		case ".":
+			policy.Action = PolicyNXDomain
+		case "*.":
+			policy.Action = PolicyNoData
+		case rpzPassthru:
+			policy.Action = PolicyPassthru
+		case rpzDrop:
+			policy.Action = PolicyDrop
+		case rpzTCPOnly:
+			policy.Action = PolicyTCPOnly
+		}
+	}
+
+	if policy.Action != PolicyLocalData {
+		policy.Records = nil
+	}
+
+	return policy, nil
+}
+
+// add adds the rule to the trigger type selected by the suffix of its
+// trigger
+func (z *PolicyZone) add(policy *Policy) error {
+	trigger := policy.Trigger
+
+	switch {
+	case strings.HasSuffix(trigger, rpzIP):
+		prefix, err := parseIPTrigger(strings.TrimSuffix(trigger, rpzIP))
+		if err != nil {
+			return err
+		}
+		z.responseIPs = append(z.responseIPs, ipPolicy{prefix, policy})
+	case strings.HasSuffix(trigger, rpzNSIP):
+		prefix, err := parseIPTrigger(strings.TrimSuffix(trigger, rpzNSIP))
+		if err != nil {
+			return err
+		}
+		z.nameServerIPs = append(z.nameServerIPs, ipPolicy{prefix, policy})
+	case strings.HasSuffix(trigger, rpzNSDName):
+		addName(z.nsdnames, z.nsdnameWildcards, strings.TrimSuffix(trigger, rpzNSDName), policy)
+	case strings.HasSuffix(trigger, rpzClientIP):
+		return ErrUnsupportedTrigger
+	default:
+		addName(z.qnames, z.qnameWildcards, trigger, policy)
+	}
+
+	return nil
+}
+
+// addName adds a name trigger. Wildcard triggers (*.example.com) match all
+// subdomains of the name, but not the name itself
+func addName(exact, wildcards map[string]*Policy, trigger string, policy *Policy) {
+	if trigger == "*" {
+		wildcards["."] = policy
+		return
+	}
+
+	if strings.HasPrefix(trigger, "*.") {
+		wildcards[trigger[2:]+"."] = policy
+		return
+	}
+
+	exact[trigger+"."] = policy
+}
+
+// parseIPTrigger parses the prefix of an IP trigger. Triggers consist of the
+// prefix length followed by the labels of the address in reverse order.
+// IPv6 addresses use 'zz' for the longest run of zeros.
+// Example: 24.0.2.0.192 -> 192.0.2.0/24 and 48.zz.db8.2001 -> 2001:db8::/48
+func parseIPTrigger(trigger string) (netip.Prefix, error) {
+	parts := strings.Split(trigger, ".")
+	if len(parts) < 2 {
+		return netip.Prefix{}, ErrInvalidTrigger
+	}
+
+	bits, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return netip.Prefix{}, ErrInvalidTrigger
+	}
+
+	words := make([]string, 0, len(parts)-1)
+	for i := len(parts) - 1; i > 0; i-- {
+		words = append(words, parts[i])
+	}
+
+	var address string
+	if len(words) == 4 && !strings.Contains(trigger, "zz") {
+		address = strings.Join(words, ".")
+	} else {
+		for i, word := range words {
+			if word == "zz" {
+				words[i] = ""
+			}
+		}
+
+		address = strings.Join(words, ":")
+		if words[0] == "" {
+			address = ":" + address
+		}
+		if words[len(words)-1] == "" {
+			address += ":"
+		}
+	}
+
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return netip.Prefix{}, ErrInvalidTrigger
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}, ErrInvalidTrigger
+	}
+
+	return prefix, nil
+}
+
+// matchQName returns the rule triggered by the lowercase, fully qualified
+// query name or nil
+func (z *PolicyZone) matchQName(name string) *Policy {
+	return matchName(z.qnames, z.qnameWildcards, name)
+}
+
+// matchResponse returns the rule triggered by the response. Response IP
+// triggers take precedence over NSDNAME triggers, which take precedence over
+// NSIP triggers
+func (z *PolicyZone) matchResponse(answer, servers []rr.RR) *Policy {
+	if policy := matchIP(z.responseIPs, answer); policy != nil {
+		return policy
+	}
+
+	for _, server := range servers {
+		if ns, ok := server.(*rr.NS); ok {
+			if policy := matchName(z.nsdnames, z.nsdnameWildcards, strings.ToLower(ns.NSDName)); policy != nil {
+				return policy
+			}
+		}
+	}
+
+	return matchIP(z.nameServerIPs, servers)
+}
+
+// matchName returns the exact rule of the name or the wildcard rule of its
+// closest ancestor
+func matchName(exact, wildcards map[string]*Policy, name string) *Policy {
+	if policy, ok := exact[name]; ok {
+		return policy
+	}
+
+	for n := labels.Parent(name); n != ""; n = labels.Parent(n) {
+		if policy, ok := wildcards[n]; ok {
+			return policy
+		}
+	}
+
+	return nil
+}
+
+// matchIP returns the rule with the longest prefix containing one of the
+// addresses of the A and AAAA records
+func matchIP(ips []ipPolicy, records []rr.RR) *Policy {
+	var (
+		matched *Policy
+		bits    = -1
+	)
+
+	for _, record := range records {
+		var addr netip.Addr
+		switch r := record.(type) {
+		case *rr.A:
+			addr = r.Address
+		case *rr.AAAA:
+			addr = r.Address
+		default:
+			continue
+		}
+
+		for _, ip := range ips {
+			if ip.prefix.Bits() <= bits {
+				break
+			}
+
+			if ip.prefix.Contains(addr.Unmap()) {
+				matched, bits = ip.policy, ip.prefix.Bits()
+				break
+			}
+		}
+	}
+
+	return matched
+}
+
+// answer adds the local data of the rule to the message. Records of other
+// types than the question type are left out, except for CNAME records.
+// Targets of CNAME records starting with '*.' get the wildcard replaced by
+// the query name. The target of the CNAME record is returned, so that it
+// can be resolved
+func (p *Policy) answer(message *dns.Message, question dns.Question) string {
+	var target string
+
+	for _, record := range p.Records {
+		header := *record.Header()
+		if header.Type != question.Type && header.Type != rr.TypeCNAME && question.Type != rr.TypeANY {
+			continue
+		}
+
+		answer := rr.Copy(record)
+		if cname, ok := answer.(*rr.CNAME); ok {
+			if strings.HasPrefix(cname.Target, "*.") {
+				cname.Target = strings.TrimSuffix(question.Name, ".") + cname.Target[1:]
+			}
+
+			if question.Type != rr.TypeCNAME {
+				target = cname.Target
+			}
+		}
+
+		header.Name = question.Name
+		header.RDLength = answer.Len()
+		answer.SetHeader(header)
+
+		message.AddAnswer(answer)
+	}
+
+	return target
+}
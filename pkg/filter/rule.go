@@ -0,0 +1,117 @@
+package filter
+
+import (
+	"net/netip"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/go-void/portal/pkg/labels"
+)
+
+// Rule describes a single filter rule. Rules match names exactly, names
+// including their subdomains, glob patterns or regular expressions. IP rules
+// match addresses in answers instead
+type Rule struct {
+	// Name is the lowercase, fully qualified name the rule matches. Glob
+	// rules contain '*', which matches any sequence of characters
+	// including dots. Regular expression rules store the expression
+	// enclosed in slashes
+	Name string
+
+	// Regexp is the compiled expression of regular expression rules. It
+	// is matched against the lowercase name without the trailing dot
+	Regexp *regexp.Regexp
+
+	// Prefix is the prefix of IP rules, which match A and AAAA records of
+	// answers with an address within the prefix. The name of IP rules is
+	// the prefix in CIDR notation
+	Prefix netip.Prefix
+
+	// Address is used to answer filtered queries in null mode
+	Address netip.Addr
+
+	// Subdomains indicates if the rule matches all subdomains of the name
+	// as well
+	Subdomains bool
+
+	// Allow marks exception rules, which allow names matched by block
+	// rules
+	Allow bool
+
+	// Important block rules take precedence over exception rules
+	Important bool
+}
+
+// isGlob returns if the rule is a glob rule
+func (r *Rule) isGlob() bool {
+	return r.Regexp == nil && strings.Contains(r.Name, "*")
+}
+
+// isIP returns if the rule is an IP rule
+func (r *Rule) isIP() bool {
+	return r.Prefix.IsValid()
+}
+
+// ruleKey identifies duplicate rules
+type ruleKey struct {
+	name       string
+	subdomains bool
+	allow      bool
+}
+
+func (r Rule) key() ruleKey {
+	return ruleKey{r.Name, r.Subdomains, r.Allow}
+}
+
+// matchGlob returns if the glob rule matches the name. Glob rules matching
+// subdomains match if the name or one of its ancestors matches
+func matchGlob(rule *Rule, name string) bool {
+	for n := name; n != ""; n = labels.Parent(n) {
+		if ok, _ := path.Match(rule.Name, n); ok {
+			return true
+		}
+
+		if !rule.Subdomains {
+			return false
+		}
+	}
+	return false
+}
+
+// parsePrefix parses an IP address or a prefix in CIDR notation. Addresses
+// are turned into prefixes of their full length
+func parsePrefix(input string) (netip.Prefix, bool) {
+	if strings.Contains(input, "/") {
+		prefix, err := netip.ParsePrefix(input)
+		return prefix.Masked(), err == nil
+	}
+
+	addr, err := netip.ParseAddr(input)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), true
+}
+
+// ipRule returns the IP rule of the prefix
+func ipRule(prefix netip.Prefix) Rule {
+	return Rule{
+		Name:    prefix.String(),
+		Prefix:  prefix,
+		Address: defaultFilterIP,
+	}
+}
+
+// literalSuffix returns the labels of the glob pattern following the last
+// label which contains a wildcard.
+// Example: ads*.example.com. -> example.com.
+func literalSuffix(pattern string) string {
+	i := strings.LastIndex(pattern, "*")
+	if j := strings.Index(pattern[i:], "."); j >= 0 && i+j+1 < len(pattern) {
+		return pattern[i+j+1:]
+	}
+	return "."
+}
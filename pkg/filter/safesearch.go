@@ -0,0 +1,92 @@
+package filter
+
+import "sort"
+
+// Safe search endpoints of the search engines. Queries for the search
+// engines are answered with a CNAME record pointing to the endpoint, which
+// enforces safe search for all clients
+const (
+	googleSafeSearch     = "forcesafesearch.google.com."
+	youTubeSafeSearch    = "restrict.youtube.com."
+	bingSafeSearch       = "strict.bing.com."
+	duckDuckGoSafeSearch = "safe.duckduckgo.com."
+)
+
+// googleDomains are the top level domains of the Google search.
+// See https://www.google.com/supported_domains
+var googleDomains = []string{
+	"com", "ad", "ae", "com.af", "com.ag", "al", "am", "co.ao", "com.ar",
+	"as", "at", "com.au", "az", "ba", "com.bd", "be", "bf", "bg", "com.bh",
+	"bi", "bj", "com.bn", "com.bo", "com.br", "bs", "bt", "co.bw", "by",
+	"com.bz", "ca", "cat", "cd", "cf", "cg", "ch", "ci", "co.ck", "cl", "cm",
+	"cn", "com.co", "co.cr", "com.cu", "cv", "com.cy", "cz", "de", "dj",
+	"dk", "dm", "com.do", "dz", "com.ec", "ee", "com.eg", "es", "com.et",
+	"fi", "com.fj", "fm", "fr", "ga", "ge", "gg", "com.gh", "com.gi", "gl",
+	"gm", "gr", "com.gt", "gy", "com.hk", "hn", "hr", "ht", "hu", "co.id",
+	"ie", "co.il", "im", "co.in", "iq", "is", "it", "je", "com.jm", "jo",
+	"co.jp", "co.ke", "com.kh", "ki", "kg", "co.kr", "com.kw", "kz", "la",
+	"com.lb", "li", "lk", "co.ls", "lt", "lu", "lv", "com.ly", "co.ma", "md",
+	"me", "mg", "mk", "ml", "com.mm", "mn", "com.mt", "mu", "mv", "mw",
+	"com.mx", "com.my", "co.mz", "com.na", "com.ng", "com.ni", "ne", "nl",
+	"no", "com.np", "nr", "nu", "co.nz", "com.om", "com.pa", "com.pe",
+	"com.pg", "com.ph", "com.pk", "pl", "pn", "com.pr", "ps", "pt",
+	"com.py", "com.qa", "ro", "rs", "ru", "rw", "com.sa", "com.sb", "sc",
+	"se", "com.sg", "sh", "si", "sk", "com.sl", "sn", "so", "sm", "sr",
+	"st", "com.sv", "td", "tg", "co.th", "com.tj", "tl", "tm", "tn", "to",
+	"com.tr", "tt", "com.tw", "co.tz", "com.ua", "co.ug", "co.uk",
+	"com.uy", "co.uz", "com.vc", "co.ve", "co.vi", "com.vn", "vu", "ws",
+	"co.za", "co.zm", "co.zw",
+}
+
+// safeSearchSets maps the names of the built-in safe search sets to their
+// rewrite rules
+var safeSearchSets = map[string][]Rewrite{
+	"google":     googleRewrites(),
+	"youtube":    safeSearchRewrites("youtube", youTubeSafeSearch, "www.youtube.com.", "m.youtube.com.", "youtubei.googleapis.com.", "youtube.googleapis.com.", "www.youtube-nocookie.com."),
+	"bing":       safeSearchRewrites("bing", bingSafeSearch, "bing.com.", "www.bing.com."),
+	"duckduckgo": safeSearchRewrites("duckduckgo", duckDuckGoSafeSearch, "duckduckgo.com.", "www.duckduckgo.com.", "start.duckduckgo.com."),
+}
+
+// SafeSearchSets returns the names of the built-in safe search sets in
+// lexical order
+func SafeSearchSets() []string {
+	names := make([]string, 0, len(safeSearchSets))
+	for name := range safeSearchSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// safeSearchSetName returns the name of the rewrite set of the safe search
+// set, which identifies its rewrites in results
+func safeSearchSetName(name string) string {
+	return "safesearch-" + name
+}
+
+// safeSearchRewrites returns the rewrite rules of the names to the safe
+// search endpoint
+func safeSearchRewrites(set, target string, names ...string) []Rewrite {
+	rewrites := make([]Rewrite, 0, len(names))
+	for _, name := range names {
+		rewrites = append(rewrites, Rewrite{
+			Name:   name,
+			Target: target,
+			Set:    safeSearchSetName(set),
+		})
+	}
+
+	return rewrites
+}
+
+// googleRewrites returns the rewrite rules of the Google search of all top
+// level domains
+func googleRewrites() []Rewrite {
+	names := make([]string, 0, 2*len(googleDomains))
+	for _, tld := range googleDomains {
+		names = append(names, "google."+tld+".", "www.google."+tld+".")
+	}
+
+	return safeSearchRewrites("google", googleSafeSearch, names...)
+}
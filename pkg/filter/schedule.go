@@ -0,0 +1,219 @@
+package filter
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSchedule  = errors.New("filter: invalid schedule")
+	ErrInvalidTimeRange = errors.New("filter: invalid time range")
+)
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Schedule describes when a list or group is active. It is active if any of
+// its time ranges contains the current time in the time zone of the
+// schedule. A schedule is not modified after it was built
+type Schedule struct {
+	// Name identifies the schedule, e.g. in collector entries
+	Name string
+
+	// Location is the time zone the time ranges are evaluated in
+	Location *time.Location
+
+	// Ranges are the time ranges in which the schedule is active
+	Ranges []TimeRange
+}
+
+// TimeRange is a range of time on a set of weekdays. If the end is before
+// the start, the range ends on the following day.
+// Example: 'sun-thu 20:00-07:00' is active from sunday 20:00 to monday 07:00,
+// ..., from thursday 20:00 to friday 07:00
+type TimeRange struct {
+	// Days are the weekdays the range starts on, indexed by time.Weekday
+	Days [7]bool
+
+	// Start and End are the offsets from midnight. End is at most 24h
+	Start time.Duration
+	End   time.Duration
+}
+
+// NewSchedule returns the schedule with the name and time ranges. The time
+// zone is an IANA time zone name, e.g. 'Europe/Berlin'. An empty time zone
+// selects the local time zone
+func NewSchedule(name, timeZone string, ranges []string) (*Schedule, error) {
+	if name == "" || len(ranges) == 0 {
+		return nil, ErrInvalidSchedule
+	}
+
+	location := time.Local
+	if timeZone != "" {
+		l, err := time.LoadLocation(timeZone)
+		if err != nil {
+			return nil, err
+		}
+		location = l
+	}
+
+	s := &Schedule{
+		Name:     name,
+		Location: location,
+	}
+
+	for _, r := range ranges {
+		tr, err := ParseTimeRange(r)
+		if err != nil {
+			return nil, err
+		}
+		s.Ranges = append(s.Ranges, tr)
+	}
+
+	return s, nil
+}
+
+// ParseTimeRange parses a time range in the format '[<days>] [<hh:mm>-<hh:mm>]'.
+// Days are a comma separated list of weekdays or ranges of weekdays. Without
+// days the range applies to every day, without times to the whole day.
+// Example: 'mon-fri 08:00-16:00', 'sat,sun' or '22:00-06:00'
+func ParseTimeRange(input string) (TimeRange, error) {
+	var (
+		tr     = TimeRange{End: 24 * time.Hour}
+		fields = strings.Fields(strings.ToLower(input))
+	)
+
+	if len(fields) == 0 || len(fields) > 2 {
+		return tr, ErrInvalidTimeRange
+	}
+
+	days := fields[0]
+	if strings.Contains(days, ":") {
+		days = ""
+	} else {
+		fields = fields[1:]
+	}
+
+	if days == "" {
+		for i := range tr.Days {
+			tr.Days[i] = true
+		}
+	} else if err := parseDays(&tr, days); err != nil {
+		return tr, err
+	}
+
+	if len(fields) == 0 {
+		return tr, nil
+	}
+
+	times := strings.Split(fields[0], "-")
+	if len(times) != 2 {
+		return tr, ErrInvalidTimeRange
+	}
+
+	start, err := parseClock(times[0])
+	if err != nil {
+		return tr, err
+	}
+
+	end, err := parseClock(times[1])
+	if err != nil {
+		return tr, err
+	}
+
+	if start == end || start == 24*time.Hour {
+		return tr, ErrInvalidTimeRange
+	}
+
+	tr.Start, tr.End = start, end
+	return tr, nil
+}
+
+// parseDays parses a comma separated list of weekdays and ranges of
+// weekdays. Ranges wrap around the end of the week, e.g. 'fri-mon'
+func parseDays(tr *TimeRange, input string) error {
+	for _, part := range strings.Split(input, ",") {
+		bounds := strings.Split(part, "-")
+		if len(bounds) > 2 {
+			return ErrInvalidTimeRange
+		}
+
+		first := weekday(bounds[0])
+		last := weekday(bounds[len(bounds)-1])
+		if first < 0 || last < 0 {
+			return ErrInvalidTimeRange
+		}
+
+		for d := first; ; d = (d + 1) % 7 {
+			tr.Days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// weekday returns the index of the abbreviated weekday or -1
+func weekday(name string) int {
+	for i, d := range weekdays {
+		if name == d {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseClock parses a time of day in the format 'hh:mm'. 24:00 marks the
+// end of the day
+func parseClock(input string) (time.Duration, error) {
+	parts := strings.Split(input, ":")
+	if len(parts) != 2 || len(parts[1]) != 2 {
+		return 0, ErrInvalidTimeRange
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil || hours < 0 || hours > 24 {
+		return 0, ErrInvalidTimeRange
+	}
+
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes < 0 || minutes > 59 || hours == 24 && minutes != 0 {
+		return 0, ErrInvalidTimeRange
+	}
+
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// Active returns if the schedule is active at the time. A nil schedule is
+// always active
+func (s *Schedule) Active(t time.Time) bool {
+	if s == nil {
+		return true
+	}
+
+	t = t.In(s.Location)
+	var (
+		day       = t.Weekday()
+		yesterday = (day + 6) % 7
+		offset    = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	)
+
+	for _, r := range s.Ranges {
+		if r.Start < r.End {
+			if r.Days[day] && offset >= r.Start && offset < r.End {
+				return true
+			}
+			continue
+		}
+
+		// The range spans midnight. It started either today or yesterday
+		if r.Days[day] && offset >= r.Start || r.Days[yesterday] && offset < r.End {
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,7 +2,7 @@ package filter
 
 type Store interface {
 	// Add adds a new filter to the store
-	Add(string, Filter) error
+	Add(string, *Filter) error
 
 	// Get returns a filter by name
 	Get(string) (*Filter, error)
@@ -15,7 +15,7 @@ func NewDefault() Store {
 	return &DefaultStore{}
 }
 
-func (s *DefaultStore) Add(name string, f Filter) error {
+func (s *DefaultStore) Add(name string, f *Filter) error {
 	// Code ...
 	return nil
 }
@@ -38,7 +38,7 @@ func FromRoot(name string) ([]string, bool) {
 
 			labels = append(labels, name[i+1:b])
 			b = i
-		case c == '-',
+		case c == '-', c == '_', c == '*',
 			c >= 0x30 && c <= 0x39, // ASCII 0-9
 			c >= 0x41 && c <= 0x5A, // ASCII A-Z
 			c >= 0x61 && c <= 0x7A: // ASCII a-z
@@ -82,7 +82,7 @@ func FromBottom(name string) ([]string, bool) {
 				labels = append(labels, ".")
 				return labels, true
 			}
-		case c == '-',
+		case c == '-', c == '_', c == '*',
 			c >= 0x30 && c <= 0x39, // ASCII 0-9
 			c >= 0x41 && c <= 0x5A, // ASCII A-Z
 			c >= 0x61 && c <= 0x7A: // ASCII a-z
@@ -106,7 +106,7 @@ func IsValid(name string) bool {
 				return false
 			}
 			dot = true
-		case c == '-',
+		case c == '-', c == '_', c == '*',
 			c >= 0x30 && c <= 0x39, // ASCII 0-9
 			c >= 0x41 && c <= 0x5A, // ASCII A-Z
 			c >= 0x61 && c <= 0x7A: // ASCII a-z
@@ -121,7 +121,7 @@ func IsValid(name string) bool {
 // Rootify returns the (domain) name terminated by '.' if not already present.
 // Example: example.com -> example.com. or example.com. -> example.com.
 func Rootify(name string) string {
-	if name[len(name)-1] == '.' {
+	if name == "" || name[len(name)-1] == '.' {
 		return name
 	}
 	return name + "."
@@ -144,3 +144,67 @@ func Len(name string) int {
 
 	return c
 }
+
+// Parent returns the parent name by removing the leftmost label. The
+// parent of a top-level domain is root (.) and root has no parent ("").
+// Example: www.example.com. -> example.com.
+func Parent(name string) string {
+	if name == "" || name == "." {
+		return ""
+	}
+
+	for i := 0; i < len(name)-1; i++ {
+		if name[i] == '.' {
+			return name[i+1:]
+		}
+	}
+
+	return "."
+}
+
+// IsSubdomain returns if child is equal to or a subdomain of parent. Names
+// are compared case-insensitively.
+// Example: www.example.com. is a subdomain of example.com.
+func IsSubdomain(child, parent string) bool {
+	child, parent = strings.ToLower(Rootify(child)), strings.ToLower(Rootify(parent))
+
+	if parent == "." || child == parent {
+		return true
+	}
+
+	return strings.HasSuffix(child, "."+parent)
+}
+
+// Compare compares two names in canonical order. Labels are compared from
+// right to left as lowercase octet strings. The result is -1 if a sorts
+// before b, 0 if the names are equal and +1 otherwise.
+// See https://datatracker.ietf.org/doc/html/rfc4034#section-6.1
+func Compare(a, b string) int {
+	la := split(strings.ToLower(Rootify(a)))
+	lb := split(strings.ToLower(Rootify(b)))
+
+	for i, j := len(la)-1, len(lb)-1; i >= 0 || j >= 0; i, j = i-1, j-1 {
+		switch {
+		case i < 0:
+			return -1
+		case j < 0:
+			return 1
+		}
+
+		if c := strings.Compare(la[i], lb[j]); c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+// split returns the labels of a fully qualified name without the root
+// label
+func split(name string) []string {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return nil
+	}
+	return strings.Split(name, ".")
+}
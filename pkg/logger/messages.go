@@ -8,6 +8,10 @@ const (
 	ErrAcceptMessage    = "did not accept DNS message"
 	ErrHandleRequest    = "failed to handle incoming DNS request"
 	ErrResolverLookup   = "failed to lookup domain name via resolver"
+	ErrLoadZone         = "failed to load zone"
+	ErrOpenStore        = "failed to open record store"
+	ErrRefreshStore     = "failed to refresh record store"
+	ErrSignZone         = "failed to enable signing of zone"
 )
 
 // UDP related log messages
@@ -25,7 +29,31 @@ const (
 	ErrTCPWriteClose = "failed to write packet or close TCP conn"
 )
 
+// DNS over HTTPS related log messages
+const (
+	ErrDoHListen = "failed to serve DNS over HTTPS"
+	ErrDoHWrite  = "failed to write DNS over HTTPS response"
+)
+
+// Zone transfer related log messages
+const (
+	ErrTransferRefused = "refused zone transfer"
+	ErrRefreshZone     = "failed to refresh secondary zone"
+	ErrExpireZone      = "secondary zone expired"
+	ErrSendNotify      = "failed to send notify"
+	ErrNotifyRefused   = "refused notify"
+	ErrUpdateRefused   = "refused dynamic update"
+	ErrUpdateZone      = "failed to update zone"
+	ErrVerifyTSIG      = "failed to verify TSIG"
+)
+
 // Filter related log messages
 const (
-	DebugNoSuchFilter = "no matching filter found"
+	DebugNoSuchFilter    = "no matching filter found"
+	ErrMatchFilter       = "failed to match filter"
+	ErrAddFilterRule     = "failed to add filter rule"
+	ErrLoadFilterList    = "failed to load filter list"
+	ErrAddFilterSchedule = "failed to add filter schedule"
+
+	DebugParseFilterRule = "failed to parse filter rule"
 )
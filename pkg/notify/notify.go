@@ -0,0 +1,160 @@
+// Package notify sends DNS NOTIFY messages to inform secondaries about
+// changes of a zone. See https://datatracker.ietf.org/doc/html/rfc1996
+package notify
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-void/portal/pkg/client"
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/logger"
+	"github.com/go-void/portal/pkg/tsig"
+	"github.com/go-void/portal/pkg/types/dns"
+	"github.com/go-void/portal/pkg/types/opcode"
+	"github.com/go-void/portal/pkg/types/rcode"
+	"github.com/go-void/portal/pkg/types/rr"
+
+	"go.uber.org/zap"
+)
+
+var ErrInvalidResponse = errors.New("notify: invalid response")
+
+const (
+	// DefaultRetries is the number of retries after the first NOTIFY
+	// message to a target failed
+	DefaultRetries = 5
+
+	// DefaultInterval is the interval before the first retry. The interval
+	// doubles with every retry
+	DefaultInterval = 2 * time.Second
+)
+
+// Notifier sends NOTIFY messages and retries them until the target responds
+// or the retries are exhausted.
+// See https://datatracker.ietf.org/doc/html/rfc1996#section-3.6
+type Notifier struct {
+	// Retries is the number of retries per target
+	Retries int
+
+	// Interval is the interval before the first retry
+	Interval time.Duration
+
+	client *client.Client
+	logger *logger.Logger
+
+	// serials keeps the most recent serial of each zone. Retries for
+	// older serials stop as a newer NOTIFY supersedes them
+	serials map[string]uint32
+	lock    sync.Mutex
+}
+
+func New(c *client.Client, l *logger.Logger) *Notifier {
+	return &Notifier{
+		Retries:  DefaultRetries,
+		Interval: DefaultInterval,
+		client:   c,
+		logger:   l,
+		serials:  make(map[string]uint32),
+	}
+}
+
+// Notify sends NOTIFY messages for the zone with the SOA record to every
+// target in the background. If the key is not nil, the messages are signed
+// with the key
+func (n *Notifier) Notify(soa *rr.SOA, targets []netip.AddrPort, key *tsig.Key) {
+	origin := strings.ToLower(labels.Rootify(soa.H.Name))
+
+	n.lock.Lock()
+	n.serials[origin] = soa.Serial
+	n.lock.Unlock()
+
+	for _, target := range targets {
+		go n.notify(origin, soa, key, target)
+	}
+}
+
+// notify sends NOTIFY messages to a single target until it responds
+func (n *Notifier) notify(origin string, soa *rr.SOA, key *tsig.Key, target netip.AddrPort) {
+	interval := n.Interval
+
+	for i := 0; i <= n.Retries; i++ {
+		if i > 0 {
+			time.Sleep(interval)
+			interval *= 2
+		}
+
+		if !n.isCurrent(origin, soa.Serial) {
+			return
+		}
+
+		err := n.send(origin, soa, key, target)
+		if err == nil {
+			n.logger.Debug("sent notify",
+				zap.String("context", "notify"),
+				zap.String("zone", origin),
+				zap.String("target", target.String()),
+				zap.Uint32("serial", soa.Serial),
+			)
+			return
+		}
+
+		n.logger.Debug(logger.ErrSendNotify,
+			zap.String("context", "notify"),
+			zap.String("zone", origin),
+			zap.String("target", target.String()),
+			zap.Int("attempt", i+1),
+			zap.Error(err),
+		)
+	}
+
+	n.logger.Warn(logger.ErrSendNotify,
+		zap.String("context", "notify"),
+		zap.String("zone", origin),
+		zap.String("target", target.String()),
+	)
+}
+
+// send sends a single NOTIFY message and validates the response. The SOA
+// record is added to the answer section as a hint for the secondary.
+// See https://datatracker.ietf.org/doc/html/rfc1996#section-3.7
+func (n *Notifier) send(origin string, soa *rr.SOA, key *tsig.Key, target netip.AddrPort) error {
+	header := dns.NewHeader(n.client.GetID())
+	header.OpCode = opcode.Notify
+	header.Authoritative = true
+	header.RecursionDesired = false
+
+	message := dns.NewMessageWith(header)
+	message.AddQuestion(dns.Question{
+		Name:  origin,
+		Type:  rr.TypeSOA,
+		Class: soa.H.Class,
+	})
+	message.AddAnswer(soa)
+
+	if key != nil {
+		message.TSIG = tsig.New(key)
+	}
+
+	response, err := n.client.QueryUDP(message, target)
+	if err != nil {
+		return err
+	}
+
+	if response.Header.IsQuery || response.Header.OpCode != opcode.Notify || response.Header.RCode != rcode.NoError {
+		return ErrInvalidResponse
+	}
+
+	return nil
+}
+
+// isCurrent returns if the serial is the most recent serial of the zone
+func (n *Notifier) isCurrent(origin string, serial uint32) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return n.serials[origin] == serial
+}
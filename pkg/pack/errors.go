@@ -18,8 +18,17 @@ const (
 	ErrOverflowUnpackIPv4 = OverflowError("offset overflow unpacking IPv4 address")
 	ErrOverflowUnpackIPv6 = OverflowError("offset overflow unpacking IPv6 address")
 	ErrOverflowPackIPv4   = OverflowError("offset overflow packing IPv4 address")
-	ErrOverfloPpackIPv6   = OverflowError("offset overflow packing IPv6 address")
+	ErrOverflowPackIPv6   = OverflowError("offset overflow packing IPv6 address")
 
 	ErrOverflowUnpackString = OverflowError("offset overflow unpacking character string")
+	ErrOverflowPackString   = OverflowError("offset overflow packing character string")
 	ErrOverflowUnpackName   = OverflowError("offset overflow unpacking domain name")
+	ErrOverflowPackName     = OverflowError("offset overflow packing domain name")
+
+	ErrOverflowUnpackSvcParams = OverflowError("offset overflow unpacking SvcParams")
+
+	ErrOverflowUnpackBytes      = OverflowError("offset overflow unpacking bytes")
+	ErrOverflowPackBytes        = OverflowError("offset overflow packing bytes")
+	ErrOverflowUnpackTypeBitmap = OverflowError("offset overflow unpacking type bitmap")
+	ErrOverflowPackTypeBitmap   = OverflowError("offset overflow packing type bitmap")
 )
@@ -5,9 +5,11 @@ import (
 	"errors"
 	"net"
 	"net/netip"
+	"sort"
 
 	"github.com/go-void/portal/pkg/compression"
 	"github.com/go-void/portal/pkg/types/edns"
+	"github.com/go-void/portal/pkg/types/svcb"
 )
 
 var (
@@ -57,7 +59,7 @@ func PackIPAddress(addr netip.Addr, buf []byte, offset int) (int, error) {
 	}
 
 	if offset+net.IPv6len > len(buf) {
-		return len(buf), ErrOverflowpackIPv6
+		return len(buf), ErrOverflowPackIPv6
 	}
 
 	addrSlice := addr.AsSlice()
@@ -72,7 +74,6 @@ func PackIPAddress(addr netip.Addr, buf []byte, offset int) (int, error) {
 	return offset + 8, nil
 }
 
-// TODO (Techassi): Add offset overflow check
 // TODO (Techassi): Implement message compression
 
 // PackDomainName packs a name into buf and returns the new offset.
@@ -96,13 +97,16 @@ func PackDomainName(name string, buf []byte, offset int, comp compression.Map) (
 				break
 			}
 
-			// TODO (Techassi): Add overflow check
+			// Make sure the label (and its length octet) fit
+			labelLength := i - pos
+			if offset+labelLength+1 > len(buf) {
+				return len(buf), ErrOverflowPackName
+			}
 
 			// TODO (Techassi): Handle compression
 			comp.Set(name[pos:i], offset)
 
 			// Append the label length to the buffer
-			labelLength := i - pos
 			buf[offset] = byte(labelLength)
 			offset++
 
@@ -112,7 +116,7 @@ func PackDomainName(name string, buf []byte, offset int, comp compression.Map) (
 			// Update offset and position
 			offset += labelLength
 			pos = i + 1
-		case b == '-',
+		case b == '-', b == '_', b == '*',
 			b >= 0x30 && b <= 0x39, // ASCII 0-9
 			b >= 0x41 && b <= 0x5A, // ASCII A-Z
 			b >= 0x61 && b <= 0x7A: // ASCII a-z
@@ -123,6 +127,9 @@ func PackDomainName(name string, buf []byte, offset int, comp compression.Map) (
 	}
 
 	// We packed the complete name, add null byte
+	if offset+1 > len(buf) {
+		return len(buf), ErrOverflowPackName
+	}
 	buf[offset] = 0x0
 	return offset + 1, nil
 }
@@ -134,15 +141,14 @@ func PackCharacterString(characters string, buf []byte, offset int) (int, error)
 		return offset, ErrCharacterStringTooLong
 	}
 
+	if offset+len(characters)+1 > len(buf) {
+		return len(buf), ErrOverflowPackString
+	}
+
 	buf[offset] = uint8(len(characters))
 	offset++
 
-	for i := 0; i < len(characters); i++ {
-		buf[offset] = characters[i]
-		offset++
-	}
-
-	return offset, nil
+	return offset + copy(buf[offset:], characters), nil
 }
 
 // PackEDNSOptions packs all EDNS options into buf and returns the new offset
@@ -169,3 +175,92 @@ func PackEDNSOptions(options []edns.Option, buf []byte, offset int) (int, error)
 
 	return offset, nil
 }
+
+// PackSvcParams packs all SvcParams into buf and returns the new offset. The
+// params have to be sorted by key in strictly increasing order
+func PackSvcParams(params []svcb.Param, buf []byte, offset int) (int, error) {
+	err := svcb.Validate(params)
+	if err != nil {
+		return offset, err
+	}
+
+	for _, param := range params {
+		o, err := PackUint16(uint16(param.Key()), buf, offset)
+		if err != nil {
+			return offset, err
+		}
+		offset = o
+
+		o, err = PackUint16(param.Len(), buf, offset)
+		if err != nil {
+			return offset, err
+		}
+		offset = o
+
+		o, err = param.Pack(buf, offset)
+		if err != nil {
+			return offset, err
+		}
+		offset = o
+	}
+
+	return offset, nil
+}
+
+// PackBytes copies data into buf and returns the new offset
+func PackBytes(data []byte, buf []byte, offset int) (int, error) {
+	if offset+len(data) > len(buf) {
+		return len(buf), ErrOverflowPackBytes
+	}
+
+	return offset + copy(buf[offset:], data), nil
+}
+
+// PackTypeBitmap packs the types as a type bitmap into buf and returns the
+// new offset. See https://datatracker.ietf.org/doc/html/rfc4034#section-4.1.2
+func PackTypeBitmap(types []uint16, buf []byte, offset int) (int, error) {
+	windows := TypeBitmapWindows(types)
+
+	for _, window := range windows {
+		if offset+2+len(window.Bitmap) > len(buf) {
+			return len(buf), ErrOverflowPackTypeBitmap
+		}
+
+		buf[offset] = window.Block
+		buf[offset+1] = byte(len(window.Bitmap))
+		offset += 2 + copy(buf[offset+2:], window.Bitmap)
+	}
+
+	return offset, nil
+}
+
+// TypeBitmapWindow is a single window block of a type bitmap
+type TypeBitmapWindow struct {
+	Block  uint8
+	Bitmap []byte
+}
+
+// TypeBitmapWindows returns the window blocks of the types in increasing
+// order. Each bitmap is trimmed to the last octet with a set bit
+func TypeBitmapWindows(types []uint16) []TypeBitmapWindow {
+	sorted := make([]uint16, len(types))
+	copy(sorted, types)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var windows []TypeBitmapWindow
+	for _, t := range sorted {
+		block, bit := uint8(t>>8), t&0xFF
+
+		if len(windows) == 0 || windows[len(windows)-1].Block != block {
+			windows = append(windows, TypeBitmapWindow{Block: block})
+		}
+
+		window := &windows[len(windows)-1]
+		for len(window.Bitmap) <= int(bit/8) {
+			window.Bitmap = append(window.Bitmap, 0)
+		}
+		window.Bitmap[bit/8] |= 0x80 >> (bit % 8)
+	}
+
+	return windows
+}
@@ -5,6 +5,7 @@ import (
 	"net/netip"
 
 	"github.com/go-void/portal/pkg/types/edns"
+	"github.com/go-void/portal/pkg/types/svcb"
 )
 
 // UnpackUint8 unpacks a uint8 from data at offset and returns the new offset
@@ -56,7 +57,7 @@ func UnpackIPv4Address(data []byte, offset int) (netip.Addr, int, error) {
 // UnpackIPv6Address unpacks a IPv6 address and returns the new offset
 func UnpackIPv6Address(data []byte, offset int) (netip.Addr, int, error) {
 	if offset+16 > len(data) {
-		return netip.Addr{}, len(data), ErrOverflowpackIPv6
+		return netip.Addr{}, len(data), ErrOverflowUnpackIPv6
 	}
 
 	addr, ok := netip.AddrFromSlice(data[offset : offset+16])
@@ -132,21 +133,18 @@ func UnpackDomainName(data []byte, offset int) (string, int, error) {
 // UnpackCharacterString unpacks a character string.
 // See https://datatracker.ietf.org/doc/html/rfc1035#section-3.3 <character-string>
 func UnpackCharacterString(data []byte, offset int) (string, int, error) {
-	l := int(data[offset])
-
-	if offset+l > len(data) {
+	if offset+1 > len(data) {
 		return "", len(data), ErrOverflowUnpackString
 	}
 
-	t := make([]byte, l)
+	l := int(data[offset])
 	offset++
 
-	for i := 0; i < l; i++ {
-		t[i] = data[offset]
-		offset++
+	if offset+l > len(data) {
+		return "", len(data), ErrOverflowUnpackString
 	}
 
-	return string(t), offset, nil
+	return string(data[offset : offset+l]), offset + l, nil
 }
 
 func UnpackEDNSOptions(data []byte, offset int, rdlen uint16) ([]edns.Option, int, error) {
@@ -166,6 +164,12 @@ func UnpackEDNSOptions(data []byte, offset int, rdlen uint16) ([]edns.Option, in
 			return options, offset, err
 		}
 
+		// Options which are not implemented yet are skipped
+		if option == nil {
+			offset += int(length)
+			continue
+		}
+
 		// TODO (Techassi): Add offset validation
 		offset, err = option.Unpack(data, offset, length)
 		if err != nil {
@@ -173,8 +177,90 @@ func UnpackEDNSOptions(data []byte, offset int, rdlen uint16) ([]edns.Option, in
 		}
 
 		options = append(options, option)
-		offset += int(length)
 	}
 
 	return options, offset, nil
 }
+
+// UnpackSvcParams unpacks all SvcParams until end (the end of the RDATA) is
+// reached and returns the new offset
+func UnpackSvcParams(data []byte, offset, end int) ([]svcb.Param, int, error) {
+	var params []svcb.Param
+
+	if end > len(data) {
+		return params, len(data), ErrOverflowUnpackSvcParams
+	}
+
+	for offset < end {
+		key, o, err := UnpackUint16(data, offset)
+		if err != nil {
+			return params, o, err
+		}
+
+		length, o, err := UnpackUint16(data, o)
+		if err != nil {
+			return params, o, err
+		}
+
+		if o+int(length) > end {
+			return params, end, ErrOverflowUnpackSvcParams
+		}
+
+		param := svcb.New(svcb.Key(key))
+		offset, err = param.Unpack(data, o, length)
+		if err != nil {
+			return params, offset, err
+		}
+
+		params = append(params, param)
+	}
+
+	return params, offset, svcb.Validate(params)
+}
+
+// UnpackBytes unpacks length bytes from data at offset and returns a copy
+// and the new offset
+func UnpackBytes(data []byte, offset, length int) ([]byte, int, error) {
+	if length < 0 || offset+length > len(data) {
+		return nil, len(data), ErrOverflowUnpackBytes
+	}
+
+	b := make([]byte, length)
+	copy(b, data[offset:offset+length])
+	return b, offset + length, nil
+}
+
+// UnpackTypeBitmap unpacks a type bitmap until end (the end of the RDATA) is
+// reached and returns the types in increasing order and the new offset.
+// See https://datatracker.ietf.org/doc/html/rfc4034#section-4.1.2
+func UnpackTypeBitmap(data []byte, offset, end int) ([]uint16, int, error) {
+	var types []uint16
+
+	if end > len(data) {
+		return types, len(data), ErrOverflowUnpackTypeBitmap
+	}
+
+	for offset < end {
+		if offset+2 > end {
+			return types, end, ErrOverflowUnpackTypeBitmap
+		}
+
+		block, length := data[offset], int(data[offset+1])
+		offset += 2
+
+		if length == 0 || length > 32 || offset+length > end {
+			return types, end, ErrOverflowUnpackTypeBitmap
+		}
+
+		for i := 0; i < length; i++ {
+			for bit := 0; bit < 8; bit++ {
+				if data[offset+i]&(0x80>>bit) != 0 {
+					types = append(types, uint16(block)<<8|uint16(i*8+bit))
+				}
+			}
+		}
+		offset += length
+	}
+
+	return types, offset, nil
+}
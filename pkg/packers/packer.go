@@ -1,6 +1,7 @@
 package packers
 
 import (
+	"github.com/go-void/portal/pkg/compression"
 	"github.com/go-void/portal/pkg/pack"
 	"github.com/go-void/portal/pkg/types/dns"
 	"github.com/go-void/portal/pkg/types/rr"
@@ -19,22 +20,22 @@ type Packer interface {
 
 	// PackQuestion packs a question by converting
 	// the provided question to the wire format
-	PackQuestion(dns.Question, []byte, int) (int, error)
+	PackQuestion(dns.Question, []byte, int, compression.Map) (int, error)
 
 	// PackRRList packs a slice of resource records
 	// by converting the provided records to the
 	// wire format
-	PackRRList([]rr.RR, []byte, int) (int, error)
+	PackRRList([]rr.RR, []byte, int, compression.Map) (int, error)
 
 	// PackRR packs a single resource record by
 	// converting the provided data to the wire
 	// format
-	PackRR(rr.RR, []byte, int) (int, error)
+	PackRR(rr.RR, []byte, int, compression.Map) (int, error)
 
 	// PackRRHeader packs a resource record header
 	// by converting the provided data to the
 	// wire format
-	PackRRHeader(*rr.Header, []byte, int) (int, error)
+	PackRRHeader(*rr.Header, []byte, int, compression.Map) (int, error)
 }
 
 // DefaultPacker is the default packer implementation
@@ -49,31 +50,37 @@ func NewDefaultPacker() Packer {
 // Packs packs a single DNS message by converting the provided
 // message to the wire format
 func (p *DefaultPacker) Pack(message *dns.Message) ([]byte, error) {
-	// FIXME (Techassi): Figure out how we can pre-allocate the buf with the correct length / size
-	var buf = make([]byte, 256*4)
+	// The uncompressed length is an upper bound of the packed length
+	var buf = make([]byte, message.Len())
 
 	offset, err := p.PackHeader(message.Header, buf, 0)
 	if err != nil {
 		return buf, err
 	}
 
-	offset, err = p.PackQuestion(message.Question[0], buf, offset)
+	offset, err = p.PackQuestion(message.Question[0], buf, offset, message.Compression)
 	if err != nil {
 		return buf, err
 	}
 
-	offset, err = p.PackRRList(message.Answer, buf, offset)
+	offset, err = p.PackRRList(message.Answer, buf, offset, message.Compression)
 	if err != nil {
 		return buf, err
 	}
 
-	offset, err = p.PackRRList(message.Authority, buf, offset)
+	offset, err = p.PackRRList(message.Authority, buf, offset, message.Compression)
 	if err != nil {
 		return buf, err
 	}
 
-	offset, err = p.PackRRList(message.Additional, buf, offset)
-	return buf[:offset], err
+	offset, err = p.PackRRList(message.Additional, buf, offset, message.Compression)
+	if err != nil || message.TSIG == nil {
+		return buf[:offset], err
+	}
+
+	// The TSIG RR covers the packed message, which is why it gets
+	// appended after packing
+	return message.TSIG.Sign(buf[:offset])
 }
 
 // PackHeader packs header data by converting the provided header to the wire format
@@ -110,8 +117,8 @@ func (p *DefaultPacker) PackHeader(header dns.Header, buf []byte, offset int) (i
 }
 
 // PackQuestion packs a question by converting the provided question to the wire format
-func (p *DefaultPacker) PackQuestion(question dns.Question, buf []byte, offset int) (int, error) {
-	offset, err := pack.PackDomainName(question.Name, buf, offset)
+func (p *DefaultPacker) PackQuestion(question dns.Question, buf []byte, offset int, comp compression.Map) (int, error) {
+	offset, err := pack.PackDomainName(question.Name, buf, offset, comp)
 	if err != nil {
 		return offset, err
 	}
@@ -127,14 +134,14 @@ func (p *DefaultPacker) PackQuestion(question dns.Question, buf []byte, offset i
 
 // PackRRList packs a slice of resource records by converting the provided records to
 // the wire format
-func (p *DefaultPacker) PackRRList(rrs []rr.RR, buf []byte, offset int) (int, error) {
+func (p *DefaultPacker) PackRRList(rrs []rr.RR, buf []byte, offset int, comp compression.Map) (int, error) {
 	if len(rrs) == 0 {
 		return offset, nil
 	}
 
 	var err error
 	for _, rr := range rrs {
-		offset, err = p.PackRR(rr, buf, offset)
+		offset, err = p.PackRR(rr, buf, offset, comp)
 		if err != nil {
 			return offset, err
 		}
@@ -144,20 +151,36 @@ func (p *DefaultPacker) PackRRList(rrs []rr.RR, buf []byte, offset int) (int, er
 
 // PackRR packs a single resource record by converting the provided
 // data to the wire format
-func (p *DefaultPacker) PackRR(rr rr.RR, buf []byte, offset int) (int, error) {
-	offset, err := p.PackRRHeader(rr.Header(), buf, offset)
+func (p *DefaultPacker) PackRR(record rr.RR, buf []byte, offset int, comp compression.Map) (int, error) {
+	offset, err := p.PackRRHeader(record.Header(), buf, offset, comp)
+	if err != nil {
+		return offset, err
+	}
+
+	// RRs of class ANY in dynamic updates and their prerequisites have
+	// empty RDATA. See https://datatracker.ietf.org/doc/html/rfc2136#section-2.4
+	if record.Header().Class == rr.ANY && record.Header().Type != rr.TypeOPT && record.Header().Type != rr.TypeTSIG {
+		_, err = pack.PackUint16(0, buf, offset-2)
+		return offset, err
+	}
+
+	// The RDATA length is only known after packing (e.g. because of
+	// variable length data), which is why we overwrite RDLENGTH
+	start := offset
+
+	offset, err = record.Pack(buf, offset, comp)
 	if err != nil {
 		return offset, err
 	}
 
-	offset, err = rr.Pack(buf, offset)
+	_, err = pack.PackUint16(uint16(offset-start), buf, start-2)
 	return offset, err
 }
 
 // PackRRHeader packs a resource record header by converting the provided data
 // to the wire format
-func (p *DefaultPacker) PackRRHeader(header *rr.Header, buf []byte, offset int) (int, error) {
-	offset, err := pack.PackDomainName(header.Name, buf, offset)
+func (p *DefaultPacker) PackRRHeader(header *rr.Header, buf []byte, offset int, comp compression.Map) (int, error) {
+	offset, err := pack.PackDomainName(header.Name, buf, offset, comp)
 	if err != nil {
 		return offset, err
 	}
@@ -193,14 +193,21 @@ func (p *DefaultUnpacker) UnpackRR(data []byte, offset int) (rr.RR, int, error)
 		return nil, offset, err
 	}
 
+	// Types we don't implement are kept as opaque RDATA
 	record, err := rr.New(header.Type)
 	if err != nil {
-		return nil, offset, err
+		record = &rr.Unknown{}
 	}
 
 	// TODO (Techassi): Check RDLENGTH
 	record.SetHeader(header)
 
+	// RRs of dynamic updates and their prerequisites can have empty RDATA.
+	// See https://datatracker.ietf.org/doc/html/rfc2136#section-2.4
+	if header.RDLength == 0 {
+		return record, offset, nil
+	}
+
 	offset, err = record.Unpack(data, offset)
 	if err != nil {
 		return nil, offset, err
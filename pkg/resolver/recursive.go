@@ -89,7 +89,10 @@ func (r *RecursiveResolver) ResolveRaw(name string, class, t uint16) (Result, er
 }
 
 func (r *RecursiveResolver) Lookup(name string, class, t uint16) (Result, error) {
-	var ip = r.Hint()
+	var (
+		ip      = r.Hint()
+		servers []rr.RR
+	)
 
 	for {
 		response, err := r.client.Query(name, class, t, ip)
@@ -99,7 +102,9 @@ func (r *RecursiveResolver) Lookup(name string, class, t uint16) (Result, error)
 
 		// We got an answer, return it immediatly
 		if response.Header.ANCount > 0 {
-			return NewResult(response), nil
+			result := NewResult(response)
+			result.NameServers = servers
+			return result, nil
 		}
 
 		// We have no direct answer or references to
@@ -116,8 +121,11 @@ func (r *RecursiveResolver) Lookup(name string, class, t uint16) (Result, error)
 		//                  because we found a SOA record.
 
 		if response.IsSOA() {
-			return NewResult(response), nil
+			result := NewResult(response)
+			result.NameServers = servers
+			return result, nil
 		}
+		servers = append(servers, referral(response)...)
 
 		// Try to find glue records
 		newIP, err := r.FindGlue(response, class, t)
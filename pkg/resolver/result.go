@@ -1,6 +1,8 @@
 package resolver
 
 import (
+	"strings"
+
 	"github.com/go-void/portal/pkg/types/dns"
 	"github.com/go-void/portal/pkg/types/rr"
 )
@@ -9,8 +11,44 @@ type Result struct {
 	Answer     []rr.RR
 	Authority  []rr.RR
 	Additional []rr.RR
+
+	// NameServers are the NS and glue records of the referrals which were
+	// followed to resolve the answer. Answers from the cache or from
+	// upstream servers don't carry them
+	NameServers []rr.RR
 }
 
 func NewResult(message *dns.Message) Result {
-	return Result{message.Answer, message.Authority, message.Additional}
+	return Result{
+		Answer:     message.Answer,
+		Authority:  message.Authority,
+		Additional: message.Additional,
+	}
+}
+
+// referral returns the NS records of the authority section of the referral
+// and the glue records of these name servers
+func referral(message *dns.Message) []rr.RR {
+	var (
+		records []rr.RR
+		names   = make(map[string]bool)
+	)
+
+	for _, record := range message.Authority {
+		if ns, ok := record.(*rr.NS); ok {
+			records = append(records, ns)
+			names[strings.ToLower(ns.NSDName)] = true
+		}
+	}
+
+	for _, record := range message.Additional {
+		switch record.(type) {
+		case *rr.A, *rr.AAAA:
+			if names[strings.ToLower(record.Header().Name)] {
+				records = append(records, record)
+			}
+		}
+	}
+
+	return records
 }
@@ -0,0 +1,415 @@
+// Package secondary keeps copies of zones mastered on other DNS servers up
+// to date by transferring them from their primaries.
+// See https://datatracker.ietf.org/doc/html/rfc1034#section-4.3.5
+package secondary
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-void/portal/pkg/client"
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/logger"
+	"github.com/go-void/portal/pkg/store"
+	"github.com/go-void/portal/pkg/tsig"
+	"github.com/go-void/portal/pkg/types/dns"
+	"github.com/go-void/portal/pkg/types/rr"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidTransfer = errors.New("secondary: invalid transfer")
+	ErrNoSOA           = errors.New("secondary: no SOA record in answer")
+)
+
+// DefaultRetry is the interval between attempts to transfer a zone which
+// was never transferred and thus has no SOA RETRY value yet
+const DefaultRetry = time.Minute
+
+// Scheduler refreshes secondary zones based on the REFRESH, RETRY and
+// EXPIRE fields of their SOA records
+type Scheduler struct {
+	store  store.Store
+	client *client.Client
+	logger *logger.Logger
+
+	zones map[string]*zone
+	stop  chan struct{}
+	lock  sync.RWMutex
+	wg    sync.WaitGroup
+}
+
+// zone describes the refresh state of a single secondary zone
+type zone struct {
+	origin    string
+	primaries []netip.AddrPort
+
+	// key signs the requests to the primaries. It is nil if the
+	// zone is transferred without TSIG
+	key *tsig.Key
+
+	// refresh triggers an immediate refresh, e.g. after a NOTIFY
+	refresh chan struct{}
+
+	// refreshed is the time of the last successful check of the
+	// zone with one of the primaries or the start of the refresh
+	// loop if the zone already existed
+	refreshed time.Time
+}
+
+func New(s store.Store, c *client.Client, l *logger.Logger) *Scheduler {
+	return &Scheduler{
+		store:  s,
+		client: c,
+		logger: l,
+		zones:  make(map[string]*zone),
+		stop:   make(chan struct{}),
+	}
+}
+
+// Add adds a secondary zone which is transferred from the primaries. If the
+// key is not nil, requests to the primaries are signed with the key. Zones
+// need to be added before calling Run
+func (s *Scheduler) Add(origin string, primaries []netip.AddrPort, key *tsig.Key) {
+	origin = strings.ToLower(labels.Rootify(origin))
+
+	s.lock.Lock()
+	s.zones[origin] = &zone{
+		origin:    origin,
+		primaries: primaries,
+		key:       key,
+		refresh:   make(chan struct{}, 1),
+	}
+	s.lock.Unlock()
+}
+
+// Has returns if the zone with origin is a secondary zone
+func (s *Scheduler) Has(origin string) bool {
+	s.lock.RLock()
+	_, ok := s.zones[strings.ToLower(labels.Rootify(origin))]
+	s.lock.RUnlock()
+	return ok
+}
+
+// Primaries returns the primaries of the secondary zone with origin
+func (s *Scheduler) Primaries(origin string) []netip.AddrPort {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if z, ok := s.zones[strings.ToLower(labels.Rootify(origin))]; ok {
+		return z.primaries
+	}
+	return nil
+}
+
+// Run starts the refresh loop of every zone in a separate goroutine. The
+// first transfer of each zone starts immediately
+func (s *Scheduler) Run() {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for _, z := range s.zones {
+		s.wg.Add(1)
+		go s.run(z)
+	}
+}
+
+// Stop stops all refresh loops and waits for running transfers to finish
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// Refresh triggers an immediate refresh of the zone with origin and returns
+// if the zone is a secondary zone
+func (s *Scheduler) Refresh(origin string) bool {
+	s.lock.RLock()
+	z, ok := s.zones[strings.ToLower(labels.Rootify(origin))]
+	s.lock.RUnlock()
+
+	if !ok {
+		return false
+	}
+
+	// A refresh which is already pending covers this one as well
+	select {
+	case z.refresh <- struct{}{}:
+	default:
+	}
+
+	return true
+}
+
+// run refreshes the zone whenever the timer fires or a refresh is triggered
+func (s *Scheduler) run(z *zone) {
+	defer s.wg.Done()
+
+	// A zone which is already in the store (e.g. a persistent store after a
+	// restart) expires relative to startup, not to the zero time
+	if s.soa(z.origin) != nil {
+		z.refreshed = time.Now()
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-timer.C:
+		case <-z.refresh:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		}
+
+		timer.Reset(s.refresh(z))
+	}
+}
+
+// refresh checks the primaries for a newer version of the zone and
+// transfers it if needed. It returns the duration until the next refresh.
+// See https://datatracker.ietf.org/doc/html/rfc1035#section-3.3.13
+func (s *Scheduler) refresh(z *zone) time.Duration {
+	current := s.soa(z.origin)
+
+	err := s.sync(z, current)
+	if err == nil {
+		z.refreshed = time.Now()
+
+		if soa := s.soa(z.origin); soa != nil {
+			return seconds(soa.Refresh)
+		}
+		return DefaultRetry
+	}
+
+	s.logger.Warn(logger.ErrRefreshZone,
+		zap.String("context", "secondary"),
+		zap.String("zone", z.origin),
+		zap.Error(err),
+	)
+
+	if current == nil {
+		return DefaultRetry
+	}
+
+	// The zone is no longer authoritative if none of the primaries could be
+	// reached for longer than EXPIRE
+	if time.Since(z.refreshed) > seconds(current.Expire) {
+		s.logger.Warn(logger.ErrExpireZone,
+			zap.String("context", "secondary"),
+			zap.String("zone", z.origin),
+		)
+
+		s.store.RemoveZone(z.origin)
+		return DefaultRetry
+	}
+
+	return seconds(current.Retry)
+}
+
+// sync syncs the zone with the first primary which answers
+func (s *Scheduler) sync(z *zone, current *rr.SOA) error {
+	var err error
+
+	for _, primary := range z.primaries {
+		err = s.syncFrom(z, current, primary)
+		if err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// syncFrom compares the serial of the current SOA record with the serial
+// of the primary and transfers the zone if the primary has a newer version
+func (s *Scheduler) syncFrom(z *zone, current *rr.SOA, primary netip.AddrPort) error {
+	if current != nil {
+		remote, err := s.querySOA(z, primary)
+		if err != nil {
+			return err
+		}
+
+		if current.SerialCompare(remote) != rr.SerialLess {
+			return nil
+		}
+	}
+
+	envelopes, err := s.client.Transfer(z.origin, current, z.key, primary)
+	if err != nil {
+		return err
+	}
+
+	var records []rr.RR
+	for envelope := range envelopes {
+		if envelope.Err != nil {
+			return envelope.Err
+		}
+		records = append(records, envelope.Records...)
+	}
+
+	return s.apply(z.origin, current, records)
+}
+
+// querySOA queries the SOA record of the zone from the primary
+func (s *Scheduler) querySOA(z *zone, primary netip.AddrPort) (*rr.SOA, error) {
+	header := dns.NewHeader(s.client.GetID())
+	header.RecursionDesired = false
+
+	query := dns.NewMessageWith(header)
+	query.AddQuestion(dns.Question{
+		Name:  z.origin,
+		Type:  rr.TypeSOA,
+		Class: rr.IN,
+	})
+
+	if z.key != nil {
+		query.TSIG = tsig.New(z.key)
+	}
+
+	message, err := s.client.QueryUDP(query, primary)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range message.Answer {
+		if soa, ok := record.(*rr.SOA); ok {
+			return soa, nil
+		}
+	}
+
+	return nil, ErrNoSOA
+}
+
+// apply loads the transferred records into the store. The zone is replaced
+// at once, which means queries never see a partially transferred zone
+func (s *Scheduler) apply(origin string, current *rr.SOA, records []rr.RR) error {
+	if len(records) == 0 {
+		return ErrInvalidTransfer
+	}
+
+	soa, ok := records[0].(*rr.SOA)
+	if !ok {
+		return ErrInvalidTransfer
+	}
+
+	// A single SOA record means the zone is up to date
+	if len(records) == 1 {
+		return nil
+	}
+
+	if last, ok := records[len(records)-1].(*rr.SOA); !ok || last.Serial != soa.Serial {
+		return ErrInvalidTransfer
+	}
+
+	// An IXFR response with deltas has the old SOA record as its second
+	// record. Otherwise the response contains the full zone
+	if _, ok := records[1].(*rr.SOA); ok && current != nil {
+		existing, err := s.store.Zone(origin)
+		if err != nil {
+			return err
+		}
+
+		records, err = applyDeltas(existing, records)
+		if err != nil {
+			return err
+		}
+	} else {
+		records = records[:len(records)-1]
+	}
+
+	err := s.store.AddZone(origin, records)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("transferred zone",
+		zap.String("context", "secondary"),
+		zap.String("zone", origin),
+		zap.Uint32("serial", soa.Serial),
+		zap.Int("records", len(records)),
+	)
+
+	return nil
+}
+
+// soa returns the SOA record of the zone or nil if the zone doesn't exist
+func (s *Scheduler) soa(origin string) *rr.SOA {
+	records, err := s.store.Zone(origin)
+	if err != nil || len(records) == 0 {
+		return nil
+	}
+
+	soa, ok := records[0].(*rr.SOA)
+	if !ok {
+		return nil
+	}
+
+	return soa
+}
+
+// applyDeltas applies the deltas of an incremental IXFR response to the
+// existing records of the zone and returns the records of the new version.
+// See https://datatracker.ietf.org/doc/html/rfc1995#section-4
+func applyDeltas(existing, records []rr.RR) ([]rr.RR, error) {
+	var (
+		keys = []string{}
+		set  = make(map[string]rr.RR)
+	)
+
+	for _, record := range existing {
+		if _, ok := record.(*rr.SOA); ok {
+			continue
+		}
+
+		key := record.String()
+		keys = append(keys, key)
+		set[key] = record
+	}
+
+	// Each delta consists of the old SOA, the deleted records, the new SOA
+	// and the added records. The last record is the final SOA
+	for i := 1; i < len(records)-1; {
+		i++
+		for ; i < len(records) && !isSOA(records[i]); i++ {
+			delete(set, records[i].String())
+		}
+
+		if i >= len(records)-1 {
+			return nil, ErrInvalidTransfer
+		}
+
+		i++
+		for ; i < len(records) && !isSOA(records[i]); i++ {
+			key := records[i].String()
+			if _, ok := set[key]; !ok {
+				keys = append(keys, key)
+			}
+			set[key] = records[i]
+		}
+	}
+
+	zone := []rr.RR{records[0]}
+	for _, key := range keys {
+		if record, ok := set[key]; ok {
+			zone = append(zone, record)
+			delete(set, key)
+		}
+	}
+
+	return zone, nil
+}
+
+func isSOA(record rr.RR) bool {
+	return record.Header().Type == rr.TypeSOA
+}
+
+func seconds(s uint32) time.Duration {
+	return time.Duration(s) * time.Second
+}
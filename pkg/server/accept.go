@@ -21,7 +21,7 @@ func DefaultAcceptFunc(h dns.Header) AcceptAction {
 		return IgnoreMessage
 	}
 
-	if h.OpCode != opcode.Query {
+	if h.OpCode != opcode.Query && h.OpCode != opcode.Notify && h.OpCode != opcode.Update {
 		return NoImplMessage
 	}
 
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"github.com/go-void/portal/pkg/cache"
+	"github.com/go-void/portal/pkg/types/dns"
+	"github.com/go-void/portal/pkg/types/rr"
+)
+
+// addServiceAdditionals adds A and AAAA records of the target names of SVCB
+// and HTTPS records in the answer section to the additional section.
+// See https://datatracker.ietf.org/doc/html/rfc9460#section-4.1
+func (s *Server) addServiceAdditionals(message *dns.Message) {
+	for _, answer := range message.Answer {
+		var target string
+
+		switch record := answer.(type) {
+		case *rr.SVCB:
+			target = record.TargetName()
+		case *rr.HTTPS:
+			target = record.TargetName()
+		default:
+			continue
+		}
+
+		if target == "." {
+			continue
+		}
+
+		for _, t := range []uint16{rr.TypeA, rr.TypeAAAA} {
+			if hasAdditional(message, target, t) {
+				continue
+			}
+
+			for _, record := range s.lookupLocal(target, answer.Header().Class, t) {
+				message.AddAdditional(record)
+			}
+		}
+	}
+}
+
+// lookupLocal looks up records in the record store and the cache without
+// querying any remote DNS server
+func (s *Server) lookupLocal(name string, class, t uint16) []rr.RR {
+	records, err := s.RecordStore.Get(name, class, t)
+	if err == nil && len(records) > 0 {
+		return records
+	}
+
+	if !s.cacheEnabled {
+		return nil
+	}
+
+	records, status, err := s.Cache.Lookup(name, class, t)
+	if err != nil || status != cache.Hit {
+		return nil
+	}
+
+	return records
+}
+
+// hasAdditional returns if the additional section already contains records
+// with name and type
+func hasAdditional(message *dns.Message, name string, t uint16) bool {
+	for _, record := range message.Additional {
+		if record.Header().Name == name && record.Header().Type == t {
+			return true
+		}
+	}
+	return false
+}
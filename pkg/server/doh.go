@@ -0,0 +1,143 @@
+package server
+
+import (
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/go-void/portal/pkg/constants"
+	"github.com/go-void/portal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// serveDoH starts serving DNS over HTTPS on the configured address. Without
+// a certificate and key, requests are served via plain HTTP.
+// See https://datatracker.ietf.org/doc/html/rfc8484
+func (s *Server) serveDoH() error {
+	listener, err := net.Listen("tcp", s.config.Server.DoHAddrPort.String())
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(constants.DoHPath, s.handleDoH)
+	mux.HandleFunc(constants.DoHPath+"/", s.handleDoH)
+
+	s.HTTPServer = &http.Server{
+		Handler:     mux,
+		ReadTimeout: constants.DoHReadTimeout,
+	}
+
+	go func() {
+		var err error
+		if s.config.Server.DoHCert != "" {
+			err = s.HTTPServer.ServeTLS(listener, s.config.Server.DoHCert, s.config.Server.DoHKey)
+		} else {
+			err = s.HTTPServer.Serve(listener)
+		}
+
+		if err != nil && err != http.ErrServerClosed {
+			s.Logger.Error(logger.ErrDoHListen,
+				zap.String("context", "server"),
+				zap.Error(err),
+			)
+		}
+	}()
+
+	return nil
+}
+
+// handleDoH handles a single DNS over HTTPS request. Messages are sent
+// base64url encoded in the dns parameter of GET requests or as body of POST
+// requests. The path segment after /dns-query is the token of the client
+func (s *Server) handleDoH(w http.ResponseWriter, r *http.Request) {
+	var (
+		b     []byte
+		err   error
+		token = strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, constants.DoHPath), "/")
+	)
+
+	switch r.Method {
+	case http.MethodGet:
+		b, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+	case http.MethodPost:
+		if r.Header.Get("Content-Type") != constants.DoHContentType {
+			http.Error(w, "unsupported media type", http.StatusUnsupportedMediaType)
+			return
+		}
+		b, err = io.ReadAll(io.LimitReader(r.Body, constants.TCPMaxMessageSize))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil || len(b) == 0 {
+		http.Error(w, "invalid DNS message", http.StatusBadRequest)
+		return
+	}
+
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		http.Error(w, "invalid remote address", http.StatusBadRequest)
+		return
+	}
+
+	header, offset, err := s.Unpacker.UnpackHeader(b)
+	if err != nil || s.AcceptFunc(header) != AcceptMessage {
+		http.Error(w, "invalid DNS message", http.StatusBadRequest)
+		return
+	}
+
+	m, err := s.Unpacker.Unpack(header, b, offset)
+	if err != nil {
+		http.Error(w, "invalid DNS message", http.StatusBadRequest)
+		return
+	}
+
+	s.conns.Add(1)
+	if s.verify(b, m) {
+		m, err = s.handle(m, addrPort, "https", token)
+		switch err {
+		case nil:
+		case ErrQueryDropped:
+			// Closing the connection without a response is the closest
+			// to not answering at all
+			panic(http.ErrAbortHandler)
+		case ErrNoQuestions:
+			http.Error(w, "invalid DNS message", http.StatusBadRequest)
+			return
+		default:
+			s.conns.Done()
+			s.Logger.Error(logger.ErrHandleRequest,
+				zap.String("context", "server"),
+				zap.Error(err),
+			)
+			http.Error(w, "failed to handle DNS message", http.StatusInternalServerError)
+			return
+		}
+	}
+	defer s.conns.Done()
+
+	response, err := s.Packer.Pack(m)
+	if err != nil {
+		s.Logger.Error(logger.ErrPackDNSMessage,
+			zap.String("context", "server"),
+			zap.Error(err),
+		)
+		http.Error(w, "failed to pack DNS message", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", constants.DoHContentType)
+	_, err = w.Write(response)
+	if err != nil {
+		s.Logger.Error(logger.ErrDoHWrite,
+			zap.String("context", "server"),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/logger"
+	"github.com/go-void/portal/pkg/store"
+	"github.com/go-void/portal/pkg/types/dns"
+	"github.com/go-void/portal/pkg/types/rcode"
+	"github.com/go-void/portal/pkg/types/rr"
+
+	"go.uber.org/zap"
+)
+
+// handleNotify answers NOTIFY messages. A NOTIFY of one of the primaries of
+// a secondary zone triggers an immediate refresh of the zone.
+// See https://datatracker.ietf.org/doc/html/rfc1996#section-3.7
+func (s *Server) handleNotify(message *dns.Message, addr netip.Addr) *dns.Message {
+	question := message.Question[0]
+
+	message.SetIsResponse()
+	message.Answer, message.Header.ANCount = nil, 0
+	message.Authority, message.Header.NSCount = nil, 0
+	message.Additional, message.Header.ARCount = nil, 0
+
+	if question.Type != rr.TypeSOA {
+		message.Header.RCode = rcode.NotImplemented
+		return message
+	}
+
+	primaries := s.Secondary.Primaries(question.Name)
+	if primaries == nil {
+		message.Header.RCode = rcode.NotAuth
+		return message
+	}
+
+	// NOTIFY messages of zones with a key must be signed with the key
+	options := s.zones[strings.ToLower(labels.Rootify(question.Name))]
+	if !isPrimary(primaries, addr) || !authenticated(message, options.Key) {
+		s.Logger.Info(logger.ErrNotifyRefused,
+			zap.String("context", "server"),
+			zap.String("zone", question.Name),
+			zap.String("address", addr.String()),
+		)
+
+		message.Header.RCode = rcode.Refused
+		return message
+	}
+
+	s.Secondary.Refresh(question.Name)
+	message.Header.Authoritative = true
+	return message
+}
+
+// onZoneChange notifies the secondaries of configured zones which were
+// added or updated to a newer serial
+func (s *Server) onZoneChange(change store.Change) {
+	if _, ok := s.zones[change.Origin]; !ok {
+		return
+	}
+
+	switch change.Kind {
+	case store.ZoneAdded:
+	case store.ZoneUpdated:
+		if change.Delta.From.SerialCompare(change.Delta.To) != rr.SerialLess {
+			return
+		}
+	default:
+		return
+	}
+
+	go s.notifyZone(change.Origin)
+}
+
+// notifyZone sends NOTIFY messages for the zone to the also-notify targets
+// and the name servers of the zone. The primary name server in the MNAME
+// field of the SOA record is skipped.
+// See https://datatracker.ietf.org/doc/html/rfc1996#section-3.6
+func (s *Server) notifyZone(origin string) {
+	records, err := s.RecordStore.Zone(origin)
+	if err != nil {
+		return
+	}
+	soa := records[0].(*rr.SOA)
+
+	var (
+		options = s.zones[strings.ToLower(origin)]
+		targets = options.AlsoNotify
+		seen    = make(map[netip.AddrPort]bool)
+	)
+
+	nameServers, _ := s.RecordStore.Get(origin, soa.H.Class, rr.TypeNS)
+	for _, record := range nameServers {
+		name := record.(*rr.NS).NSDName
+		if strings.EqualFold(name, soa.MName) {
+			continue
+		}
+
+		for _, addr := range s.lookupAddrs(name, soa.H.Class) {
+			targets = append(targets, netip.AddrPortFrom(addr, 53))
+		}
+	}
+
+	unique := make([]netip.AddrPort, 0, len(targets))
+	for _, target := range targets {
+		if seen[target] || target == s.AddrPort {
+			continue
+		}
+
+		seen[target] = true
+		unique = append(unique, target)
+	}
+
+	s.Notifier.Notify(soa, unique, options.Key)
+}
+
+// lookupAddrs returns the IP addresses of the name. The addresses are
+// looked up locally first and then via the resolver
+func (s *Server) lookupAddrs(name string, class uint16) []netip.Addr {
+	var addrs []netip.Addr
+
+	for _, t := range []uint16{rr.TypeA, rr.TypeAAAA} {
+		records := s.lookupLocal(name, class, t)
+		if len(records) == 0 && s.Resolver != nil {
+			result, err := s.Resolver.ResolveRaw(name, class, t)
+			if err == nil {
+				records = result.Answer
+			}
+		}
+
+		for _, record := range records {
+			switch record := record.(type) {
+			case *rr.A:
+				addrs = append(addrs, record.Address)
+			case *rr.AAAA:
+				addrs = append(addrs, record.Address)
+			}
+		}
+	}
+
+	return addrs
+}
+
+// isPrimary returns if the address belongs to one of the primaries
+func isPrimary(primaries []netip.AddrPort, addr netip.Addr) bool {
+	for _, primary := range primaries {
+		if primary.Addr().Unmap() == addr.Unmap() {
+			return true
+		}
+	}
+	return false
+}
@@ -4,20 +4,32 @@ package server
 import (
 	"errors"
 	"net"
+	"net/http"
+	"net/netip"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/go-void/portal/pkg/authority"
 	"github.com/go-void/portal/pkg/cache"
+	"github.com/go-void/portal/pkg/client"
 	"github.com/go-void/portal/pkg/collector"
 	"github.com/go-void/portal/pkg/config"
 	"github.com/go-void/portal/pkg/constants"
 	"github.com/go-void/portal/pkg/dio"
+	"github.com/go-void/portal/pkg/dnssec"
 	"github.com/go-void/portal/pkg/filter"
 	"github.com/go-void/portal/pkg/logger"
+	"github.com/go-void/portal/pkg/notify"
 	"github.com/go-void/portal/pkg/packers"
 	"github.com/go-void/portal/pkg/resolver"
+	"github.com/go-void/portal/pkg/secondary"
 	"github.com/go-void/portal/pkg/store"
+	"github.com/go-void/portal/pkg/tsig"
 	"github.com/go-void/portal/pkg/types/dns"
+	"github.com/go-void/portal/pkg/types/opcode"
+	"github.com/go-void/portal/pkg/update"
+	"github.com/go-void/portal/pkg/zone"
 
 	"go.uber.org/zap"
 	"golang.org/x/net/ipv4"
@@ -29,6 +41,7 @@ var (
 	ErrUnexpectedConnection = errors.New("unexpected connection")
 	ErrNoSuchNetwork        = errors.New("no such network")
 	ErrNoQuestions          = errors.New("no questions")
+	ErrQueryDropped         = errors.New("query dropped by filter")
 )
 
 type OptionsFunc func(*Server) error
@@ -37,15 +50,13 @@ type OptionsFunc func(*Server) error
 
 // Server describes options for running a DNS server
 type Server struct {
-	// The address the server is running on (default: 0.0.0.0)
-	Address net.IP
+	// The address and port the server is running on
+	// (default: 127.0.0.1:53)
+	AddrPort netip.AddrPort
 
 	// Network the server is using (default: udp)
 	Network string
 
-	// Port the server is listening on (default: 53)
-	Port int
-
 	// If the server is running in TCP mode, this listener
 	// listens for incoming DNS messages
 	TCPListener *net.TCPListener
@@ -54,6 +65,9 @@ type Server struct {
 	// listens for incoming UDP messages
 	UDPListener *net.UDPConn
 
+	// HTTPServer serves DNS over HTTPS requests if enabled
+	HTTPServer *http.Server
+
 	// Logger is a light-weight wrapper around zap.Logger which
 	// allows to the server (and all sub-components) to write
 	// structured and leveled logs to one or multiple files
@@ -79,6 +93,10 @@ type Server struct {
 	// filtering
 	Filter filter.Engine
 
+	// FilterLists loads the configured filter lists and reloads
+	// them when their files change
+	FilterLists *filter.Watcher
+
 	// Cache implements the Cache interface to store record
 	// data in memory for a specified TTL
 	Cache cache.Cache
@@ -91,6 +109,25 @@ type Server struct {
 	// in-memory tree structure
 	RecordStore store.Store
 
+	// Authority answers queries for zones the server is
+	// authoritative for with data from the record store
+	Authority *authority.Authority
+
+	// Signer signs zones with DNSSEC enabled online and maintains
+	// their NSEC or NSEC3 chains
+	Signer *dnssec.Signer
+
+	// Secondary keeps secondary zones up to date by transferring
+	// them from their primaries
+	Secondary *secondary.Scheduler
+
+	// Notifier sends NOTIFY messages to secondaries when one
+	// of the zones changes
+	Notifier *notify.Notifier
+
+	// Updater applies dynamic updates to primary zones
+	Updater *update.Updater
+
 	// Resolver implements the Resolver interface to resolve
 	// unknown domain names either iteratively or recursively.
 	// After resolving the record data should get stored in
@@ -138,24 +175,35 @@ type Server struct {
 	recursive bool
 
 	config *config.Config
+
+	// zones maps the origin of each configured zone to its options
+	zones map[string]config.ZoneOptions
+
+	// keys holds the TSIG keys signed messages are verified with
+	keys tsig.Keyring
 }
 
 // New creates a new DNS server instance
 func New(cfg *config.Config) *Server {
 	server := &Server{
 		UDPMessageSize: constants.UDPMinMessageSize,
-		Address:        cfg.Server.Address,
+		AddrPort:       cfg.Server.AddrPort,
 		Network:        cfg.Server.Network,
-		Port:           cfg.Server.Port,
 		cacheEnabled:   cfg.Server.CacheEnabled,
 		recursive:      cfg.Resolver.Mode == "r",
 		conns:          sync.WaitGroup{},
 		wg:             sync.WaitGroup{},
 		messageList:    sync.Pool{},
 		config:         cfg,
+		zones:          make(map[string]config.ZoneOptions),
+		keys:           make(tsig.Keyring),
 	}
 	server.messageList.New = createByteBuffer(constants.UDPMinMessageSize)
 
+	for _, key := range cfg.Keys {
+		server.keys.Add(key.Key)
+	}
+
 	ancillary4 := ipv4.NewControlMessage(ipv4.FlagDst | ipv4.FlagInterface)
 	ancillary6 := ipv6.NewControlMessage(ipv6.FlagDst | ipv6.FlagInterface)
 
@@ -183,16 +231,50 @@ func (s *Server) Run() error {
 
 	s.Logger.Info("start server", zap.String("context", "server"))
 
+	// Setup the record store, which might connect to a database
+	if s.RecordStore == nil {
+		s.RecordStore, err = store.Open(s.config.Store, s.Logger)
+		if err != nil {
+			s.Logger.Error(logger.ErrOpenStore,
+				zap.String("context", "server"),
+				zap.String("backend", s.config.Store.Backend),
+				zap.Error(err),
+			)
+			return err
+		}
+	}
+
 	// Setup defaults
 	s.Defaults()
 
+	err = s.loadZones()
+	if err != nil {
+		return err
+	}
+
+	err = s.loadFilters()
+	if err != nil {
+		return err
+	}
+
 	s.Collector.Run()
 	s.running = true
 	s.wg.Add(1)
 
+	if s.config.Server.DoHAddress != "" {
+		err := s.serveDoH()
+		if err != nil {
+			s.Logger.Error(logger.ErrDoHListen,
+				zap.String("context", "server"),
+				zap.Error(err),
+			)
+			return err
+		}
+	}
+
 	switch s.Network {
 	case "udp", "udp4", "udp6":
-		listener, err := createUDPListener(s.Network, s.Address, s.Port)
+		listener, err := createUDPListener(s.Network, s.AddrPort)
 		if err != nil {
 			s.Logger.Error("failed to create UDP listener",
 				zap.String("context", "server"),
@@ -204,7 +286,7 @@ func (s *Server) Run() error {
 		go s.serveUDP()
 		return nil
 	case "tcp", "tcp4", "tcp6":
-		listener, err := createTCPListener(s.Network, s.Address, s.Port)
+		listener, err := createTCPListener(s.Network, s.AddrPort)
 		if err != nil {
 			s.Logger.Error("failed to create TCP listener",
 				zap.String("context", "server"),
@@ -239,6 +321,11 @@ func (s *Server) Defaults() {
 		s.Filter = filter.NewDefaultEngine(s.Logger)
 	}
 
+	if s.FilterLists == nil {
+		interval := time.Duration(s.config.Filter.ReloadInterval) * time.Second
+		s.FilterLists = filter.NewWatcher(interval, s.Logger)
+	}
+
 	if s.Cache == nil {
 		s.Cache = cache.NewDefaultCache(s.Logger)
 	}
@@ -278,22 +365,334 @@ func (s *Server) Defaults() {
 	if s.RecordStore == nil {
 		s.RecordStore = store.NewDefault()
 	}
+
+	if s.Signer == nil {
+		s.Signer = dnssec.New(s.RecordStore)
+	}
+
+	if s.Authority == nil {
+		s.Authority = authority.New(s.RecordStore, s.Signer)
+	}
+
+	if s.Secondary == nil {
+		s.Secondary = secondary.New(s.RecordStore, client.New(s.Logger), s.Logger)
+	}
+
+	if s.Notifier == nil {
+		s.Notifier = notify.New(client.New(s.Logger), s.Logger)
+	}
+
+	if s.Updater == nil {
+		s.Updater = update.New(s.RecordStore)
+	}
+}
+
+// loadZones parses the master files of all configured primary zones and
+// adds the records to the record store. Secondary zones are transferred
+// from their primaries in the background. Secondaries of all zones get
+// notified whenever a zone changes in the record store
+func (s *Server) loadZones() error {
+	for _, z := range s.config.Zones {
+		s.zones[strings.ToLower(z.Name)] = z
+	}
+	s.RecordStore.Subscribe(s.onZoneChange)
+
+	for _, z := range s.config.Zones {
+		if z.Type == "secondary" {
+			s.Secondary.Add(z.Name, z.Primaries, z.Key)
+			continue
+		}
+
+		// Signing needs to be enabled before the records are added,
+		// which publishes the keys of the zone
+		if z.DNSSEC {
+			err := s.Signer.AddZone(z.Name, dnssec.Options{
+				KeyDirectory: z.KeyDirectory,
+				Algorithm:    z.Algorithm,
+				NSEC3:        z.NSEC3,
+				OptOut:       z.NSEC3OptOut,
+				Iterations:   z.NSEC3Iterations,
+				Salt:         z.NSEC3Salt,
+			})
+			if err != nil {
+				s.Logger.Error(logger.ErrSignZone,
+					zap.String("context", "server"),
+					zap.String("zone", z.Name),
+					zap.Error(err),
+				)
+				return err
+			}
+		}
+
+		records, err := zone.ParseFile(z.File, z.Name)
+		if err == nil {
+			err = s.RecordStore.AddZone(z.Name, records)
+		}
+
+		if err != nil {
+			s.Logger.Error(logger.ErrLoadZone,
+				zap.String("context", "server"),
+				zap.String("zone", z.Name),
+				zap.Error(err),
+			)
+			return err
+		}
+
+		s.Logger.Info("loaded zone",
+			zap.String("context", "server"),
+			zap.String("zone", z.Name),
+			zap.Int("records", len(records)),
+		)
+	}
+
+	s.Secondary.Run()
+	s.Signer.Run()
+	return nil
+}
+
+// loadFilters registers the configured default filter and the filters of
+// all groups with the filter engine. Lists and policy zones are reloaded
+// when their files change. Policy zones apply to all filters. Policy zones
+// without a file follow the zone with the same name in the record store.
+// Filtered queries are answered by the filter before the cache and resolver
+// are consulted
+func (s *Server) loadFilters() error {
+	if !s.config.Filter.Enabled {
+		return nil
+	}
+
+	s.Filter.SetTrustedProxies(s.config.Filter.TrustedProxies)
+
+	schedules := make(map[string]*filter.Schedule)
+	for _, options := range s.config.Filter.Schedules {
+		schedule, err := filter.NewSchedule(options.Name, options.TimeZone, options.Ranges)
+		if err != nil {
+			s.Logger.Error(logger.ErrAddFilterSchedule,
+				zap.String("context", "server"),
+				zap.String("schedule", options.Name),
+				zap.Error(err),
+			)
+			return err
+		}
+		schedules[options.Name] = schedule
+	}
+
+	for _, rule := range s.config.Filter.Allow {
+		err := s.Filter.AddAllowRule("", rule)
+		if err != nil {
+			s.Logger.Error(logger.ErrAddFilterRule,
+				zap.String("context", "server"),
+				zap.String("allow", rule),
+				zap.Error(err),
+			)
+			return err
+		}
+	}
+
+	f, err := s.newFilter(constants.FilterDefaultName, s.config.Filter.Mode, s.config.Filter.Rules, s.config.Filter.Lists, schedules)
+	if err != nil {
+		return err
+	}
+
+	err = s.setRewrites(f, s.config.Filter.Rewrites, s.config.Filter.SafeSearch)
+	if err != nil {
+		return err
+	}
+
+	err = s.Filter.AddFilter(f)
+	if err != nil {
+		return err
+	}
+	filters := []*filter.Filter{f}
+
+	for _, group := range s.config.Filter.Groups {
+		f, err := s.newFilter(group.Name, group.Mode, group.Rules, group.Lists, schedules)
+		if err != nil {
+			return err
+		}
+		f.Schedule = schedules[group.Schedule]
+
+		err = s.setRewrites(f, group.Rewrites, group.SafeSearch)
+		if err != nil {
+			return err
+		}
+
+		for _, rule := range group.Allow {
+			err := f.Allowlist.Add(rule)
+			if err != nil {
+				s.Logger.Error(logger.ErrAddFilterRule,
+					zap.String("context", "server"),
+					zap.String("filter", group.Name),
+					zap.String("allow", rule),
+					zap.Error(err),
+				)
+				return err
+			}
+		}
+
+		err = s.Filter.AddFilter(f)
+		if err != nil {
+			return err
+		}
+
+		err = s.Filter.AddClients(group.Name, group.Clients, group.Tokens)
+		if err != nil {
+			return err
+		}
+		filters = append(filters, f)
+	}
+
+	for _, pz := range s.config.Filter.PolicyZones {
+		if pz.File == "" {
+			s.followPolicyZone(filters, pz.Name)
+			continue
+		}
+
+		for _, f := range filters {
+			err := s.FilterLists.AddPolicyZone(f, pz.Name, pz.File)
+			if err != nil {
+				s.Logger.Error(logger.ErrLoadFilterList,
+					zap.String("context", "server"),
+					zap.String("zone", pz.Name),
+					zap.String("path", pz.File),
+					zap.Error(err),
+				)
+				return err
+			}
+		}
+	}
+
+	s.FilterLists.Run()
+	return nil
+}
+
+// setRewrites adds the rewrite rules to the filter and enables the safe
+// search sets
+func (s *Server) setRewrites(f *filter.Filter, rewrites, safeSearch []string) error {
+	for _, rewrite := range rewrites {
+		err := f.AddRewrite(rewrite)
+		if err != nil {
+			s.Logger.Error(logger.ErrAddFilterRule,
+				zap.String("context", "server"),
+				zap.String("filter", f.Name),
+				zap.String("rewrite", rewrite),
+				zap.Error(err),
+			)
+			return err
+		}
+	}
+
+	err := f.SetSafeSearch(safeSearch)
+	if err != nil {
+		s.Logger.Error(logger.ErrAddFilterRule,
+			zap.String("context", "server"),
+			zap.String("filter", f.Name),
+			zap.Strings("safe_search", safeSearch),
+			zap.Error(err),
+		)
+	}
+
+	return err
+}
+
+// newFilter creates a filter with the rules and lists. The lists are
+// loaded and reloaded when their files change. Lists refer to the schedules
+// by name
+func (s *Server) newFilter(name, mode string, rules []string, lists []config.FilterListOptions, schedules map[string]*filter.Schedule) (*filter.Filter, error) {
+	m, err := filter.MethodFromString(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	f := filter.New(name, m, s.config.Filter.TTL, s.AddrPort.Addr())
+	f.BloomFilter = s.config.Filter.BloomFilter
+
+	for _, rule := range rules {
+		err := f.AddRule(filter.DomainRule, rule)
+		if err != nil {
+			s.Logger.Error(logger.ErrAddFilterRule,
+				zap.String("context", "server"),
+				zap.String("filter", name),
+				zap.String("rule", rule),
+				zap.Error(err),
+			)
+			return nil, err
+		}
+	}
+
+	for _, list := range lists {
+		if schedule, ok := schedules[list.Schedule]; ok {
+			f.SetSchedule(list.Name, schedule)
+		}
+
+		t, err := filter.RuleTypeFromString(list.Format)
+		if err == nil {
+			err = s.FilterLists.Add(f, filter.List{
+				Name: list.Name,
+				Path: list.Path,
+				Type: t,
+			})
+		}
+
+		if err != nil {
+			s.Logger.Error(logger.ErrLoadFilterList,
+				zap.String("context", "server"),
+				zap.String("filter", name),
+				zap.String("list", list.Name),
+				zap.String("path", list.Path),
+				zap.Error(err),
+			)
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// followPolicyZone loads the policy zone from the zone with the same name
+// in the record store into the filters whenever the zone changes. Until the
+// zone exists, e.g. because the first transfer of a secondary zone didn't
+// finish yet, the policy zone is empty but keeps its precedence
+func (s *Server) followPolicyZone(filters []*filter.Filter, origin string) {
+	load := func() {
+		records, _ := s.RecordStore.Zone(origin)
+
+		z, errs := filter.NewPolicyZone(origin, records)
+		for _, err := range errs {
+			s.Logger.Debug(logger.DebugParseFilterRule,
+				zap.String("context", "server"),
+				zap.String("zone", origin),
+				zap.Error(err),
+			)
+		}
+
+		for _, f := range filters {
+			f.SetPolicyZone(z)
+		}
+	}
+
+	s.RecordStore.Subscribe(func(change store.Change) {
+		if strings.EqualFold(change.Origin, origin) {
+			load()
+		}
+	})
+	load()
 }
 
 // handle handles name matching and returns a response message
-func (s *Server) handle(message *dns.Message, ip net.IP) (*dns.Message, error) {
+func (s *Server) handle(message *dns.Message, addrPort netip.AddrPort, network, token string) (*dns.Message, error) {
 	start := time.Now()
 
 	s.Logger.Debug("handle incoming DNS request",
 		zap.String("context", "server"),
-		zap.String("address", ip.String()),
+		zap.String("address", addrPort.String()),
 		zap.Object("message", message),
 	)
 
 	if len(message.Question) == 0 {
 		s.Logger.Debug("empty DNS request (no question)",
 			zap.String("context", "server"),
-			zap.String("address", ip.String()),
+			zap.String("address", addrPort.String()),
 			zap.Object("message", message),
 		)
 
@@ -301,29 +700,58 @@ func (s *Server) handle(message *dns.Message, ip net.IP) (*dns.Message, error) {
 		return message, ErrNoQuestions
 	}
 
+	if message.Header.OpCode == opcode.Notify {
+		return s.handleNotify(message, addrPort.Addr()), nil
+	}
+
+	if message.Header.OpCode == opcode.Update {
+		return s.handleUpdate(message, addrPort.Addr()), nil
+	}
+
+	// Zone transfers via TCP are handled before, see handleTCP
+	if isTransfer(message.Question[0]) {
+		return s.handleUDPTransfer(message, addrPort.Addr()), nil
+	}
+
 	// TODO (Techassi): Add support for ANY queries, see RFC 8482
-	var err error
 
-	// filtered, message, err := s.Filter.Match(ip, message)
-	// if err != nil {
-	// 	// FIXME (Techassi): How whould we handle a filter error? Should we abort or continue (and answer the query)
-	// 	s.Logger.Error("failed to match filter",
-	// 		zap.String("context", "server"),
-	// 		zap.String("address", ip.String()),
-	// 		zap.Object("message", message),
-	// 		zap.Error(err),
-	// 	)
+	// The filter is selected by the token, the client subnet of trusted
+	// proxies or the source address of the client
+	client := filter.Client{Addr: addrPort.Addr(), Token: token}
+	client.Subnet, _ = message.ClientSubnet()
 
-	// 	return message, err
-	// }
+	// A failing filter doesn't prevent answering the query, the query is
+	// just not filtered
+	filtered, err := s.Filter.Match(client, message)
+	if err != nil {
+		s.Logger.Error(logger.ErrMatchFilter,
+			zap.String("context", "server"),
+			zap.String("address", addrPort.String()),
+			zap.Object("message", message),
+			zap.Error(err),
+		)
+	}
 
-	// if filtered {
-	// 	end := time.Since(start)
-	// 	entry := collector.NewFilteredEntry(message.Question[0], message.Answer[0], end, ip)
-	// 	go s.Collector.AddEntry(entry)
+	if err == nil && applies(filtered, network) {
+		return s.respondFiltered(message, filtered, addrPort, start)
+	}
 
-	// 	return message, nil
-	// }
+	// Answer authoritatively if the name is in one of our zones
+	result, err := s.Authority.Lookup(message.Question[0], message.DNSSECOK())
+	if err == nil {
+		message.AddRecords(result.Answer, result.Authority, result.Additional)
+		s.addServiceAdditionals(message)
+		message.Header.Authoritative = result.Authoritative
+		message.Header.RCode = result.RCode
+		message.SetIsResponse()
+		message.SetRecursionAvailable(s.recursive)
+
+		end := time.Since(start)
+		centry := collector.NewEntry(message.Question[0], message.Answer, end, addrPort.Addr())
+		go s.Collector.AddEntry(centry)
+
+		return message, nil
+	}
 
 	if s.cacheEnabled {
 		records, status, err := s.Cache.LookupQuestion(message.Question[0])
@@ -334,30 +762,114 @@ func (s *Server) handle(message *dns.Message, ip net.IP) (*dns.Message, error) {
 
 		if status == cache.Hit {
 			message.AddAnswers(records)
+
+			filtered, err := s.Filter.MatchResponse(client, message, nil)
+			if err == nil && applies(filtered, network) {
+				return s.respondFiltered(message, filtered, addrPort, start)
+			}
+
+			s.addServiceAdditionals(message)
 			return message, nil
 		}
 
 	}
 
-	// Check for custom records at this point
+	// Answer with custom records which are not part of any zone
+	records, err := s.RecordStore.GetFromQuestion(message.Question[0])
+	if err == nil && len(records) > 0 {
+		message.AddAnswers(records)
+		s.addServiceAdditionals(message)
+		message.SetIsResponse()
+		message.SetRecursionAvailable(s.recursive)
+		return message, nil
+	}
 
-	result, err := s.Resolver.Resolve(message)
+	resolved, err := s.Resolver.Resolve(message)
 	if err != nil {
 		return message, err
 	}
 
 	// Finalize response
-	message.AddRecords(result.Answer, result.Authority, result.Additional)
+	message.AddRecords(resolved.Answer, resolved.Authority, resolved.Additional)
+
+	filtered, err = s.Filter.MatchResponse(client, message, resolved.NameServers)
+	if err != nil {
+		s.Logger.Error(logger.ErrMatchFilter,
+			zap.String("context", "server"),
+			zap.String("address", addrPort.String()),
+			zap.Object("message", message),
+			zap.Error(err),
+		)
+	}
+
+	if err == nil && applies(filtered, network) {
+		return s.respondFiltered(message, filtered, addrPort, start)
+	}
+
+	s.addServiceAdditionals(message)
 	message.SetIsResponse()
 	message.SetRecursionAvailable(s.recursive)
 
 	end := time.Since(start)
-	centry := collector.NewEntry(message.Question[0], message.Answer, end, ip)
+	centry := collector.NewEntry(message.Question[0], message.Answer, end, addrPort.Addr())
 	go s.Collector.AddEntry(centry)
 
 	return message, nil
 }
 
+// applies returns if the filter result changes the response. Queries via
+// TCP or HTTPS are answered as usual if the filter only requires TCP
+func applies(filtered filter.Result, network string) bool {
+	return filtered.Filtered && !(filtered.Action == filter.ActionTCPOnly && network != "udp")
+}
+
+// respondFiltered finishes the response to a message filtered by the filter
+// engine. Dropped queries return ErrQueryDropped and are not answered.
+// Queries via UDP which require TCP are answered with a truncated response.
+// A rewritten answer gets the records of its target added
+func (s *Server) respondFiltered(message *dns.Message, filtered filter.Result, addrPort netip.AddrPort, start time.Time) (*dns.Message, error) {
+	switch filtered.Action {
+	case filter.ActionDrop:
+		s.conns.Done()
+		return message, ErrQueryDropped
+	case filter.ActionTCPOnly:
+		message.ClearRecords()
+		message.Header.Truncated = true
+	}
+
+	if filtered.Target != "" {
+		_, class, t := message.Q()
+
+		resolved, err := s.Resolver.ResolveRaw(filtered.Target, class, t)
+		if err == nil {
+			message.AddAnswers(resolved.Answer)
+		}
+	}
+
+	message.SetIsResponse()
+	message.SetRecursionAvailable(s.recursive)
+
+	end := time.Since(start)
+	fentry := collector.NewFilteredEntry(message.Question[0], message.Answer, end, addrPort.Addr())
+	if filtered.Rewrite != "" {
+		fentry = collector.NewRewrittenEntry(message.Question[0], message.Answer, end, addrPort.Addr())
+	}
+
+	fentry.AppliedFilter = filtered.Filter
+	switch {
+	case filtered.PolicyZone != "":
+		fentry.AppliedFilter += "/" + filtered.PolicyZone
+	case filtered.Rewrite != "":
+		fentry.AppliedFilter += "/" + filtered.Rewrite
+	}
+	if filtered.Schedule != "" {
+		fentry.AppliedFilter += "@" + filtered.Schedule
+	}
+	go s.Collector.AddEntry(fentry)
+
+	return message, nil
+}
+
 // isRunning returns if the server instance is running
 func (s *Server) isRunning() bool {
 	s.lock.RLock()
@@ -367,6 +879,12 @@ func (s *Server) isRunning() bool {
 }
 
 func (s *Server) Shutdown() {
+	s.Secondary.Stop()
+	s.Signer.Stop()
+	s.FilterLists.Stop()
+	if s.HTTPServer != nil {
+		s.HTTPServer.Close()
+	}
 	s.Logger.Close()
 	s.wg.Done()
 }
@@ -1,16 +1,21 @@
 package server
 
 import (
+	"errors"
+	"io"
 	"net"
+	"os"
+	"time"
 
+	"github.com/go-void/portal/pkg/constants"
 	"github.com/go-void/portal/pkg/logger"
 	"github.com/go-void/portal/pkg/types/dns"
 
 	"go.uber.org/zap"
 )
 
-// serveTCP is the main listen / answer loop, which handles DNS queries and
-// responses via TCP
+// serveTCP is the main listen / answer loop, which accepts TCP conns and
+// serves each of them in a separate goroutine
 func (s *Server) serveTCP() {
 	for s.isRunning() {
 		conn, err := s.TCPListener.AcceptTCP()
@@ -19,14 +24,35 @@ func (s *Server) serveTCP() {
 				zap.String("context", "server"),
 				zap.Error(err),
 			)
+			continue
+		}
+
+		go s.serveTCPConn(conn)
+	}
+}
+
+// serveTCPConn reads and answers DNS messages of a single TCP conn until the
+// client closes the conn or it is idle for too long. Messages are answered
+// in order, which keeps multi-message responses like zone transfers intact.
+// See https://datatracker.ietf.org/doc/html/rfc7766#section-6.2.1
+func (s *Server) serveTCPConn(conn *net.TCPConn) {
+	defer conn.Close()
+
+	for s.isRunning() {
+		err := conn.SetReadDeadline(time.Now().Add(constants.TCPIdleTimeout))
+		if err != nil {
+			return
 		}
 
 		b, err := s.Reader.ReadTCP(conn)
 		if err != nil {
-			s.Logger.Error(logger.ErrTCPRead,
-				zap.String("context", "server"),
-				zap.Error(err),
-			)
+			if !errors.Is(err, io.EOF) && !errors.Is(err, os.ErrDeadlineExceeded) {
+				s.Logger.Error(logger.ErrTCPRead,
+					zap.String("context", "server"),
+					zap.Error(err),
+				)
+			}
+			return
 		}
 
 		header, offset, err := s.Unpacker.UnpackHeader(b)
@@ -35,6 +61,7 @@ func (s *Server) serveTCP() {
 				zap.String("context", "server"),
 				zap.Error(err),
 			)
+			return
 		}
 
 		switch result := s.AcceptFunc(header); result {
@@ -45,27 +72,41 @@ func (s *Server) serveTCP() {
 					zap.String("context", "server"),
 					zap.Error(err),
 				)
+				return
 			}
 
 			s.conns.Add(1)
-			go s.handleTCP(m, conn)
+			if !s.verify(b, m) {
+				s.writeTCP(m, conn)
+				continue
+			}
+
+			s.handleTCP(m, conn)
 		default:
 			// TODO (Techassi): Handle with appropriate response
 			s.Logger.Info(logger.ErrAcceptMessage,
 				zap.String("context", "server"),
 				zap.String("reason", result.String()),
-				zap.Error(err),
 			)
-			panic("Not accepted")
+			return
 		}
 	}
 }
 
 // handleTCP handles name matching and returns a response message via TCP
 func (s *Server) handleTCP(message *dns.Message, conn *net.TCPConn) {
+	if len(message.Question) > 0 && isTransfer(message.Question[0]) {
+		s.handleTransfer(message, conn)
+		return
+	}
+
 	// NOTE (Techassi): This is a little ugly
 	addr := conn.RemoteAddr().(*net.TCPAddr)
-	message, err := s.handle(message, addr.AddrPort())
+	message, err := s.handle(message, addr.AddrPort(), "tcp", "")
+	if err == ErrQueryDropped {
+		return
+	}
+
 	if err != nil {
 		s.Logger.Error(logger.ErrHandleRequest,
 			zap.String("context", "server"),
@@ -90,9 +131,9 @@ func (s *Server) writeTCP(message *dns.Message, conn *net.TCPConn) {
 		return
 	}
 
-	err = s.Writer.WriteTCPClose(conn, b)
+	err = s.Writer.WriteTCP(conn, b)
 	if err != nil {
-		s.Logger.Error(logger.ErrTCPWriteClose,
+		s.Logger.Error(logger.ErrTCPWrite,
 			zap.String("context", "server"),
 			zap.Error(err),
 		)
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/go-void/portal/pkg/constants"
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/logger"
+	"github.com/go-void/portal/pkg/types/dns"
+	"github.com/go-void/portal/pkg/types/rcode"
+	"github.com/go-void/portal/pkg/types/rr"
+
+	"go.uber.org/zap"
+)
+
+// transferMessageSize is the size in octets after which records of a zone
+// transfer continue in the next message. This stays well below the maximum
+// message size as some clients handle smaller messages better
+const transferMessageSize = 16384
+
+// isTransfer returns if the question requests a zone transfer
+func isTransfer(question dns.Question) bool {
+	return question.Type == rr.TypeAXFR || question.Type == rr.TypeIXFR
+}
+
+// allowTransfer returns the origin of the zone requested by the message and
+// if the client is allowed to transfer the zone. The message must be signed
+// if the zone has a key
+func (s *Server) allowTransfer(message *dns.Message, addr netip.Addr) (string, bool) {
+	question := message.Question[0]
+
+	origin, ok := s.RecordStore.FindZone(question.Name)
+	if !ok || !strings.EqualFold(origin, labels.Rootify(question.Name)) {
+		return "", false
+	}
+
+	options, ok := s.zones[origin]
+	if !ok {
+		return "", false
+	}
+
+	return origin, options.TransferACL.Allows(addr) && authenticated(message, options.Key)
+}
+
+// handleTransfer answers AXFR and IXFR requests via TCP. The records can
+// span multiple messages.
+// See https://datatracker.ietf.org/doc/html/rfc5936#section-2.2 and
+// https://datatracker.ietf.org/doc/html/rfc1995#section-4
+func (s *Server) handleTransfer(message *dns.Message, conn *net.TCPConn) {
+	defer s.conns.Done()
+
+	var (
+		addr     = conn.RemoteAddr().(*net.TCPAddr).AddrPort().Addr()
+		question = message.Question[0]
+	)
+
+	origin, ok := s.allowTransfer(message, addr)
+	if !ok {
+		s.Logger.Info(logger.ErrTransferRefused,
+			zap.String("context", "server"),
+			zap.String("zone", question.Name),
+			zap.String("address", addr.String()),
+		)
+		s.writeTransferError(message, rcode.Refused, conn)
+		return
+	}
+
+	records, err := s.RecordStore.Zone(origin)
+	if err != nil {
+		s.writeTransferError(message, rcode.ServerFailure, conn)
+		return
+	}
+
+	var answer []rr.RR
+	switch question.Type {
+	case rr.TypeAXFR:
+		answer = fullTransfer(records)
+	case rr.TypeIXFR:
+		if len(message.Authority) == 0 || message.Authority[0].Header().Type != rr.TypeSOA {
+			s.writeTransferError(message, rcode.FormatError, conn)
+			return
+		}
+		answer = s.incrementalTransfer(origin, message.Authority[0].(*rr.SOA), records)
+	}
+
+	s.Logger.Debug("transfer zone",
+		zap.String("context", "server"),
+		zap.String("zone", origin),
+		zap.String("type", rr.TypeToString(question.Type)),
+		zap.String("address", addr.String()),
+		zap.Int("records", len(answer)),
+	)
+
+	err = s.writeTransfer(message, answer, conn)
+	if err != nil {
+		s.Logger.Error(logger.ErrTCPWrite,
+			zap.String("context", "server"),
+			zap.Error(err),
+		)
+	}
+}
+
+// handleUDPTransfer answers AXFR and IXFR requests via UDP. AXFR is not
+// supported via UDP. IXFR is answered with the current SOA record, which
+// tells the client to retry via TCP if it isn't up to date.
+// See https://datatracker.ietf.org/doc/html/rfc1995#section-4
+func (s *Server) handleUDPTransfer(message *dns.Message, addr netip.Addr) *dns.Message {
+	message.SetIsResponse()
+
+	if message.Question[0].Type == rr.TypeAXFR {
+		message.Header.RCode = rcode.NotImplemented
+		return message
+	}
+
+	origin, ok := s.allowTransfer(message, addr)
+	if !ok {
+		message.Header.RCode = rcode.Refused
+		return message
+	}
+
+	records, err := s.RecordStore.Zone(origin)
+	if err != nil {
+		message.Header.RCode = rcode.ServerFailure
+		return message
+	}
+
+	message.Header.Authoritative = true
+	message.AddAnswer(records[0])
+	return message
+}
+
+// incrementalTransfer returns the records of an IXFR response. If the
+// journal doesn't cover the serial of the client, the response falls back
+// to the format of a full transfer
+func (s *Server) incrementalTransfer(origin string, client *rr.SOA, records []rr.RR) []rr.RR {
+	current := records[0].(*rr.SOA)
+
+	// The client is up to date, which is answered with the current SOA
+	if client.SerialCompare(current) != rr.SerialLess {
+		return []rr.RR{current}
+	}
+
+	deltas, ok := s.RecordStore.Changes(origin, client.Serial)
+	if !ok {
+		return fullTransfer(records)
+	}
+
+	answer := []rr.RR{current}
+	for _, delta := range deltas {
+		answer = append(answer, delta.From)
+		answer = append(answer, delta.Deleted...)
+		answer = append(answer, delta.To)
+		answer = append(answer, delta.Added...)
+	}
+
+	return append(answer, current)
+}
+
+// fullTransfer returns the records of an AXFR response, which starts and
+// ends with the SOA record
+func fullTransfer(records []rr.RR) []rr.RR {
+	answer := make([]rr.RR, 0, len(records)+1)
+	answer = append(answer, records...)
+	return append(answer, records[0])
+}
+
+// writeTransfer writes the answer records in one or more messages to the
+// TCP conn. Every message repeats the ID and the question of the request
+func (s *Server) writeTransfer(request *dns.Message, answer []rr.RR, conn *net.TCPConn) error {
+	for len(answer) > 0 {
+		message := transferMessage(request)
+
+		size := message.Len()
+		for len(answer) > 0 {
+			record := answer[0]
+			size += labels.Len(record.Header().Name) + constants.RRHeaderFixedLen + int(record.Len())
+
+			// Every message contains at least one record
+			if size > transferMessageSize && message.Header.ANCount > 0 {
+				break
+			}
+
+			message.AddAnswer(record)
+			answer = answer[1:]
+		}
+
+		b, err := s.Packer.Pack(message)
+		if err != nil {
+			return err
+		}
+
+		err = s.Writer.WriteTCP(conn, b)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeTransferError writes a single message with the provided rcode to
+// the TCP conn
+func (s *Server) writeTransferError(request *dns.Message, code rcode.Code, conn *net.TCPConn) {
+	message := transferMessage(request)
+	message.Header.Authoritative = false
+	message.Header.RCode = code
+
+	b, err := s.Packer.Pack(message)
+	if err != nil {
+		s.Logger.Error(logger.ErrPackDNSMessage,
+			zap.String("context", "server"),
+			zap.Error(err),
+		)
+		return
+	}
+
+	err = s.Writer.WriteTCP(conn, b)
+	if err != nil {
+		s.Logger.Error(logger.ErrTCPWrite,
+			zap.String("context", "server"),
+			zap.Error(err),
+		)
+	}
+}
+
+// transferMessage returns a new empty authoritative response to the
+// transfer request. The response shares the transaction of a signed request
+func transferMessage(request *dns.Message) *dns.Message {
+	message := dns.NewMessageWith(dns.Header{
+		ID:               request.Header.ID,
+		OpCode:           request.Header.OpCode,
+		Authoritative:    true,
+		RecursionDesired: request.Header.RecursionDesired,
+	})
+	message.AddQuestion(request.Question[0])
+	message.TSIG = request.TSIG
+
+	return message
+}
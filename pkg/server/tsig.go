@@ -0,0 +1,65 @@
+package server
+
+import (
+	"github.com/go-void/portal/pkg/logger"
+	"github.com/go-void/portal/pkg/tsig"
+	"github.com/go-void/portal/pkg/types/dns"
+	"github.com/go-void/portal/pkg/types/rcode"
+	"github.com/go-void/portal/pkg/types/rr"
+
+	"go.uber.org/zap"
+)
+
+// verify verifies the TSIG RR of a signed message and removes it from the
+// additional section. The response shares the transaction of the request
+// and thus gets signed as well. If the verification fails, the message is
+// turned into the error response and false is returned.
+// See https://datatracker.ietf.org/doc/html/rfc8945#section-5.2
+func (s *Server) verify(data []byte, message *dns.Message) bool {
+	n := len(message.Additional)
+	if n == 0 || message.Additional[n-1].Header().Type != rr.TypeTSIG {
+		return true
+	}
+
+	record := message.Additional[n-1]
+	message.Additional = message.Additional[:n-1]
+	message.Header.ARCount--
+
+	t, err := tsig.Receive(data, s.keys)
+	if err == nil {
+		message.TSIG = t
+		return true
+	}
+
+	s.Logger.Info(logger.ErrVerifyTSIG,
+		zap.String("context", "server"),
+		zap.String("key", record.Header().Name),
+		zap.Error(err),
+	)
+
+	// A malformed TSIG RR can't be used to sign the response
+	code := rcode.NotAuth
+	if t == nil {
+		code = rcode.FormatError
+	}
+
+	message.TSIG = t
+	message.SetIsResponse()
+	message.Answer, message.Header.ANCount = nil, 0
+	message.Authority, message.Header.NSCount = nil, 0
+	message.Additional, message.Header.ARCount = nil, 0
+	message.Header.RCode = code
+
+	return false
+}
+
+// authenticated returns if the message is signed with the key. If the key
+// is nil, the message doesn't need to be signed
+func authenticated(message *dns.Message, key *tsig.Key) bool {
+	if key == nil {
+		return true
+	}
+
+	t := message.TSIG
+	return t != nil && t.Error == rcode.NoError && t.Key != nil && t.Key.Name == key.Name
+}
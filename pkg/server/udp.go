@@ -41,6 +41,11 @@ func (s *Server) serveUDP() {
 			}
 
 			s.conns.Add(1)
+			if !s.verify(b, m) {
+				go s.writeUDP(m, session)
+				continue
+			}
+
 			go s.handleUDP(m, session)
 		default:
 			// TODO (Techassi): Handle with appropriate response
@@ -56,7 +61,11 @@ func (s *Server) serveUDP() {
 
 // handleUDP handles name matching and returns a response message via UDP
 func (s *Server) handleUDP(message *dns.Message, session dns.Session) {
-	message, err := s.handle(message, session.AddrPort)
+	message, err := s.handle(message, session.AddrPort, "udp", "")
+	if err == ErrQueryDropped {
+		return
+	}
+
 	if err != nil {
 		s.Logger.Error(logger.ErrHandleRequest,
 			zap.String("context", "server"),
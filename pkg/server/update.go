@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/logger"
+	"github.com/go-void/portal/pkg/types/dns"
+	"github.com/go-void/portal/pkg/types/rcode"
+	"github.com/go-void/portal/pkg/types/rr"
+
+	"go.uber.org/zap"
+)
+
+// handleUpdate answers UPDATE messages. Only primary zones can be updated
+// and only by clients allowed by the update ACL of the zone. Secondaries
+// get notified after each change.
+// See https://datatracker.ietf.org/doc/html/rfc2136#section-3
+func (s *Server) handleUpdate(message *dns.Message, addr netip.Addr) *dns.Message {
+	var (
+		zone   = message.Question[0]
+		origin = strings.ToLower(labels.Rootify(zone.Name))
+		code   = rcode.NoError
+	)
+
+	options, ok := s.zones[origin]
+	switch {
+	case zone.Type != rr.TypeSOA:
+		code = rcode.FormatError
+	case !ok || options.Type != "primary":
+		code = rcode.NotAuth
+	case !options.UpdateACL.Allows(addr) || !authenticated(message, options.Key):
+		s.Logger.Info(logger.ErrUpdateRefused,
+			zap.String("context", "server"),
+			zap.String("zone", zone.Name),
+			zap.String("address", addr.String()),
+		)
+		code = rcode.Refused
+	default:
+		var soa *rr.SOA
+		code, soa = s.Updater.Apply(origin, message)
+
+		if code == rcode.ServerFailure {
+			s.Logger.Error(logger.ErrUpdateZone,
+				zap.String("context", "server"),
+				zap.String("zone", origin),
+			)
+		}
+
+		if soa != nil {
+			s.Logger.Info("updated zone",
+				zap.String("context", "server"),
+				zap.String("zone", origin),
+				zap.Uint32("serial", soa.Serial),
+				zap.String("address", addr.String()),
+			)
+		}
+	}
+
+	// The response only contains the zone section of the request.
+	// See https://datatracker.ietf.org/doc/html/rfc2136#section-3.8
+	message.SetIsResponse()
+	message.Answer, message.Header.ANCount = nil, 0
+	message.Authority, message.Header.NSCount = nil, 0
+	message.Additional, message.Header.ARCount = nil, 0
+	message.Header.RCode = code
+
+	return message
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"github.com/go-void/portal/pkg/types/rr"
+)
+
+// MaxJournalDeltas limits how many deltas are kept per zone. Older
+// deltas get dropped, which forces clients to do a full transfer
+const MaxJournalDeltas = 100
+
+// Delta describes the changes between two versions of a zone.
+// See https://datatracker.ietf.org/doc/html/rfc1995#section-4
+type Delta struct {
+	// From is the SOA record of the old version of the zone
+	From *rr.SOA
+
+	// To is the SOA record of the new version of the zone
+	To *rr.SOA
+
+	// Deleted contains the records which were removed
+	Deleted []rr.RR
+
+	// Added contains the records which were added
+	Added []rr.RR
+}
+
+// Journal keeps the most recent deltas of a zone in order of their serial
+type Journal struct {
+	deltas []Delta
+}
+
+// NewJournal returns a new empty journal
+func NewJournal() *Journal {
+	return &Journal{}
+}
+
+// Add appends a delta to the journal. If the delta doesn't continue from
+// the most recent serial, the history is broken and all older deltas are
+// dropped
+func (j *Journal) Add(delta Delta) {
+	if len(j.deltas) > 0 && j.deltas[len(j.deltas)-1].To.Serial != delta.From.Serial {
+		j.deltas = nil
+	}
+
+	j.deltas = append(j.deltas, delta)
+	if len(j.deltas) > MaxJournalDeltas {
+		j.deltas = j.deltas[len(j.deltas)-MaxJournalDeltas:]
+	}
+}
+
+// Since returns all deltas which lead from the version with the provided
+// serial to the current version and if the journal covers this serial
+func (j *Journal) Since(serial uint32) ([]Delta, bool) {
+	for i, delta := range j.deltas {
+		if delta.From.Serial == serial {
+			deltas := make([]Delta, len(j.deltas)-i)
+			copy(deltas, j.deltas[i:])
+			return deltas, true
+		}
+	}
+	return nil, false
+}
+
+// Diff returns the delta between two versions of a zone. The SOA records
+// are not part of the deleted and added records. Records are compared by
+// their presentation format, which means TTL changes are changes as well
+func Diff(from, to []rr.RR) Delta {
+	var (
+		delta   = Delta{}
+		current = make(map[string]bool, len(to))
+		old     = make(map[string]bool, len(from))
+	)
+
+	for _, record := range to {
+		if soa, ok := record.(*rr.SOA); ok {
+			delta.To = soa
+			continue
+		}
+		current[record.String()] = true
+	}
+
+	for _, record := range from {
+		if soa, ok := record.(*rr.SOA); ok {
+			delta.From = soa
+			continue
+		}
+
+		old[record.String()] = true
+		if !current[record.String()] {
+			delta.Deleted = append(delta.Deleted, record)
+		}
+	}
+
+	for _, record := range to {
+		if _, ok := record.(*rr.SOA); ok {
+			continue
+		}
+
+		if !old[record.String()] {
+			delta.Added = append(delta.Added, record)
+		}
+	}
+
+	return delta
+}
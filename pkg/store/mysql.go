@@ -1 +1,58 @@
 package store
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/go-void/portal/pkg/config"
+
+	driver "github.com/go-sql-driver/mysql"
+)
+
+// mysql is the dialect of MySQL and MariaDB databases
+var mysql = dialect{
+	driver: "mysql",
+	migrations: [][]string{
+		{
+			`CREATE TABLE zones (
+				id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+				origin VARCHAR(255) NOT NULL UNIQUE,
+				version BIGINT UNSIGNED NOT NULL DEFAULT 0
+			)`,
+			`CREATE TABLE records (
+				id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+				zone_id BIGINT NULL,
+				name VARCHAR(255) NOT NULL,
+				class SMALLINT UNSIGNED NOT NULL,
+				type SMALLINT UNSIGNED NOT NULL,
+				ttl INT UNSIGNED NOT NULL,
+				data TEXT NOT NULL,
+				INDEX records_zone_id (zone_id),
+				INDEX records_name (name),
+				FOREIGN KEY (zone_id) REFERENCES zones (id)
+			)`,
+		},
+	},
+}
+
+// mysqlDSN returns the DSN of the MySQL / MariaDB database. The host and
+// port default to 127.0.0.1:3306
+func mysqlDSN(opts config.StoreOptions) string {
+	host, port := opts.Host, opts.Port
+	if host == "" {
+		host = "127.0.0.1"
+	}
+
+	if port == 0 {
+		port = 3306
+	}
+
+	cfg := driver.NewConfig()
+	cfg.User = opts.Username
+	cfg.Passwd = opts.Password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(host, strconv.Itoa(port))
+	cfg.DBName = opts.Database
+
+	return cfg.FormatDSN()
+}
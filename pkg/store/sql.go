@@ -0,0 +1,792 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-void/portal/pkg/config"
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/logger"
+	"github.com/go-void/portal/pkg/tree"
+	"github.com/go-void/portal/pkg/types/dns"
+	"github.com/go-void/portal/pkg/types/rr"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNoSuchBackend    = errors.New("store: no such backend")
+	ErrInvalidRecord    = errors.New("store: invalid record in database")
+	ErrUnknownMigration = errors.New("store: database schema is newer than supported")
+)
+
+// DefaultCacheTTL is the interval in which the SQL store checks the
+// database for zones and custom records which were changed by others
+const DefaultCacheTTL = 30 * time.Second
+
+// dialect describes the differences between the supported SQL databases
+type dialect struct {
+	// driver is the name of the database/sql driver
+	driver string
+
+	// migrations contains the statements of each schema version. The
+	// schema version is the index of the migration plus one
+	migrations [][]string
+}
+
+// Open returns the record store of the configured backend. The default
+// backend is the in-memory store. SQL stores check the database for changes
+// in the background and log failed checks with the logger
+func Open(opts config.StoreOptions, l *logger.Logger) (Store, error) {
+	var (
+		s   *SQLStore
+		err error
+	)
+
+	switch opts.Backend {
+	case "", "default":
+		return NewDefault(), nil
+	case "sqlite":
+		s, err = NewSQL(sqlite, opts.Database, time.Duration(opts.CacheTTL)*time.Second)
+	case "mysql", "mariadb":
+		s, err = NewSQL(mysql, mysqlDSN(opts), time.Duration(opts.CacheTTL)*time.Second)
+	default:
+		return nil, ErrNoSuchBackend
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.Run(l)
+	return s, nil
+}
+
+// SQLStore implements a store backed by a SQL database. Zones are loaded
+// into an in-memory cache on first access and reloaded once their version
+// in the database changed. All records outside of zones are cached as well
+type SQLStore struct {
+	db *sql.DB
+
+	// cache holds the zones which were accessed since the store was
+	// opened. It also keeps the journal of each zone
+	cache *DefaultStore
+
+	// custom contains all records which don't belong to any zone
+	custom *tree.Tree
+
+	// origins maps the origin of each zone in the database to its
+	// version, while loaded maps the origin of each cached zone to
+	// the version it was loaded at
+	origins map[string]uint64
+	loaded  map[string]uint64
+
+	// refreshing serializes refreshes, which means an older snapshot
+	// of the database never replaces a newer one
+	refreshing sync.Mutex
+
+	ttl  time.Duration
+	stop chan struct{}
+	lock sync.RWMutex
+	wg   sync.WaitGroup
+}
+
+// NewSQL opens the database with the dialect and migrates the schema to
+// the most recent version. A TTL of zero uses the default cache TTL
+func NewSQL(d dialect, dsn string, ttl time.Duration) (*SQLStore, error) {
+	db, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.driver == sqlite.driver {
+		// SQLite only allows a single writer at a time. A single
+		// connection avoids busy errors between concurrent writes
+		db.SetMaxOpenConns(1)
+	}
+
+	err = migrate(db, d.migrations)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if ttl == 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	s := &SQLStore{
+		db:      db,
+		cache:   NewDefault(),
+		custom:  tree.New(),
+		origins: make(map[string]uint64),
+		loaded:  make(map[string]uint64),
+		ttl:     ttl,
+		stop:    make(chan struct{}),
+	}
+
+	err = s.refresh()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Run starts checking the database for changes made by others in the
+// background. Failed checks are logged and the cache keeps serving the
+// previous data
+func (s *SQLStore) Run(l *logger.Logger) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.ttl)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+
+			err := s.refresh()
+			if err != nil {
+				l.Error(logger.ErrRefreshStore,
+					zap.String("context", "store"),
+					zap.Error(err),
+				)
+			}
+		}
+	}()
+}
+
+// Close stops checking the database for changes and closes the database
+func (s *SQLStore) Close() error {
+	close(s.stop)
+	s.wg.Wait()
+	return s.db.Close()
+}
+
+// GetFromQuestion returns a record by name and the selected type's data.
+// Example: example.com with type A would return 93.184.216.34
+func (s *SQLStore) GetFromQuestion(question dns.Question) ([]rr.RR, error) {
+	return s.Get(question.Name, question.Class, question.Type)
+}
+
+// Get returns a record by name and the selected type's data.
+// Example: example.com with type A would return 93.184.216.34
+func (s *SQLStore) Get(name string, class, t uint16) ([]rr.RR, error) {
+	if origin, ok := s.FindZone(name); ok {
+		if err := s.load(origin); err != nil {
+			return nil, err
+		}
+		return s.cache.Get(name, class, t)
+	}
+
+	s.lock.RLock()
+	node, err := s.custom.Get(canonical(name))
+	s.lock.RUnlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return node.Records(class, t)
+}
+
+// Has returns if the name exists. This includes empty non-terminals
+func (s *SQLStore) Has(name string) bool {
+	if origin, ok := s.FindZone(name); ok {
+		return s.load(origin) == nil && s.cache.Has(name)
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	_, err := s.custom.Get(canonical(name))
+	return err == nil
+}
+
+// Add adds new resource records to the store. Records of a zone are added
+// in a transaction, which increments the serial of the zone
+func (s *SQLStore) Add(name string, records []rr.RR) error {
+	if origin, ok := s.FindZone(name); ok {
+		return change(s, origin, func(t Txn) error {
+			for _, record := range records {
+				if err := t.Add(record); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	return s.updateCustom(func(tx *sql.Tx) error {
+		existing, err := queryRecords(tx, "zone_id IS NULL AND name = ?", canonical(name))
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			if containsRecord(existing, record) {
+				continue
+			}
+
+			if err := insertRecord(tx, nil, record); err != nil {
+				return err
+			}
+			existing = append(existing, record)
+		}
+
+		return nil
+	})
+}
+
+// ReplaceRRset replaces all records with the provided name, class and type.
+// No records delete the RRset
+func (s *SQLStore) ReplaceRRset(name string, class, t uint16, records []rr.RR) error {
+	if origin, ok := s.FindZone(name); ok {
+		return change(s, origin, func(txn Txn) error {
+			return txn.Replace(name, class, t, records)
+		})
+	}
+
+	for _, record := range records {
+		if !matches(record, name, class, t) {
+			return ErrMismatch
+		}
+	}
+
+	return s.updateCustom(func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM records WHERE zone_id IS NULL AND name = ? AND class = ? AND type = ?",
+			canonical(name), class, t)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			if err := insertRecord(tx, nil, record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeleteRRset deletes all records with the provided name, class and type.
+// The type ANY deletes all records of the name
+func (s *SQLStore) DeleteRRset(name string, class, t uint16) error {
+	if origin, ok := s.FindZone(name); ok {
+		return change(s, origin, func(txn Txn) error {
+			return txn.Delete(name, class, t)
+		})
+	}
+
+	return s.updateCustom(func(tx *sql.Tx) error {
+		if t == rr.TypeANY {
+			_, err := tx.Exec("DELETE FROM records WHERE zone_id IS NULL AND name = ?", canonical(name))
+			return err
+		}
+
+		_, err := tx.Exec("DELETE FROM records WHERE zone_id IS NULL AND name = ? AND class = ? AND type = ?",
+			canonical(name), class, t)
+		return err
+	})
+}
+
+// AddZone adds all records of the zone with the provided origin. An
+// existing zone with the same origin gets replaced
+func (s *SQLStore) AddZone(origin string, records []rr.RR) error {
+	origin = canonical(origin)
+
+	if _, err := buildZone(origin, records); err != nil {
+		return err
+	}
+
+	// The current version is loaded first to record the changes
+	// in the journal of the zone
+	if err := s.load(origin); err != nil && !errors.Is(err, ErrNoSuchZone) {
+		return err
+	}
+
+	var version uint64
+	err := s.transaction(func(tx *sql.Tx) error {
+		id, v, err := upsertZone(tx, origin)
+		if err != nil {
+			return err
+		}
+
+		version = v
+		return replaceRecords(tx, id, records)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.cacheZone(origin, version, records)
+}
+
+// RemoveZone removes the zone with the provided origin and its journal
+func (s *SQLStore) RemoveZone(origin string) error {
+	origin = canonical(origin)
+
+	// Loading the zone first makes sure subscribers get notified
+	if err := s.load(origin); err != nil {
+		return err
+	}
+
+	err := s.transaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM records WHERE zone_id = (SELECT id FROM zones WHERE origin = ?)", origin)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("DELETE FROM zones WHERE origin = ?", origin)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	delete(s.origins, origin)
+	delete(s.loaded, origin)
+	s.lock.Unlock()
+
+	return s.cache.RemoveZone(origin)
+}
+
+// FindZone returns the origin of the closest zone enclosing the name and
+// if such a zone exists
+func (s *SQLStore) FindZone(name string) (string, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for name = canonical(name); name != ""; name = labels.Parent(name) {
+		if _, ok := s.origins[name]; ok {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// Zones returns the origins of all zones in lexical order
+func (s *SQLStore) Zones() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	origins := make([]string, 0, len(s.origins))
+	for origin := range s.origins {
+		origins = append(origins, origin)
+	}
+
+	sort.Strings(origins)
+	return origins
+}
+
+// Zone returns all records of the zone with the provided origin. The SOA
+// record is always the first record
+func (s *SQLStore) Zone(origin string) ([]rr.RR, error) {
+	if err := s.load(canonical(origin)); err != nil {
+		return nil, err
+	}
+
+	return s.cache.Zone(origin)
+}
+
+// Walk calls the function for every record of the zone with the provided
+// origin, starting with the SOA record. Walking stops if the function
+// returns false
+func (s *SQLStore) Walk(origin string, fn func(rr.RR) bool) error {
+	if err := s.load(canonical(origin)); err != nil {
+		return err
+	}
+
+	return s.cache.Walk(origin, fn)
+}
+
+// Begin starts a transaction on the zone with the provided origin. The
+// transaction fails to commit if the zone was changed in the database in
+// the meantime
+func (s *SQLStore) Begin(origin string) (Txn, error) {
+	origin = canonical(origin)
+
+	if err := s.load(origin); err != nil {
+		return nil, err
+	}
+
+	records, err := s.cache.Zone(origin)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	version := s.loaded[origin]
+	s.lock.RUnlock()
+
+	return newTxn(s, origin, version, records), nil
+}
+
+// Changes returns the deltas of the zone with the provided origin which
+// lead from the version with the provided serial to the current version.
+// The journal is kept in memory and starts empty whenever the store is
+// opened
+func (s *SQLStore) Changes(origin string, serial uint32) ([]Delta, bool) {
+	if err := s.load(canonical(origin)); err != nil {
+		return nil, false
+	}
+
+	return s.cache.Changes(origin, serial)
+}
+
+// Subscribe registers a function which gets called after every change of a
+// zone. Changes made by others are only noticed once the store checked the
+// database in the background and the zone gets accessed
+func (s *SQLStore) Subscribe(fn func(Change)) {
+	s.cache.Subscribe(fn)
+}
+
+// commit replaces the records of the zone in the database if the version
+// of the zone didn't change since the transaction started
+func (s *SQLStore) commit(origin string, version uint64, records []rr.RR) error {
+	if _, err := buildZone(origin, records); err != nil {
+		return err
+	}
+
+	err := s.transaction(func(tx *sql.Tx) error {
+		result, err := tx.Exec("UPDATE zones SET version = version + 1 WHERE origin = ? AND version = ?", origin, version)
+		if err != nil {
+			return err
+		}
+
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return ErrConflict
+		}
+
+		var id int64
+		err = tx.QueryRow("SELECT id FROM zones WHERE origin = ?", origin).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		return replaceRecords(tx, id, records)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.cacheZone(origin, version+1, records)
+}
+
+// load loads the zone with the provided origin into the cache unless the
+// cached version is still current
+func (s *SQLStore) load(origin string) error {
+	s.lock.RLock()
+	version, ok := s.origins[origin]
+	cached, isCached := s.loaded[origin]
+	s.lock.RUnlock()
+
+	if !ok {
+		return ErrNoSuchZone
+	}
+
+	if isCached && cached == version {
+		return nil
+	}
+
+	var records []rr.RR
+	err := s.transaction(func(tx *sql.Tx) error {
+		err := tx.QueryRow("SELECT version FROM zones WHERE origin = ?", origin).Scan(&version)
+		if err == sql.ErrNoRows {
+			return ErrNoSuchZone
+		}
+
+		if err != nil {
+			return err
+		}
+
+		records, err = queryRecords(tx, "zone_id = (SELECT id FROM zones WHERE origin = ?)", origin)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.cacheZone(origin, version, records)
+}
+
+// cacheZone replaces the cached zone with the records of the version
+func (s *SQLStore) cacheZone(origin string, version uint64, records []rr.RR) error {
+	err := s.cache.AddZone(origin, records)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	s.origins[origin] = version
+	s.loaded[origin] = version
+	s.lock.Unlock()
+
+	return nil
+}
+
+// refresh reloads the versions of all zones and the custom records from
+// the database. Changed zones get reloaded on their next access. If the
+// database can't be read, the cache keeps serving the previous data
+func (s *SQLStore) refresh() error {
+	s.refreshing.Lock()
+	defer s.refreshing.Unlock()
+
+	var (
+		origins = make(map[string]uint64)
+		custom  = tree.New()
+	)
+
+	err := s.transaction(func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT origin, version FROM zones")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				origin  string
+				version uint64
+			)
+
+			if err := rows.Scan(&origin, &version); err != nil {
+				return err
+			}
+			origins[origin] = version
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		records, err := queryRecords(tx, "zone_id IS NULL")
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			node, err := custom.Populate(canonical(record.Header().Name))
+			if err != nil {
+				return err
+			}
+			node.AddRecords([]rr.RR{record})
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+
+	var removed []string
+	for origin := range s.loaded {
+		if _, ok := origins[origin]; !ok {
+			removed = append(removed, origin)
+			delete(s.loaded, origin)
+		}
+	}
+
+	s.origins = origins
+	s.custom = custom
+	s.lock.Unlock()
+
+	for _, origin := range removed {
+		s.cache.RemoveZone(origin)
+	}
+
+	return nil
+}
+
+// updateCustom runs the function in a database transaction and reloads
+// the custom records afterwards
+func (s *SQLStore) updateCustom(fn func(*sql.Tx) error) error {
+	err := s.transaction(fn)
+	if err != nil {
+		return err
+	}
+
+	return s.refresh()
+}
+
+// transaction runs the function in a database transaction, which is
+// committed if the function returns no error
+func (s *SQLStore) transaction(fn func(*sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// migrate creates the schema_migrations table if needed and runs all
+// migrations which were not run yet in order
+func migrate(db *sql.DB, migrations [][]string) error {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL)")
+	if err != nil {
+		return err
+	}
+
+	var current int
+	err = db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	if current > len(migrations) {
+		return ErrUnknownMigration
+	}
+
+	for i := current; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		for _, statement := range migrations[i] {
+			if _, err := tx.Exec(statement); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("store: migration %d: %w", i+1, err)
+			}
+		}
+
+		if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES (?)", i+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// upsertZone inserts the zone if it doesn't exist yet and increments its
+// version. It returns the ID and the new version of the zone
+func upsertZone(tx *sql.Tx, origin string) (int64, uint64, error) {
+	var (
+		id      int64
+		version uint64
+	)
+
+	err := tx.QueryRow("SELECT id, version FROM zones WHERE origin = ?", origin).Scan(&id, &version)
+	if err == sql.ErrNoRows {
+		result, err := tx.Exec("INSERT INTO zones (origin, version) VALUES (?, 1)", origin)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		id, err = result.LastInsertId()
+		return id, 1, err
+	}
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	_, err = tx.Exec("UPDATE zones SET version = ? WHERE id = ?", version+1, id)
+	return id, version + 1, err
+}
+
+// replaceRecords replaces all records of the zone with the provided ID
+func replaceRecords(tx *sql.Tx, id int64, records []rr.RR) error {
+	_, err := tx.Exec("DELETE FROM records WHERE zone_id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if err := insertRecord(tx, id, record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertRecord inserts the record. The RDATA is stored in presentation
+// format, which keeps the records readable and editable by others. The
+// zone ID is nil for custom records
+func insertRecord(tx *sql.Tx, zone interface{}, record rr.RR) error {
+	h := record.Header()
+
+	_, err := tx.Exec("INSERT INTO records (zone_id, name, class, type, ttl, data) VALUES (?, ?, ?, ?, ?, ?)",
+		zone, canonical(h.Name), h.Class, h.Type, h.TTL, presentationData(record))
+	return err
+}
+
+// queryRecords returns all records matching the condition
+func queryRecords(tx *sql.Tx, condition string, args ...interface{}) ([]rr.RR, error) {
+	rows, err := tx.Query("SELECT name, class, type, ttl, data FROM records WHERE "+condition+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []rr.RR
+	for rows.Next() {
+		var (
+			name, data string
+			class, t   uint16
+			ttl        uint32
+		)
+
+		if err := rows.Scan(&name, &class, &t, &ttl, &data); err != nil {
+			return nil, err
+		}
+
+		record, err := rr.Parse(fmt.Sprintf("%s %d %s %s %s", name, ttl,
+			rr.ClassToString(class), rr.TypeToString(t), data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", ErrInvalidRecord, name, err)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+// presentationData returns the RDATA of the record in presentation format,
+// which follows the owner name, TTL, class and type
+func presentationData(record rr.RR) string {
+	fields := strings.SplitN(record.String(), "\t", 5)
+	return fields[len(fields)-1]
+}
+
+// containsRecord returns if the records contain an identical record
+func containsRecord(records []rr.RR, record rr.RR) bool {
+	for _, existing := range records {
+		if sameRRset(existing, record) && existing.IsSame(record) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,504 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-void/portal/pkg/types/rr"
+)
+
+// testZone is a minimal valid zone with an SOA and NS record at its apex
+var testZone = []string{
+	"example.com. 3600 IN SOA ns1.example.com. admin.example.com. 1 7200 3600 1209600 300",
+	"example.com. 3600 IN NS ns1.example.com.",
+	"ns1.example.com. 3600 IN A 192.0.2.1",
+	"www.example.com. 300 IN A 192.0.2.10",
+	"www.example.com. 300 IN A 192.0.2.11",
+}
+
+// testDSN returns the DSN of a shared in-memory SQLite database, which is
+// dropped once the last connection to it is closed. All stores opened
+// within the same test share the database
+func testDSN(t *testing.T) string {
+	return fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+}
+
+// openSQLite opens a store backed by the in-memory database of the test
+func openSQLite(t *testing.T) *SQLStore {
+	t.Helper()
+
+	s, err := NewSQL(sqlite, testDSN(t), 0)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func parseRecords(t *testing.T, lines []string) []rr.RR {
+	t.Helper()
+
+	records := make([]rr.RR, 0, len(lines))
+	for _, line := range lines {
+		record, err := rr.Parse(line)
+		if err != nil {
+			t.Fatalf("failed to parse record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func recordStrings(records []rr.RR) []string {
+	s := make([]string, 0, len(records))
+	for _, record := range records {
+		s = append(s, record.String())
+	}
+	return s
+}
+
+func TestSQLMigrate(t *testing.T) {
+	tests := []struct {
+		name       string
+		migrations [][]string
+		reopen     [][]string
+		version    int
+		err        error
+	}{
+		{
+			name:       "all migrations",
+			migrations: sqlite.migrations,
+			version:    len(sqlite.migrations),
+		},
+		{
+			name:       "idempotent",
+			migrations: sqlite.migrations,
+			reopen:     sqlite.migrations,
+			version:    len(sqlite.migrations),
+		},
+		{
+			name:       "additional migration",
+			migrations: sqlite.migrations,
+			reopen:     append(sqlite.migrations[:len(sqlite.migrations):len(sqlite.migrations)], []string{"CREATE INDEX records_type ON records (type)"}),
+			version:    len(sqlite.migrations) + 1,
+		},
+		{
+			name:       "newer schema",
+			migrations: append(sqlite.migrations[:len(sqlite.migrations):len(sqlite.migrations)], []string{"CREATE INDEX records_type ON records (type)"}),
+			reopen:     sqlite.migrations,
+			version:    len(sqlite.migrations) + 1,
+			err:        ErrUnknownMigration,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := openSQLite(t)
+
+			// The store already ran the migrations of the dialect, which
+			// makes the schema_migrations table start at that version
+			_, err := s.db.Exec("DELETE FROM schema_migrations")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, table := range []string{"records", "zones"} {
+				if _, err := s.db.Exec("DROP TABLE " + table); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = migrate(s.db, test.migrations)
+			if err != nil {
+				t.Fatalf("failed to migrate: %v", err)
+			}
+
+			if test.reopen != nil {
+				err = migrate(s.db, test.reopen)
+				if !errors.Is(err, test.err) {
+					t.Fatalf("expected error %v, got %v", test.err, err)
+				}
+			}
+
+			var version int
+			err = s.db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if version != test.version {
+				t.Errorf("expected schema version %d, got %d", test.version, version)
+			}
+		})
+	}
+}
+
+func TestSQLZoneRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  string
+		records []string
+		err     error
+	}{
+		{
+			name:    "valid zone",
+			origin:  "example.com.",
+			records: testZone,
+		},
+		{
+			name:    "origin without trailing dot",
+			origin:  "Example.COM",
+			records: testZone,
+		},
+		{
+			name:    "missing SOA",
+			origin:  "example.com.",
+			records: testZone[1:],
+			err:     ErrMissingSOA,
+		},
+		{
+			name:    "out of zone",
+			origin:  "example.com.",
+			records: append(testZone[:len(testZone):len(testZone)], "www.example.org. 300 IN A 192.0.2.1"),
+			err:     ErrOutOfZone,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := openSQLite(t)
+
+			records := parseRecords(t, test.records)
+			err := s.AddZone(test.origin, records)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+
+			if test.err != nil {
+				if zones := s.Zones(); len(zones) != 0 {
+					t.Errorf("expected no zones, got %v", zones)
+				}
+				return
+			}
+
+			// A second store reads the zone from the database instead of
+			// the cache of the first one
+			other, err := NewSQL(sqlite, testDSN(t), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer other.Close()
+
+			for _, store := range []*SQLStore{s, other} {
+				got, err := store.Zone(test.origin)
+				if err != nil {
+					t.Fatalf("failed to get zone: %v", err)
+				}
+
+				if want := recordStrings(records); strings.Join(recordStrings(got), "\n") != strings.Join(want, "\n") {
+					t.Errorf("expected records\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(recordStrings(got), "\n"))
+				}
+
+				if origin, ok := store.FindZone("www.example.com."); !ok || origin != "example.com." {
+					t.Errorf("expected zone example.com., got %q", origin)
+				}
+			}
+		})
+	}
+}
+
+func TestSQLTxnConflict(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// concurrent commits a change through a second store while the
+		// transaction of the first store is still open
+		concurrent bool
+		err        error
+		serial     uint32
+	}{
+		{
+			name:   "no conflict",
+			serial: 2,
+		},
+		{
+			name:       "changed by other store",
+			concurrent: true,
+			err:        ErrConflict,
+			serial:     2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := openSQLite(t)
+			if err := s.AddZone("example.com.", parseRecords(t, testZone)); err != nil {
+				t.Fatal(err)
+			}
+
+			txn, err := s.Begin("example.com.")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.concurrent {
+				other, err := NewSQL(sqlite, testDSN(t), 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer other.Close()
+
+				err = other.Add("mail.example.com.", parseRecords(t, []string{"mail.example.com. 300 IN A 192.0.2.20"}))
+				if err != nil {
+					t.Fatalf("failed to add record with other store: %v", err)
+				}
+			}
+
+			err = txn.Add(parseRecords(t, []string{"ftp.example.com. 300 IN A 192.0.2.30"})[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = txn.Commit()
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+
+			// Reload the versions to see the commit of the other store
+			if err := s.refresh(); err != nil {
+				t.Fatal(err)
+			}
+
+			records, err := s.Zone("example.com.")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if serial := records[0].(*rr.SOA).Serial; serial != test.serial {
+				t.Errorf("expected serial %d, got %d", test.serial, serial)
+			}
+		})
+	}
+}
+
+func TestSQLRRset(t *testing.T) {
+	tests := []struct {
+		name    string
+		owner   string
+		replace []string
+		delete  bool
+		want    []string
+		err     error
+	}{
+		{
+			name:    "replace zone RRset",
+			owner:   "www.example.com.",
+			replace: []string{"www.example.com. 60 IN A 192.0.2.99"},
+			want:    []string{"www.example.com.\t60\tIN\tA\t192.0.2.99"},
+		},
+		{
+			name:   "delete zone RRset",
+			owner:  "www.example.com.",
+			delete: true,
+		},
+		{
+			name:    "replace custom RRset",
+			owner:   "host.example.org.",
+			replace: []string{"host.example.org. 60 IN A 198.51.100.1", "host.example.org. 60 IN A 198.51.100.2"},
+			want:    []string{"host.example.org.\t60\tIN\tA\t198.51.100.1", "host.example.org.\t60\tIN\tA\t198.51.100.2"},
+		},
+		{
+			name:   "delete custom RRset",
+			owner:  "host.example.org.",
+			delete: true,
+		},
+		{
+			name:    "mismatching record",
+			owner:   "host.example.org.",
+			replace: []string{"other.example.org. 60 IN A 198.51.100.1"},
+			err:     ErrMismatch,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := openSQLite(t)
+			if err := s.AddZone("example.com.", parseRecords(t, testZone)); err != nil {
+				t.Fatal(err)
+			}
+
+			err := s.Add("host.example.org.", parseRecords(t, []string{"host.example.org. 300 IN A 198.51.100.10"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.delete {
+				err = s.DeleteRRset(test.owner, rr.IN, rr.TypeA)
+			} else {
+				err = s.ReplaceRRset(test.owner, rr.IN, rr.TypeA, parseRecords(t, test.replace))
+			}
+
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+
+			if test.err != nil {
+				return
+			}
+
+			records, _ := s.Get(test.owner, rr.IN, rr.TypeA)
+			if got := strings.Join(recordStrings(records), "\n"); got != strings.Join(test.want, "\n") {
+				t.Errorf("expected records\n%s\ngot\n%s", strings.Join(test.want, "\n"), got)
+			}
+		})
+	}
+}
+
+func TestSQLSubscribe(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*SQLStore) error
+		kind   ChangeKind
+		serial uint32
+	}{
+		{
+			name: "add zone",
+			change: func(s *SQLStore) error {
+				return nil
+			},
+			kind:   ZoneAdded,
+			serial: 1,
+		},
+		{
+			name: "update zone",
+			change: func(s *SQLStore) error {
+				record, err := rr.Parse("mail.example.com. 300 IN A 192.0.2.20")
+				if err != nil {
+					return err
+				}
+				return s.Add("mail.example.com.", []rr.RR{record})
+			},
+			kind:   ZoneUpdated,
+			serial: 2,
+		},
+		{
+			name: "remove zone",
+			change: func(s *SQLStore) error {
+				return s.RemoveZone("example.com.")
+			},
+			kind: ZoneRemoved,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := openSQLite(t)
+
+			var changes []Change
+			s.Subscribe(func(change Change) {
+				changes = append(changes, change)
+			})
+
+			if err := s.AddZone("example.com.", parseRecords(t, testZone)); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := test.change(s); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(changes) == 0 {
+				t.Fatal("expected a change notification")
+			}
+
+			last := changes[len(changes)-1]
+			if last.Kind != test.kind || last.Origin != "example.com." {
+				t.Fatalf("expected change %d of example.com., got %d of %s", test.kind, last.Kind, last.Origin)
+			}
+
+			if test.kind == ZoneRemoved {
+				if last.SOA != nil {
+					t.Errorf("expected no SOA record, got %s", last.SOA)
+				}
+				return
+			}
+
+			if last.SOA == nil || last.SOA.Serial != test.serial {
+				t.Errorf("expected serial %d, got %v", test.serial, last.SOA)
+			}
+		})
+	}
+}
+
+func TestSQLReplaceZone(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// corrupt stores invalid data for a record of the existing zone
+		corrupt bool
+		kind    ChangeKind
+		err     error
+	}{
+		{
+			name: "zone of other store",
+			kind: ZoneUpdated,
+		},
+		{
+			name:    "invalid record in database",
+			corrupt: true,
+			err:     ErrInvalidRecord,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := openSQLite(t)
+			if err := s.AddZone("example.com.", parseRecords(t, testZone)); err != nil {
+				t.Fatal(err)
+			}
+
+			if test.corrupt {
+				_, err := s.db.Exec("UPDATE records SET data = 'invalid' WHERE name = 'www.example.com.'")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// The zone isn't cached by the other store yet
+			other, err := NewSQL(sqlite, testDSN(t), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer other.Close()
+
+			var changes []Change
+			other.Subscribe(func(change Change) {
+				changes = append(changes, change)
+			})
+
+			lines := append([]string{"example.com. 3600 IN SOA ns1.example.com. admin.example.com. 2 7200 3600 1209600 300"}, testZone[1:]...)
+			err = other.AddZone("example.com.", parseRecords(t, lines))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+
+			if test.err != nil {
+				if len(changes) != 0 {
+					t.Errorf("expected no change notification, got %d", len(changes))
+				}
+				return
+			}
+
+			// Loading the zone into the cache adds it first
+			if len(changes) == 0 || changes[len(changes)-1].Kind != test.kind {
+				t.Fatalf("expected change %d, got %+v", test.kind, changes)
+			}
+
+			if from := changes[len(changes)-1].Delta.From; from == nil || from.Serial != 1 {
+				t.Errorf("expected delta from serial 1, got %v", from)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package store
+
+import (
+	// Registers the pure Go SQLite driver, which doesn't require cgo
+	_ "modernc.org/sqlite"
+)
+
+// sqlite is the dialect of SQLite databases. The DSN is the path of the
+// database file
+var sqlite = dialect{
+	driver: "sqlite",
+	migrations: [][]string{
+		{
+			`CREATE TABLE zones (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				origin VARCHAR(255) NOT NULL UNIQUE,
+				version INTEGER NOT NULL DEFAULT 0
+			)`,
+			`CREATE TABLE records (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				zone_id INTEGER REFERENCES zones (id),
+				name VARCHAR(255) NOT NULL,
+				class INTEGER NOT NULL,
+				type INTEGER NOT NULL,
+				ttl INTEGER NOT NULL,
+				data TEXT NOT NULL
+			)`,
+			`CREATE INDEX records_zone_id ON records (zone_id)`,
+			`CREATE INDEX records_name ON records (name)`,
+		},
+	},
+}
@@ -2,11 +2,27 @@
 package store
 
 import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-void/portal/pkg/labels"
 	"github.com/go-void/portal/pkg/tree"
 	"github.com/go-void/portal/pkg/types/dns"
 	"github.com/go-void/portal/pkg/types/rr"
 )
 
+var (
+	ErrMissingSOA = errors.New("store: zone has no SOA record at apex")
+	ErrMissingNS  = errors.New("store: zone has no NS record at apex")
+	ErrOutOfZone  = errors.New("store: record out of zone")
+	ErrNoSuchZone = errors.New("store: no such zone")
+	ErrConflict   = errors.New("store: zone changed during transaction")
+	ErrTxnDone    = errors.New("store: transaction already committed or rolled back")
+	ErrMismatch   = errors.New("store: record doesn't belong to RRset")
+)
+
 type Store interface {
 	// GetFromQuestion returns records by name and the selected type's data.
 	// Example: example.com with type A would return 93.184.216.34
@@ -18,50 +34,169 @@ type Store interface {
 
 	// Add adds a new resource records to the store
 	Add(string, []rr.RR) error
+
+	// ReplaceRRset replaces all records with the provided name, class and
+	// type. No records delete the RRset
+	ReplaceRRset(string, uint16, uint16, []rr.RR) error
+
+	// DeleteRRset deletes all records with the provided name, class and
+	// type. The type ANY deletes all records of the name
+	DeleteRRset(string, uint16, uint16) error
+
+	// AddZone adds all records of the zone with the provided origin. An
+	// existing zone with the same origin gets replaced
+	AddZone(string, []rr.RR) error
+
+	// RemoveZone removes the zone with the provided origin and its journal
+	RemoveZone(string) error
+
+	// FindZone returns the origin of the closest zone enclosing the name
+	// and if such a zone exists
+	FindZone(string) (string, bool)
+
+	// Zones returns the origins of all zones in lexical order
+	Zones() []string
+
+	// Has returns if the name exists. This includes empty non-terminals,
+	// which don't own any records but have descendants which do
+	Has(string) bool
+
+	// Zone returns all records of the zone with the provided origin. The
+	// SOA record is always the first record
+	Zone(string) ([]rr.RR, error)
+
+	// Walk calls the function for every record of the zone with the
+	// provided origin, starting with the SOA record. Walking stops if the
+	// function returns false
+	Walk(string, func(rr.RR) bool) error
+
+	// Begin starts a transaction on the zone with the provided origin
+	Begin(string) (Txn, error)
+
+	// Changes returns the deltas of the zone with the provided origin which
+	// lead from the version with the provided serial to the current version
+	// and if the journal of the zone covers this serial
+	Changes(string, uint32) ([]Delta, bool)
+
+	// Subscribe registers a function which gets called after every change
+	// of a zone
+	Subscribe(func(Change))
 }
 
 // DefaultStore implements a default store based on a in-memory tree structure
 type DefaultStore struct {
 	*tree.Tree
+
+	// zones maps the origin of each zone to the records of
+	// the zone and its journal
+	zones map[string]*zone
+
+	// subscribers get called after every change of a zone
+	subscribers []func(Change)
+	lock        sync.RWMutex
+}
+
+// zone holds the current version of a zone
+type zone struct {
+	// tree contains all records of the zone
+	tree *tree.Tree
+
+	// journal keeps the changes between versions of the zone
+	journal *Journal
+
+	// version gets incremented whenever the zone gets replaced,
+	// which allows detecting conflicting transactions
+	version uint64
 }
 
 func NewDefault() *DefaultStore {
 	return &DefaultStore{
-		Tree: tree.New(),
+		Tree:  tree.New(),
+		zones: make(map[string]*zone),
 	}
 }
 
 // GetFromQuestion returns a record by name and the selected type's data.
 // Example: example.com with type A would return 93.184.216.34
 func (s *DefaultStore) GetFromQuestion(question dns.Question) ([]rr.RR, error) {
-	node, err := s.Tree.Get(question.Name)
-	if err != nil {
-		return nil, err
+	return s.Get(question.Name, question.Class, question.Type)
+}
+
+// Add adds a new resource record to the store. Records of a zone are added
+// in a transaction, which increments the serial of the zone
+func (s *DefaultStore) Add(name string, records []rr.RR) error {
+	if origin, ok := s.FindZone(name); ok {
+		return change(s, origin, func(t Txn) error {
+			for _, record := range records {
+				if err := t.Add(record); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
 	}
 
-	records, err := node.Records(question.Class, question.Type)
+	node, err := s.Tree.Populate(strings.ToLower(name))
 	if err != nil {
-		return nil, err
+		return tree.ErrNodeNotFound
 	}
 
-	return records, err
+	node.AddRecords(records)
+	return nil
 }
 
-// Add adds a new resource record to the store
-func (s *DefaultStore) Add(name string, records []rr.RR) error {
-	node, err := s.Tree.Populate(name)
+// ReplaceRRset replaces all records with the provided name, class and type.
+// No records delete the RRset
+func (s *DefaultStore) ReplaceRRset(name string, class, t uint16, records []rr.RR) error {
+	if origin, ok := s.FindZone(name); ok {
+		return change(s, origin, func(txn Txn) error {
+			return txn.Replace(name, class, t, records)
+		})
+	}
+
+	for _, record := range records {
+		if !matches(record, name, class, t) {
+			return ErrMismatch
+		}
+	}
+
+	node, err := s.Tree.Populate(strings.ToLower(name))
 	if err != nil {
 		return tree.ErrNodeNotFound
 	}
 
-	node.AddRecords(records)
+	node.SetRecords(class, t, records)
+	return nil
+}
+
+// DeleteRRset deletes all records with the provided name, class and type.
+// The type ANY deletes all records of the name
+func (s *DefaultStore) DeleteRRset(name string, class, t uint16) error {
+	if origin, ok := s.FindZone(name); ok {
+		return change(s, origin, func(txn Txn) error {
+			return txn.Delete(name, class, t)
+		})
+	}
+
+	node, err := s.Tree.Get(strings.ToLower(name))
+	if err != nil {
+		return nil
+	}
+
+	if t == rr.TypeANY {
+		node.RemoveRecords()
+	} else {
+		node.SetRecords(class, t, nil)
+	}
+
+	s.Tree.Prune(name)
 	return nil
 }
 
 // Get returns a record by name and the selected type's data.
 // Example: example.com with type A would return 93.184.216.34
 func (s *DefaultStore) Get(name string, class, t uint16) ([]rr.RR, error) {
-	node, err := s.Tree.Get(name)
+	node, err := s.treeFor(name).Get(strings.ToLower(name))
 	if err != nil {
 		return nil, err
 	}
@@ -73,3 +208,301 @@ func (s *DefaultStore) Get(name string, class, t uint16) ([]rr.RR, error) {
 
 	return records, err
 }
+
+// AddZone adds all records of the zone with the provided origin. The zone
+// is built in a separate tree first and then replaces any existing zone
+// with the same origin at once. Every record must be at or below the origin
+// and the zone must have a SOA and NS record at its apex. If the serial of
+// the replacing zone is greater, the changes get recorded in the journal
+func (s *DefaultStore) AddZone(origin string, records []rr.RR) error {
+	origin = strings.ToLower(labels.Rootify(origin))
+
+	t, err := buildZone(origin, records)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	change := s.replaceZone(origin, t)
+	s.lock.Unlock()
+
+	s.notify(change)
+	return nil
+}
+
+// RemoveZone removes the zone with the provided origin and its journal
+func (s *DefaultStore) RemoveZone(origin string) error {
+	origin = strings.ToLower(labels.Rootify(origin))
+
+	s.lock.Lock()
+	if _, ok := s.zones[origin]; !ok {
+		s.lock.Unlock()
+		return ErrNoSuchZone
+	}
+
+	delete(s.zones, origin)
+	s.lock.Unlock()
+
+	s.notify(Change{
+		Kind:   ZoneRemoved,
+		Origin: origin,
+	})
+	return nil
+}
+
+// Zone returns all records of the zone with the provided origin. The SOA
+// record is always the first record
+func (s *DefaultStore) Zone(origin string) ([]rr.RR, error) {
+	s.lock.RLock()
+	z, ok := s.zones[strings.ToLower(labels.Rootify(origin))]
+	s.lock.RUnlock()
+
+	if !ok {
+		return nil, ErrNoSuchZone
+	}
+
+	return zoneRecords(z.tree), nil
+}
+
+// Walk calls the function for every record of the zone with the provided
+// origin, starting with the SOA record. Walking stops if the function
+// returns false
+func (s *DefaultStore) Walk(origin string, fn func(rr.RR) bool) error {
+	records, err := s.Zone(origin)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if !fn(record) {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Zones returns the origins of all zones in lexical order
+func (s *DefaultStore) Zones() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	origins := make([]string, 0, len(s.zones))
+	for origin := range s.zones {
+		origins = append(origins, origin)
+	}
+
+	sort.Strings(origins)
+	return origins
+}
+
+// Begin starts a transaction on the zone with the provided origin. The
+// transaction works on a snapshot of the zone, which replaces the zone at
+// once when committed
+func (s *DefaultStore) Begin(origin string) (Txn, error) {
+	origin = strings.ToLower(labels.Rootify(origin))
+
+	s.lock.RLock()
+	z, ok := s.zones[origin]
+	s.lock.RUnlock()
+
+	if !ok {
+		return nil, ErrNoSuchZone
+	}
+
+	return newTxn(s, origin, z.version, zoneRecords(z.tree)), nil
+}
+
+// Changes returns the deltas of the zone with the provided origin which
+// lead from the version with the provided serial to the current version
+func (s *DefaultStore) Changes(origin string, serial uint32) ([]Delta, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	z, ok := s.zones[strings.ToLower(labels.Rootify(origin))]
+	if !ok {
+		return nil, false
+	}
+
+	return z.journal.Since(serial)
+}
+
+// Subscribe registers a function which gets called after every change of a
+// zone. Functions are called in order of registration and should not block
+func (s *DefaultStore) Subscribe(fn func(Change)) {
+	s.lock.Lock()
+	s.subscribers = append(s.subscribers, fn)
+	s.lock.Unlock()
+}
+
+// FindZone returns the origin of the closest zone enclosing the name and
+// if such a zone exists
+func (s *DefaultStore) FindZone(name string) (string, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for name = strings.ToLower(labels.Rootify(name)); name != ""; name = labels.Parent(name) {
+		if _, ok := s.zones[name]; ok {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// Has returns if the name exists. This includes empty non-terminals
+func (s *DefaultStore) Has(name string) bool {
+	_, err := s.treeFor(name).Get(strings.ToLower(name))
+	return err == nil
+}
+
+// commit replaces the zone with the records of the transaction if the zone
+// didn't change since the transaction started
+func (s *DefaultStore) commit(origin string, version uint64, records []rr.RR) error {
+	t, err := buildZone(origin, records)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	z, ok := s.zones[origin]
+	if !ok || z.version != version {
+		s.lock.Unlock()
+		return ErrConflict
+	}
+
+	change := s.replaceZone(origin, t)
+	s.lock.Unlock()
+
+	s.notify(change)
+	return nil
+}
+
+// replaceZone replaces the zone with origin and records the changes in the
+// journal. The lock must be held by the caller
+func (s *DefaultStore) replaceZone(origin string, t *tree.Tree) Change {
+	old, ok := s.zones[origin]
+	if !ok {
+		s.zones[origin] = &zone{
+			tree:    t,
+			journal: NewJournal(),
+		}
+
+		return Change{
+			Kind:   ZoneAdded,
+			Origin: origin,
+			SOA:    zoneRecords(t)[0].(*rr.SOA),
+		}
+	}
+
+	var (
+		delta   = Diff(old.tree.Records(), t.Records())
+		journal = old.journal
+	)
+
+	switch delta.From.SerialCompare(delta.To) {
+	case rr.SerialLess:
+		journal.Add(delta)
+	case rr.SerialGreater:
+		journal = NewJournal()
+	}
+
+	s.zones[origin] = &zone{
+		tree:    t,
+		journal: journal,
+		version: old.version + 1,
+	}
+
+	return Change{
+		Kind:   ZoneUpdated,
+		Origin: origin,
+		SOA:    delta.To,
+		Delta:  delta,
+	}
+}
+
+// notify calls all subscribers with the change
+func (s *DefaultStore) notify(change Change) {
+	s.lock.RLock()
+	subscribers := s.subscribers
+	s.lock.RUnlock()
+
+	for _, fn := range subscribers {
+		fn(change)
+	}
+}
+
+// treeFor returns the tree of the closest zone enclosing the name or the
+// tree of custom records if no such zone exists
+func (s *DefaultStore) treeFor(name string) *tree.Tree {
+	origin, ok := s.FindZone(name)
+	if !ok {
+		return s.Tree
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if z, ok := s.zones[origin]; ok {
+		return z.tree
+	}
+	return s.Tree
+}
+
+// buildZone builds the tree of a zone. Every record must be at or below the
+// origin and the zone must have a SOA and NS record at its apex
+func buildZone(origin string, records []rr.RR) (*tree.Tree, error) {
+	var (
+		zone   = tree.New()
+		hasSOA = false
+		hasNS  = false
+	)
+
+	for _, record := range records {
+		name := strings.ToLower(record.Header().Name)
+		if !labels.IsSubdomain(name, origin) {
+			return nil, ErrOutOfZone
+		}
+
+		if labels.Rootify(name) == origin {
+			switch record.Header().Type {
+			case rr.TypeSOA:
+				hasSOA = true
+			case rr.TypeNS:
+				hasNS = true
+			}
+		}
+
+		node, err := zone.Populate(name)
+		if err != nil {
+			return nil, err
+		}
+		node.AddRecords([]rr.RR{record})
+	}
+
+	if !hasSOA {
+		return nil, ErrMissingSOA
+	}
+
+	if !hasNS {
+		return nil, ErrMissingNS
+	}
+
+	return zone, nil
+}
+
+// zoneRecords returns all records of the zone tree with the SOA record as
+// the first record
+func zoneRecords(t *tree.Tree) []rr.RR {
+	// The apex comes first in the tree, which means the SOA record
+	// is within the first few records
+	records := t.Records()
+	for i, record := range records {
+		if record.Header().Type == rr.TypeSOA {
+			records[0], records[i] = records[i], records[0]
+			break
+		}
+	}
+
+	return records
+}
@@ -0,0 +1,309 @@
+package store
+
+import (
+	"strings"
+
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/types/rr"
+)
+
+// ChangeKind describes how a zone changed
+type ChangeKind int
+
+const (
+	ZoneAdded ChangeKind = iota
+	ZoneUpdated
+	ZoneRemoved
+)
+
+// Change describes a change of a zone which gets passed to subscribers
+type Change struct {
+	Kind ChangeKind
+
+	// Origin is the origin of the changed zone
+	Origin string
+
+	// SOA is the SOA record of the current version of the zone. It is nil
+	// if the zone was removed
+	SOA *rr.SOA
+
+	// Delta contains the changed records if the zone was updated
+	Delta Delta
+}
+
+// Txn describes a transaction on a single zone. Changes are only visible
+// within the transaction until they are committed, which replaces the zone
+// at once
+type Txn interface {
+	// Get returns records by name, class and type. The type ANY returns
+	// all records of the name
+	Get(string, uint16, uint16) []rr.RR
+
+	// Records returns all records of the zone with the SOA record as the
+	// first record
+	Records() []rr.RR
+
+	// Add adds a record. An identical record gets replaced, which allows
+	// changing the TTL
+	Add(rr.RR) error
+
+	// Remove removes a record
+	Remove(rr.RR) error
+
+	// Replace replaces all records with the provided name, class and type.
+	// No records delete the RRset
+	Replace(string, uint16, uint16, []rr.RR) error
+
+	// Delete deletes all records with the provided name, class and type.
+	// The type ANY deletes all records of the name
+	Delete(string, uint16, uint16) error
+
+	// Commit validates the zone and replaces the current version with it.
+	// The serial gets incremented unless it was already increased within
+	// the transaction. It returns the SOA record of the new version or nil
+	// if nothing changed
+	Commit() (*rr.SOA, error)
+
+	// Rollback discards all changes
+	Rollback()
+}
+
+// committer replaces a zone with the records of a transaction if the zone
+// is still at the version the transaction started with
+type committer interface {
+	commit(string, uint64, []rr.RR) error
+}
+
+// txn implements a transaction on a snapshot of the records of a zone
+type txn struct {
+	store   committer
+	origin  string
+	version uint64
+
+	// base contains the records of the zone when the transaction started
+	base []rr.RR
+
+	// records maps each name to its records. Names are kept in order of
+	// their first appearance to keep the order of the zone stable
+	records map[string][]rr.RR
+	names   []string
+	done    bool
+}
+
+func newTxn(s committer, origin string, version uint64, records []rr.RR) *txn {
+	t := &txn{
+		store:   s,
+		origin:  origin,
+		version: version,
+		base:    records,
+		records: make(map[string][]rr.RR),
+	}
+
+	for _, record := range records {
+		name := canonical(record.Header().Name)
+		if _, ok := t.records[name]; !ok {
+			t.names = append(t.names, name)
+		}
+		t.records[name] = append(t.records[name], record)
+	}
+
+	return t
+}
+
+func (t *txn) Get(name string, class, typ uint16) []rr.RR {
+	var records []rr.RR
+
+	for _, record := range t.records[canonical(name)] {
+		if typ == rr.TypeANY || matches(record, name, class, typ) {
+			records = append(records, record)
+		}
+	}
+
+	return records
+}
+
+func (t *txn) Records() []rr.RR {
+	var records []rr.RR
+
+	for _, name := range t.names {
+		records = append(records, t.records[name]...)
+	}
+
+	for i, record := range records {
+		if record.Header().Type == rr.TypeSOA {
+			records[0], records[i] = records[i], records[0]
+			break
+		}
+	}
+
+	return records
+}
+
+func (t *txn) Add(record rr.RR) error {
+	if t.done {
+		return ErrTxnDone
+	}
+
+	name := canonical(record.Header().Name)
+	if !labels.IsSubdomain(name, t.origin) {
+		return ErrOutOfZone
+	}
+
+	records, ok := t.records[name]
+	if !ok {
+		t.names = append(t.names, name)
+	}
+
+	for i, existing := range records {
+		if sameRRset(existing, record) && existing.IsSame(record) {
+			updated := make([]rr.RR, len(records))
+			copy(updated, records)
+			updated[i] = record
+
+			t.records[name] = updated
+			return nil
+		}
+	}
+
+	t.records[name] = append(records[:len(records):len(records)], record)
+	return nil
+}
+
+func (t *txn) Remove(record rr.RR) error {
+	if t.done {
+		return ErrTxnDone
+	}
+
+	name := canonical(record.Header().Name)
+	t.records[name] = filter(t.records[name], func(existing rr.RR) bool {
+		return !sameRRset(existing, record) || !existing.IsSame(record)
+	})
+
+	return nil
+}
+
+func (t *txn) Replace(name string, class, typ uint16, records []rr.RR) error {
+	if t.done {
+		return ErrTxnDone
+	}
+
+	for _, record := range records {
+		if !matches(record, name, class, typ) {
+			return ErrMismatch
+		}
+	}
+
+	if err := t.Delete(name, class, typ); err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if err := t.Add(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *txn) Delete(name string, class, typ uint16) error {
+	if t.done {
+		return ErrTxnDone
+	}
+
+	name = canonical(name)
+	t.records[name] = filter(t.records[name], func(record rr.RR) bool {
+		return typ != rr.TypeANY && !matches(record, name, class, typ)
+	})
+
+	return nil
+}
+
+func (t *txn) Commit() (*rr.SOA, error) {
+	if t.done {
+		return nil, ErrTxnDone
+	}
+	t.done = true
+
+	var (
+		records = t.Records()
+		delta   = Diff(t.base, records)
+	)
+
+	if delta.To == nil {
+		return nil, ErrMissingSOA
+	}
+
+	if len(delta.Added) == 0 && len(delta.Deleted) == 0 && delta.From.String() == delta.To.String() {
+		return nil, nil
+	}
+
+	// Any change of the zone requires a new serial. Unless the serial was
+	// increased within the transaction, the SOA record gets copied to keep
+	// the current version untouched
+	if delta.From.SerialCompare(delta.To) != rr.SerialLess {
+		soa := rr.Copy(delta.To).(*rr.SOA)
+		soa.Serial = delta.From.Serial
+		soa.SerialAdd(1)
+
+		records[0] = soa
+		delta.To = soa
+	}
+
+	if err := t.store.commit(t.origin, t.version, records); err != nil {
+		return nil, err
+	}
+
+	return delta.To, nil
+}
+
+func (t *txn) Rollback() {
+	t.done = true
+}
+
+// change applies the changes of the function to the zone with origin in a
+// single transaction
+func change(s Store, origin string, fn func(Txn) error) error {
+	t, err := s.Begin(origin)
+	if err != nil {
+		return err
+	}
+
+	err = fn(t)
+	if err != nil {
+		t.Rollback()
+		return err
+	}
+
+	_, err = t.Commit()
+	return err
+}
+
+// matches returns if the record belongs to the RRset with the provided
+// name, class and type
+func matches(record rr.RR, name string, class, t uint16) bool {
+	header := record.Header()
+	return header.Class == class && header.Type == t && canonical(header.Name) == canonical(name)
+}
+
+// sameRRset returns if both records belong to the same RRset
+func sameRRset(a, b rr.RR) bool {
+	return matches(a, b.Header().Name, b.Header().Class, b.Header().Type)
+}
+
+// filter returns the records for which keep returns true in a new slice
+func filter(records []rr.RR, keep func(rr.RR) bool) []rr.RR {
+	var filtered []rr.RR
+
+	for _, record := range records {
+		if keep(record) {
+			filtered = append(filtered, record)
+		}
+	}
+
+	return filtered
+}
+
+func canonical(name string) string {
+	return strings.ToLower(labels.Rootify(name))
+}
@@ -1,54 +1,130 @@
 package tree
 
 import (
+	"sort"
+	"strings"
+
 	"github.com/go-void/portal/pkg/types/rr"
 )
 
+// key identifies a RRset of a node
+type key struct {
+	class uint16
+	t     uint16
+}
+
+// Node stores the records of a single name. Nodes are only valid as long
+// as they are part of the tree, which guards them with its lock
 type Node struct {
-	parent   *Node
-	children map[string]Node
-	records  map[uint16][]rr.RR
+	tree   *Tree
+	parent *Node
+
+	// label is the lowercase label of the node
+	label string
+
+	children map[string]*Node
+	records  map[key][]rr.RR
+
+	// value is arbitrary data attached to the node by users of the tree
+	// which don't store records
+	value interface{}
 }
 
-// Parent returns this node's parent node
+func newNode(t *Tree, parent *Node, label string) *Node {
+	return &Node{
+		tree:     t,
+		parent:   parent,
+		label:    label,
+		children: make(map[string]*Node),
+		records:  make(map[key][]rr.RR),
+	}
+}
+
+// Parent returns this node's parent node. The root node has no parent
 func (n *Node) Parent() *Node {
 	return n.parent
 }
 
+// Name returns the lowercase, fully qualified name of this node
+func (n *Node) Name() string {
+	if n.parent == nil {
+		return "."
+	}
+
+	name := n.label + "."
+	for p := n.parent; p.parent != nil; p = p.parent {
+		name += p.label + "."
+	}
+	return name
+}
+
 // Child returns this node's child identified by 'name" or an error if this child doesn't exist
-func (n *Node) Child(name string) (Node, error) {
-	if node, ok := n.children[name]; ok {
+func (n *Node) Child(name string) (*Node, error) {
+	n.tree.lock.RLock()
+	defer n.tree.lock.RUnlock()
+
+	if node, ok := n.children[strings.ToLower(name)]; ok {
 		return node, nil
 	}
-	return Node{}, ErrNodeNotFound
+	return nil, ErrNodeNotFound
 }
 
-// AddChild adds a child to this node or returns an error if the child already exists
-func (n *Node) AddChild(name string, child Node) error {
-	if _, ok := n.children[name]; ok {
-		return ErrChildAlreadyExists
-	}
-	n.children[name] = child
-	return nil
+// HasChildren returns if this node has any children
+func (n *Node) HasChildren() bool {
+	n.tree.lock.RLock()
+	defer n.tree.lock.RUnlock()
+
+	return len(n.children) > 0
+}
+
+// HasRecords returns if this node stores any records
+func (n *Node) HasRecords() bool {
+	n.tree.lock.RLock()
+	defer n.tree.lock.RUnlock()
+
+	return len(n.records) > 0
+}
+
+// Value returns the value attached to this node or nil
+func (n *Node) Value() interface{} {
+	n.tree.lock.RLock()
+	defer n.tree.lock.RUnlock()
+
+	return n.value
+}
+
+// SetValue attaches a value to this node. Nodes with a value are not
+// pruned
+func (n *Node) SetValue(v interface{}) {
+	n.tree.lock.Lock()
+	defer n.tree.lock.Unlock()
+
+	n.value = v
 }
 
 // Record returns a stored record with class and type
 func (n *Node) Records(class, t uint16) ([]rr.RR, error) {
-	if entry, ok := n.records[class*100+t]; ok {
+	n.tree.lock.RLock()
+	defer n.tree.lock.RUnlock()
+
+	if entry, ok := n.records[key{class, t}]; ok {
 		return entry, nil
 	}
 	return nil, ErrNoSuchData
 }
 
-// AddRecords adds records to this node
+// AddRecords adds records to this node. Records which are already stored
+// are skipped
 func (n *Node) AddRecords(records []rr.RR) {
-	isSame := false
+	n.tree.lock.Lock()
+	defer n.tree.lock.Unlock()
 
-	for i := 0; i < len(records); i++ {
-		key := records[i].Header().Class*100 + records[i].Header().Type
+	for _, record := range records {
+		k := key{record.Header().Class, record.Header().Type}
+		isSame := false
 
-		for j := 0; j < len(n.records[key]); j++ {
-			if records[i].IsSame(n.records[key][j]) {
+		for _, existing := range n.records[k] {
+			if record.IsSame(existing) {
 				isSame = true
 				break
 			}
@@ -58,6 +134,76 @@ func (n *Node) AddRecords(records []rr.RR) {
 			continue
 		}
 
-		n.records[key] = append(n.records[key], records[i])
+		n.records[k] = append(n.records[k], record)
+	}
+}
+
+// SetRecords replaces the records with class and type. No records remove
+// the RRset
+func (n *Node) SetRecords(class, t uint16, records []rr.RR) {
+	n.tree.lock.Lock()
+	defer n.tree.lock.Unlock()
+
+	k := key{class, t}
+	if len(records) == 0 {
+		delete(n.records, k)
+		return
+	}
+
+	n.records[k] = records
+}
+
+// RemoveRecords removes all records of this node
+func (n *Node) RemoveRecords() {
+	n.tree.lock.Lock()
+	defer n.tree.lock.Unlock()
+
+	n.records = make(map[key][]rr.RR)
+}
+
+// AllRecords returns the records of this node and all of its descendants.
+// Children are visited in lexical order of their labels
+func (n *Node) AllRecords() []rr.RR {
+	n.tree.lock.RLock()
+	defer n.tree.lock.RUnlock()
+
+	return n.allRecords([]rr.RR{})
+}
+
+// allRecords appends the records of this node and its descendants to
+// records. The caller needs to hold the lock
+func (n *Node) allRecords(records []rr.RR) []rr.RR {
+	keys := make([]key, 0, len(n.records))
+	for k := range n.records {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].class != keys[j].class {
+			return keys[i].class < keys[j].class
+		}
+		return keys[i].t < keys[j].t
+	})
+
+	for _, k := range keys {
+		records = append(records, n.records[k]...)
 	}
+
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		records = n.children[name].allRecords(records)
+	}
+
+	return records
+}
+
+// detach removes this node from its parent. The caller needs to hold the
+// write lock
+func (n *Node) detach() {
+	delete(n.parent.children, n.label)
 }
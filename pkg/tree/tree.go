@@ -1,60 +1,77 @@
 // Package tree implements a in-memory tree / node structure to store
-// and retrieve resource record data
+// and retrieve resource record data. Labels are compared case-insensitively
+// and all operations are safe for concurrent use
 package tree
 
 import (
 	"errors"
+	"strings"
+	"sync"
 
 	"github.com/go-void/portal/pkg/labels"
 	"github.com/go-void/portal/pkg/types/rr"
 )
 
 var (
-	ErrNodeNotFound       = errors.New("node not found in tree")
-	ErrNoSuchData         = errors.New("no such data")
-	ErrChildAlreadyExists = errors.New("child already exists")
+	ErrNodeNotFound = errors.New("node not found in tree")
+	ErrNoSuchData   = errors.New("no such data")
 )
 
 // Tree describes a tree structure which stores data for DNS labels
 type Tree struct {
-	root Node
+	root *Node
+
+	// lock guards all nodes of the tree
+	lock sync.RWMutex
 }
 
 func New() *Tree {
-	return &Tree{
-		root: Node{
-			parent:   nil,
-			children: make(map[string]Node),
-			records:  make(map[uint16][]rr.RR),
-		},
-	}
+	t := &Tree{}
+	t.root = newNode(t, nil, ".")
+
+	return t
 }
 
 // Get retrieves a node via a name. Example: example.com traverses
 // the tree like . -> com -> example
-func (t *Tree) Get(name string) (Node, error) {
+func (t *Tree) Get(name string) (*Node, error) {
 	node, err := t.Walk(name)
 	if err != nil {
-		return Node{}, err
+		return nil, err
 	}
 	return node, nil
 }
 
-// Walk traverses the tree until the end of labels is reached
-func (t *Tree) Walk(name string) (Node, error) {
+// Records returns all records stored in the tree
+func (t *Tree) Records() []rr.RR {
+	return t.root.AllRecords()
+}
+
+// Walk traverses the tree until the end of labels is reached. If the name
+// doesn't exist, the deepest existing node along the path is returned with
+// ErrNodeNotFound
+func (t *Tree) Walk(name string) (*Node, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.walk(name)
+}
+
+// walk implements Walk. The caller needs to hold the lock
+func (t *Tree) walk(name string) (*Node, error) {
 	names, ok := labels.FromRoot(name)
 	if !ok {
-		return Node{}, labels.ErrInvalidName
+		return nil, labels.ErrInvalidName
 	}
 
 	current := t.root
-	for _, name := range names {
-		if name == "" || name == "." {
+	for _, label := range names {
+		if label == "" || label == "." {
 			continue
 		}
 
-		node, err := current.Child(name)
-		if err != nil {
+		node, ok := current.children[strings.ToLower(label)]
+		if !ok {
 			return current, ErrNodeNotFound
 		}
 
@@ -66,25 +83,28 @@ func (t *Tree) Walk(name string) (Node, error) {
 
 // WalkChain traverses the tree until the end of labels is reached which
 // returns a list of nodes
-func (t *Tree) WalkChain(name string) ([]Node, error) {
+func (t *Tree) WalkChain(name string) ([]*Node, error) {
 	names, ok := labels.FromRoot(name)
 	if !ok {
 		return nil, labels.ErrInvalidName
 	}
 
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
 	var (
 		current = t.root
-		nodes   = []Node{}
+		nodes   = []*Node{}
 	)
 
-	for _, name := range names {
-		if name == "" || name == "." {
+	for _, label := range names {
+		if label == "" || label == "." {
 			nodes = append(nodes, current)
 			continue
 		}
 
-		node, err := current.Child(name)
-		if err != nil {
+		node, ok := current.children[strings.ToLower(label)]
+		if !ok {
 			return nodes, ErrNodeNotFound
 		}
 
@@ -97,35 +117,116 @@ func (t *Tree) WalkChain(name string) ([]Node, error) {
 
 // Populate traverses the tree and adds nodes along the path which
 // don't exist yet
-func (t *Tree) Populate(name string) (Node, error) {
+func (t *Tree) Populate(name string) (*Node, error) {
 	names, ok := labels.FromRoot(name)
 	if !ok {
-		return Node{}, labels.ErrInvalidName
+		return nil, labels.ErrInvalidName
 	}
 
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
 	current := t.root
-	for _, name := range names {
-		if name == "" || name == "." {
+	for _, label := range names {
+		if label == "" || label == "." {
 			continue
 		}
 
-		node, err := current.Child(name)
-		if err != nil {
-			node := Node{
-				parent:   &current,
-				children: make(map[string]Node),
-				records:  make(map[uint16][]rr.RR),
-			}
-
-			err := current.AddChild(name, node)
-			if err != nil {
-				return Node{}, err
-			}
-
-			current = node
-			continue
+		label = strings.ToLower(label)
+		node, ok := current.children[label]
+		if !ok {
+			node = newNode(t, current, label)
+			current.children[label] = node
 		}
+
 		current = node
 	}
+
 	return current, nil
 }
+
+// ClosestEncloser returns the deepest existing node which is an ancestor
+// of or equal to the name. Nodes without records which have descendants
+// (empty non-terminals) exist as well.
+// See https://datatracker.ietf.org/doc/html/rfc4592#section-3.3.1
+func (t *Tree) ClosestEncloser(name string) (*Node, error) {
+	node, err := t.Walk(name)
+	if err != nil && err != ErrNodeNotFound {
+		return nil, err
+	}
+	return node, nil
+}
+
+// Lookup returns the node of the name. If the name doesn't exist, the
+// wildcard node '*' below the closest encloser is returned, which matches
+// the name. The returned bool indicates if the node is a wildcard match.
+// See https://datatracker.ietf.org/doc/html/rfc4592#section-3.3.3
+func (t *Tree) Lookup(name string) (*Node, bool, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	encloser, err := t.walk(name)
+	if err == nil {
+		return encloser, false, nil
+	}
+
+	if err != ErrNodeNotFound {
+		return nil, false, err
+	}
+
+	if wildcard, ok := encloser.children["*"]; ok {
+		return wildcard, true, nil
+	}
+
+	return nil, false, ErrNodeNotFound
+}
+
+// Delete removes the node of the name including all of its descendants.
+// Ancestors which are left without records and children are removed as
+// well
+func (t *Tree) Delete(name string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	node, err := t.walk(name)
+	if err != nil {
+		return err
+	}
+
+	if node == t.root {
+		node.children = make(map[string]*Node)
+		node.records = make(map[key][]rr.RR)
+		node.value = nil
+		return nil
+	}
+
+	node.detach()
+	prune(node.parent)
+
+	return nil
+}
+
+// Prune removes the node of the name if it has neither records, children
+// nor a value. Ancestors which are left empty are removed as well. This
+// should be called after removing records from a node
+func (t *Tree) Prune(name string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	node, err := t.walk(name)
+	if err != nil {
+		return
+	}
+	prune(node)
+}
+
+// prune removes the node and its ancestors as long as they are empty. The
+// root node is never removed. The caller needs to hold the write lock
+func prune(node *Node) {
+	for ; node != nil && node.parent != nil; node = node.parent {
+		if len(node.records) > 0 || len(node.children) > 0 || node.value != nil {
+			return
+		}
+		node.detach()
+	}
+}
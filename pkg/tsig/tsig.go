@@ -0,0 +1,466 @@
+// Package tsig authenticates DNS messages with transaction signatures based
+// on shared secret keys. See https://datatracker.ietf.org/doc/html/rfc8945
+package tsig
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"strings"
+	"time"
+
+	"github.com/go-void/portal/pkg/compression"
+	"github.com/go-void/portal/pkg/constants"
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/pack"
+	"github.com/go-void/portal/pkg/types/rcode"
+	"github.com/go-void/portal/pkg/types/rr"
+)
+
+var (
+	ErrBadSig           = errors.New("tsig: bad signature")
+	ErrBadKey           = errors.New("tsig: bad key")
+	ErrBadTime          = errors.New("tsig: bad time")
+	ErrUnsigned         = errors.New("tsig: message is not signed")
+	ErrFormat           = errors.New("tsig: malformed message")
+	ErrInvalidAlgorithm = errors.New("tsig: invalid algorithm")
+	ErrInvalidSecret    = errors.New("tsig: invalid secret")
+)
+
+const (
+	HmacSHA256 = "hmac-sha256."
+	HmacSHA512 = "hmac-sha512."
+
+	// DefaultFudge is the permitted difference in seconds between the
+	// time signed and the time of verification
+	DefaultFudge = 300
+
+	// maxUnsigned is the maximum number of consecutive unsigned messages
+	// in a multi-message response.
+	// See https://datatracker.ietf.org/doc/html/rfc8945#section-5.3.1
+	maxUnsigned = 99
+)
+
+var algorithms = map[string]func() hash.Hash{
+	HmacSHA256: sha256.New,
+	HmacSHA512: sha512.New,
+}
+
+// Key is a shared secret key. The name of the key identifies it in
+// both the TSIG RR and the configuration
+type Key struct {
+	Name      string
+	Algorithm string
+	Secret    []byte
+}
+
+// NewKey returns a new key with name and algorithm. The secret is
+// expected to be base64 encoded
+func NewKey(name, algorithm, secret string) (*Key, error) {
+	algorithm = strings.ToLower(labels.Rootify(algorithm))
+	if _, ok := algorithms[algorithm]; !ok {
+		return nil, ErrInvalidAlgorithm
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil || len(decoded) == 0 {
+		return nil, ErrInvalidSecret
+	}
+
+	return &Key{
+		Name:      strings.ToLower(labels.Rootify(name)),
+		Algorithm: algorithm,
+		Secret:    decoded,
+	}, nil
+}
+
+// Keyring maps the names of keys to keys
+type Keyring map[string]*Key
+
+// Add adds the key to the keyring. An existing key with the same name
+// gets replaced
+func (k Keyring) Add(key *Key) {
+	k[key.Name] = key
+}
+
+// Get returns the key with name and if it exists
+func (k Keyring) Get(name string) (*Key, bool) {
+	key, ok := k[strings.ToLower(labels.Rootify(name))]
+	return key, ok
+}
+
+// Transaction keeps the state of a signed exchange of messages. The MAC of
+// a response covers the MAC of the request and the MAC of every following
+// message in a multi-message response covers the MAC of the message before.
+// Both the client and the server use a single transaction for a request
+// and all messages of its response
+type Transaction struct {
+	// Key is the key the messages are signed with. It is nil if the key
+	// of a received request is unknown
+	Key *Key
+
+	// Error is the TSIG error of the received request, which is returned
+	// in the TSIG RR of the response
+	Error rcode.Code
+
+	// request is the TSIG RR of a received request
+	request *rr.TSIG
+
+	// mac is the MAC of the last signed or verified message
+	mac []byte
+
+	// messages is the number of signed and verified messages
+	messages int
+
+	// unsigned holds the unsigned messages of a multi-message response
+	// received since the last signed message
+	unsigned []byte
+	skipped  int
+}
+
+// New returns a new transaction which signs the request and verifies the
+// response with the key
+func New(key *Key) *Transaction {
+	return &Transaction{
+		Key: key,
+	}
+}
+
+// Receive verifies the TSIG RR of a received request with the matching key
+// of the keyring and returns the transaction to sign the response with. If
+// the verification fails, the transaction is returned as well, as the
+// response needs to carry the TSIG error. ErrUnsigned is returned if the
+// request has no TSIG RR.
+// See https://datatracker.ietf.org/doc/html/rfc8945#section-5.2
+func Receive(data []byte, keys Keyring) (*Transaction, error) {
+	message, record, err := split(data)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Transaction{
+		request: record,
+	}
+
+	key, ok := keys.Get(record.H.Name)
+	if !ok {
+		t.Error = rcode.BadKey
+		return t, ErrBadKey
+	}
+	t.Key = key
+
+	return t, t.verify(message, record)
+}
+
+// Sign signs the packed message and returns the message with the TSIG RR
+// appended to the additional section. The response to a request which
+// failed verification because of an unknown key or a bad MAC is not signed.
+// See https://datatracker.ietf.org/doc/html/rfc8945#section-5.3
+func (t *Transaction) Sign(data []byte) ([]byte, error) {
+	if len(data) < constants.DNSHeaderLen {
+		return nil, ErrFormat
+	}
+
+	record := &rr.TSIG{
+		H: rr.Header{
+			Type:  rr.TypeTSIG,
+			Class: rr.ANY,
+		},
+		TimeSigned: uint64(time.Now().Unix()),
+		Fudge:      DefaultFudge,
+		OriginalID: binary.BigEndian.Uint16(data),
+		Error:      uint16(t.Error),
+	}
+
+	if t.Key != nil {
+		record.H.Name = t.Key.Name
+		record.Algorithm = t.Key.Algorithm
+	} else {
+		record.H.Name = t.request.H.Name
+		record.Algorithm = t.request.Algorithm
+	}
+
+	switch t.Error {
+	case rcode.BadKey, rcode.BadSig:
+	case rcode.BadTime:
+		// The response carries the time of the server, which allows the
+		// client to detect the clock skew
+		record.OtherData = uint48(record.TimeSigned)
+		record.OtherLen = uint16(len(record.OtherData))
+		record.TimeSigned = t.request.TimeSigned
+		fallthrough
+	default:
+		record.MAC = t.digest(data, record)
+		record.MACSize = uint16(len(record.MAC))
+
+		t.mac = record.MAC
+		t.messages++
+	}
+
+	return appendRecord(data, record)
+}
+
+// Verify verifies the next received message of the transaction, e.g. the
+// response to a signed request. Messages of a multi-message response after
+// the first one may be unsigned, as they are covered by the MAC of the next
+// signed message.
+// See https://datatracker.ietf.org/doc/html/rfc8945#section-5.3.1
+func (t *Transaction) Verify(data []byte) error {
+	message, record, err := split(data)
+	if errors.Is(err, ErrUnsigned) && t.messages >= 2 {
+		if t.skipped >= maxUnsigned {
+			return ErrBadSig
+		}
+
+		t.unsigned = append(t.unsigned, data...)
+		t.skipped++
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	switch rcode.Code(record.Error) {
+	case rcode.NoError:
+	case rcode.BadKey:
+		return ErrBadKey
+	case rcode.BadTime:
+		return ErrBadTime
+	default:
+		return ErrBadSig
+	}
+
+	return t.verify(message, record)
+}
+
+// Pending returns if unsigned messages were received since the last signed
+// message. The last message of a multi-message response must be signed
+func (t *Transaction) Pending() bool {
+	return t.skipped > 0
+}
+
+// verify verifies the MAC and the time of the TSIG RR. The time is checked
+// after the MAC, as the time of an unauthenticated message is meaningless
+func (t *Transaction) verify(message []byte, record *rr.TSIG) error {
+	if !strings.EqualFold(labels.Rootify(record.H.Name), t.Key.Name) ||
+		!strings.EqualFold(labels.Rootify(record.Algorithm), t.Key.Algorithm) {
+		t.Error = rcode.BadKey
+		return ErrBadKey
+	}
+
+	if !hmac.Equal(record.MAC, t.digest(message, record)) {
+		t.Error = rcode.BadSig
+		return ErrBadSig
+	}
+
+	t.mac = record.MAC
+	t.messages++
+	t.unsigned = nil
+	t.skipped = 0
+
+	now := uint64(time.Now().Unix())
+	if now > record.TimeSigned+uint64(record.Fudge) || record.TimeSigned > now+uint64(record.Fudge) {
+		t.Error = rcode.BadTime
+		return ErrBadTime
+	}
+
+	return nil
+}
+
+// digest computes the MAC of the message without its TSIG RR. The first
+// message of a response covers the MAC of the request. Following messages
+// only cover the timers of the TSIG RR.
+// See https://datatracker.ietf.org/doc/html/rfc8945#section-4.3
+func (t *Transaction) digest(message []byte, record *rr.TSIG) []byte {
+	var (
+		h   = hmac.New(algorithms[t.Key.Algorithm], t.Key.Secret)
+		buf = make([]byte, 2)
+	)
+
+	if len(t.mac) > 0 {
+		binary.BigEndian.PutUint16(buf, uint16(len(t.mac)))
+		h.Write(buf)
+		h.Write(t.mac)
+	}
+
+	h.Write(t.unsigned)
+	h.Write(message)
+
+	if t.messages < 2 {
+		h.Write(canonicalName(record.H.Name))
+		h.Write([]byte{0, byte(rr.ANY), 0, 0, 0, 0})
+		h.Write(canonicalName(record.Algorithm))
+	}
+
+	h.Write(uint48(record.TimeSigned))
+	binary.BigEndian.PutUint16(buf, record.Fudge)
+	h.Write(buf)
+
+	if t.messages < 2 {
+		binary.BigEndian.PutUint16(buf, record.Error)
+		h.Write(buf)
+		binary.BigEndian.PutUint16(buf, record.OtherLen)
+		h.Write(buf)
+		h.Write(record.OtherData)
+	}
+
+	return h.Sum(nil)
+}
+
+// split splits the message into the message without its TSIG RR and the
+// TSIG RR. The returned message has the ARCOUNT decremented and the ID
+// replaced by the original ID, which is the message the MAC covers
+func split(data []byte) ([]byte, *rr.TSIG, error) {
+	if len(data) < constants.DNSHeaderLen {
+		return nil, nil, ErrFormat
+	}
+
+	var (
+		qdCount = int(binary.BigEndian.Uint16(data[4:]))
+		rrCount = int(binary.BigEndian.Uint16(data[6:])) + int(binary.BigEndian.Uint16(data[8:]))
+		arCount = binary.BigEndian.Uint16(data[10:])
+		offset  = constants.DNSHeaderLen
+		err     error
+	)
+
+	if arCount == 0 {
+		return nil, nil, ErrUnsigned
+	}
+
+	for i := 0; i < qdCount; i++ {
+		offset, err = skipName(data, offset)
+		if err != nil {
+			return nil, nil, err
+		}
+		offset += 4
+	}
+
+	for i := 0; i < rrCount+int(arCount)-1; i++ {
+		offset, err = skipName(data, offset)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if offset+10 > len(data) {
+			return nil, nil, ErrFormat
+		}
+		offset += 10 + int(binary.BigEndian.Uint16(data[offset+8:]))
+	}
+
+	start := offset
+	offset, err = skipName(data, offset)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if offset+10 > len(data) || binary.BigEndian.Uint16(data[offset:]) != rr.TypeTSIG {
+		return nil, nil, ErrUnsigned
+	}
+
+	name, _, err := pack.UnpackDomainName(data, start)
+	if err != nil {
+		return nil, nil, ErrFormat
+	}
+
+	record := &rr.TSIG{
+		H: rr.Header{
+			Name:     name,
+			Type:     rr.TypeTSIG,
+			Class:    binary.BigEndian.Uint16(data[offset+2:]),
+			TTL:      binary.BigEndian.Uint32(data[offset+4:]),
+			RDLength: binary.BigEndian.Uint16(data[offset+8:]),
+		},
+	}
+
+	end, err := record.Unpack(data, offset+10)
+	if err != nil || end != offset+10+int(record.H.RDLength) || end != len(data) {
+		return nil, nil, ErrFormat
+	}
+
+	message := make([]byte, start)
+	copy(message, data[:start])
+	binary.BigEndian.PutUint16(message, record.OriginalID)
+	binary.BigEndian.PutUint16(message[10:], arCount-1)
+
+	return message, record, nil
+}
+
+// appendRecord appends the TSIG RR to the message and increments ARCOUNT
+func appendRecord(data []byte, record *rr.TSIG) ([]byte, error) {
+	buf := make([]byte, len(data)+labels.Len(record.H.Name)+constants.RRHeaderFixedLen+int(record.Len())+1)
+	copy(buf, data)
+
+	offset, err := pack.PackDomainName(record.H.Name, buf, len(data), compression.New())
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err = pack.PackUint16(record.H.Type, buf, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err = pack.PackUint16(record.H.Class, buf, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err = pack.PackUint32(record.H.TTL, buf, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	start := offset + 2
+	end, err := record.Pack(buf, start, compression.New())
+	if err != nil {
+		return nil, err
+	}
+
+	binary.BigEndian.PutUint16(buf[offset:], uint16(end-start))
+	binary.BigEndian.PutUint16(buf[10:], binary.BigEndian.Uint16(buf[10:])+1)
+
+	return buf[:end], nil
+}
+
+// skipName returns the offset after the (possibly compressed) name
+func skipName(data []byte, offset int) (int, error) {
+	for offset < len(data) {
+		b := data[offset]
+		switch {
+		case b == 0:
+			return offset + 1, nil
+		case b&0xC0 == 0xC0:
+			if offset+2 > len(data) {
+				return 0, ErrFormat
+			}
+			return offset + 2, nil
+		default:
+			offset += int(b) + 1
+		}
+	}
+
+	return 0, ErrFormat
+}
+
+// canonicalName returns the uncompressed wire format of the name in
+// lowercase. See https://datatracker.ietf.org/doc/html/rfc4034#section-6.2
+func canonicalName(name string) []byte {
+	name = strings.ToLower(labels.Rootify(name))
+
+	buf := make([]byte, len(name)+1)
+	offset, err := pack.PackDomainName(name, buf, 0, compression.New())
+	if err != nil {
+		return nil
+	}
+
+	return buf[:offset]
+}
+
+func uint48(n uint64) []byte {
+	return []byte{byte(n >> 40), byte(n >> 32), byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+}
@@ -1,9 +1,13 @@
 package dns
 
 import (
+	"net/netip"
+
 	"github.com/go-void/portal/pkg/compression"
 	"github.com/go-void/portal/pkg/constants"
 	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/tsig"
+	"github.com/go-void/portal/pkg/types/edns"
 	"github.com/go-void/portal/pkg/types/rr"
 
 	"go.uber.org/zap/zapcore"
@@ -21,6 +25,11 @@ type Message struct {
 	// Compression keeps track of compression pointers
 	// and domain names
 	Compression compression.Map
+
+	// TSIG is the transaction the message gets signed with
+	// when packed. Responses share the transaction of the
+	// request
+	TSIG *tsig.Transaction
 }
 
 // NewMessage returns a new empty (default value) DNS message
@@ -92,7 +101,7 @@ func (m *Message) AddAuthority(record rr.RR) {
 		return
 	}
 
-	m.Answer = append(m.Authority, record)
+	m.Authority = append(m.Authority, record)
 	m.Header.NSCount++
 }
 
@@ -106,7 +115,24 @@ func (m *Message) AddAdditional(record rr.RR) {
 	m.Header.ARCount++
 }
 
-// Len returns the length of the message in octets
+// ClearRecords removes all records from the answer, authority and additional
+// sections. The OPT record of the additional section is kept
+func (m *Message) ClearRecords() {
+	m.Answer, m.Authority = nil, nil
+	m.Header.ANCount, m.Header.NSCount = 0, 0
+
+	var additional []rr.RR
+	for _, record := range m.Additional {
+		if _, ok := record.(*rr.OPT); ok {
+			additional = append(additional, record)
+		}
+	}
+
+	m.Additional = additional
+	m.Header.ARCount = uint16(len(additional))
+}
+
+// Len returns the length of the message in octets without name compression
 func (m *Message) Len() int {
 	// Fixed DNS header length
 	len := constants.DNSHeaderLen
@@ -116,15 +142,15 @@ func (m *Message) Len() int {
 	len += constants.DNSQuestionFixedLen
 
 	for _, a := range m.Answer {
-		len += int(a.Len())
+		len += labels.Len(a.Header().Name) + constants.RRHeaderFixedLen + int(a.Len())
 	}
 
 	for _, a := range m.Authority {
-		len += int(a.Len())
+		len += labels.Len(a.Header().Name) + constants.RRHeaderFixedLen + int(a.Len())
 	}
 
 	for _, a := range m.Additional {
-		len += int(a.Len())
+		len += labels.Len(a.Header().Name) + constants.RRHeaderFixedLen + int(a.Len())
 	}
 
 	return len
@@ -147,6 +173,39 @@ func (m *Message) IsEDNS() bool {
 	return false
 }
 
+// DNSSECOK returns if the DO bit is set in the EDNS OPT record, which
+// requests DNSSEC records in the response.
+// See https://datatracker.ietf.org/doc/html/rfc3225#section-3
+func (m *Message) DNSSECOK() bool {
+	for i := len(m.Additional) - 1; i >= 0; i-- {
+		if opt, ok := m.Additional[i].(*rr.OPT); ok {
+			// The DO bit is the most significant bit of the
+			// extended flags, which are the lower 16 bits of the TTL
+			return opt.H.TTL&0x8000 != 0
+		}
+	}
+	return false
+}
+
+// ClientSubnet returns the subnet of the EDNS Client Subnet option and if
+// the message carries the option.
+// See https://datatracker.ietf.org/doc/html/rfc7871
+func (m *Message) ClientSubnet() (netip.Prefix, bool) {
+	for i := len(m.Additional) - 1; i >= 0; i-- {
+		opt, ok := m.Additional[i].(*rr.OPT)
+		if !ok {
+			continue
+		}
+
+		for _, option := range opt.Options {
+			if ecs, ok := option.(*edns.ClientSubnet); ok {
+				return ecs.Subnet, true
+			}
+		}
+	}
+	return netip.Prefix{}, false
+}
+
 // IsSOA returns if the message has a SOA record
 func (m *Message) IsSOA() bool {
 	// We iterate from the front because the SOA record is usually at the
@@ -43,7 +43,7 @@ var codeMap = map[uint16]func() Option{
 	CodeDAU:           func() Option { return nil },
 	CodeDHU:           func() Option { return nil },
 	CodeN3U:           func() Option { return nil },
-	CodeECS:           func() Option { return nil },
+	CodeECS:           func() Option { return new(ClientSubnet) },
 	CodeEXPIRE:        func() Option { return nil },
 	CodeCOOKIE:        func() Option { return new(Cookie) },
 	CodeTCPKEEPALIVE:  func() Option { return nil },
@@ -0,0 +1,115 @@
+package edns
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+var (
+	ErrInvalidClientSubnet = errors.New("invalid client subnet option")
+)
+
+// Address families of the client subnet option.
+// See https://www.iana.org/assignments/address-family-numbers
+const (
+	FamilyIPv4 uint16 = 1
+	FamilyIPv6 uint16 = 2
+)
+
+// ClientSubnet is the EDNS Client Subnet (ECS) option, which carries the
+// subnet of the client a query was sent on behalf of.
+// See https://datatracker.ietf.org/doc/html/rfc7871#section-6
+type ClientSubnet struct {
+	// Subnet is the address of the client with the source prefix length
+	Subnet netip.Prefix
+
+	// Scope is the scope prefix length of responses
+	Scope uint8
+}
+
+// Code returns the option code
+func (o *ClientSubnet) Code() uint16 {
+	return CodeECS
+}
+
+// Len returns the option length
+func (o *ClientSubnet) Len() uint16 {
+	return 4 + uint16(o.addressLen())
+}
+
+func (o *ClientSubnet) String() string {
+	return fmt.Sprintf("Subnet: %s, Scope: %d", o.Subnet, o.Scope)
+}
+
+// addressLen returns the number of octets of the address required by the
+// source prefix length
+func (o *ClientSubnet) addressLen() int {
+	return (o.Subnet.Bits() + 7) / 8
+}
+
+// Unpack unpacks the option data
+func (o *ClientSubnet) Unpack(data []byte, offset int, length uint16) (int, error) {
+	if length < 4 || offset+int(length) > len(data) {
+		return offset, ErrInvalidClientSubnet
+	}
+
+	var (
+		family = uint16(data[offset])<<8 | uint16(data[offset+1])
+		source = int(data[offset+2])
+		raw    = data[offset+4 : offset+int(length)]
+		addr   netip.Addr
+	)
+
+	switch family {
+	case FamilyIPv4:
+		var a [4]byte
+		if len(raw) > len(a) {
+			return offset, ErrInvalidClientSubnet
+		}
+		copy(a[:], raw)
+		addr = netip.AddrFrom4(a)
+	case FamilyIPv6:
+		var a [16]byte
+		if len(raw) > len(a) {
+			return offset, ErrInvalidClientSubnet
+		}
+		copy(a[:], raw)
+		addr = netip.AddrFrom16(a)
+	default:
+		return offset, ErrInvalidClientSubnet
+	}
+
+	subnet, err := addr.Prefix(source)
+	if err != nil {
+		return offset, ErrInvalidClientSubnet
+	}
+
+	o.Subnet = subnet
+	o.Scope = data[offset+3]
+
+	return offset + int(length), nil
+}
+
+// Pack packs the option data
+func (o *ClientSubnet) Pack(buf []byte, offset int) (int, error) {
+	if offset+int(o.Len()) > len(buf) {
+		return offset, ErrInvalidClientSubnet
+	}
+
+	family := FamilyIPv4
+	if o.Subnet.Addr().Is6() {
+		family = FamilyIPv6
+	}
+
+	buf[offset] = byte(family >> 8)
+	buf[offset+1] = byte(family)
+	buf[offset+2] = byte(o.Subnet.Bits())
+	buf[offset+3] = o.Scope
+	offset += 4
+
+	addr := o.Subnet.Masked().Addr().AsSlice()
+	offset += copy(buf[offset:], addr[:o.addressLen()])
+
+	return offset, nil
+}
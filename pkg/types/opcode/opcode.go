@@ -7,4 +7,7 @@ const (
 	Query Code = iota
 	IQuery
 	Status
+	_
+	Notify // See https://datatracker.ietf.org/doc/html/rfc1996
+	Update // See https://datatracker.ietf.org/doc/html/rfc2136
 )
@@ -10,4 +10,23 @@ const (
 	NameError
 	NotImplemented
 	Refused
+
+	// The following codes are defined in RFC 2136.
+	// See https://datatracker.ietf.org/doc/html/rfc2136#section-2.2
+
+	YXDomain // Name exists when it should not
+	YXRRSet  // RRset exists when it should not
+	NXRRSet  // RRset that should exist does not
+	NotAuth  // Server is not authoritative for the zone
+	NotZone  // Name not contained in the zone
+)
+
+// The following codes don't fit into the header and only appear in the
+// error field of TSIG RRs.
+// See https://datatracker.ietf.org/doc/html/rfc8945#section-3
+
+const (
+	BadSig  Code = 16 // TSIG signature failure
+	BadKey  Code = 17 // Key not recognized
+	BadTime Code = 18 // Signature out of time window
 )
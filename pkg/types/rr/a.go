@@ -36,7 +36,21 @@ func (rr *A) SetData(data ...interface{}) error {
 }
 
 func (rr *A) String() string {
-	return fmt.Sprintf("A <%v %s>", rr.H, rr.Address)
+	return fmt.Sprintf("%s\t%s", rr.H, rr.Address)
+}
+
+func (rr *A) ParseData(rdata []string, origin string) error {
+	if err := parseFieldCount(rdata, 1); err != nil {
+		return err
+	}
+
+	addr, err := parseAddress(rdata[0], false)
+	if err != nil {
+		return err
+	}
+	rr.Address = addr
+
+	return nil
 }
 
 func (rr *A) Len() uint16 {
@@ -36,7 +36,21 @@ func (rr *AAAA) SetData(data ...interface{}) error {
 }
 
 func (rr *AAAA) String() string {
-	return fmt.Sprintf("%v %s", rr.H, rr.Address)
+	return fmt.Sprintf("%s\t%s", rr.H, rr.Address)
+}
+
+func (rr *AAAA) ParseData(rdata []string, origin string) error {
+	if err := parseFieldCount(rdata, 1); err != nil {
+		return err
+	}
+
+	addr, err := parseAddress(rdata[0], true)
+	if err != nil {
+		return err
+	}
+	rr.Address = addr
+
+	return nil
 }
 
 func (rr *AAAA) Len() uint16 {
@@ -1,5 +1,10 @@
 package rr
 
+import (
+	"strconv"
+	"strings"
+)
+
 // Class describes RR class codes.
 // See https://datatracker.ietf.org/doc/html/rfc1035#section-3.2.4
 const (
@@ -11,5 +16,54 @@ const (
 	// QClasses are a superset of classes and should only be
 	// allowed in questions
 
-	ANY uint16 = 255 // Any class (*)
+	NONE uint16 = 254 // None class, see RFC 2136
+	ANY  uint16 = 255 // Any class (*)
 )
+
+var classToStringMap = map[uint16]string{
+	IN:   "IN",
+	CS:   "CS",
+	CH:   "CH",
+	HS:   "HS",
+	NONE: "NONE",
+	ANY:  "ANY",
+}
+
+var stringToClassMap = map[string]uint16{
+	"IN":   IN,
+	"CS":   CS,
+	"CH":   CH,
+	"HS":   HS,
+	"NONE": NONE,
+	"ANY":  ANY,
+}
+
+// ClassToString returns the mnemonic of the class. Unknown classes are
+// returned in the generic 'CLASSNNN' format.
+// See https://datatracker.ietf.org/doc/html/rfc3597#section-5
+func ClassToString(c uint16) string {
+	if s, ok := classToStringMap[c]; ok {
+		return s
+	}
+	return "CLASS" + strconv.Itoa(int(c))
+}
+
+// ClassFromString returns the class of the mnemonic or generic 'CLASSNNN'
+// format and if the class is valid
+func ClassFromString(s string) (uint16, bool) {
+	s = strings.ToUpper(s)
+	if c, ok := stringToClassMap[s]; ok {
+		return c, true
+	}
+
+	if !strings.HasPrefix(s, "CLASS") {
+		return 0, false
+	}
+
+	c, err := strconv.ParseUint(s[5:], 10, 16)
+	if err != nil {
+		return 0, false
+	}
+
+	return uint16(c), true
+}
@@ -1,6 +1,8 @@
 package rr
 
 import (
+	"fmt"
+
 	"github.com/go-void/portal/pkg/compression"
 	"github.com/go-void/portal/pkg/pack"
 )
@@ -33,7 +35,21 @@ func (rr *CNAME) SetData(data ...interface{}) error {
 }
 
 func (rr *CNAME) String() string {
-	return ""
+	return fmt.Sprintf("%s\t%s", rr.H, rr.Target)
+}
+
+func (rr *CNAME) ParseData(rdata []string, origin string) error {
+	if err := parseFieldCount(rdata, 1); err != nil {
+		return err
+	}
+
+	name, err := AbsoluteName(rdata[0], origin)
+	if err != nil {
+		return err
+	}
+	rr.Target = name
+
+	return nil
 }
 
 func (rr *CNAME) Len() uint16 {
@@ -0,0 +1,290 @@
+package rr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/go-void/portal/pkg/compression"
+	"github.com/go-void/portal/pkg/pack"
+)
+
+// Flags of DNSKEY records.
+// See https://datatracker.ietf.org/doc/html/rfc4034#section-2.1.1
+const (
+	DNSKEYFlagZone uint16 = 1 << 8 // Zone Key
+	DNSKEYFlagSEP  uint16 = 1      // Secure Entry Point (KSK)
+)
+
+// See https://datatracker.ietf.org/doc/html/rfc4034#section-2.1
+type DNSKEY struct {
+	H         Header
+	Flags     uint16
+	Protocol  uint8
+	Algorithm uint8
+	PublicKey []byte
+}
+
+func (rr *DNSKEY) Header() *Header {
+	return &rr.H
+}
+
+func (rr *DNSKEY) SetHeader(header Header) {
+	rr.H = header
+}
+
+func (rr *DNSKEY) SetData(data ...interface{}) error {
+	flags, protocol, algorithm, key, err := dnskeyData(data)
+	if err != nil {
+		return err
+	}
+
+	rr.Flags, rr.Protocol, rr.Algorithm, rr.PublicKey = flags, protocol, algorithm, key
+	return nil
+}
+
+func (rr *DNSKEY) String() string {
+	return fmt.Sprintf("%s\t%s", rr.H, dnskeyString(rr.Flags, rr.Protocol, rr.Algorithm, rr.PublicKey))
+}
+
+func (rr *DNSKEY) ParseData(rdata []string, origin string) error {
+	flags, protocol, algorithm, key, err := dnskeyParse(rdata)
+	if err != nil {
+		return err
+	}
+
+	rr.Flags, rr.Protocol, rr.Algorithm, rr.PublicKey = flags, protocol, algorithm, key
+	return nil
+}
+
+func (rr *DNSKEY) Len() uint16 {
+	return uint16(len(rr.PublicKey)) + 4
+}
+
+func (rr *DNSKEY) IsSame(o RR) bool {
+	other, ok := o.(*DNSKEY)
+	if !ok {
+		return false
+	}
+
+	return rr.Flags == other.Flags && rr.Protocol == other.Protocol &&
+		rr.Algorithm == other.Algorithm && bytes.Equal(rr.PublicKey, other.PublicKey)
+}
+
+func (rr *DNSKEY) Unpack(data []byte, offset int) (int, error) {
+	flags, protocol, algorithm, key, offset, err := dnskeyUnpack(data, offset, rr.H.RDLength)
+	if err != nil {
+		return offset, err
+	}
+
+	rr.Flags, rr.Protocol, rr.Algorithm, rr.PublicKey = flags, protocol, algorithm, key
+	return offset, nil
+}
+
+func (rr *DNSKEY) Pack(buf []byte, offset int, _ compression.Map) (int, error) {
+	return dnskeyPack(rr.Flags, rr.Protocol, rr.Algorithm, rr.PublicKey, buf, offset)
+}
+
+// KeyTag returns the key tag of the key.
+// See https://datatracker.ietf.org/doc/html/rfc4034#appendix-B
+func (rr *DNSKEY) KeyTag() uint16 {
+	return dnskeyTag(rr.Flags, rr.Protocol, rr.Algorithm, rr.PublicKey)
+}
+
+// CDNSKEY is the child copy of a DNSKEY record, which signals the parent
+// zone to update its DS records.
+// See https://datatracker.ietf.org/doc/html/rfc7344#section-3.2
+type CDNSKEY struct {
+	H         Header
+	Flags     uint16
+	Protocol  uint8
+	Algorithm uint8
+	PublicKey []byte
+}
+
+func (rr *CDNSKEY) Header() *Header {
+	return &rr.H
+}
+
+func (rr *CDNSKEY) SetHeader(header Header) {
+	rr.H = header
+}
+
+func (rr *CDNSKEY) SetData(data ...interface{}) error {
+	flags, protocol, algorithm, key, err := dnskeyData(data)
+	if err != nil {
+		return err
+	}
+
+	rr.Flags, rr.Protocol, rr.Algorithm, rr.PublicKey = flags, protocol, algorithm, key
+	return nil
+}
+
+func (rr *CDNSKEY) String() string {
+	return fmt.Sprintf("%s\t%s", rr.H, dnskeyString(rr.Flags, rr.Protocol, rr.Algorithm, rr.PublicKey))
+}
+
+func (rr *CDNSKEY) ParseData(rdata []string, origin string) error {
+	flags, protocol, algorithm, key, err := dnskeyParse(rdata)
+	if err != nil {
+		return err
+	}
+
+	rr.Flags, rr.Protocol, rr.Algorithm, rr.PublicKey = flags, protocol, algorithm, key
+	return nil
+}
+
+func (rr *CDNSKEY) Len() uint16 {
+	return uint16(len(rr.PublicKey)) + 4
+}
+
+func (rr *CDNSKEY) IsSame(o RR) bool {
+	other, ok := o.(*CDNSKEY)
+	if !ok {
+		return false
+	}
+
+	return rr.Flags == other.Flags && rr.Protocol == other.Protocol &&
+		rr.Algorithm == other.Algorithm && bytes.Equal(rr.PublicKey, other.PublicKey)
+}
+
+func (rr *CDNSKEY) Unpack(data []byte, offset int) (int, error) {
+	flags, protocol, algorithm, key, offset, err := dnskeyUnpack(data, offset, rr.H.RDLength)
+	if err != nil {
+		return offset, err
+	}
+
+	rr.Flags, rr.Protocol, rr.Algorithm, rr.PublicKey = flags, protocol, algorithm, key
+	return offset, nil
+}
+
+func (rr *CDNSKEY) Pack(buf []byte, offset int, _ compression.Map) (int, error) {
+	return dnskeyPack(rr.Flags, rr.Protocol, rr.Algorithm, rr.PublicKey, buf, offset)
+}
+
+// dnskeyData converts the data passed to SetData. It expects the flags,
+// protocol, algorithm and public key
+func dnskeyData(data []interface{}) (uint16, uint8, uint8, []byte, error) {
+	if len(data) != 4 {
+		return 0, 0, 0, nil, ErrInvalidRRData
+	}
+
+	flags, ok := data[0].(uint16)
+	if !ok {
+		return 0, 0, 0, nil, ErrFailedToConvertRRData
+	}
+
+	protocol, ok := data[1].(uint8)
+	if !ok {
+		return 0, 0, 0, nil, ErrFailedToConvertRRData
+	}
+
+	algorithm, ok := data[2].(uint8)
+	if !ok {
+		return 0, 0, 0, nil, ErrFailedToConvertRRData
+	}
+
+	key, ok := data[3].([]byte)
+	if !ok {
+		return 0, 0, 0, nil, ErrFailedToConvertRRData
+	}
+
+	return flags, protocol, algorithm, key, nil
+}
+
+// dnskeyParse parses '<flags> <protocol> <algorithm> <public key>' where the
+// base64 encoded public key can be split into multiple fields
+func dnskeyParse(rdata []string) (uint16, uint8, uint8, []byte, error) {
+	if len(rdata) < 4 {
+		return 0, 0, 0, nil, ErrInvalidPresentation
+	}
+
+	flags, err := parseUint16(rdata[0])
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	protocol, err := parseUint8(rdata[1])
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	algorithm, err := parseUint8(rdata[2])
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	key, err := parseBase64(rdata[3:])
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	return flags, protocol, algorithm, key, nil
+}
+
+func dnskeyString(flags uint16, protocol, algorithm uint8, key []byte) string {
+	return fmt.Sprintf("%d %d %d %s", flags, protocol, algorithm, base64.StdEncoding.EncodeToString(key))
+}
+
+func dnskeyUnpack(data []byte, offset int, rdlength uint16) (uint16, uint8, uint8, []byte, int, error) {
+	end := offset + int(rdlength)
+
+	flags, offset, err := pack.UnpackUint16(data, offset)
+	if err != nil {
+		return 0, 0, 0, nil, offset, err
+	}
+
+	protocol, offset, err := pack.UnpackUint8(data, offset)
+	if err != nil {
+		return 0, 0, 0, nil, offset, err
+	}
+
+	algorithm, offset, err := pack.UnpackUint8(data, offset)
+	if err != nil {
+		return 0, 0, 0, nil, offset, err
+	}
+
+	key, offset, err := pack.UnpackBytes(data, offset, end-offset)
+	if err != nil {
+		return 0, 0, 0, nil, offset, err
+	}
+
+	return flags, protocol, algorithm, key, offset, nil
+}
+
+func dnskeyPack(flags uint16, protocol, algorithm uint8, key []byte, buf []byte, offset int) (int, error) {
+	offset, err := pack.PackUint16(flags, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint8(protocol, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint8(algorithm, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	return pack.PackBytes(key, buf, offset)
+}
+
+// dnskeyTag calculates the key tag over the wire format of the RDATA
+func dnskeyTag(flags uint16, protocol, algorithm uint8, key []byte) uint16 {
+	rdata := make([]byte, 4+len(key))
+	dnskeyPack(flags, protocol, algorithm, key, rdata, 0)
+
+	var ac uint32
+	for i, b := range rdata {
+		if i&1 == 1 {
+			ac += uint32(b)
+		} else {
+			ac += uint32(b) << 8
+		}
+	}
+	ac += ac >> 16 & 0xFFFF
+
+	return uint16(ac & 0xFFFF)
+}
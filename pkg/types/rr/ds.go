@@ -0,0 +1,260 @@
+package rr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/go-void/portal/pkg/compression"
+	"github.com/go-void/portal/pkg/pack"
+)
+
+// See https://datatracker.ietf.org/doc/html/rfc4034#section-5.1
+type DS struct {
+	H          Header
+	KeyTag     uint16
+	Algorithm  uint8
+	DigestType uint8
+	Digest     []byte
+}
+
+func (rr *DS) Header() *Header {
+	return &rr.H
+}
+
+func (rr *DS) SetHeader(header Header) {
+	rr.H = header
+}
+
+func (rr *DS) SetData(data ...interface{}) error {
+	tag, algorithm, digestType, digest, err := dsData(data)
+	if err != nil {
+		return err
+	}
+
+	rr.KeyTag, rr.Algorithm, rr.DigestType, rr.Digest = tag, algorithm, digestType, digest
+	return nil
+}
+
+func (rr *DS) String() string {
+	return fmt.Sprintf("%s\t%s", rr.H, dsString(rr.KeyTag, rr.Algorithm, rr.DigestType, rr.Digest))
+}
+
+func (rr *DS) ParseData(rdata []string, origin string) error {
+	tag, algorithm, digestType, digest, err := dsParse(rdata)
+	if err != nil {
+		return err
+	}
+
+	rr.KeyTag, rr.Algorithm, rr.DigestType, rr.Digest = tag, algorithm, digestType, digest
+	return nil
+}
+
+func (rr *DS) Len() uint16 {
+	return uint16(len(rr.Digest)) + 4
+}
+
+func (rr *DS) IsSame(o RR) bool {
+	other, ok := o.(*DS)
+	if !ok {
+		return false
+	}
+
+	return rr.KeyTag == other.KeyTag && rr.Algorithm == other.Algorithm &&
+		rr.DigestType == other.DigestType && bytes.Equal(rr.Digest, other.Digest)
+}
+
+func (rr *DS) Unpack(data []byte, offset int) (int, error) {
+	tag, algorithm, digestType, digest, offset, err := dsUnpack(data, offset, rr.H.RDLength)
+	if err != nil {
+		return offset, err
+	}
+
+	rr.KeyTag, rr.Algorithm, rr.DigestType, rr.Digest = tag, algorithm, digestType, digest
+	return offset, nil
+}
+
+func (rr *DS) Pack(buf []byte, offset int, _ compression.Map) (int, error) {
+	return dsPack(rr.KeyTag, rr.Algorithm, rr.DigestType, rr.Digest, buf, offset)
+}
+
+// CDS is the child copy of a DS record, which signals the parent zone to
+// update its DS records.
+// See https://datatracker.ietf.org/doc/html/rfc7344#section-3.1
+type CDS struct {
+	H          Header
+	KeyTag     uint16
+	Algorithm  uint8
+	DigestType uint8
+	Digest     []byte
+}
+
+func (rr *CDS) Header() *Header {
+	return &rr.H
+}
+
+func (rr *CDS) SetHeader(header Header) {
+	rr.H = header
+}
+
+func (rr *CDS) SetData(data ...interface{}) error {
+	tag, algorithm, digestType, digest, err := dsData(data)
+	if err != nil {
+		return err
+	}
+
+	rr.KeyTag, rr.Algorithm, rr.DigestType, rr.Digest = tag, algorithm, digestType, digest
+	return nil
+}
+
+func (rr *CDS) String() string {
+	return fmt.Sprintf("%s\t%s", rr.H, dsString(rr.KeyTag, rr.Algorithm, rr.DigestType, rr.Digest))
+}
+
+func (rr *CDS) ParseData(rdata []string, origin string) error {
+	tag, algorithm, digestType, digest, err := dsParse(rdata)
+	if err != nil {
+		return err
+	}
+
+	rr.KeyTag, rr.Algorithm, rr.DigestType, rr.Digest = tag, algorithm, digestType, digest
+	return nil
+}
+
+func (rr *CDS) Len() uint16 {
+	return uint16(len(rr.Digest)) + 4
+}
+
+func (rr *CDS) IsSame(o RR) bool {
+	other, ok := o.(*CDS)
+	if !ok {
+		return false
+	}
+
+	return rr.KeyTag == other.KeyTag && rr.Algorithm == other.Algorithm &&
+		rr.DigestType == other.DigestType && bytes.Equal(rr.Digest, other.Digest)
+}
+
+func (rr *CDS) Unpack(data []byte, offset int) (int, error) {
+	tag, algorithm, digestType, digest, offset, err := dsUnpack(data, offset, rr.H.RDLength)
+	if err != nil {
+		return offset, err
+	}
+
+	rr.KeyTag, rr.Algorithm, rr.DigestType, rr.Digest = tag, algorithm, digestType, digest
+	return offset, nil
+}
+
+func (rr *CDS) Pack(buf []byte, offset int, _ compression.Map) (int, error) {
+	return dsPack(rr.KeyTag, rr.Algorithm, rr.DigestType, rr.Digest, buf, offset)
+}
+
+// dsData converts the data passed to SetData. It expects the key tag,
+// algorithm, digest type and digest
+func dsData(data []interface{}) (uint16, uint8, uint8, []byte, error) {
+	if len(data) != 4 {
+		return 0, 0, 0, nil, ErrInvalidRRData
+	}
+
+	tag, ok := data[0].(uint16)
+	if !ok {
+		return 0, 0, 0, nil, ErrFailedToConvertRRData
+	}
+
+	algorithm, ok := data[1].(uint8)
+	if !ok {
+		return 0, 0, 0, nil, ErrFailedToConvertRRData
+	}
+
+	digestType, ok := data[2].(uint8)
+	if !ok {
+		return 0, 0, 0, nil, ErrFailedToConvertRRData
+	}
+
+	digest, ok := data[3].([]byte)
+	if !ok {
+		return 0, 0, 0, nil, ErrFailedToConvertRRData
+	}
+
+	return tag, algorithm, digestType, digest, nil
+}
+
+// dsParse parses '<key tag> <algorithm> <digest type> <digest>' where the
+// hex encoded digest can be split into multiple fields
+func dsParse(rdata []string) (uint16, uint8, uint8, []byte, error) {
+	if len(rdata) < 4 {
+		return 0, 0, 0, nil, ErrInvalidPresentation
+	}
+
+	tag, err := parseUint16(rdata[0])
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	algorithm, err := parseUint8(rdata[1])
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	digestType, err := parseUint8(rdata[2])
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	digest, err := hex.DecodeString(strings.Join(rdata[3:], ""))
+	if err != nil {
+		return 0, 0, 0, nil, ErrInvalidPresentation
+	}
+
+	return tag, algorithm, digestType, digest, nil
+}
+
+func dsString(tag uint16, algorithm, digestType uint8, digest []byte) string {
+	return fmt.Sprintf("%d %d %d %s", tag, algorithm, digestType, strings.ToUpper(hex.EncodeToString(digest)))
+}
+
+func dsUnpack(data []byte, offset int, rdlength uint16) (uint16, uint8, uint8, []byte, int, error) {
+	end := offset + int(rdlength)
+
+	tag, offset, err := pack.UnpackUint16(data, offset)
+	if err != nil {
+		return 0, 0, 0, nil, offset, err
+	}
+
+	algorithm, offset, err := pack.UnpackUint8(data, offset)
+	if err != nil {
+		return 0, 0, 0, nil, offset, err
+	}
+
+	digestType, offset, err := pack.UnpackUint8(data, offset)
+	if err != nil {
+		return 0, 0, 0, nil, offset, err
+	}
+
+	digest, offset, err := pack.UnpackBytes(data, offset, end-offset)
+	if err != nil {
+		return 0, 0, 0, nil, offset, err
+	}
+
+	return tag, algorithm, digestType, digest, offset, nil
+}
+
+func dsPack(tag uint16, algorithm, digestType uint8, digest []byte, buf []byte, offset int) (int, error) {
+	offset, err := pack.PackUint16(tag, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint8(algorithm, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint8(digestType, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	return pack.PackBytes(digest, buf, offset)
+}
@@ -1,6 +1,8 @@
 package rr
 
 import (
+	"fmt"
+
 	"github.com/go-void/portal/pkg/compression"
 	"github.com/go-void/portal/pkg/pack"
 )
@@ -25,14 +27,15 @@ func (rr *HINFO) SetData(data ...interface{}) error {
 		return ErrInvalidRRData
 	}
 
+	// CPU and OS are single character strings which can't be split
 	cpu, ok := data[0].(string)
-	if !ok {
+	if !ok || len(cpu) > MaxCharacterStringLen {
 		return ErrFailedToConvertRRData
 	}
 	rr.CPU = cpu
 
 	os, ok := data[1].(string)
-	if !ok {
+	if !ok || len(os) > MaxCharacterStringLen {
 		return ErrFailedToConvertRRData
 	}
 	rr.OS = os
@@ -41,7 +44,22 @@ func (rr *HINFO) SetData(data ...interface{}) error {
 }
 
 func (rr *HINFO) String() string {
-	return ""
+	return fmt.Sprintf("%s\t%s", rr.H, quoteCharacterStrings([]string{rr.CPU, rr.OS}))
+}
+
+func (rr *HINFO) ParseData(rdata []string, origin string) error {
+	if err := parseFieldCount(rdata, 2); err != nil {
+		return err
+	}
+
+	strs, err := parseCharacterStrings(rdata)
+	if err != nil {
+		return err
+	}
+
+	rr.CPU = strs[0]
+	rr.OS = strs[1]
+	return nil
 }
 
 func (rr *HINFO) Len() uint16 {
@@ -58,13 +76,19 @@ func (rr *HINFO) IsSame(o RR) bool {
 }
 
 func (rr *HINFO) Unpack(data []byte, offset int) (int, error) {
-	cpu, offset, err := pack.UnpackCharacterString(data, offset)
+	// Both character strings have to fit into RDLENGTH
+	end := offset + int(rr.H.RDLength)
+	if end > len(data) {
+		return len(data), pack.ErrOverflowUnpackString
+	}
+
+	cpu, offset, err := pack.UnpackCharacterString(data[:end], offset)
 	if err != nil {
 		return offset, err
 	}
 	rr.CPU = cpu
 
-	os, offset, err := pack.UnpackCharacterString(data, offset)
+	os, offset, err := pack.UnpackCharacterString(data[:end], offset)
 	if err != nil {
 		return offset, err
 	}
@@ -0,0 +1,95 @@
+package rr
+
+import (
+	"fmt"
+
+	"github.com/go-void/portal/pkg/compression"
+	"github.com/go-void/portal/pkg/types/svcb"
+)
+
+// HTTPS uses the same RDATA format as SVCB, but is bound to the HTTP(S)
+// scheme.
+// See https://datatracker.ietf.org/doc/html/rfc9460#section-9
+type HTTPS struct {
+	H        Header
+	Priority uint16
+	Target   string
+	Params   []svcb.Param
+}
+
+func (rr *HTTPS) Header() *Header {
+	return &rr.H
+}
+
+func (rr *HTTPS) SetHeader(header Header) {
+	rr.H = header
+}
+
+func (rr *HTTPS) SetData(data ...interface{}) error {
+	priority, target, params, err := svcbData(data)
+	if err != nil {
+		return err
+	}
+
+	rr.Priority = priority
+	rr.Target = target
+	rr.Params = params
+	return nil
+}
+
+func (rr *HTTPS) String() string {
+	return fmt.Sprintf("%s\t%s", rr.H, svcbString(rr.Priority, rr.Target, rr.Params))
+}
+
+func (rr *HTTPS) ParseData(rdata []string, origin string) error {
+	priority, target, params, err := svcbParse(rdata, origin)
+	if err != nil {
+		return err
+	}
+
+	rr.Priority = priority
+	rr.Target = target
+	rr.Params = params
+	return nil
+}
+
+func (rr *HTTPS) Len() uint16 {
+	return svcbLen(rr.Target, rr.Params)
+}
+
+func (rr *HTTPS) IsSame(o RR) bool {
+	other, ok := o.(*HTTPS)
+	if !ok {
+		return false
+	}
+
+	return svcbIsSame(rr.Priority, rr.Target, rr.Params, other.Priority, other.Target, other.Params)
+}
+
+func (rr *HTTPS) Unpack(data []byte, offset int) (int, error) {
+	priority, target, params, offset, err := svcbUnpack(data, offset, rr.H.RDLength)
+	if err != nil {
+		return offset, err
+	}
+
+	rr.Priority = priority
+	rr.Target = target
+	rr.Params = params
+	return offset, nil
+}
+
+func (rr *HTTPS) Pack(buf []byte, offset int, _ compression.Map) (int, error) {
+	return svcbPack(rr.Priority, rr.Target, rr.Params, buf, offset)
+}
+
+// TargetName returns the effective target name. In ServiceMode a target of
+// "." refers to the owner name of the record.
+// See https://datatracker.ietf.org/doc/html/rfc9460#section-2.5
+func (rr *HTTPS) TargetName() string {
+	return svcbTargetName(rr.H.Name, rr.Priority, rr.Target)
+}
+
+// IsAliasMode returns if the record is in AliasMode (SvcPriority 0)
+func (rr *HTTPS) IsAliasMode() bool {
+	return rr.Priority == 0
+}
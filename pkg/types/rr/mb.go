@@ -1,6 +1,8 @@
 package rr
 
 import (
+	"fmt"
+
 	"github.com/go-void/portal/pkg/compression"
 	"github.com/go-void/portal/pkg/pack"
 )
@@ -34,7 +36,21 @@ func (rr *MB) SetData(data ...interface{}) error {
 }
 
 func (rr *MB) String() string {
-	return ""
+	return fmt.Sprintf("%s\t%s", rr.H, rr.MADName)
+}
+
+func (rr *MB) ParseData(rdata []string, origin string) error {
+	if err := parseFieldCount(rdata, 1); err != nil {
+		return err
+	}
+
+	name, err := AbsoluteName(rdata[0], origin)
+	if err != nil {
+		return err
+	}
+	rr.MADName = name
+
+	return nil
 }
 
 func (rr *MB) Len() uint16 {
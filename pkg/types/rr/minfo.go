@@ -1,6 +1,8 @@
 package rr
 
 import (
+	"fmt"
+
 	"github.com/go-void/portal/pkg/compression"
 	"github.com/go-void/portal/pkg/pack"
 )
@@ -41,7 +43,27 @@ func (rr *MINFO) SetData(data ...interface{}) error {
 }
 
 func (rr *MINFO) String() string {
-	return ""
+	return fmt.Sprintf("%s\t%s %s", rr.H, rr.RMailBox, rr.EMailBox)
+}
+
+func (rr *MINFO) ParseData(rdata []string, origin string) error {
+	if err := parseFieldCount(rdata, 2); err != nil {
+		return err
+	}
+
+	rmailbox, err := AbsoluteName(rdata[0], origin)
+	if err != nil {
+		return err
+	}
+	rr.RMailBox = rmailbox
+
+	emailbox, err := AbsoluteName(rdata[1], origin)
+	if err != nil {
+		return err
+	}
+	rr.EMailBox = emailbox
+
+	return nil
 }
 
 func (rr *MINFO) Len() uint16 {
@@ -44,7 +44,27 @@ func (rr *MX) SetData(data ...interface{}) error {
 }
 
 func (rr *MX) String() string {
-	return fmt.Sprintf("MX <%v Preference: %d, Exchange: %s>", rr.H, rr.Preference, rr.Exchange)
+	return fmt.Sprintf("%s\t%d %s", rr.H, rr.Preference, rr.Exchange)
+}
+
+func (rr *MX) ParseData(rdata []string, origin string) error {
+	if err := parseFieldCount(rdata, 2); err != nil {
+		return err
+	}
+
+	preference, err := parseUint16(rdata[0])
+	if err != nil {
+		return err
+	}
+	rr.Preference = preference
+
+	exchange, err := AbsoluteName(rdata[1], origin)
+	if err != nil {
+		return err
+	}
+	rr.Exchange = exchange
+
+	return nil
 }
 
 func (rr *MX) Len() uint16 {
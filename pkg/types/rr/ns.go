@@ -35,7 +35,21 @@ func (rr *NS) SetData(data ...interface{}) error {
 }
 
 func (rr *NS) String() string {
-	return fmt.Sprintf("%v %s", rr.H, rr.NSDName)
+	return fmt.Sprintf("%s\t%s", rr.H, rr.NSDName)
+}
+
+func (rr *NS) ParseData(rdata []string, origin string) error {
+	if err := parseFieldCount(rdata, 1); err != nil {
+		return err
+	}
+
+	name, err := AbsoluteName(rdata[0], origin)
+	if err != nil {
+		return err
+	}
+	rr.NSDName = name
+
+	return nil
 }
 
 func (rr *NS) Len() uint16 {
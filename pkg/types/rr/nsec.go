@@ -0,0 +1,178 @@
+package rr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-void/portal/pkg/compression"
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/pack"
+)
+
+// See https://datatracker.ietf.org/doc/html/rfc4034#section-4.1
+type NSEC struct {
+	H          Header
+	NextDomain string
+	Types      []uint16
+}
+
+func (rr *NSEC) Header() *Header {
+	return &rr.H
+}
+
+func (rr *NSEC) SetHeader(header Header) {
+	rr.H = header
+}
+
+func (rr *NSEC) SetData(data ...interface{}) error {
+	if len(data) != 2 {
+		return ErrInvalidRRData
+	}
+
+	next, ok := data[0].(string)
+	if !ok {
+		return ErrFailedToConvertRRData
+	}
+	rr.NextDomain = next
+
+	types, ok := data[1].([]uint16)
+	if !ok {
+		return ErrFailedToConvertRRData
+	}
+	rr.Types = types
+
+	return nil
+}
+
+func (rr *NSEC) String() string {
+	return fmt.Sprintf("%s\t%s%s", rr.H, rr.NextDomain, formatTypes(rr.Types))
+}
+
+func (rr *NSEC) ParseData(rdata []string, origin string) error {
+	if len(rdata) < 1 {
+		return ErrInvalidPresentation
+	}
+
+	next, err := AbsoluteName(rdata[0], origin)
+	if err != nil {
+		return err
+	}
+	rr.NextDomain = next
+
+	types, err := parseTypes(rdata[1:])
+	if err != nil {
+		return err
+	}
+	rr.Types = types
+
+	return nil
+}
+
+func (rr *NSEC) Len() uint16 {
+	return uint16(labels.Len(rr.NextDomain)) + typeBitmapLen(rr.Types)
+}
+
+func (rr *NSEC) IsSame(o RR) bool {
+	other, ok := o.(*NSEC)
+	if !ok {
+		return false
+	}
+
+	return strings.EqualFold(rr.NextDomain, other.NextDomain) && sameTypes(rr.Types, other.Types)
+}
+
+func (rr *NSEC) Unpack(data []byte, offset int) (int, error) {
+	end := offset + int(rr.H.RDLength)
+
+	next, offset, err := pack.UnpackDomainName(data, offset)
+	if err != nil {
+		return offset, err
+	}
+	rr.NextDomain = next
+
+	types, offset, err := pack.UnpackTypeBitmap(data, offset, end)
+	if err != nil {
+		return offset, err
+	}
+	rr.Types = types
+
+	return offset, nil
+}
+
+// Pack packs the RDATA of the NSEC RR. The next domain name is never
+// compressed. See https://datatracker.ietf.org/doc/html/rfc4034#section-4.1.1
+func (rr *NSEC) Pack(buf []byte, offset int, _ compression.Map) (int, error) {
+	offset, err := pack.PackDomainName(rr.NextDomain, buf, offset, compression.New())
+	if err != nil {
+		return offset, err
+	}
+
+	return pack.PackTypeBitmap(rr.Types, buf, offset)
+}
+
+// HasType returns if the type bitmap contains the type
+func (rr *NSEC) HasType(t uint16) bool {
+	return hasType(rr.Types, t)
+}
+
+// formatTypes returns the presentation format of a type bitmap. Each type
+// is preceded by a space
+func formatTypes(types []uint16) string {
+	var b strings.Builder
+
+	for _, t := range types {
+		b.WriteByte(' ')
+		b.WriteString(TypeToString(t))
+	}
+
+	return b.String()
+}
+
+// parseTypes parses the type mnemonics of a type bitmap
+func parseTypes(rdata []string) ([]uint16, error) {
+	types := make([]uint16, 0, len(rdata))
+
+	for _, field := range rdata {
+		t, ok := TypeFromString(field)
+		if !ok {
+			return nil, ErrInvalidPresentation
+		}
+		types = append(types, t)
+	}
+
+	return types, nil
+}
+
+// typeBitmapLen returns the length of the type bitmap in octets
+func typeBitmapLen(types []uint16) uint16 {
+	var length uint16
+
+	for _, window := range pack.TypeBitmapWindows(types) {
+		length += uint16(len(window.Bitmap)) + 2
+	}
+
+	return length
+}
+
+func sameTypes(a, b []uint16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, t := range a {
+		if !hasType(b, t) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hasType(types []uint16, t uint16) bool {
+	for _, other := range types {
+		if other == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,360 @@
+package rr
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/go-void/portal/pkg/compression"
+	"github.com/go-void/portal/pkg/pack"
+)
+
+// NSEC3FlagOptOut indicates that the NSEC3 record may cover unsigned
+// delegations. See https://datatracker.ietf.org/doc/html/rfc5155#section-3.1.2.1
+const NSEC3FlagOptOut uint8 = 1
+
+// Base32Hex is the encoding of hashed owner names in NSEC3 records
+var Base32Hex = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+// See https://datatracker.ietf.org/doc/html/rfc5155#section-3
+type NSEC3 struct {
+	H             Header
+	HashAlgorithm uint8
+	Flags         uint8
+	Iterations    uint16
+	Salt          []byte
+	NextHashed    []byte
+	Types         []uint16
+}
+
+func (rr *NSEC3) Header() *Header {
+	return &rr.H
+}
+
+func (rr *NSEC3) SetHeader(header Header) {
+	rr.H = header
+}
+
+func (rr *NSEC3) SetData(data ...interface{}) error {
+	if len(data) != 6 {
+		return ErrInvalidRRData
+	}
+
+	algorithm, flags, iterations, salt, err := nsec3paramData(data[:4])
+	if err != nil {
+		return err
+	}
+
+	next, ok := data[4].([]byte)
+	if !ok {
+		return ErrFailedToConvertRRData
+	}
+
+	types, ok := data[5].([]uint16)
+	if !ok {
+		return ErrFailedToConvertRRData
+	}
+
+	rr.HashAlgorithm, rr.Flags, rr.Iterations, rr.Salt = algorithm, flags, iterations, salt
+	rr.NextHashed, rr.Types = next, types
+	return nil
+}
+
+func (rr *NSEC3) String() string {
+	return fmt.Sprintf("%s\t%s %s%s", rr.H, nsec3paramString(rr.HashAlgorithm, rr.Flags, rr.Iterations, rr.Salt),
+		Base32Hex.EncodeToString(rr.NextHashed), formatTypes(rr.Types))
+}
+
+func (rr *NSEC3) ParseData(rdata []string, origin string) error {
+	if len(rdata) < 5 {
+		return ErrInvalidPresentation
+	}
+
+	algorithm, flags, iterations, salt, err := nsec3paramParse(rdata[:4])
+	if err != nil {
+		return err
+	}
+
+	next, err := Base32Hex.DecodeString(strings.ToUpper(rdata[4]))
+	if err != nil {
+		return ErrInvalidPresentation
+	}
+
+	types, err := parseTypes(rdata[5:])
+	if err != nil {
+		return err
+	}
+
+	rr.HashAlgorithm, rr.Flags, rr.Iterations, rr.Salt = algorithm, flags, iterations, salt
+	rr.NextHashed, rr.Types = next, types
+	return nil
+}
+
+func (rr *NSEC3) Len() uint16 {
+	return uint16(len(rr.Salt)+len(rr.NextHashed)) + 6 + typeBitmapLen(rr.Types)
+}
+
+func (rr *NSEC3) IsSame(o RR) bool {
+	other, ok := o.(*NSEC3)
+	if !ok {
+		return false
+	}
+
+	return rr.HashAlgorithm == other.HashAlgorithm && rr.Flags == other.Flags &&
+		rr.Iterations == other.Iterations && bytes.Equal(rr.Salt, other.Salt) &&
+		bytes.Equal(rr.NextHashed, other.NextHashed) && sameTypes(rr.Types, other.Types)
+}
+
+func (rr *NSEC3) Unpack(data []byte, offset int) (int, error) {
+	end := offset + int(rr.H.RDLength)
+
+	algorithm, flags, iterations, salt, offset, err := nsec3paramUnpack(data, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	length, offset, err := pack.UnpackUint8(data, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	next, offset, err := pack.UnpackBytes(data, offset, int(length))
+	if err != nil {
+		return offset, err
+	}
+
+	types, offset, err := pack.UnpackTypeBitmap(data, offset, end)
+	if err != nil {
+		return offset, err
+	}
+
+	rr.HashAlgorithm, rr.Flags, rr.Iterations, rr.Salt = algorithm, flags, iterations, salt
+	rr.NextHashed, rr.Types = next, types
+	return offset, nil
+}
+
+func (rr *NSEC3) Pack(buf []byte, offset int, _ compression.Map) (int, error) {
+	offset, err := nsec3paramPack(rr.HashAlgorithm, rr.Flags, rr.Iterations, rr.Salt, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint8(uint8(len(rr.NextHashed)), buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackBytes(rr.NextHashed, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	return pack.PackTypeBitmap(rr.Types, buf, offset)
+}
+
+// HasType returns if the type bitmap contains the type
+func (rr *NSEC3) HasType(t uint16) bool {
+	return hasType(rr.Types, t)
+}
+
+// IsOptOut returns if the opt-out flag is set
+func (rr *NSEC3) IsOptOut() bool {
+	return rr.Flags&NSEC3FlagOptOut != 0
+}
+
+// NSEC3PARAM contains the parameters needed to calculate hashed owner names.
+// See https://datatracker.ietf.org/doc/html/rfc5155#section-4
+type NSEC3PARAM struct {
+	H             Header
+	HashAlgorithm uint8
+	Flags         uint8
+	Iterations    uint16
+	Salt          []byte
+}
+
+func (rr *NSEC3PARAM) Header() *Header {
+	return &rr.H
+}
+
+func (rr *NSEC3PARAM) SetHeader(header Header) {
+	rr.H = header
+}
+
+func (rr *NSEC3PARAM) SetData(data ...interface{}) error {
+	algorithm, flags, iterations, salt, err := nsec3paramData(data)
+	if err != nil {
+		return err
+	}
+
+	rr.HashAlgorithm, rr.Flags, rr.Iterations, rr.Salt = algorithm, flags, iterations, salt
+	return nil
+}
+
+func (rr *NSEC3PARAM) String() string {
+	return fmt.Sprintf("%s\t%s", rr.H, nsec3paramString(rr.HashAlgorithm, rr.Flags, rr.Iterations, rr.Salt))
+}
+
+func (rr *NSEC3PARAM) ParseData(rdata []string, origin string) error {
+	if err := parseFieldCount(rdata, 4); err != nil {
+		return err
+	}
+
+	algorithm, flags, iterations, salt, err := nsec3paramParse(rdata)
+	if err != nil {
+		return err
+	}
+
+	rr.HashAlgorithm, rr.Flags, rr.Iterations, rr.Salt = algorithm, flags, iterations, salt
+	return nil
+}
+
+func (rr *NSEC3PARAM) Len() uint16 {
+	return uint16(len(rr.Salt)) + 5
+}
+
+func (rr *NSEC3PARAM) IsSame(o RR) bool {
+	other, ok := o.(*NSEC3PARAM)
+	if !ok {
+		return false
+	}
+
+	return rr.HashAlgorithm == other.HashAlgorithm && rr.Flags == other.Flags &&
+		rr.Iterations == other.Iterations && bytes.Equal(rr.Salt, other.Salt)
+}
+
+func (rr *NSEC3PARAM) Unpack(data []byte, offset int) (int, error) {
+	algorithm, flags, iterations, salt, offset, err := nsec3paramUnpack(data, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	rr.HashAlgorithm, rr.Flags, rr.Iterations, rr.Salt = algorithm, flags, iterations, salt
+	return offset, nil
+}
+
+func (rr *NSEC3PARAM) Pack(buf []byte, offset int, _ compression.Map) (int, error) {
+	return nsec3paramPack(rr.HashAlgorithm, rr.Flags, rr.Iterations, rr.Salt, buf, offset)
+}
+
+// nsec3paramData converts the hash algorithm, flags, iterations and salt
+// passed to SetData
+func nsec3paramData(data []interface{}) (uint8, uint8, uint16, []byte, error) {
+	if len(data) != 4 {
+		return 0, 0, 0, nil, ErrInvalidRRData
+	}
+
+	algorithm, ok := data[0].(uint8)
+	if !ok {
+		return 0, 0, 0, nil, ErrFailedToConvertRRData
+	}
+
+	flags, ok := data[1].(uint8)
+	if !ok {
+		return 0, 0, 0, nil, ErrFailedToConvertRRData
+	}
+
+	iterations, ok := data[2].(uint16)
+	if !ok {
+		return 0, 0, 0, nil, ErrFailedToConvertRRData
+	}
+
+	salt, ok := data[3].([]byte)
+	if !ok {
+		return 0, 0, 0, nil, ErrFailedToConvertRRData
+	}
+
+	return algorithm, flags, iterations, salt, nil
+}
+
+// nsec3paramParse parses '<hash algorithm> <flags> <iterations> <salt>'
+// where an empty salt is represented by '-'
+func nsec3paramParse(rdata []string) (uint8, uint8, uint16, []byte, error) {
+	algorithm, err := parseUint8(rdata[0])
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	flags, err := parseUint8(rdata[1])
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	iterations, err := parseUint16(rdata[2])
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	var salt []byte
+	if rdata[3] != "-" {
+		salt, err = hex.DecodeString(rdata[3])
+		if err != nil || len(salt) > 255 {
+			return 0, 0, 0, nil, ErrInvalidPresentation
+		}
+	}
+
+	return algorithm, flags, iterations, salt, nil
+}
+
+func nsec3paramString(algorithm, flags uint8, iterations uint16, salt []byte) string {
+	s := "-"
+	if len(salt) > 0 {
+		s = strings.ToUpper(hex.EncodeToString(salt))
+	}
+
+	return fmt.Sprintf("%d %d %d %s", algorithm, flags, iterations, s)
+}
+
+func nsec3paramUnpack(data []byte, offset int) (uint8, uint8, uint16, []byte, int, error) {
+	algorithm, offset, err := pack.UnpackUint8(data, offset)
+	if err != nil {
+		return 0, 0, 0, nil, offset, err
+	}
+
+	flags, offset, err := pack.UnpackUint8(data, offset)
+	if err != nil {
+		return 0, 0, 0, nil, offset, err
+	}
+
+	iterations, offset, err := pack.UnpackUint16(data, offset)
+	if err != nil {
+		return 0, 0, 0, nil, offset, err
+	}
+
+	length, offset, err := pack.UnpackUint8(data, offset)
+	if err != nil {
+		return 0, 0, 0, nil, offset, err
+	}
+
+	salt, offset, err := pack.UnpackBytes(data, offset, int(length))
+	if err != nil {
+		return 0, 0, 0, nil, offset, err
+	}
+
+	return algorithm, flags, iterations, salt, offset, nil
+}
+
+func nsec3paramPack(algorithm, flags uint8, iterations uint16, salt []byte, buf []byte, offset int) (int, error) {
+	offset, err := pack.PackUint8(algorithm, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint8(flags, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint16(iterations, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint8(uint8(len(salt)), buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	return pack.PackBytes(salt, buf, offset)
+}
@@ -1,6 +1,10 @@
 package rr
 
-import "github.com/go-void/portal/pkg/compression"
+import (
+	"fmt"
+
+	"github.com/go-void/portal/pkg/compression"
+)
 
 // See https://datatracker.ietf.org/doc/html/rfc1035#section-3.3.10
 type NULL struct {
@@ -29,23 +33,44 @@ func (rr *NULL) SetData(data ...interface{}) error {
 	return nil
 }
 
+// String returns the presentation format. NULL records can hold anything,
+// which is why the RDATA is always presented in the generic format
 func (rr *NULL) String() string {
-	return ""
+	return fmt.Sprintf("%s\t%s", rr.H, formatGeneric([]byte(rr.Data)))
+}
+
+func (rr *NULL) ParseData(rdata []string, origin string) error {
+	return ParseGeneric(rr, rdata)
 }
 
 func (rr *NULL) Len() uint16 {
-	return 0
+	return uint16(len(rr.Data))
 }
 
 func (rr *NULL) IsSame(o RR) bool {
-	return true
+	other, ok := o.(*NULL)
+	if !ok {
+		return false
+	}
+
+	return rr.Data == other.Data
 }
 
+// Unpack unpacks the RDATA, which can be anything up to 65535 octets
 func (rr *NULL) Unpack(data []byte, offset int) (int, error) {
-	return offset, nil
+	end := offset + int(rr.H.RDLength)
+	if end > len(data) {
+		return len(data), ErrInvalidRRData
+	}
+
+	rr.Data = string(data[offset:end])
+	return end, nil
 }
 
-func (rr *NULL) Pack(data []byte, offset int, _ compression.Map) (int, error) {
-	// TODO (Techassi): Anything can be put in here. How do we pack that?
-	return offset, nil
+func (rr *NULL) Pack(buf []byte, offset int, _ compression.Map) (int, error) {
+	if offset+len(rr.Data) > len(buf) {
+		return len(buf), ErrInvalidRRData
+	}
+
+	return offset + copy(buf[offset:], rr.Data), nil
 }
@@ -35,14 +35,20 @@ func (rr *OPT) SetData(data ...interface{}) error {
 	return nil
 }
 
+// String returns a textual representation of the OPT pseudo RR. It has no
+// presentation format as it is never stored in master files
 func (rr *OPT) String() string {
-	return fmt.Sprintf("OPT <%v %v>", rr.H, rr.Options)
+	return fmt.Sprintf("%s\t; udp: %d, options: %v", rr.H.Name, rr.H.Class, rr.Options)
+}
+
+func (rr *OPT) ParseData(rdata []string, origin string) error {
+	return ErrNotParseable
 }
 
 func (rr *OPT) Len() uint16 {
 	var len = uint16(0)
 	for _, o := range rr.Options {
-		len += o.Len()
+		len += o.Len() + 4
 	}
 	return len
 }
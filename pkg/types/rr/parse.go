@@ -0,0 +1,306 @@
+package rr
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/zone/tokenizer"
+)
+
+var (
+	ErrInvalidPresentation = errors.New("invalid presentation format")
+	ErrInvalidTTL          = errors.New("invalid TTL")
+	ErrInvalidName         = errors.New("invalid domain name")
+	ErrRelativeName        = errors.New("relative domain name without origin")
+	ErrNotParseable        = errors.New("RR type has no presentation format")
+)
+
+// Parse parses a single RR in presentation format, e.g.
+// 'example.com. 300 IN MX 10 mail.example.com.'. The TTL and class are
+// optional and can appear in any order. Relative names are interpreted
+// relative to the root.
+// See https://datatracker.ietf.org/doc/html/rfc1035#section-5.1
+func Parse(s string) (RR, error) {
+	t := tokenizer.NewTokenizer(strings.NewReader(s))
+
+	tokens, err := t.Entry()
+	if err != nil {
+		if err == io.EOF {
+			return nil, ErrInvalidPresentation
+		}
+		return nil, err
+	}
+
+	// Only a single RR is allowed
+	if _, err := t.Entry(); err != io.EOF {
+		return nil, ErrInvalidPresentation
+	}
+
+	fields := tokenizer.Fields(tokens)
+	if len(fields) < 2 {
+		return nil, ErrInvalidPresentation
+	}
+
+	name, err := AbsoluteName(fields[0], ".")
+	if err != nil {
+		return nil, err
+	}
+
+	header := Header{
+		Name:  name,
+		Class: IN,
+	}
+
+	// The TTL and class can appear in any order before the type
+	i := 1
+	for seenTTL, seenClass := false, false; i < len(fields)-1; i++ {
+		if c, ok := ClassFromString(fields[i]); ok && !seenClass {
+			header.Class = c
+			seenClass = true
+			continue
+		}
+
+		if ttl, err := ParseTTL(fields[i]); err == nil && !seenTTL {
+			header.TTL = ttl
+			seenTTL = true
+			continue
+		}
+
+		break
+	}
+
+	return ParseWithHeader(header, fields[i], fields[i+1:], ".")
+}
+
+// ParseWithHeader creates a new RR of type 'typeName' and parses the RDATA
+// from presentation format. The header's type is set accordingly
+func ParseWithHeader(header Header, typeName string, rdata []string, origin string) (RR, error) {
+	t, ok := TypeFromString(typeName)
+	if !ok {
+		return nil, ErrNoSuchType
+	}
+
+	record, err := New(t)
+	if err != nil {
+		record = &Unknown{}
+	}
+
+	header.Type = t
+	record.SetHeader(header)
+
+	if IsGeneric(rdata) {
+		err = ParseGeneric(record, rdata)
+	} else {
+		err = record.ParseData(rdata, origin)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// ParseTTL parses a TTL which is either a number of seconds or a duration
+// using the units w, d, h, m and s (e.g. 1h30m)
+func ParseTTL(s string) (uint32, error) {
+	if s == "" {
+		return 0, ErrInvalidTTL
+	}
+
+	if ttl, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return uint32(ttl), nil
+	}
+
+	var total, current uint64
+	var digits bool
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= '0' && c <= '9' {
+			current = current*10 + uint64(c-'0')
+			digits = true
+			continue
+		}
+
+		if !digits {
+			return 0, ErrInvalidTTL
+		}
+
+		switch c {
+		case 'w', 'W':
+			total += current * 604800
+		case 'd', 'D':
+			total += current * 86400
+		case 'h', 'H':
+			total += current * 3600
+		case 'm', 'M':
+			total += current * 60
+		case 's', 'S':
+			total += current
+		default:
+			return 0, ErrInvalidTTL
+		}
+
+		current = 0
+		digits = false
+	}
+
+	if digits {
+		total += current
+	}
+
+	if total > 1<<32-1 {
+		return 0, ErrInvalidTTL
+	}
+
+	return uint32(total), nil
+}
+
+// AbsoluteName returns the absolute (fully qualified) version of name.
+// '@' refers to the origin and relative names get the origin appended
+func AbsoluteName(name, origin string) (string, error) {
+	switch {
+	case name == "@":
+		name = origin
+	case name == "":
+		return "", ErrInvalidName
+	case !strings.HasSuffix(name, "."):
+		if origin == "" {
+			return "", ErrRelativeName
+		}
+
+		if origin == "." {
+			name += "."
+		} else {
+			name += "." + origin
+		}
+	}
+
+	if name == "" {
+		return "", ErrRelativeName
+	}
+
+	if !labels.IsValid(name) {
+		return "", ErrInvalidName
+	}
+
+	return name, nil
+}
+
+// IsGeneric returns if the RDATA uses the generic '\# <length> <hex>' format.
+// See https://datatracker.ietf.org/doc/html/rfc3597#section-5
+func IsGeneric(rdata []string) bool {
+	return len(rdata) > 0 && rdata[0] == `\#`
+}
+
+// ParseGeneric parses RDATA in the generic format and unpacks it into
+// record
+func ParseGeneric(record RR, rdata []string) error {
+	if len(rdata) < 2 {
+		return ErrInvalidPresentation
+	}
+
+	length, err := strconv.ParseUint(rdata[1], 10, 16)
+	if err != nil {
+		return ErrInvalidPresentation
+	}
+
+	data, err := hex.DecodeString(strings.Join(rdata[2:], ""))
+	if err != nil || len(data) != int(length) {
+		return ErrInvalidPresentation
+	}
+
+	record.Header().RDLength = uint16(length)
+	if length == 0 {
+		return nil
+	}
+
+	offset, err := record.Unpack(data, 0)
+	if err != nil {
+		return err
+	}
+
+	if offset != len(data) {
+		return ErrInvalidPresentation
+	}
+
+	return nil
+}
+
+// formatGeneric returns RDATA in the generic format
+func formatGeneric(data []byte) string {
+	if len(data) == 0 {
+		return `\# 0`
+	}
+	return `\# ` + strconv.Itoa(len(data)) + " " + hex.EncodeToString(data)
+}
+
+// parseFieldCount checks that the RDATA consists of exactly n fields
+func parseFieldCount(rdata []string, n int) error {
+	if len(rdata) != n {
+		return ErrInvalidPresentation
+	}
+	return nil
+}
+
+// parseUint16 parses a presentation field as a uint16
+func parseUint16(s string) (uint16, error) {
+	n, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return 0, ErrInvalidPresentation
+	}
+	return uint16(n), nil
+}
+
+// parseUint8 parses a presentation field as a uint8
+func parseUint8(s string) (uint8, error) {
+	n, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return 0, ErrInvalidPresentation
+	}
+	return uint8(n), nil
+}
+
+// parseBase64 parses base64 encoded data which can be split into multiple
+// presentation fields
+func parseBase64(rdata []string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.Join(rdata, ""))
+	if err != nil {
+		return nil, ErrInvalidPresentation
+	}
+	return data, nil
+}
+
+// parseAddress parses a presentation field as an IPv4 or IPv6 address
+func parseAddress(s string, v6 bool) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(s)
+	if err != nil || addr.Is6() != v6 {
+		return netip.Addr{}, ErrInvalidPresentation
+	}
+	return addr, nil
+}
+
+// parseCharacterStrings unescapes presentation fields and checks that
+// each fits into a single character string
+func parseCharacterStrings(rdata []string) ([]string, error) {
+	strs := make([]string, len(rdata))
+	for i, field := range rdata {
+		s, err := tokenizer.Unescape(field)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(s) > MaxCharacterStringLen {
+			return nil, ErrInvalidPresentation
+		}
+		strs[i] = s
+	}
+	return strs, nil
+}
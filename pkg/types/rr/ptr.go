@@ -1,6 +1,8 @@
 package rr
 
 import (
+	"fmt"
+
 	"github.com/go-void/portal/pkg/compression"
 	"github.com/go-void/portal/pkg/pack"
 )
@@ -33,7 +35,21 @@ func (rr *PTR) SetData(data ...interface{}) error {
 }
 
 func (rr *PTR) String() string {
-	return ""
+	return fmt.Sprintf("%s\t%s", rr.H, rr.PTRDName)
+}
+
+func (rr *PTR) ParseData(rdata []string, origin string) error {
+	if err := parseFieldCount(rdata, 1); err != nil {
+		return err
+	}
+
+	name, err := AbsoluteName(rdata[0], origin)
+	if err != nil {
+		return err
+	}
+	rr.PTRDName = name
+
+	return nil
 }
 
 func (rr *PTR) Len() uint16 {
@@ -10,8 +10,9 @@ package rr
 
 import (
 	"errors"
+	"fmt"
 	"math"
-	"strings"
+	"reflect"
 	"time"
 
 	"github.com/go-void/portal/pkg/compression"
@@ -36,9 +37,14 @@ type RR interface {
 	// Set resource record data
 	SetData(...interface{}) error
 
-	// String returns the representation of any RR as text
+	// String returns the presentation format of the RR.
+	// See https://datatracker.ietf.org/doc/html/rfc1035#section-5.1
 	String() string
 
+	// ParseData parses the RDATA from presentation format. Relative
+	// domain names are made absolute by appending the origin
+	ParseData([]string, string) error
+
 	// Len returns the records RDLENGTH
 	Len() uint16
 
@@ -75,6 +81,12 @@ type Header struct {
 	Expires int64
 }
 
+// String returns the presentation format of the header, which consists of
+// the owner name, TTL, class and type
+func (h Header) String() string {
+	return fmt.Sprintf("%s\t%d\t%s\t%s", h.Name, h.TTL, ClassToString(h.Class), TypeToString(h.Type))
+}
+
 // New returns a new RR based on the provided type
 func New(t uint16) (RR, error) {
 	create, ok := typeMap[t]
@@ -87,7 +99,11 @@ func New(t uint16) (RR, error) {
 
 // NewFromName returns a new RR based on the provided name
 func NewFromName(name string) (RR, uint16, error) {
-	t := stringToTypeMap[strings.ToUpper(name)]
+	t, ok := TypeFromString(name)
+	if !ok {
+		return nil, 0, ErrNoSuchType
+	}
+
 	rr, err := New(t)
 	if err != nil {
 		return nil, 0, err
@@ -102,3 +118,17 @@ func UpdateTTL(record RR, expire time.Time) {
 	ttl := uint32(math.Max(0, (time.Until(expire)).Seconds()))
 	h.TTL = ttl
 }
+
+// Copy returns a shallow copy of the record. Changes to the header of the
+// copy don't affect the original, but RDATA slices are shared
+func Copy(record RR) RR {
+	v := reflect.ValueOf(record)
+	if v.Kind() != reflect.Ptr {
+		return record
+	}
+
+	c := reflect.New(v.Elem().Type())
+	c.Elem().Set(v.Elem())
+
+	return c.Interface().(RR)
+}
@@ -0,0 +1,306 @@
+package rr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-void/portal/pkg/compression"
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/pack"
+)
+
+// rrsigTimeFormat is the presentation format of the signature expiration
+// and inception fields. See https://datatracker.ietf.org/doc/html/rfc4034#section-3.2
+const rrsigTimeFormat = "20060102150405"
+
+// See https://datatracker.ietf.org/doc/html/rfc4034#section-3.1
+type RRSIG struct {
+	H           Header
+	TypeCovered uint16
+	Algorithm   uint8
+	Labels      uint8
+	OriginalTTL uint32
+	Expiration  uint32
+	Inception   uint32
+	KeyTag      uint16
+	SignerName  string
+	Signature   []byte
+}
+
+func (rr *RRSIG) Header() *Header {
+	return &rr.H
+}
+
+func (rr *RRSIG) SetHeader(header Header) {
+	rr.H = header
+}
+
+func (rr *RRSIG) SetData(data ...interface{}) error {
+	if len(data) != 9 {
+		return ErrInvalidRRData
+	}
+
+	var ok bool
+	if rr.TypeCovered, ok = data[0].(uint16); !ok {
+		return ErrFailedToConvertRRData
+	}
+
+	if rr.Algorithm, ok = data[1].(uint8); !ok {
+		return ErrFailedToConvertRRData
+	}
+
+	if rr.Labels, ok = data[2].(uint8); !ok {
+		return ErrFailedToConvertRRData
+	}
+
+	if rr.OriginalTTL, ok = data[3].(uint32); !ok {
+		return ErrFailedToConvertRRData
+	}
+
+	if rr.Expiration, ok = data[4].(uint32); !ok {
+		return ErrFailedToConvertRRData
+	}
+
+	if rr.Inception, ok = data[5].(uint32); !ok {
+		return ErrFailedToConvertRRData
+	}
+
+	if rr.KeyTag, ok = data[6].(uint16); !ok {
+		return ErrFailedToConvertRRData
+	}
+
+	if rr.SignerName, ok = data[7].(string); !ok {
+		return ErrFailedToConvertRRData
+	}
+
+	if rr.Signature, ok = data[8].([]byte); !ok {
+		return ErrFailedToConvertRRData
+	}
+
+	return nil
+}
+
+func (rr *RRSIG) String() string {
+	return fmt.Sprintf("%s\t%s %d %d %d %s %s %d %s %s", rr.H, TypeToString(rr.TypeCovered), rr.Algorithm,
+		rr.Labels, rr.OriginalTTL, formatSignatureTime(rr.Expiration), formatSignatureTime(rr.Inception),
+		rr.KeyTag, rr.SignerName, base64.StdEncoding.EncodeToString(rr.Signature))
+}
+
+func (rr *RRSIG) ParseData(rdata []string, origin string) error {
+	if len(rdata) < 9 {
+		return ErrInvalidPresentation
+	}
+
+	t, ok := TypeFromString(rdata[0])
+	if !ok {
+		return ErrInvalidPresentation
+	}
+	rr.TypeCovered = t
+
+	algorithm, err := parseUint8(rdata[1])
+	if err != nil {
+		return err
+	}
+	rr.Algorithm = algorithm
+
+	labelCount, err := parseUint8(rdata[2])
+	if err != nil {
+		return err
+	}
+	rr.Labels = labelCount
+
+	ttl, err := strconv.ParseUint(rdata[3], 10, 32)
+	if err != nil {
+		return ErrInvalidPresentation
+	}
+	rr.OriginalTTL = uint32(ttl)
+
+	expiration, err := parseSignatureTime(rdata[4])
+	if err != nil {
+		return err
+	}
+	rr.Expiration = expiration
+
+	inception, err := parseSignatureTime(rdata[5])
+	if err != nil {
+		return err
+	}
+	rr.Inception = inception
+
+	tag, err := parseUint16(rdata[6])
+	if err != nil {
+		return err
+	}
+	rr.KeyTag = tag
+
+	signer, err := AbsoluteName(rdata[7], origin)
+	if err != nil {
+		return err
+	}
+	rr.SignerName = signer
+
+	signature, err := parseBase64(rdata[8:])
+	if err != nil {
+		return err
+	}
+	rr.Signature = signature
+
+	return nil
+}
+
+func (rr *RRSIG) Len() uint16 {
+	return uint16(labels.Len(rr.SignerName)+len(rr.Signature)) + 18
+}
+
+func (rr *RRSIG) IsSame(o RR) bool {
+	other, ok := o.(*RRSIG)
+	if !ok {
+		return false
+	}
+
+	return rr.TypeCovered == other.TypeCovered && rr.Algorithm == other.Algorithm &&
+		rr.Labels == other.Labels && rr.OriginalTTL == other.OriginalTTL &&
+		rr.Expiration == other.Expiration && rr.Inception == other.Inception &&
+		rr.KeyTag == other.KeyTag && rr.SignerName == other.SignerName &&
+		bytes.Equal(rr.Signature, other.Signature)
+}
+
+func (rr *RRSIG) Unpack(data []byte, offset int) (int, error) {
+	end := offset + int(rr.H.RDLength)
+
+	typeCovered, offset, err := pack.UnpackUint16(data, offset)
+	if err != nil {
+		return offset, err
+	}
+	rr.TypeCovered = typeCovered
+
+	algorithm, offset, err := pack.UnpackUint8(data, offset)
+	if err != nil {
+		return offset, err
+	}
+	rr.Algorithm = algorithm
+
+	labelCount, offset, err := pack.UnpackUint8(data, offset)
+	if err != nil {
+		return offset, err
+	}
+	rr.Labels = labelCount
+
+	ttl, offset, err := pack.UnpackUint32(data, offset)
+	if err != nil {
+		return offset, err
+	}
+	rr.OriginalTTL = ttl
+
+	expiration, offset, err := pack.UnpackUint32(data, offset)
+	if err != nil {
+		return offset, err
+	}
+	rr.Expiration = expiration
+
+	inception, offset, err := pack.UnpackUint32(data, offset)
+	if err != nil {
+		return offset, err
+	}
+	rr.Inception = inception
+
+	tag, offset, err := pack.UnpackUint16(data, offset)
+	if err != nil {
+		return offset, err
+	}
+	rr.KeyTag = tag
+
+	signer, offset, err := pack.UnpackDomainName(data, offset)
+	if err != nil {
+		return offset, err
+	}
+	rr.SignerName = signer
+
+	signature, offset, err := pack.UnpackBytes(data, offset, end-offset)
+	if err != nil {
+		return offset, err
+	}
+	rr.Signature = signature
+
+	return offset, nil
+}
+
+// Pack packs the RDATA of the RRSIG RR. The signer's name is never
+// compressed. See https://datatracker.ietf.org/doc/html/rfc4034#section-3.1.7
+func (rr *RRSIG) Pack(buf []byte, offset int, comp compression.Map) (int, error) {
+	offset, err := rr.PackWithoutSignature(buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	return pack.PackBytes(rr.Signature, buf, offset)
+}
+
+// PackWithoutSignature packs all RDATA fields except the signature, which
+// is the start of the data a signature is calculated over.
+// See https://datatracker.ietf.org/doc/html/rfc4034#section-3.1.8.1
+func (rr *RRSIG) PackWithoutSignature(buf []byte, offset int) (int, error) {
+	offset, err := pack.PackUint16(rr.TypeCovered, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint8(rr.Algorithm, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint8(rr.Labels, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint32(rr.OriginalTTL, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint32(rr.Expiration, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint32(rr.Inception, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint16(rr.KeyTag, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	return pack.PackDomainName(rr.SignerName, buf, offset, compression.New())
+}
+
+// formatSignatureTime formats a signature expiration or inception time as
+// YYYYMMDDHHmmSS in UTC
+func formatSignatureTime(t uint32) string {
+	return time.Unix(int64(t), 0).UTC().Format(rrsigTimeFormat)
+}
+
+// parseSignatureTime parses a signature expiration or inception time in
+// the YYYYMMDDHHmmSS format or as seconds since the epoch
+func parseSignatureTime(s string) (uint32, error) {
+	if len(s) == len(rrsigTimeFormat) {
+		t, err := time.Parse(rrsigTimeFormat, s)
+		if err != nil {
+			return 0, ErrInvalidPresentation
+		}
+		return uint32(t.Unix()), nil
+	}
+
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, ErrInvalidPresentation
+	}
+	return uint32(n), nil
+}
@@ -2,6 +2,8 @@ package rr
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
 
 	"github.com/go-void/portal/pkg/compression"
 	"github.com/go-void/portal/pkg/constants"
@@ -91,7 +93,48 @@ func (rr *SOA) SetData(data ...interface{}) error {
 }
 
 func (rr *SOA) String() string {
-	return ""
+	return fmt.Sprintf("%s\t%s %s %d %d %d %d %d", rr.H, rr.MName, rr.RName,
+		rr.Serial, rr.Refresh, rr.Retry, rr.Expire, rr.Minimum)
+}
+
+func (rr *SOA) ParseData(rdata []string, origin string) error {
+	if err := parseFieldCount(rdata, 7); err != nil {
+		return err
+	}
+
+	mname, err := AbsoluteName(rdata[0], origin)
+	if err != nil {
+		return err
+	}
+	rr.MName = mname
+
+	rname, err := AbsoluteName(rdata[1], origin)
+	if err != nil {
+		return err
+	}
+	rr.RName = rname
+
+	serial, err := strconv.ParseUint(rdata[2], 10, 32)
+	if err != nil {
+		return ErrInvalidPresentation
+	}
+	rr.Serial = uint32(serial)
+
+	// The remaining fields are time intervals which can use units
+	// like TTLs
+	times := make([]uint32, 4)
+	for i := range times {
+		times[i], err = ParseTTL(rdata[3+i])
+		if err != nil {
+			return err
+		}
+	}
+
+	rr.Refresh = times[0]
+	rr.Retry = times[1]
+	rr.Expire = times[2]
+	rr.Minimum = times[3]
+	return nil
 }
 
 func (rr *SOA) Len() uint16 {
@@ -211,7 +254,9 @@ func (rr *SOA) SerialAdd(n int) error {
 		return ErrSOASerialOutOfRange
 	}
 
-	rr.Serial = (rr.Serial + uint32(n)) % constants.SerialBits
+	// The addition wraps around at 2^32, which is the modulo of the serial
+	// number arithmetic. See https://datatracker.ietf.org/doc/html/rfc1982#section-3.1
+	rr.Serial += uint32(n)
 	return nil
 }
 
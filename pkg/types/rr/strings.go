@@ -0,0 +1,68 @@
+package rr
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MaxCharacterStringLen is the maximum length of a single character string
+// in octets (excluding the length octet).
+// See https://datatracker.ietf.org/doc/html/rfc1035#section-3.3
+const MaxCharacterStringLen = 255
+
+// SplitCharacterString splits s into chunks which each fit into a single
+// character string. Empty strings result in a single empty chunk
+func SplitCharacterString(s string) []string {
+	if len(s) <= MaxCharacterStringLen {
+		return []string{s}
+	}
+
+	var chunks []string
+	for len(s) > MaxCharacterStringLen {
+		chunks = append(chunks, s[:MaxCharacterStringLen])
+		s = s[MaxCharacterStringLen:]
+	}
+
+	if len(s) > 0 {
+		chunks = append(chunks, s)
+	}
+
+	return chunks
+}
+
+// quoteCharacterString returns the presentation format of a character
+// string. It is always quoted, quotes and backslashes are escaped with a
+// backslash and non-printable octets are escaped as \DDD.
+// See https://datatracker.ietf.org/doc/html/rfc1035#section-5.1
+func quoteCharacterString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c > 0x7E:
+			b.WriteByte('\\')
+			d := strconv.Itoa(int(c))
+			b.WriteString(strings.Repeat("0", 3-len(d)) + d)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	b.WriteByte('"')
+	return b.String()
+}
+
+// quoteCharacterStrings returns the presentation format of multiple
+// character strings separated by a space
+func quoteCharacterStrings(strs []string) string {
+	quoted := make([]string, len(strs))
+	for i, s := range strs {
+		quoted[i] = quoteCharacterString(s)
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,236 @@
+package rr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-void/portal/pkg/compression"
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/pack"
+	"github.com/go-void/portal/pkg/types/svcb"
+)
+
+// See https://datatracker.ietf.org/doc/html/rfc9460#section-2.2
+type SVCB struct {
+	H        Header
+	Priority uint16
+	Target   string
+	Params   []svcb.Param
+}
+
+func (rr *SVCB) Header() *Header {
+	return &rr.H
+}
+
+func (rr *SVCB) SetHeader(header Header) {
+	rr.H = header
+}
+
+func (rr *SVCB) SetData(data ...interface{}) error {
+	priority, target, params, err := svcbData(data)
+	if err != nil {
+		return err
+	}
+
+	rr.Priority = priority
+	rr.Target = target
+	rr.Params = params
+	return nil
+}
+
+func (rr *SVCB) String() string {
+	return fmt.Sprintf("%s\t%s", rr.H, svcbString(rr.Priority, rr.Target, rr.Params))
+}
+
+func (rr *SVCB) ParseData(rdata []string, origin string) error {
+	priority, target, params, err := svcbParse(rdata, origin)
+	if err != nil {
+		return err
+	}
+
+	rr.Priority = priority
+	rr.Target = target
+	rr.Params = params
+	return nil
+}
+
+func (rr *SVCB) Len() uint16 {
+	return svcbLen(rr.Target, rr.Params)
+}
+
+func (rr *SVCB) IsSame(o RR) bool {
+	other, ok := o.(*SVCB)
+	if !ok {
+		return false
+	}
+
+	return svcbIsSame(rr.Priority, rr.Target, rr.Params, other.Priority, other.Target, other.Params)
+}
+
+func (rr *SVCB) Unpack(data []byte, offset int) (int, error) {
+	priority, target, params, offset, err := svcbUnpack(data, offset, rr.H.RDLength)
+	if err != nil {
+		return offset, err
+	}
+
+	rr.Priority = priority
+	rr.Target = target
+	rr.Params = params
+	return offset, nil
+}
+
+func (rr *SVCB) Pack(buf []byte, offset int, _ compression.Map) (int, error) {
+	return svcbPack(rr.Priority, rr.Target, rr.Params, buf, offset)
+}
+
+// TargetName returns the effective target name. In ServiceMode a target of
+// "." refers to the owner name of the record.
+// See https://datatracker.ietf.org/doc/html/rfc9460#section-2.5
+func (rr *SVCB) TargetName() string {
+	return svcbTargetName(rr.H.Name, rr.Priority, rr.Target)
+}
+
+// IsAliasMode returns if the record is in AliasMode (SvcPriority 0)
+func (rr *SVCB) IsAliasMode() bool {
+	return rr.Priority == 0
+}
+
+// svcbData converts the data passed to SetData. It expects the priority,
+// the target name and optionally a list of params
+func svcbData(data []interface{}) (uint16, string, []svcb.Param, error) {
+	if len(data) < 2 {
+		return 0, "", nil, ErrInvalidRRData
+	}
+
+	priority, ok := data[0].(uint16)
+	if !ok {
+		return 0, "", nil, ErrFailedToConvertRRData
+	}
+
+	target, ok := data[1].(string)
+	if !ok {
+		return 0, "", nil, ErrFailedToConvertRRData
+	}
+
+	var params []svcb.Param
+	for _, d := range data[2:] {
+		param, ok := d.(svcb.Param)
+		if !ok {
+			return 0, "", nil, ErrFailedToConvertRRData
+		}
+		params = append(params, param)
+	}
+
+	// Params must be stored in strictly increasing key order
+	sort.SliceStable(params, func(i, j int) bool {
+		return params[i].Key() < params[j].Key()
+	})
+
+	if err := svcb.Validate(params); err != nil {
+		return 0, "", nil, err
+	}
+
+	return priority, target, params, nil
+}
+
+func svcbParse(rdata []string, origin string) (uint16, string, []svcb.Param, error) {
+	if len(rdata) < 2 {
+		return 0, "", nil, ErrInvalidPresentation
+	}
+
+	priority, err := parseUint16(rdata[0])
+	if err != nil {
+		return 0, "", nil, err
+	}
+
+	target, err := AbsoluteName(rdata[1], origin)
+	if err != nil {
+		return 0, "", nil, err
+	}
+
+	data := []interface{}{priority, target}
+	for _, field := range rdata[2:] {
+		param, err := svcb.Parse(field)
+		if err != nil {
+			return 0, "", nil, err
+		}
+		data = append(data, param)
+	}
+
+	return svcbData(data)
+}
+
+func svcbString(priority uint16, target string, params []svcb.Param) string {
+	parts := []string{fmt.Sprintf("%d %s", priority, target)}
+	for _, param := range params {
+		parts = append(parts, svcb.Format(param))
+	}
+	return strings.Join(parts, " ")
+}
+
+func svcbLen(target string, params []svcb.Param) uint16 {
+	l := uint16(labels.Len(target)) + 2
+	for _, param := range params {
+		l += param.Len() + 4
+	}
+	return l
+}
+
+func svcbIsSame(ap uint16, at string, aparams []svcb.Param, bp uint16, bt string, bparams []svcb.Param) bool {
+	if ap != bp || at != bt || len(aparams) != len(bparams) {
+		return false
+	}
+
+	for i := range aparams {
+		if svcb.Format(aparams[i]) != svcb.Format(bparams[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func svcbUnpack(data []byte, offset int, rdlength uint16) (uint16, string, []svcb.Param, int, error) {
+	end := offset + int(rdlength)
+
+	priority, offset, err := pack.UnpackUint16(data, offset)
+	if err != nil {
+		return 0, "", nil, offset, err
+	}
+
+	target, offset, err := pack.UnpackDomainName(data, offset)
+	if err != nil {
+		return 0, "", nil, offset, err
+	}
+
+	params, offset, err := pack.UnpackSvcParams(data, offset, end)
+	if err != nil {
+		return 0, "", nil, offset, err
+	}
+
+	return priority, target, params, offset, nil
+}
+
+func svcbPack(priority uint16, target string, params []svcb.Param, buf []byte, offset int) (int, error) {
+	offset, err := pack.PackUint16(priority, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	// The target name must not be compressed, use a separate map
+	// See https://datatracker.ietf.org/doc/html/rfc9460#section-2.2
+	offset, err = pack.PackDomainName(target, buf, offset, compression.New())
+	if err != nil {
+		return offset, err
+	}
+
+	return pack.PackSvcParams(params, buf, offset)
+}
+
+func svcbTargetName(owner string, priority uint16, target string) string {
+	if target == "." && priority != 0 {
+		return owner
+	}
+	return target
+}
@@ -0,0 +1,173 @@
+package rr
+
+import (
+	"fmt"
+
+	"github.com/go-void/portal/pkg/compression"
+	"github.com/go-void/portal/pkg/pack"
+)
+
+// TSIG is the transaction signature meta RR. It is always the last record
+// of the additional section and is never cached or stored.
+// See https://datatracker.ietf.org/doc/html/rfc8945#section-4.2
+type TSIG struct {
+	H          Header
+	Algorithm  string
+	TimeSigned uint64 // 48 bit seconds since the epoch
+	Fudge      uint16
+	MACSize    uint16
+	MAC        []byte
+	OriginalID uint16
+	Error      uint16
+	OtherLen   uint16
+	OtherData  []byte
+}
+
+func (rr *TSIG) Header() *Header {
+	return &rr.H
+}
+
+func (rr *TSIG) SetHeader(header Header) {
+	rr.H = header
+}
+
+func (rr *TSIG) SetData(data ...interface{}) error {
+	return nil
+}
+
+// String returns a textual representation of the TSIG meta RR. It has no
+// presentation format as it is never stored in master files
+func (rr *TSIG) String() string {
+	return fmt.Sprintf("%s\t%s %d %d %d %s %d %d %d %s", rr.H, rr.Algorithm, rr.TimeSigned,
+		rr.Fudge, rr.MACSize, formatGeneric(rr.MAC), rr.OriginalID, rr.Error, rr.OtherLen,
+		formatGeneric(rr.OtherData))
+}
+
+func (rr *TSIG) ParseData(rdata []string, origin string) error {
+	return ErrNotParseable
+}
+
+func (rr *TSIG) Len() uint16 {
+	return uint16(len(rr.Algorithm)+len(rr.MAC)+len(rr.OtherData)) + 1 + 16
+}
+
+func (rr *TSIG) IsSame(o RR) bool {
+	return false
+}
+
+func (rr *TSIG) Unpack(data []byte, offset int) (int, error) {
+	algorithm, offset, err := pack.UnpackDomainName(data, offset)
+	if err != nil {
+		return offset, err
+	}
+	rr.Algorithm = algorithm
+
+	high, offset, err := pack.UnpackUint16(data, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	low, offset, err := pack.UnpackUint32(data, offset)
+	if err != nil {
+		return offset, err
+	}
+	rr.TimeSigned = uint64(high)<<32 | uint64(low)
+
+	fudge, offset, err := pack.UnpackUint16(data, offset)
+	if err != nil {
+		return offset, err
+	}
+	rr.Fudge = fudge
+
+	macSize, offset, err := pack.UnpackUint16(data, offset)
+	if err != nil {
+		return offset, err
+	}
+	rr.MACSize = macSize
+
+	if offset+int(macSize) > len(data) {
+		return len(data), ErrInvalidRRData
+	}
+	rr.MAC = make([]byte, macSize)
+	offset += copy(rr.MAC, data[offset:offset+int(macSize)])
+
+	originalID, offset, err := pack.UnpackUint16(data, offset)
+	if err != nil {
+		return offset, err
+	}
+	rr.OriginalID = originalID
+
+	tsigError, offset, err := pack.UnpackUint16(data, offset)
+	if err != nil {
+		return offset, err
+	}
+	rr.Error = tsigError
+
+	otherLen, offset, err := pack.UnpackUint16(data, offset)
+	if err != nil {
+		return offset, err
+	}
+	rr.OtherLen = otherLen
+
+	if offset+int(otherLen) > len(data) {
+		return len(data), ErrInvalidRRData
+	}
+	rr.OtherData = make([]byte, otherLen)
+	offset += copy(rr.OtherData, data[offset:offset+int(otherLen)])
+
+	return offset, nil
+}
+
+// Pack packs the RDATA of the TSIG RR. The algorithm name is never
+// compressed. See https://datatracker.ietf.org/doc/html/rfc8945#section-4.2
+func (rr *TSIG) Pack(buf []byte, offset int, comp compression.Map) (int, error) {
+	offset, err := pack.PackDomainName(rr.Algorithm, buf, offset, comp)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint16(uint16(rr.TimeSigned>>32), buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint32(uint32(rr.TimeSigned), buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint16(rr.Fudge, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint16(rr.MACSize, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	if offset+len(rr.MAC) > len(buf) {
+		return len(buf), ErrInvalidRRData
+	}
+	offset += copy(buf[offset:], rr.MAC)
+
+	offset, err = pack.PackUint16(rr.OriginalID, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint16(rr.Error, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	offset, err = pack.PackUint16(rr.OtherLen, buf, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	if offset+len(rr.OtherData) > len(buf) {
+		return len(buf), ErrInvalidRRData
+	}
+	return offset + copy(buf[offset:], rr.OtherData), nil
+}
@@ -1,16 +1,18 @@
 package rr
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/go-void/portal/pkg/compression"
 	"github.com/go-void/portal/pkg/pack"
+	"github.com/go-void/portal/pkg/zone/tokenizer"
 )
 
-// TODO (Techassi): Figure out if a TXT record can hold multiple strings
-
 // See https://datatracker.ietf.org/doc/html/rfc1035#section-3.3.14
 type TXT struct {
 	H    Header
-	Data string
+	Data []string
 }
 
 func (rr *TXT) Header() *Header {
@@ -21,25 +23,59 @@ func (rr *TXT) SetHeader(header Header) {
 	rr.H = header
 }
 
+// SetData sets one or more strings (or a slice of strings). Strings longer
+// than 255 octets are split into multiple character strings
 func (rr *TXT) SetData(data ...interface{}) error {
-	if len(data) != 1 {
+	if len(data) == 0 {
 		return ErrInvalidRRData
 	}
 
-	d, ok := data[0].(string)
-	if !ok {
-		return ErrFailedToConvertRRData
+	var strs []string
+	for _, d := range data {
+		switch s := d.(type) {
+		case string:
+			strs = append(strs, SplitCharacterString(s)...)
+		case []string:
+			for _, str := range s {
+				strs = append(strs, SplitCharacterString(str)...)
+			}
+		default:
+			return ErrFailedToConvertRRData
+		}
 	}
-	rr.Data = d
+
+	rr.Data = strs
 	return nil
 }
 
 func (rr *TXT) String() string {
-	return ""
+	return fmt.Sprintf("%s\t%s", rr.H, quoteCharacterStrings(rr.Data))
+}
+
+func (rr *TXT) ParseData(rdata []string, origin string) error {
+	if len(rdata) == 0 {
+		return ErrInvalidPresentation
+	}
+
+	var strs []string
+	for _, field := range rdata {
+		s, err := tokenizer.Unescape(field)
+		if err != nil {
+			return err
+		}
+		strs = append(strs, SplitCharacterString(s)...)
+	}
+	rr.Data = strs
+
+	return nil
 }
 
 func (rr *TXT) Len() uint16 {
-	return uint16(len(rr.Data)) + 1
+	var l = uint16(0)
+	for _, s := range rr.Data {
+		l += uint16(len(s)) + 1
+	}
+	return l
 }
 
 func (rr *TXT) IsSame(o RR) bool {
@@ -48,20 +84,57 @@ func (rr *TXT) IsSame(o RR) bool {
 		return false
 	}
 
-	return rr.Data == other.Data
+	if len(rr.Data) != len(other.Data) {
+		return false
+	}
+
+	for i := range rr.Data {
+		if rr.Data[i] != other.Data[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Text returns all character strings concatenated. This is useful for
+// records like SPF or DKIM which are split into multiple strings
+func (rr *TXT) Text() string {
+	return strings.Join(rr.Data, "")
 }
 
 func (rr *TXT) Unpack(data []byte, offset int) (int, error) {
-	// TODO (Techassi): Figure out how to unpack multiple character strings
-	str, offset, err := pack.UnpackCharacterString(data, offset)
-	if err != nil {
-		return offset, err
+	var (
+		end  = offset + int(rr.H.RDLength)
+		strs = []string{}
+	)
+
+	if end > len(data) {
+		return len(data), pack.ErrOverflowUnpackString
 	}
-	rr.Data = str
+
+	for offset < end {
+		str, o, err := pack.UnpackCharacterString(data[:end], offset)
+		if err != nil {
+			return o, err
+		}
+
+		strs = append(strs, str)
+		offset = o
+	}
+	rr.Data = strs
 
 	return offset, nil
 }
 
 func (rr *TXT) Pack(buf []byte, offset int, _ compression.Map) (int, error) {
-	return pack.PackCharacterString(rr.Data, buf, offset)
+	for _, s := range rr.Data {
+		o, err := pack.PackCharacterString(s, buf, offset)
+		if err != nil {
+			return o, err
+		}
+		offset = o
+	}
+
+	return offset, nil
 }
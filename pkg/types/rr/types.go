@@ -1,28 +1,45 @@
 package rr
 
+import (
+	"strconv"
+	"strings"
+)
+
 const (
-	TypeNone  uint16 = 0
-	TypeA     uint16 = 1  // A host address
-	TypeNS    uint16 = 2  // An authoritative name server
-	TypeMD    uint16 = 3  // A mail destination (Obsolete - use MX)
-	TypeMF    uint16 = 4  // A mail forwarded (Obsolete - use MX)
-	TypeCNAME uint16 = 5  // The canonical name for an alias
-	TypeSOA   uint16 = 6  // Marks the start of a zone of authority
-	TypeMB    uint16 = 7  // A mailbox domain name (EXPERIMENTAL)
-	TypeMG    uint16 = 8  // A mail group member (EXPERIMENTAL)
-	TypeMR    uint16 = 9  // A mail rename domain name (EXPERIMENTAL)
-	TypeNULL  uint16 = 10 // A null RR (EXPERIMENTAL)
-	TypePTR   uint16 = 12 // A domain name pointer
-	TypeHINFO uint16 = 13 // Host information
-	TypeMINFO uint16 = 14 // Mailbox or mail list information
-	TypeMX    uint16 = 15 // Mail exchange
-	TypeTXT   uint16 = 16 // Text strings
-	TypeAAAA  uint16 = 28 // AAAA host address
-	TypeOPT   uint16 = 41 // OPT Record / Meta record
+	TypeNone       uint16 = 0
+	TypeA          uint16 = 1  // A host address
+	TypeNS         uint16 = 2  // An authoritative name server
+	TypeMD         uint16 = 3  // A mail destination (Obsolete - use MX)
+	TypeMF         uint16 = 4  // A mail forwarded (Obsolete - use MX)
+	TypeCNAME      uint16 = 5  // The canonical name for an alias
+	TypeSOA        uint16 = 6  // Marks the start of a zone of authority
+	TypeMB         uint16 = 7  // A mailbox domain name (EXPERIMENTAL)
+	TypeMG         uint16 = 8  // A mail group member (EXPERIMENTAL)
+	TypeMR         uint16 = 9  // A mail rename domain name (EXPERIMENTAL)
+	TypeNULL       uint16 = 10 // A null RR (EXPERIMENTAL)
+	TypePTR        uint16 = 12 // A domain name pointer
+	TypeHINFO      uint16 = 13 // Host information
+	TypeMINFO      uint16 = 14 // Mailbox or mail list information
+	TypeMX         uint16 = 15 // Mail exchange
+	TypeTXT        uint16 = 16 // Text strings
+	TypeAAAA       uint16 = 28 // AAAA host address
+	TypeOPT        uint16 = 41 // OPT Record / Meta record
+	TypeDS         uint16 = 43 // Delegation signer
+	TypeRRSIG      uint16 = 46 // Signature of a RRset
+	TypeNSEC       uint16 = 47 // Next secure record (authenticated denial of existence)
+	TypeDNSKEY     uint16 = 48 // Public key of a zone
+	TypeNSEC3      uint16 = 50 // Hashed next secure record
+	TypeNSEC3PARAM uint16 = 51 // Parameters of hashed next secure records
+	TypeCDS        uint16 = 59 // Child copy of DS
+	TypeCDNSKEY    uint16 = 60 // Child copy of DNSKEY
+	TypeSVCB       uint16 = 64 // General-purpose service binding
+	TypeHTTPS      uint16 = 65 // SVCB-compatible type for use with HTTP
 
 	// QTypes are a superset of types and should only be
 	// allowed in questions
 
+	TypeTSIG  uint16 = 250 // Transaction signature (meta RR)
+	TypeIXFR  uint16 = 251 // A request for an incremental transfer of a zone
 	TypeAXFR  uint16 = 252 // A request for a transfer of an entire zone
 	TypeMAILB uint16 = 253 // A request for mailbox-related records (MB, MG or MR)
 	TypeMAILA uint16 = 254 // A request for mail agent RRs (Obsolete - see MX)
@@ -47,6 +64,18 @@ var typeMap = map[uint16]func() RR{
 	TypeA:     func() RR { return new(A) },
 	TypeAAAA:  func() RR { return new(AAAA) },
 	TypeOPT:   func() RR { return new(OPT) },
+	TypeSVCB:  func() RR { return new(SVCB) },
+	TypeHTTPS: func() RR { return new(HTTPS) },
+	TypeTSIG:  func() RR { return new(TSIG) },
+
+	TypeDS:         func() RR { return new(DS) },
+	TypeRRSIG:      func() RR { return new(RRSIG) },
+	TypeNSEC:       func() RR { return new(NSEC) },
+	TypeDNSKEY:     func() RR { return new(DNSKEY) },
+	TypeNSEC3:      func() RR { return new(NSEC3) },
+	TypeNSEC3PARAM: func() RR { return new(NSEC3PARAM) },
+	TypeCDS:        func() RR { return new(CDS) },
+	TypeCDNSKEY:    func() RR { return new(CDNSKEY) },
 }
 
 var typeToStringMap = map[uint16]string{
@@ -68,6 +97,20 @@ var typeToStringMap = map[uint16]string{
 	TypeTXT:   "TXT",
 	TypeAAAA:  "AAAA",
 	TypeOPT:   "OPT",
+	TypeDS:    "DS",
+
+	TypeRRSIG:      "RRSIG",
+	TypeNSEC:       "NSEC",
+	TypeDNSKEY:     "DNSKEY",
+	TypeNSEC3:      "NSEC3",
+	TypeNSEC3PARAM: "NSEC3PARAM",
+	TypeCDS:        "CDS",
+	TypeCDNSKEY:    "CDNSKEY",
+
+	TypeSVCB:  "SVCB",
+	TypeHTTPS: "HTTPS",
+	TypeTSIG:  "TSIG",
+	TypeIXFR:  "IXFR",
 	TypeAXFR:  "AXFR",
 	TypeMAILB: "MAILB",
 	TypeMAILA: "MAILA",
@@ -93,12 +136,52 @@ var stringToTypeMap = map[string]uint16{
 	"TXT":   TypeTXT,
 	"AAAA":  TypeAAAA,
 	"OPT":   TypeOPT,
+	"DS":    TypeDS,
+
+	"RRSIG":      TypeRRSIG,
+	"NSEC":       TypeNSEC,
+	"DNSKEY":     TypeDNSKEY,
+	"NSEC3":      TypeNSEC3,
+	"NSEC3PARAM": TypeNSEC3PARAM,
+	"CDS":        TypeCDS,
+	"CDNSKEY":    TypeCDNSKEY,
+
+	"SVCB":  TypeSVCB,
+	"HTTPS": TypeHTTPS,
+	"TSIG":  TypeTSIG,
+	"IXFR":  TypeIXFR,
 	"AXFR":  TypeAXFR,
 	"MAILB": TypeMAILB,
 	"MAILA": TypeMAILA,
 	"ANY":   TypeANY,
 }
 
+// TypeToString returns the mnemonic of the type. Unknown types are returned
+// in the generic 'TYPENNN' format.
+// See https://datatracker.ietf.org/doc/html/rfc3597#section-5
 func TypeToString(t uint16) string {
-	return typeToStringMap[t]
+	if s, ok := typeToStringMap[t]; ok {
+		return s
+	}
+	return "TYPE" + strconv.Itoa(int(t))
+}
+
+// TypeFromString returns the type of the mnemonic or generic 'TYPENNN'
+// format and if the type is valid
+func TypeFromString(s string) (uint16, bool) {
+	s = strings.ToUpper(s)
+	if t, ok := stringToTypeMap[s]; ok {
+		return t, true
+	}
+
+	if !strings.HasPrefix(s, "TYPE") {
+		return 0, false
+	}
+
+	t, err := strconv.ParseUint(s[4:], 10, 16)
+	if err != nil {
+		return 0, false
+	}
+
+	return uint16(t), true
 }
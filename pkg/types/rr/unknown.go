@@ -0,0 +1,76 @@
+package rr
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/go-void/portal/pkg/compression"
+)
+
+// Unknown holds the opaque RDATA of RR types which are not implemented.
+// See https://datatracker.ietf.org/doc/html/rfc3597
+type Unknown struct {
+	H    Header
+	Data []byte
+}
+
+func (rr *Unknown) Header() *Header {
+	return &rr.H
+}
+
+func (rr *Unknown) SetHeader(header Header) {
+	rr.H = header
+}
+
+func (rr *Unknown) SetData(data ...interface{}) error {
+	if len(data) != 1 {
+		return ErrInvalidRRData
+	}
+
+	d, ok := data[0].([]byte)
+	if !ok {
+		return ErrFailedToConvertRRData
+	}
+	rr.Data = d
+	return nil
+}
+
+func (rr *Unknown) String() string {
+	return fmt.Sprintf("%s\t%s", rr.H, formatGeneric(rr.Data))
+}
+
+func (rr *Unknown) ParseData(rdata []string, origin string) error {
+	return ParseGeneric(rr, rdata)
+}
+
+func (rr *Unknown) Len() uint16 {
+	return uint16(len(rr.Data))
+}
+
+func (rr *Unknown) IsSame(o RR) bool {
+	other, ok := o.(*Unknown)
+	if !ok {
+		return false
+	}
+
+	return rr.H.Type == other.H.Type && bytes.Equal(rr.Data, other.Data)
+}
+
+func (rr *Unknown) Unpack(data []byte, offset int) (int, error) {
+	end := offset + int(rr.H.RDLength)
+	if end > len(data) {
+		return len(data), ErrInvalidRRData
+	}
+
+	rr.Data = make([]byte, rr.H.RDLength)
+	copy(rr.Data, data[offset:end])
+	return end, nil
+}
+
+func (rr *Unknown) Pack(buf []byte, offset int, _ compression.Map) (int, error) {
+	if offset+len(rr.Data) > len(buf) {
+		return len(buf), ErrInvalidRRData
+	}
+
+	return offset + copy(buf[offset:], rr.Data), nil
+}
@@ -0,0 +1,576 @@
+package svcb
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Mandatory lists keys which a client must understand to use the RR.
+// See https://datatracker.ietf.org/doc/html/rfc9460#section-8
+type Mandatory struct {
+	Keys []Key
+}
+
+// Key returns the SvcParamKey
+func (p *Mandatory) Key() Key {
+	return KeyMandatory
+}
+
+// Len returns the length of the SvcParamValue in octets
+func (p *Mandatory) Len() uint16 {
+	return uint16(len(p.Keys) * 2)
+}
+
+// Unpack unpacks the SvcParamValue
+func (p *Mandatory) Unpack(data []byte, offset int, length uint16) (int, error) {
+	if length == 0 || length%2 != 0 || offset+int(length) > len(data) {
+		return len(data), ErrInvalidValue
+	}
+
+	p.Keys = nil
+	for end := offset + int(length); offset < end; offset += 2 {
+		p.Keys = append(p.Keys, Key(binary.BigEndian.Uint16(data[offset:])))
+	}
+
+	return offset, nil
+}
+
+// Pack packs the SvcParamValue. The keys are sorted in strictly increasing
+// order as required by the RFC
+func (p *Mandatory) Pack(buf []byte, offset int) (int, error) {
+	if offset+int(p.Len()) > len(buf) {
+		return len(buf), ErrOverflowParam
+	}
+
+	keys := make([]Key, len(p.Keys))
+	copy(keys, p.Keys)
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	for _, key := range keys {
+		binary.BigEndian.PutUint16(buf[offset:], uint16(key))
+		offset += 2
+	}
+
+	return offset, nil
+}
+
+// String returns the presentation format of the SvcParamValue
+func (p *Mandatory) String() string {
+	keys := make([]string, len(p.Keys))
+	for i, key := range p.Keys {
+		keys[i] = key.String()
+	}
+	return strings.Join(keys, ",")
+}
+
+// Parse parses the presentation format of the SvcParamValue
+func (p *Mandatory) Parse(input string) error {
+	if input == "" {
+		return ErrInvalidValue
+	}
+
+	p.Keys = nil
+	for _, name := range strings.Split(input, ",") {
+		key, err := KeyFromString(name)
+		if err != nil {
+			return err
+		}
+		p.Keys = append(p.Keys, key)
+	}
+
+	return nil
+}
+
+// ALPN lists the supported application layer protocol IDs.
+// See https://datatracker.ietf.org/doc/html/rfc9460#section-7.1
+type ALPN struct {
+	IDs []string
+}
+
+// Key returns the SvcParamKey
+func (p *ALPN) Key() Key {
+	return KeyALPN
+}
+
+// Len returns the length of the SvcParamValue in octets
+func (p *ALPN) Len() uint16 {
+	var l = uint16(0)
+	for _, id := range p.IDs {
+		l += uint16(len(id)) + 1
+	}
+	return l
+}
+
+// Unpack unpacks the SvcParamValue
+func (p *ALPN) Unpack(data []byte, offset int, length uint16) (int, error) {
+	end := offset + int(length)
+	if length == 0 || end > len(data) {
+		return len(data), ErrInvalidValue
+	}
+
+	p.IDs = nil
+	for offset < end {
+		l := int(data[offset])
+		offset++
+
+		if l == 0 || offset+l > end {
+			return len(data), ErrInvalidValue
+		}
+
+		p.IDs = append(p.IDs, string(data[offset:offset+l]))
+		offset += l
+	}
+
+	return offset, nil
+}
+
+// Pack packs the SvcParamValue
+func (p *ALPN) Pack(buf []byte, offset int) (int, error) {
+	if offset+int(p.Len()) > len(buf) {
+		return len(buf), ErrOverflowParam
+	}
+
+	for _, id := range p.IDs {
+		if len(id) == 0 || len(id) > 255 {
+			return offset, ErrInvalidValue
+		}
+
+		buf[offset] = uint8(len(id))
+		offset++
+
+		offset += copy(buf[offset:], id)
+	}
+
+	return offset, nil
+}
+
+// String returns the presentation format of the SvcParamValue. Commas and
+// backslashes inside of IDs are escaped
+func (p *ALPN) String() string {
+	ids := make([]string, len(p.IDs))
+	for i, id := range p.IDs {
+		id = strings.ReplaceAll(id, `\`, `\\`)
+		ids[i] = strings.ReplaceAll(id, ",", `\,`)
+	}
+	return strings.Join(ids, ",")
+}
+
+// Parse parses the presentation format of the SvcParamValue
+func (p *ALPN) Parse(input string) error {
+	if input == "" {
+		return ErrInvalidValue
+	}
+
+	var (
+		id      []byte
+		escaped bool
+	)
+
+	p.IDs = nil
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		switch {
+		case escaped:
+			id = append(id, c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == ',':
+			if len(id) == 0 {
+				return ErrInvalidValue
+			}
+			p.IDs = append(p.IDs, string(id))
+			id = nil
+		default:
+			id = append(id, c)
+		}
+	}
+
+	if len(id) == 0 || escaped {
+		return ErrInvalidValue
+	}
+	p.IDs = append(p.IDs, string(id))
+
+	return nil
+}
+
+// NoDefaultALPN indicates that the default protocol is not supported. This
+// param has an empty value.
+// See https://datatracker.ietf.org/doc/html/rfc9460#section-7.1
+type NoDefaultALPN struct{}
+
+// Key returns the SvcParamKey
+func (p *NoDefaultALPN) Key() Key {
+	return KeyNoDefaultALPN
+}
+
+// Len returns the length of the SvcParamValue in octets
+func (p *NoDefaultALPN) Len() uint16 {
+	return 0
+}
+
+// Unpack unpacks the SvcParamValue
+func (p *NoDefaultALPN) Unpack(data []byte, offset int, length uint16) (int, error) {
+	if length != 0 {
+		return len(data), ErrUnexpectedValue
+	}
+	return offset, nil
+}
+
+// Pack packs the SvcParamValue
+func (p *NoDefaultALPN) Pack(buf []byte, offset int) (int, error) {
+	return offset, nil
+}
+
+// String returns the presentation format of the SvcParamValue
+func (p *NoDefaultALPN) String() string {
+	return ""
+}
+
+// Parse parses the presentation format of the SvcParamValue
+func (p *NoDefaultALPN) Parse(input string) error {
+	if input != "" {
+		return ErrUnexpectedValue
+	}
+	return nil
+}
+
+// Port specifies the TCP or UDP port of the alternative endpoint.
+// See https://datatracker.ietf.org/doc/html/rfc9460#section-7.2
+type Port struct {
+	Port uint16
+}
+
+// Key returns the SvcParamKey
+func (p *Port) Key() Key {
+	return KeyPort
+}
+
+// Len returns the length of the SvcParamValue in octets
+func (p *Port) Len() uint16 {
+	return 2
+}
+
+// Unpack unpacks the SvcParamValue
+func (p *Port) Unpack(data []byte, offset int, length uint16) (int, error) {
+	if length != 2 || offset+2 > len(data) {
+		return len(data), ErrInvalidValue
+	}
+
+	p.Port = binary.BigEndian.Uint16(data[offset:])
+	return offset + 2, nil
+}
+
+// Pack packs the SvcParamValue
+func (p *Port) Pack(buf []byte, offset int) (int, error) {
+	if offset+2 > len(buf) {
+		return len(buf), ErrOverflowParam
+	}
+
+	binary.BigEndian.PutUint16(buf[offset:], p.Port)
+	return offset + 2, nil
+}
+
+// String returns the presentation format of the SvcParamValue
+func (p *Port) String() string {
+	return strconv.Itoa(int(p.Port))
+}
+
+// Parse parses the presentation format of the SvcParamValue
+func (p *Port) Parse(input string) error {
+	port, err := strconv.ParseUint(input, 10, 16)
+	if err != nil {
+		return ErrInvalidValue
+	}
+
+	p.Port = uint16(port)
+	return nil
+}
+
+// IPv4Hint lists IPv4 addresses which clients may use to reach the service.
+// See https://datatracker.ietf.org/doc/html/rfc9460#section-7.3
+type IPv4Hint struct {
+	Addresses []netip.Addr
+}
+
+// Key returns the SvcParamKey
+func (p *IPv4Hint) Key() Key {
+	return KeyIPv4Hint
+}
+
+// Len returns the length of the SvcParamValue in octets
+func (p *IPv4Hint) Len() uint16 {
+	return uint16(len(p.Addresses) * 4)
+}
+
+// Unpack unpacks the SvcParamValue
+func (p *IPv4Hint) Unpack(data []byte, offset int, length uint16) (int, error) {
+	addrs, offset, err := unpackAddresses(data, offset, length, 4)
+	p.Addresses = addrs
+	return offset, err
+}
+
+// Pack packs the SvcParamValue
+func (p *IPv4Hint) Pack(buf []byte, offset int) (int, error) {
+	return packAddresses(p.Addresses, buf, offset, 4)
+}
+
+// String returns the presentation format of the SvcParamValue
+func (p *IPv4Hint) String() string {
+	return formatAddresses(p.Addresses)
+}
+
+// Parse parses the presentation format of the SvcParamValue
+func (p *IPv4Hint) Parse(input string) error {
+	addrs, err := parseAddresses(input, false)
+	p.Addresses = addrs
+	return err
+}
+
+// ECH holds an ECHConfigList which is presented in base64.
+// See https://datatracker.ietf.org/doc/html/rfc9460#section-9
+type ECH struct {
+	Config []byte
+}
+
+// Key returns the SvcParamKey
+func (p *ECH) Key() Key {
+	return KeyECH
+}
+
+// Len returns the length of the SvcParamValue in octets
+func (p *ECH) Len() uint16 {
+	return uint16(len(p.Config))
+}
+
+// Unpack unpacks the SvcParamValue
+func (p *ECH) Unpack(data []byte, offset int, length uint16) (int, error) {
+	if length == 0 || offset+int(length) > len(data) {
+		return len(data), ErrInvalidValue
+	}
+
+	p.Config = make([]byte, length)
+	copy(p.Config, data[offset:])
+	return offset + int(length), nil
+}
+
+// Pack packs the SvcParamValue
+func (p *ECH) Pack(buf []byte, offset int) (int, error) {
+	if offset+len(p.Config) > len(buf) {
+		return len(buf), ErrOverflowParam
+	}
+
+	return offset + copy(buf[offset:], p.Config), nil
+}
+
+// String returns the presentation format of the SvcParamValue
+func (p *ECH) String() string {
+	return base64.StdEncoding.EncodeToString(p.Config)
+}
+
+// Parse parses the presentation format of the SvcParamValue
+func (p *ECH) Parse(input string) error {
+	config, err := base64.StdEncoding.DecodeString(input)
+	if err != nil || len(config) == 0 {
+		return ErrInvalidValue
+	}
+
+	p.Config = config
+	return nil
+}
+
+// IPv6Hint lists IPv6 addresses which clients may use to reach the service.
+// See https://datatracker.ietf.org/doc/html/rfc9460#section-7.3
+type IPv6Hint struct {
+	Addresses []netip.Addr
+}
+
+// Key returns the SvcParamKey
+func (p *IPv6Hint) Key() Key {
+	return KeyIPv6Hint
+}
+
+// Len returns the length of the SvcParamValue in octets
+func (p *IPv6Hint) Len() uint16 {
+	return uint16(len(p.Addresses) * 16)
+}
+
+// Unpack unpacks the SvcParamValue
+func (p *IPv6Hint) Unpack(data []byte, offset int, length uint16) (int, error) {
+	addrs, offset, err := unpackAddresses(data, offset, length, 16)
+	p.Addresses = addrs
+	return offset, err
+}
+
+// Pack packs the SvcParamValue
+func (p *IPv6Hint) Pack(buf []byte, offset int) (int, error) {
+	return packAddresses(p.Addresses, buf, offset, 16)
+}
+
+// String returns the presentation format of the SvcParamValue
+func (p *IPv6Hint) String() string {
+	return formatAddresses(p.Addresses)
+}
+
+// Parse parses the presentation format of the SvcParamValue
+func (p *IPv6Hint) Parse(input string) error {
+	addrs, err := parseAddresses(input, true)
+	p.Addresses = addrs
+	return err
+}
+
+// Generic holds the opaque value of a key which has no dedicated
+// implementation. The presentation format uses escaped octets.
+// See https://datatracker.ietf.org/doc/html/rfc9460#section-2.1
+type Generic struct {
+	K     Key
+	Value []byte
+}
+
+// Key returns the SvcParamKey
+func (p *Generic) Key() Key {
+	return p.K
+}
+
+// Len returns the length of the SvcParamValue in octets
+func (p *Generic) Len() uint16 {
+	return uint16(len(p.Value))
+}
+
+// Unpack unpacks the SvcParamValue
+func (p *Generic) Unpack(data []byte, offset int, length uint16) (int, error) {
+	if offset+int(length) > len(data) {
+		return len(data), ErrInvalidValue
+	}
+
+	p.Value = make([]byte, length)
+	copy(p.Value, data[offset:])
+	return offset + int(length), nil
+}
+
+// Pack packs the SvcParamValue
+func (p *Generic) Pack(buf []byte, offset int) (int, error) {
+	if offset+len(p.Value) > len(buf) {
+		return len(buf), ErrOverflowParam
+	}
+
+	return offset + copy(buf[offset:], p.Value), nil
+}
+
+// String returns the presentation format of the SvcParamValue. Non
+// printable octets and special characters are escaped as \DDD
+func (p *Generic) String() string {
+	var b strings.Builder
+	for _, c := range p.Value {
+		switch {
+		case c < 0x21 || c > 0x7E || c == '"' || c == ';' || c == '\\':
+			b.WriteString(`\` + leftPad(strconv.Itoa(int(c))))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// Parse parses the presentation format of the SvcParamValue
+func (p *Generic) Parse(input string) error {
+	p.Value = nil
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		if c != '\\' {
+			p.Value = append(p.Value, c)
+			continue
+		}
+
+		if i+3 < len(input) && isDigits(input[i+1:i+4]) {
+			n, _ := strconv.Atoi(input[i+1 : i+4])
+			if n > 255 {
+				return ErrInvalidValue
+			}
+
+			p.Value = append(p.Value, byte(n))
+			i += 3
+			continue
+		}
+
+		if i+1 >= len(input) {
+			return ErrInvalidValue
+		}
+
+		p.Value = append(p.Value, input[i+1])
+		i++
+	}
+
+	return nil
+}
+
+func unpackAddresses(data []byte, offset int, length uint16, size int) ([]netip.Addr, int, error) {
+	if length == 0 || int(length)%size != 0 || offset+int(length) > len(data) {
+		return nil, len(data), ErrInvalidValue
+	}
+
+	var addrs []netip.Addr
+	for end := offset + int(length); offset < end; offset += size {
+		addr, _ := netip.AddrFromSlice(data[offset : offset+size])
+		addrs = append(addrs, addr)
+	}
+
+	return addrs, offset, nil
+}
+
+func packAddresses(addrs []netip.Addr, buf []byte, offset, size int) (int, error) {
+	if offset+len(addrs)*size > len(buf) {
+		return len(buf), ErrOverflowParam
+	}
+
+	for _, addr := range addrs {
+		if addr.BitLen() != size*8 {
+			return offset, ErrInvalidValue
+		}
+		offset += copy(buf[offset:], addr.AsSlice())
+	}
+
+	return offset, nil
+}
+
+func formatAddresses(addrs []netip.Addr) string {
+	list := make([]string, len(addrs))
+	for i, addr := range addrs {
+		list[i] = addr.String()
+	}
+	return strings.Join(list, ",")
+}
+
+func parseAddresses(input string, v6 bool) ([]netip.Addr, error) {
+	if input == "" {
+		return nil, ErrInvalidValue
+	}
+
+	var addrs []netip.Addr
+	for _, raw := range strings.Split(input, ",") {
+		addr, err := netip.ParseAddr(raw)
+		if err != nil || addr.Is6() != v6 {
+			return nil, ErrInvalidValue
+		}
+		addrs = append(addrs, addr)
+	}
+
+	return addrs, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func leftPad(s string) string {
+	return strings.Repeat("0", 3-len(s)) + s
+}
@@ -0,0 +1,194 @@
+// Package svcb provides the SvcParams of SVCB and HTTPS resource records.
+// See https://datatracker.ietf.org/doc/html/rfc9460#section-7
+package svcb
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNoSuchKey         = errors.New("svcb: no such key")
+	ErrInvalidKey        = errors.New("svcb: invalid key")
+	ErrInvalidValue      = errors.New("svcb: invalid value")
+	ErrDuplicateKey      = errors.New("svcb: duplicate key")
+	ErrMissingMandatory  = errors.New("svcb: mandatory key missing")
+	ErrOverflowParam     = errors.New("svcb: offset overflow (un)packing param")
+	ErrUnexpectedValue   = errors.New("svcb: unexpected value")
+	ErrInvalidParamOrder = errors.New("svcb: params not in strictly increasing order")
+)
+
+// Param describes a single SvcParam which consists of a key and
+// a value (which can be empty).
+// See https://datatracker.ietf.org/doc/html/rfc9460#section-2.2
+type Param interface {
+	// Key returns the SvcParamKey
+	Key() Key
+
+	// Len returns the length of the SvcParamValue in octets
+	Len() uint16
+
+	// Unpack unpacks the SvcParamValue
+	Unpack([]byte, int, uint16) (int, error)
+
+	// Pack packs the SvcParamValue
+	Pack([]byte, int) (int, error)
+
+	// String returns the presentation format of the SvcParamValue
+	String() string
+
+	// Parse parses the presentation format of the SvcParamValue
+	Parse(string) error
+}
+
+// New returns a new param based on the provided key. Keys which are not
+// known return a generic param which holds the opaque value
+func New(key Key) Param {
+	create, ok := keyMap[key]
+	if !ok {
+		return &Generic{K: key}
+	}
+
+	return create()
+}
+
+// Format returns the presentation format of a param, e.g. 'alpn=h2,h3'
+func Format(p Param) string {
+	value := p.String()
+	if value == "" {
+		return p.Key().String()
+	}
+
+	return fmt.Sprintf("%s=%s", p.Key(), value)
+}
+
+// Parse parses a param in presentation format, e.g. 'port=8443'
+func Parse(input string) (Param, error) {
+	name, value, _ := strings.Cut(input, "=")
+
+	key, err := KeyFromString(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Values can optionally be quoted
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+
+	param := New(key)
+	err = param.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return param, nil
+}
+
+// Validate checks that the keys of params are in strictly increasing order
+// and that all keys listed in the mandatory param are present
+func Validate(params []Param) error {
+	for i := 1; i < len(params); i++ {
+		if params[i-1].Key() == params[i].Key() {
+			return ErrDuplicateKey
+		}
+
+		if params[i-1].Key() > params[i].Key() {
+			return ErrInvalidParamOrder
+		}
+	}
+
+	for _, param := range params {
+		mandatory, ok := param.(*Mandatory)
+		if !ok {
+			continue
+		}
+
+		for _, key := range mandatory.Keys {
+			if key == KeyMandatory || !has(params, key) {
+				return ErrMissingMandatory
+			}
+		}
+	}
+
+	return nil
+}
+
+func has(params []Param, key Key) bool {
+	for _, param := range params {
+		if param.Key() == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Key describes a SvcParamKey.
+// See https://www.iana.org/assignments/dns-svcb/dns-svcb.xhtml
+type Key uint16
+
+const (
+	KeyMandatory     Key = 0 // Mandatory keys in this RR
+	KeyALPN          Key = 1 // Additional supported protocols
+	KeyNoDefaultALPN Key = 2 // No support for default protocol
+	KeyPort          Key = 3 // Port for alternative endpoint
+	KeyIPv4Hint      Key = 4 // IPv4 address hints
+	KeyECH           Key = 5 // Encrypted ClientHello info
+	KeyIPv6Hint      Key = 6 // IPv6 address hints
+
+	// 65280-65534 are reserved for private use
+
+	KeyInvalid Key = 65535 // Reserved ("Invalid key")
+)
+
+var keyMap = map[Key]func() Param{
+	KeyMandatory:     func() Param { return new(Mandatory) },
+	KeyALPN:          func() Param { return new(ALPN) },
+	KeyNoDefaultALPN: func() Param { return new(NoDefaultALPN) },
+	KeyPort:          func() Param { return new(Port) },
+	KeyIPv4Hint:      func() Param { return new(IPv4Hint) },
+	KeyECH:           func() Param { return new(ECH) },
+	KeyIPv6Hint:      func() Param { return new(IPv6Hint) },
+}
+
+var keyToStringMap = map[Key]string{
+	KeyMandatory:     "mandatory",
+	KeyALPN:          "alpn",
+	KeyNoDefaultALPN: "no-default-alpn",
+	KeyPort:          "port",
+	KeyIPv4Hint:      "ipv4hint",
+	KeyECH:           "ech",
+	KeyIPv6Hint:      "ipv6hint",
+}
+
+// String returns the presentation format of the key. Keys without a
+// registered name are presented as 'keyNNNNN'
+func (k Key) String() string {
+	if name, ok := keyToStringMap[k]; ok {
+		return name
+	}
+	return "key" + strconv.Itoa(int(k))
+}
+
+// KeyFromString returns the key for the provided name. It supports
+// registered names and the generic 'keyNNNNN' format
+func KeyFromString(name string) (Key, error) {
+	for key, n := range keyToStringMap {
+		if n == name {
+			return key, nil
+		}
+	}
+
+	if !strings.HasPrefix(name, "key") {
+		return KeyInvalid, ErrNoSuchKey
+	}
+
+	n, err := strconv.ParseUint(name[3:], 10, 16)
+	if err != nil || Key(n) == KeyInvalid {
+		return KeyInvalid, ErrInvalidKey
+	}
+
+	return Key(n), nil
+}
@@ -0,0 +1,323 @@
+// Package update applies dynamic updates to zones of a record store.
+// See https://datatracker.ietf.org/doc/html/rfc2136
+package update
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/store"
+	"github.com/go-void/portal/pkg/types/dns"
+	"github.com/go-void/portal/pkg/types/rcode"
+	"github.com/go-void/portal/pkg/types/rr"
+)
+
+// Updater applies dynamic updates to zones. Each update is applied in a
+// single transaction of the store, which replaces the zone at once
+type Updater struct {
+	Store store.Store
+
+	// Updates of the same store are serialized, as concurrent updates
+	// would otherwise conflict when committed
+	lock sync.Mutex
+}
+
+func New(s store.Store) *Updater {
+	return &Updater{
+		Store: s,
+	}
+}
+
+// Apply checks the prerequisites of the UPDATE message and applies the
+// updates to the zone with origin. The zone section, prerequisites and
+// updates are expected in the question, answer and authority sections. It
+// returns the rcode of the response and the new SOA record if the zone
+// changed.
+// See https://datatracker.ietf.org/doc/html/rfc2136#section-3
+func (u *Updater) Apply(origin string, message *dns.Message) (rcode.Code, *rr.SOA) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	txn, err := u.Store.Begin(origin)
+	if err != nil {
+		return rcode.NotAuth, nil
+	}
+
+	zone := &update{
+		origin: strings.ToLower(labels.Rootify(origin)),
+		class:  message.Question[0].Class,
+		txn:    txn,
+	}
+
+	if code := zone.checkPrerequisites(message.Answer); code != rcode.NoError {
+		txn.Rollback()
+		return code, nil
+	}
+
+	if code := zone.prescan(message.Authority); code != rcode.NoError {
+		txn.Rollback()
+		return code, nil
+	}
+
+	if err := zone.apply(message.Authority); err != nil {
+		txn.Rollback()
+		return rcode.ServerFailure, nil
+	}
+
+	// The serial gets incremented automatically unless the update
+	// replaced the SOA record itself
+	soa, err := txn.Commit()
+	if err != nil {
+		return rcode.ServerFailure, nil
+	}
+
+	return rcode.NoError, soa
+}
+
+// update applies the updates of a single UPDATE message to a transaction
+// of the zone
+type update struct {
+	origin string
+	class  uint16
+	txn    store.Txn
+}
+
+// checkPrerequisites checks the prerequisites against the zone.
+// See https://datatracker.ietf.org/doc/html/rfc2136#section-3.2
+func (z *update) checkPrerequisites(prerequisites []rr.RR) rcode.Code {
+	var expected []rr.RR
+
+	for _, record := range prerequisites {
+		h := record.Header()
+
+		if h.TTL != 0 {
+			return rcode.FormatError
+		}
+
+		if !labels.IsSubdomain(h.Name, z.origin) {
+			return rcode.NotZone
+		}
+
+		switch h.Class {
+		case rr.ANY:
+			if h.RDLength != 0 {
+				return rcode.FormatError
+			}
+
+			if h.Type == rr.TypeANY {
+				if !z.nameInUse(h.Name) {
+					return rcode.NameError
+				}
+			} else if len(z.rrset(h.Name, h.Type)) == 0 {
+				return rcode.NXRRSet
+			}
+		case rr.NONE:
+			if h.RDLength != 0 {
+				return rcode.FormatError
+			}
+
+			if h.Type == rr.TypeANY {
+				if z.nameInUse(h.Name) {
+					return rcode.YXDomain
+				}
+			} else if len(z.rrset(h.Name, h.Type)) != 0 {
+				return rcode.YXRRSet
+			}
+		case z.class:
+			expected = append(expected, record)
+		default:
+			return rcode.FormatError
+		}
+	}
+
+	// RRsets which must exist with exactly the provided records
+	// (value dependent) are compared as a whole
+	for _, record := range expected {
+		var (
+			h    = record.Header()
+			want = filter(expected, h.Name, h.Type)
+			have = z.rrset(h.Name, h.Type)
+		)
+
+		if len(want) != len(have) || !containsAll(have, want) {
+			return rcode.NXRRSet
+		}
+	}
+
+	return rcode.NoError
+}
+
+// prescan checks the updates for format errors before any update gets
+// applied. See https://datatracker.ietf.org/doc/html/rfc2136#section-3.4.1
+func (z *update) prescan(updates []rr.RR) rcode.Code {
+	for _, record := range updates {
+		h := record.Header()
+
+		if !labels.IsSubdomain(h.Name, z.origin) {
+			return rcode.NotZone
+		}
+
+		switch h.Class {
+		case z.class:
+			if isMetaType(h.Type) || h.Type == rr.TypeANY {
+				return rcode.FormatError
+			}
+		case rr.ANY:
+			if h.TTL != 0 || h.RDLength != 0 || isMetaType(h.Type) {
+				return rcode.FormatError
+			}
+		case rr.NONE:
+			if h.TTL != 0 || isMetaType(h.Type) || h.Type == rr.TypeANY {
+				return rcode.FormatError
+			}
+		default:
+			return rcode.FormatError
+		}
+	}
+
+	return rcode.NoError
+}
+
+// apply applies the updates to the transaction.
+// See https://datatracker.ietf.org/doc/html/rfc2136#section-3.4.2
+func (z *update) apply(updates []rr.RR) error {
+	for _, record := range updates {
+		var (
+			h    = record.Header()
+			apex = strings.EqualFold(labels.Rootify(h.Name), z.origin)
+			err  error
+		)
+
+		switch h.Class {
+		case z.class:
+			if h.Type == rr.TypeSOA {
+				soa := z.rrset(z.origin, rr.TypeSOA)[0].(*rr.SOA)
+				if !apex || soa.SerialCompare(record.(*rr.SOA)) != rr.SerialLess {
+					continue
+				}
+
+				err = z.txn.Replace(z.origin, z.class, rr.TypeSOA, []rr.RR{record})
+				break
+			}
+
+			err = z.add(record)
+		case rr.ANY:
+			if h.Type == rr.TypeANY {
+				if !apex {
+					err = z.txn.Delete(h.Name, z.class, rr.TypeANY)
+					break
+				}
+
+				// The SOA and NS RRsets at the apex are never deleted
+				for _, existing := range z.txn.Get(h.Name, z.class, rr.TypeANY) {
+					t := existing.Header().Type
+					if t == rr.TypeSOA || t == rr.TypeNS {
+						continue
+					}
+
+					if err = z.txn.Delete(h.Name, z.class, t); err != nil {
+						break
+					}
+				}
+				break
+			}
+
+			if apex && (h.Type == rr.TypeSOA || h.Type == rr.TypeNS) {
+				continue
+			}
+
+			err = z.txn.Delete(h.Name, z.class, h.Type)
+		case rr.NONE:
+			if h.Type == rr.TypeSOA {
+				continue
+			}
+
+			// The last NS record at the apex is never deleted
+			if apex && h.Type == rr.TypeNS && len(z.rrset(h.Name, rr.TypeNS)) <= 1 {
+				continue
+			}
+
+			// Records to delete carry the class NONE, while the
+			// stored records carry the class of the zone
+			record = rr.Copy(record)
+			record.Header().Class = z.class
+			err = z.txn.Remove(record)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// add adds the record to the transaction. A CNAME record can't be added to
+// a name with other data and vice versa. An existing identical record only
+// gets its TTL updated
+func (z *update) add(record rr.RR) error {
+	h := record.Header()
+
+	for _, existing := range z.txn.Get(h.Name, z.class, rr.TypeANY) {
+		t := existing.Header().Type
+		if (h.Type == rr.TypeCNAME) != (t == rr.TypeCNAME) {
+			return nil
+		}
+
+		// A CNAME RRset only ever has a single record, which gets replaced
+		if t == rr.TypeCNAME {
+			return z.txn.Replace(h.Name, z.class, rr.TypeCNAME, []rr.RR{record})
+		}
+	}
+
+	return z.txn.Add(record)
+}
+
+// rrset returns all records of the zone with name and type
+func (z *update) rrset(name string, t uint16) []rr.RR {
+	return z.txn.Get(name, z.class, t)
+}
+
+// nameInUse returns if the name owns at least one record
+func (z *update) nameInUse(name string) bool {
+	return len(z.txn.Get(name, z.class, rr.TypeANY)) != 0
+}
+
+// filter returns all records with name and type
+func filter(records []rr.RR, name string, t uint16) []rr.RR {
+	var rrset []rr.RR
+	for _, record := range records {
+		if sameName(record, name) && record.Header().Type == t {
+			rrset = append(rrset, record)
+		}
+	}
+	return rrset
+}
+
+// containsAll returns if every record of want has an identical record in have
+func containsAll(have, want []rr.RR) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if h.IsSame(w) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func sameName(record rr.RR, name string) bool {
+	return strings.EqualFold(labels.Rootify(record.Header().Name), labels.Rootify(name))
+}
+
+// isMetaType returns if the type is a QTYPE, which can't be stored in a zone
+func isMetaType(t uint16) bool {
+	return t == rr.TypeIXFR || t == rr.TypeAXFR || t == rr.TypeMAILA || t == rr.TypeMAILB
+}
@@ -0,0 +1,163 @@
+package zone
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/types/rr"
+)
+
+var (
+	ErrMissingSOA    = errors.New("missing SOA record at apex")
+	ErrMultipleSOA   = errors.New("multiple SOA records")
+	ErrMissingNS     = errors.New("missing NS records at apex")
+	ErrCNAMEConflict = errors.New("CNAME and other data")
+	ErrMultipleCNAME = errors.New("multiple CNAME records")
+	ErrOutOfZone     = errors.New("out-of-zone record")
+	ErrOutOfZoneGlue = errors.New("out-of-zone glue record")
+	ErrOrphanGlue    = errors.New("address record below delegation is not glue")
+	ErrOccluded      = errors.New("record below delegation is occluded")
+)
+
+// CheckError describes a problem with the records of a name found while
+// checking a zone
+type CheckError struct {
+	Name string
+	Type uint16
+	Err  error
+}
+
+func (e *CheckError) Error() string {
+	return fmt.Sprintf("zone: %s %s: %s", e.Name, rr.TypeToString(e.Type), e.Err)
+}
+
+func (e *CheckError) Unwrap() error {
+	return e.Err
+}
+
+// Check checks the records of the zone with origin for semantic problems.
+// The zone needs exactly one SOA record and at least one NS record at the
+// apex. Names with a CNAME record can't have other data. Records need to
+// be within the zone, which includes glue records, and address records
+// below delegations need to be glue for a name server. The errors are
+// sorted by name in canonical order
+func Check(origin string, records []rr.RR) []error {
+	origin = strings.ToLower(labels.Rootify(origin))
+
+	var (
+		problems    []*CheckError
+		types       = make(map[string]map[uint16]int)
+		delegations = make(map[string]bool)
+		targets     = make(map[string]bool)
+	)
+
+	report := func(name string, t uint16, err error) {
+		problems = append(problems, &CheckError{Name: name, Type: t, Err: err})
+	}
+
+	for _, record := range records {
+		if ns, ok := record.(*rr.NS); ok {
+			targets[strings.ToLower(labels.Rootify(ns.NSDName))] = true
+		}
+	}
+
+	for _, record := range records {
+		header := record.Header()
+		name := strings.ToLower(labels.Rootify(header.Name))
+
+		if !labels.IsSubdomain(name, origin) {
+			if isAddress(header.Type) && targets[name] {
+				report(header.Name, header.Type, ErrOutOfZoneGlue)
+			} else {
+				report(header.Name, header.Type, ErrOutOfZone)
+			}
+			continue
+		}
+
+		if types[name] == nil {
+			types[name] = make(map[uint16]int)
+		}
+		types[name][header.Type]++
+
+		if header.Type == rr.TypeNS && name != origin {
+			delegations[name] = true
+		}
+	}
+
+	for name, t := range types {
+		if t[rr.TypeSOA] > 0 && name != origin {
+			report(name, rr.TypeSOA, ErrMultipleSOA)
+		}
+
+		if t[rr.TypeCNAME] > 1 {
+			report(name, rr.TypeCNAME, ErrMultipleCNAME)
+		}
+
+		// DNSSEC records can exist next to a CNAME record.
+		// See https://datatracker.ietf.org/doc/html/rfc4035#section-2.5
+		if t[rr.TypeCNAME] > 0 {
+			for other := range t {
+				switch other {
+				case rr.TypeCNAME, rr.TypeRRSIG, rr.TypeNSEC, rr.TypeNSEC3:
+					continue
+				}
+				report(name, other, ErrCNAMEConflict)
+			}
+		}
+
+		if !isOccluded(name, origin, delegations) {
+			continue
+		}
+
+		for other := range t {
+			switch {
+			case !isAddress(other):
+				report(name, other, ErrOccluded)
+			case !targets[name]:
+				report(name, other, ErrOrphanGlue)
+			}
+		}
+	}
+
+	switch {
+	case types[origin][rr.TypeSOA] == 0:
+		report(origin, rr.TypeSOA, ErrMissingSOA)
+	case types[origin][rr.TypeSOA] > 1:
+		report(origin, rr.TypeSOA, ErrMultipleSOA)
+	}
+
+	if types[origin][rr.TypeNS] == 0 {
+		report(origin, rr.TypeNS, ErrMissingNS)
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if c := labels.Compare(problems[i].Name, problems[j].Name); c != 0 {
+			return c < 0
+		}
+		return problems[i].Type < problems[j].Type
+	})
+
+	errs := make([]error, 0, len(problems))
+	for _, problem := range problems {
+		errs = append(errs, problem)
+	}
+
+	return errs
+}
+
+// isOccluded returns if the name is below one of the delegations
+func isOccluded(name, origin string, delegations map[string]bool) bool {
+	for p := labels.Parent(name); p != "" && p != origin && labels.IsSubdomain(p, origin); p = labels.Parent(p) {
+		if delegations[p] {
+			return true
+		}
+	}
+	return false
+}
+
+func isAddress(t uint16) bool {
+	return t == rr.TypeA || t == rr.TypeAAAA
+}
@@ -0,0 +1,200 @@
+package zone
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/go-void/portal/pkg/zone/tokenizer"
+)
+
+// parseGenerate parses the BIND '$GENERATE <range> <lhs> [<ttl>] [<class>]
+// <type> <rhs>' directive. The range has the format 'start-stop[/step]'.
+// Every '$' in lhs and rhs gets replaced by the current iterator value,
+// which can be modified via '${offset[,width[,base]]}'
+func (p *Parser) parseGenerate(directive tokenizer.Token, args []tokenizer.Token) error {
+	if len(args) < 4 {
+		return p.error(directive, ErrInvalidDirective)
+	}
+
+	start, stop, step, err := parseRange(args[0].Data)
+	if err != nil {
+		return p.error(args[0], err)
+	}
+
+	if (stop-start)/step >= MaxGenerateRecords {
+		return p.error(args[0], ErrGenerateLimit)
+	}
+
+	// The loop ends before incrementing i past stop, which could overflow
+	// if stop is close to the maximum int
+	for i := start; ; i += step {
+		tokens := make([]tokenizer.Token, len(args)-1)
+		copy(tokens, args[1:])
+
+		// The lhs is always an owner name, even if the directive is
+		// indented
+		tokens[0].Column = 1
+
+		// TTL, class and type never contain a '$', which is why we
+		// can substitute all fields
+		for j := range tokens {
+			data, err := substitute(tokens[j].Data, i)
+			if err != nil {
+				return p.error(tokens[j], err)
+			}
+			tokens[j].Data = data
+		}
+
+		err = p.parseRecord(tokens)
+		if err != nil {
+			return err
+		}
+
+		if i > stop-step {
+			return nil
+		}
+	}
+}
+
+// parseRange parses 'start-stop[/step]'
+func parseRange(s string) (int, int, int, error) {
+	r, stepStr, hasStep := strings.Cut(s, "/")
+
+	startStr, stopStr, ok := strings.Cut(r, "-")
+	if !ok {
+		return 0, 0, 0, ErrInvalidRange
+	}
+
+	start, err := strconv.Atoi(startStr)
+	if err != nil || start < 0 {
+		return 0, 0, 0, ErrInvalidRange
+	}
+
+	stop, err := strconv.Atoi(stopStr)
+	if err != nil || stop < start {
+		return 0, 0, 0, ErrInvalidRange
+	}
+
+	step := 1
+	if hasStep {
+		step, err = strconv.Atoi(stepStr)
+		if err != nil || step < 1 {
+			return 0, 0, 0, ErrInvalidRange
+		}
+	}
+
+	return start, stop, step, nil
+}
+
+// substitute replaces all '$' in template with the iterator value n.
+// '\$' is a literal '$'
+func substitute(template string, n int) (string, error) {
+	var b strings.Builder
+
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+
+		if c == '\\' && i+1 < len(template) && template[i+1] == '$' {
+			b.WriteByte('$')
+			i++
+			continue
+		}
+
+		if c != '$' {
+			b.WriteByte(c)
+			continue
+		}
+
+		// Plain '$' without modifiers
+		if i+1 >= len(template) || template[i+1] != '{' {
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+
+		end := strings.IndexByte(template[i:], '}')
+		if end == -1 {
+			return "", ErrInvalidModifier
+		}
+
+		value, err := modify(template[i+2:i+end], n)
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(value)
+		i += end
+	}
+
+	return b.String(), nil
+}
+
+// modify applies the modifier 'offset[,width[,base]]' to n. Supported
+// bases are d (decimal), o (octal), x and X (hexadecimal) and n and N
+// (nibble format, as used in ip6.arpa names)
+func modify(modifier string, n int) (string, error) {
+	parts := strings.Split(modifier, ",")
+	if len(parts) > 3 {
+		return "", ErrInvalidModifier
+	}
+
+	offset, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return "", ErrInvalidModifier
+	}
+	n += offset
+
+	width := 0
+	if len(parts) > 1 {
+		width, err = strconv.Atoi(parts[1])
+		if err != nil || width < 0 {
+			return "", ErrInvalidModifier
+		}
+	}
+
+	base := "d"
+	if len(parts) > 2 {
+		base = parts[2]
+	}
+
+	var value string
+	switch base {
+	case "d":
+		value = strconv.Itoa(n)
+	case "o":
+		value = strconv.FormatInt(int64(n), 8)
+	case "x":
+		value = strconv.FormatInt(int64(n), 16)
+	case "X":
+		value = strings.ToUpper(strconv.FormatInt(int64(n), 16))
+	case "n", "N":
+		return nibbles(n, width, base == "N"), nil
+	default:
+		return "", ErrInvalidModifier
+	}
+
+	if len(value) < width {
+		value = strings.Repeat("0", width-len(value)) + value
+	}
+
+	return value, nil
+}
+
+// nibbles returns n as reversed hexadecimal nibbles separated by dots,
+// e.g. 0x1a => 'a.1'. Width is the number of nibbles
+func nibbles(n, width int, upper bool) string {
+	hex := strconv.FormatInt(int64(n), 16)
+	if upper {
+		hex = strings.ToUpper(hex)
+	}
+
+	if len(hex) < width {
+		hex = strings.Repeat("0", width-len(hex)) + hex
+	}
+
+	parts := make([]string, len(hex))
+	for i := range hex {
+		parts[len(hex)-1-i] = string(hex[i])
+	}
+
+	return strings.Join(parts, ".")
+}
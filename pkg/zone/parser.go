@@ -0,0 +1,276 @@
+package zone
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-void/portal/pkg/types/rr"
+	"github.com/go-void/portal/pkg/zone/tokenizer"
+)
+
+// Parser parses master files. It keeps track of the current origin and
+// the values (owner, TTL and class) which are inherited by records that
+// omit them
+type Parser struct {
+	// File is the name of the parsed file (used in errors)
+	File string
+
+	// Dir is the directory relative $INCLUDE paths are resolved against
+	Dir string
+
+	// AllowInclude indicates if $INCLUDE directives are allowed
+	AllowInclude bool
+
+	tokenizer *tokenizer.Tokenizer
+
+	origin string
+
+	defaultTTL    uint32
+	hasDefaultTTL bool
+
+	lastTTL    uint32
+	hasLastTTL bool
+
+	lastOwner string
+	lastClass uint16
+
+	depth   int
+	records []rr.RR
+}
+
+// NewParser returns a new parser which reads from r. Relative names are
+// interpreted relative to origin
+func NewParser(r io.Reader, origin string) *Parser {
+	if origin != "" && !strings.HasSuffix(origin, ".") {
+		origin += "."
+	}
+
+	return &Parser{
+		AllowInclude: true,
+		tokenizer:    tokenizer.NewTokenizer(r),
+		origin:       origin,
+		lastClass:    rr.IN,
+	}
+}
+
+// Parse parses all entries and returns the records in the order they
+// appear in the master file
+func (p *Parser) Parse() ([]rr.RR, error) {
+	for {
+		tokens, err := p.tokenizer.Entry()
+		if err == io.EOF {
+			return p.records, nil
+		}
+
+		if err != nil {
+			var terr *tokenizer.Error
+			if errors.As(err, &terr) {
+				return nil, &Error{File: p.File, Line: terr.Line, Column: terr.Column, Err: terr.Err}
+			}
+			return nil, err
+		}
+
+		if isDirective(tokens[0]) {
+			err = p.parseDirective(tokens)
+		} else {
+			err = p.parseRecord(tokens)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseRecord parses an entry of the form
+// '[<domain-name>] [<TTL>] [<class>] <type> <RDATA>' where TTL and class
+// can appear in any order
+func (p *Parser) parseRecord(tokens []tokenizer.Token) error {
+	var (
+		header = rr.Header{Class: p.lastClass}
+		i      = 0
+	)
+
+	if tokens[0].IsBlank() {
+		if p.lastOwner == "" {
+			return p.error(tokens[0], ErrMissingOwner)
+		}
+		header.Name = p.lastOwner
+	} else {
+		name, err := rr.AbsoluteName(tokens[0].Data, p.origin)
+		if err != nil {
+			return p.error(tokens[0], err)
+		}
+		header.Name = name
+		i++
+	}
+
+	var hasTTL, hasClass bool
+	for ; i < len(tokens); i++ {
+		if c, ok := rr.ClassFromString(tokens[i].Data); ok && !hasClass {
+			header.Class = c
+			hasClass = true
+			continue
+		}
+
+		if ttl, err := rr.ParseTTL(tokens[i].Data); err == nil && !hasTTL {
+			header.TTL = ttl
+			hasTTL = true
+			continue
+		}
+
+		break
+	}
+
+	if i >= len(tokens) {
+		return p.error(tokens[len(tokens)-1], ErrMissingType)
+	}
+
+	typeToken := tokens[i]
+	record, err := rr.ParseWithHeader(header, typeToken.Data, tokenizer.Fields(tokens[i+1:]), p.origin)
+	if err != nil {
+		if err != rr.ErrNoSuchType && i+1 < len(tokens) {
+			return p.error(tokens[i+1], err)
+		}
+		return p.error(typeToken, err)
+	}
+
+	// Resolve the TTL. An explicit TTL is used for subsequent records
+	// without TTL if there is no $TTL. SOA records fall back to the
+	// minimum field.
+	// See https://datatracker.ietf.org/doc/html/rfc2308#section-4
+	switch {
+	case hasTTL:
+		p.lastTTL = header.TTL
+		p.hasLastTTL = true
+	case p.hasDefaultTTL:
+		record.Header().TTL = p.defaultTTL
+	case p.hasLastTTL:
+		record.Header().TTL = p.lastTTL
+	default:
+		soa, ok := record.(*rr.SOA)
+		if !ok {
+			return p.error(typeToken, ErrMissingTTL)
+		}
+
+		record.Header().TTL = soa.Minimum
+		p.lastTTL = soa.Minimum
+		p.hasLastTTL = true
+	}
+
+	p.lastOwner = header.Name
+	p.lastClass = header.Class
+	p.records = append(p.records, record)
+
+	return nil
+}
+
+// parseDirective parses the control entries $ORIGIN, $TTL, $INCLUDE and
+// $GENERATE
+func (p *Parser) parseDirective(tokens []tokenizer.Token) error {
+	directive := tokens[0]
+	args := tokens[1:]
+
+	switch strings.ToUpper(directive.Data) {
+	case "$ORIGIN":
+		if len(args) != 1 {
+			return p.error(directive, ErrInvalidDirective)
+		}
+
+		origin, err := rr.AbsoluteName(args[0].Data, p.origin)
+		if err != nil {
+			return p.error(args[0], err)
+		}
+		p.origin = origin
+	case "$TTL":
+		if len(args) != 1 {
+			return p.error(directive, ErrInvalidDirective)
+		}
+
+		ttl, err := rr.ParseTTL(args[0].Data)
+		if err != nil {
+			return p.error(args[0], err)
+		}
+
+		p.defaultTTL = ttl
+		p.hasDefaultTTL = true
+	case "$INCLUDE":
+		return p.parseInclude(directive, args)
+	case "$GENERATE":
+		return p.parseGenerate(directive, args)
+	default:
+		return p.error(directive, ErrUnknownDirective)
+	}
+
+	return nil
+}
+
+// parseInclude parses '$INCLUDE <file-name> [<domain-name>]'. The included
+// file inherits the origin, TTL and class but changes to them don't affect
+// the including file
+func (p *Parser) parseInclude(directive tokenizer.Token, args []tokenizer.Token) error {
+	if !p.AllowInclude {
+		return p.error(directive, ErrIncludeNotAllowed)
+	}
+
+	if len(args) < 1 || len(args) > 2 {
+		return p.error(directive, ErrInvalidDirective)
+	}
+
+	if p.depth >= MaxIncludeDepth {
+		return p.error(directive, ErrIncludeDepth)
+	}
+
+	origin := p.origin
+	if len(args) == 2 {
+		o, err := rr.AbsoluteName(args[1].Data, p.origin)
+		if err != nil {
+			return p.error(args[1], err)
+		}
+		origin = o
+	}
+
+	path := args[0].Data
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.Dir, path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return p.error(args[0], err)
+	}
+	defer f.Close()
+
+	child := NewParser(f, origin)
+	child.File = path
+	child.Dir = filepath.Dir(path)
+	child.depth = p.depth + 1
+	child.defaultTTL, child.hasDefaultTTL = p.defaultTTL, p.hasDefaultTTL
+	child.lastTTL, child.hasLastTTL = p.lastTTL, p.hasLastTTL
+	child.lastClass = p.lastClass
+
+	records, err := child.Parse()
+	if err != nil {
+		return err
+	}
+
+	p.records = append(p.records, records...)
+	return nil
+}
+
+func (p *Parser) error(token tokenizer.Token, err error) error {
+	return &Error{
+		File:   p.File,
+		Line:   token.Line,
+		Column: token.Column,
+		Err:    err,
+	}
+}
+
+// isDirective returns if the token starts a control entry like $ORIGIN
+func isDirective(token tokenizer.Token) bool {
+	return !token.Quoted && !token.IsBlank() && strings.HasPrefix(token.Data, "$")
+}
@@ -0,0 +1,358 @@
+// Package tokenizer splits master files (zone files) into tokens.
+// See https://datatracker.ietf.org/doc/html/rfc1035#section-5.1
+package tokenizer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+var (
+	ErrExtraClosingBracket   = errors.New("extra closing bracket")
+	ErrMissingClosingBracket = errors.New("missing closing bracket")
+	ErrUnterminatedQuote     = errors.New("unterminated quoted string")
+	ErrUnterminatedEscape    = errors.New("unterminated escape sequence")
+	ErrInvalidEscape         = errors.New("invalid escape sequence")
+)
+
+// Error wraps an error which occurred at a specific position of the input
+type Error struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+type Tokenizer struct {
+	// Input byte reader
+	Reader io.ByteReader
+
+	// Context
+	Escaped  bool
+	Quoted   bool
+	Brackets int
+
+	// Current stores the current looked at byte
+	Current byte
+
+	// Line and Column of the current byte
+	Line   int
+	Column int
+
+	// Buff holds the data of the field which is currently read
+	Buff []byte
+
+	// Field is the field which is currently read
+	Field Token
+
+	// InField indicates if we are currently reading a field
+	InField bool
+
+	// Error is non-nil if the lexer encountered an error
+	// along the way of tokenizing the input
+	Error error
+
+	// Tokens hold a series of tokens until they are consumed
+	Tokens []Token
+
+	// Done indicates if the end of the input was reached
+	Done bool
+}
+
+func NewTokenizer(r io.Reader) *Tokenizer {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReaderSize(r, 1024)
+	}
+
+	return &Tokenizer{
+		Reader: br,
+		Line:   1,
+	}
+}
+
+// Next returns the next token. It returns io.EOF when the end of the input
+// is reached
+func (t *Tokenizer) Next() (Token, error) {
+	for len(t.Tokens) == 0 {
+		if t.Error != nil {
+			return Token{}, t.Error
+		}
+
+		if t.Done {
+			return Token{}, io.EOF
+		}
+
+		t.next()
+	}
+
+	token := t.Tokens[0]
+	t.Tokens = t.Tokens[1:]
+	return token, nil
+}
+
+// Entry returns the field tokens of the next entry (a logical line, which
+// can span multiple lines when using brackets). Empty lines and lines only
+// containing comments are skipped. It returns io.EOF when the end of the
+// input is reached
+func (t *Tokenizer) Entry() ([]Token, error) {
+	var entry []Token
+
+	for {
+		token, err := t.Next()
+		if err != nil {
+			if err == io.EOF && len(entry) > 0 {
+				return entry, nil
+			}
+			return nil, err
+		}
+
+		if token.Type == NewlineToken {
+			if len(entry) == 0 {
+				continue
+			}
+			return entry, nil
+		}
+
+		entry = append(entry, token)
+	}
+}
+
+// Parse tokenizes the complete input and returns all tokens
+func (t *Tokenizer) Parse() ([]Token, error) {
+	var tokens []Token
+
+	for {
+		token, err := t.Next()
+		if err == io.EOF {
+			return tokens, nil
+		}
+
+		if err != nil {
+			return tokens, err
+		}
+
+		tokens = append(tokens, token)
+	}
+}
+
+func (t *Tokenizer) read() bool {
+	c, err := t.Reader.ReadByte()
+	if err != nil {
+		if err != io.EOF {
+			t.Error = err
+		}
+		return false
+	}
+
+	if t.Current == '\n' {
+		t.Line++
+		t.Column = 0
+	}
+
+	t.Current = c
+	t.Column++
+	return true
+}
+
+// next reads bytes until at least one token was produced or the end of
+// the input was reached
+func (t *Tokenizer) next() {
+	for len(t.Tokens) == 0 {
+		if !t.read() {
+			t.end()
+			return
+		}
+
+		c := t.Current
+
+		if t.Escaped {
+			t.Escaped = false
+			t.Buff = append(t.Buff, c)
+			continue
+		}
+
+		if c == '\\' {
+			t.start()
+			t.Escaped = true
+			t.Buff = append(t.Buff, c)
+			continue
+		}
+
+		if t.Quoted {
+			switch c {
+			case '"':
+				t.Quoted = false
+			case '\n':
+				t.fail(ErrUnterminatedQuote)
+				return
+			default:
+				t.Buff = append(t.Buff, c)
+			}
+			continue
+		}
+
+		switch c {
+		case ';':
+			t.flush()
+			t.skipComment()
+		case '\n':
+			t.flush()
+			if t.Brackets == 0 {
+				t.Tokens = append(t.Tokens, NewToken(NewlineToken, t.Line, t.Column))
+			}
+		case ' ', '\t', '\r':
+			t.flush()
+		case '(':
+			t.flush()
+			t.Brackets++
+		case ')':
+			t.flush()
+			t.Brackets--
+			if t.Brackets < 0 {
+				t.fail(ErrExtraClosingBracket)
+				return
+			}
+		case '"':
+			t.start()
+			t.Field.Quoted = true
+			t.Quoted = true
+		default:
+			t.start()
+			t.Buff = append(t.Buff, c)
+		}
+	}
+}
+
+// start starts a new field if we are not already reading one
+func (t *Tokenizer) start() {
+	if t.InField {
+		return
+	}
+
+	t.InField = true
+	t.Field = NewToken(FieldToken, t.Line, t.Column)
+	t.Buff = t.Buff[:0]
+}
+
+// flush emits the field which is currently read
+func (t *Tokenizer) flush() {
+	if !t.InField {
+		return
+	}
+
+	t.Field.Data = string(t.Buff)
+	t.Tokens = append(t.Tokens, t.Field)
+	t.InField = false
+}
+
+// skipComment skips all bytes until the end of the line. The newline
+// itself is handled as usual
+func (t *Tokenizer) skipComment() {
+	for {
+		c, err := t.Reader.ReadByte()
+		if err != nil {
+			if err != io.EOF {
+				t.Error = err
+			}
+			t.end()
+			return
+		}
+
+		if c == '\n' {
+			t.Column++
+			t.Current = c
+			t.flush()
+			if t.Brackets == 0 {
+				t.Tokens = append(t.Tokens, NewToken(NewlineToken, t.Line, t.Column))
+			}
+			return
+		}
+
+		t.Column++
+	}
+}
+
+// end handles the end of the input
+func (t *Tokenizer) end() {
+	if t.Done || t.Error != nil {
+		return
+	}
+
+	switch {
+	case t.Escaped:
+		t.fail(ErrUnterminatedEscape)
+		return
+	case t.Quoted:
+		t.fail(ErrUnterminatedQuote)
+		return
+	case t.Brackets > 0:
+		t.fail(ErrMissingClosingBracket)
+		return
+	}
+
+	t.flush()
+	t.Tokens = append(t.Tokens, NewToken(NewlineToken, t.Line, t.Column))
+	t.Done = true
+}
+
+func (t *Tokenizer) fail(err error) {
+	t.Error = &Error{
+		Line:   t.Line,
+		Column: t.Column,
+		Err:    err,
+	}
+}
+
+// Unescape replaces escape sequences in s. '\DDD' is replaced by the octet
+// with the decimal value DDD and '\X' by X.
+// See https://datatracker.ietf.org/doc/html/rfc1035#section-5.1
+func Unescape(s string) (string, error) {
+	var buf []byte
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' {
+			buf = append(buf, c)
+			continue
+		}
+
+		if i+1 >= len(s) {
+			return "", ErrUnterminatedEscape
+		}
+
+		if !isDigit(s[i+1]) {
+			buf = append(buf, s[i+1])
+			i++
+			continue
+		}
+
+		if i+3 >= len(s) || !isDigit(s[i+2]) || !isDigit(s[i+3]) {
+			return "", ErrInvalidEscape
+		}
+
+		n, _ := strconv.Atoi(s[i+1 : i+4])
+		if n > 255 {
+			return "", ErrInvalidEscape
+		}
+
+		buf = append(buf, byte(n))
+		i += 3
+	}
+
+	return string(buf), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,64 @@
+package tokenizer
+
+type TokenType int
+
+const (
+	FieldToken TokenType = iota
+	NewlineToken
+)
+
+func (t TokenType) String() string {
+	return []string{"field", "newline"}[t]
+}
+
+// Token describes a single token of a master file. Field tokens carry the
+// (unquoted) data of the field. Escape sequences are kept as is and have
+// to be interpreted by the consumer, as their meaning depends on the kind
+// of field (e.g. domain name or character string)
+type Token struct {
+	Type   TokenType
+	Data   string
+	Quoted bool
+
+	// Line and Column point to the first character of the token
+	Line   int
+	Column int
+}
+
+// NewToken returns a new token of type t at line and column
+func NewToken(t TokenType, line, column int) Token {
+	return Token{
+		Type:   t,
+		Line:   line,
+		Column: column,
+	}
+}
+
+// IsBlank returns if the token is the first token of an entry and is
+// preceded by whitespace. This indicates the owner name was omitted
+func (t *Token) IsBlank() bool {
+	return t.Column > 1
+}
+
+func (t *Token) String() string {
+	if t.Type == NewlineToken {
+		return "\n"
+	}
+
+	if t.Quoted {
+		return "\"" + t.Data + "\""
+	}
+
+	return t.Data
+}
+
+// Fields returns the data of all field tokens
+func Fields(tokens []Token) []string {
+	var fields []string
+	for _, token := range tokens {
+		if token.Type == FieldToken {
+			fields = append(fields, token.Data)
+		}
+	}
+	return fields
+}
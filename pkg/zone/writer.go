@@ -0,0 +1,146 @@
+package zone
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-void/portal/pkg/labels"
+	"github.com/go-void/portal/pkg/store"
+	"github.com/go-void/portal/pkg/tree"
+	"github.com/go-void/portal/pkg/types/rr"
+)
+
+// Write writes the records as master file to w. The file starts with the
+// $ORIGIN and $TTL directives. Owner names within the origin are written
+// relative to it and TTLs equal to the default TTL are omitted. Records are
+// sorted by owner name in canonical order, the SOA record comes first.
+// Records of the same owner are sorted by type and RDATA
+func Write(w io.Writer, origin string, records []rr.RR) error {
+	origin = labels.Rootify(origin)
+	if origin == "" {
+		origin = "."
+	}
+
+	records = Sort(records)
+	ttl := defaultTTL(records)
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "$ORIGIN %s\n", origin)
+	fmt.Fprintf(b, "$TTL %d\n", ttl)
+
+	for _, record := range records {
+		header := record.Header()
+
+		fields := []string{relativeName(header.Name, origin)}
+		if header.TTL != ttl {
+			fields = append(fields, fmt.Sprint(header.TTL))
+		}
+		fields = append(fields, rr.ClassToString(header.Class), rr.TypeToString(header.Type))
+
+		if data := rdata(record); data != "" {
+			fields = append(fields, data)
+		}
+
+		fmt.Fprintln(b, strings.Join(fields, "\t"))
+	}
+
+	return b.Flush()
+}
+
+// WriteTree writes all records of the tree as master file to w
+func WriteTree(w io.Writer, origin string, t *tree.Tree) error {
+	return Write(w, origin, t.Records())
+}
+
+// WriteStore writes the zone with origin of the record store as master
+// file to w
+func WriteStore(w io.Writer, origin string, s store.Store) error {
+	records, err := s.Zone(origin)
+	if err != nil {
+		return err
+	}
+
+	return Write(w, origin, records)
+}
+
+// Sort returns the records in a stable order. Records are sorted by owner
+// name in canonical order with the SOA record first. Records of the same
+// owner are sorted by class, type and RDATA
+func Sort(records []rr.RR) []rr.RR {
+	sorted := make([]rr.RR, len(records))
+	copy(sorted, records)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Header(), sorted[j].Header()
+
+		if (a.Type == rr.TypeSOA) != (b.Type == rr.TypeSOA) {
+			return a.Type == rr.TypeSOA
+		}
+
+		if c := labels.Compare(a.Name, b.Name); c != 0 {
+			return c < 0
+		}
+
+		if a.Class != b.Class {
+			return a.Class < b.Class
+		}
+
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+
+		return rdata(sorted[i]) < rdata(sorted[j])
+	})
+
+	return sorted
+}
+
+// defaultTTL returns the most common TTL of the records. Ties are broken by
+// the lower TTL
+func defaultTTL(records []rr.RR) uint32 {
+	counts := make(map[uint32]int)
+	for _, record := range records {
+		counts[record.Header().TTL]++
+	}
+
+	var ttl uint32
+	max := 0
+	for t, count := range counts {
+		if count > max || count == max && t < ttl {
+			ttl, max = t, count
+		}
+	}
+
+	return ttl
+}
+
+// relativeName returns the name relative to the origin. The origin itself
+// is written as '@'. Names outside of the origin stay absolute
+func relativeName(name, origin string) string {
+	name = labels.Rootify(name)
+
+	switch {
+	case strings.EqualFold(name, origin):
+		return "@"
+	case origin == ".":
+		return name
+	case labels.IsSubdomain(name, origin):
+		return name[:len(name)-len(origin)-1]
+	}
+
+	return name
+}
+
+// rdata returns the presentation format of the RDATA of the record
+func rdata(record rr.RR) string {
+	s := record.String()
+	prefix := record.Header().String()
+
+	if !strings.HasPrefix(s, prefix) {
+		return ""
+	}
+	return strings.TrimPrefix(s[len(prefix):], "\t")
+}
@@ -0,0 +1,74 @@
+// Package zone parses master files (zone files) into resource records.
+// See https://datatracker.ietf.org/doc/html/rfc1035#section-5
+package zone
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/go-void/portal/pkg/types/rr"
+)
+
+var (
+	ErrMissingOwner      = errors.New("missing owner name")
+	ErrMissingTTL        = errors.New("missing TTL and no default TTL set")
+	ErrMissingType       = errors.New("missing type")
+	ErrUnknownDirective  = errors.New("unknown directive")
+	ErrInvalidDirective  = errors.New("invalid directive")
+	ErrIncludeDepth      = errors.New("maximum $INCLUDE depth exceeded")
+	ErrInvalidRange      = errors.New("invalid $GENERATE range")
+	ErrGenerateLimit     = errors.New("maximum $GENERATE records exceeded")
+	ErrInvalidModifier   = errors.New("invalid $GENERATE modifier")
+	ErrIncludeNotAllowed = errors.New("$INCLUDE not allowed")
+)
+
+// MaxIncludeDepth limits how deep $INCLUDE directives can be nested
+const MaxIncludeDepth = 10
+
+// MaxGenerateRecords limits how many records a single $GENERATE directive
+// can create
+const MaxGenerateRecords = 65536
+
+// Error describes an error at a specific position of a master file
+type Error struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("zone: line %d, column %d: %s", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("zone: %s: line %d, column %d: %s", e.File, e.Line, e.Column, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ParseFile parses the master file at path and returns all records. The
+// origin is used until the file sets a different one via $ORIGIN
+func ParseFile(path, origin string) ([]rr.RR, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p := NewParser(f, origin)
+	p.File = path
+	p.Dir = filepath.Dir(path)
+
+	return p.Parse()
+}
+
+// Parse parses a master file read from r and returns all records. $INCLUDE
+// directives are resolved relative to the current working directory
+func Parse(r io.Reader, origin string) ([]rr.RR, error) {
+	return NewParser(r, origin).Parse()
+}